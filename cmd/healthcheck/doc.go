@@ -1,2 +1,4 @@
-// Package main implements the healthcheck command.
+// Package main implements the standalone healthcheck command.
+//
+// The command is optional: the neon binary provides the same checks with its healthcheck command.
 package main
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/bhuisgen/neon/internal/app/neon"
+)
+
+// fixturesCommand implements the fixtures command.
+type fixturesCommand struct {
+	flagset *flag.FlagSet
+	verbose bool
+	timeout int
+}
+
+const (
+	fixturesActionPull string = "pull"
+)
+
+// NewFixturesCommand creates a new fixtures command.
+func NewFixturesCommand() *fixturesCommand {
+	c := fixturesCommand{}
+	c.flagset = flag.NewFlagSet("fixtures", flag.ExitOnError)
+	c.flagset.BoolVar(&c.verbose, "verbose", false, "Use verbose output")
+	c.flagset.IntVar(&c.timeout, "timeout", 300, "Timeout in seconds")
+	c.flagset.Usage = func() {
+		fmt.Println("Usage: neon fixtures pull [OPTIONS] [directory]")
+		fmt.Println()
+		fmt.Println("Fetch all the resources of the loader rules once and write them into the fixtures directory.")
+		fmt.Println()
+		fmt.Println("Without directory, the fixtures directory of the fetcher is used if set, or the directory")
+		fmt.Println("'fixtures'. The fetcher reads the resources from this directory instead of the providers")
+		fmt.Println("when its fixtures option is set.")
+		fmt.Println()
+		fmt.Println("Options:")
+		c.flagset.PrintDefaults()
+		fmt.Println()
+	}
+
+	return &c
+}
+
+// Name returns the command name.
+func (c *fixturesCommand) Name() string {
+	return c.flagset.Name()
+}
+
+// Description returns the command description.
+func (c *fixturesCommand) Description() string {
+	return "Manage the resource fixtures"
+}
+
+// Parse parses the command arguments.
+func (c *fixturesCommand) Parse(args []string) error {
+	if len(args) == 0 || args[0] != fixturesActionPull {
+		if err := c.flagset.Parse(args); err != nil {
+			return errors.New("parse arguments")
+		}
+		return errors.New("check action")
+	}
+	if err := c.flagset.Parse(args[1:]); err != nil {
+		return errors.New("parse arguments")
+	}
+	if len(c.flagset.Args()) > 1 {
+		return errors.New("check arguments")
+	}
+	return nil
+}
+
+// Execute executes the command.
+func (c *fixturesCommand) Execute() error {
+	config, err := neon.LoadConfig()
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		return fmt.Errorf("load config: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.timeout)*time.Second)
+	defer cancel()
+
+	count, err := neon.New(config).Fixtures(ctx, c.flagset.Arg(0))
+	if err != nil {
+		fmt.Printf("Failed to pull fixtures: %v\n", err)
+		return fmt.Errorf("fixtures: %v", err)
+	}
+
+	if c.verbose {
+		fmt.Printf("%d resource(s) written\n", count)
+	}
+
+	return nil
+}
+
+var _ command = (*fixturesCommand)(nil)
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bhuisgen/neon/internal/app/neon"
+	"github.com/bhuisgen/neon/pkg/healthcheck"
+)
+
+// healthcheckCommand implements the healthcheck command.
+type healthcheckCommand struct {
+	flagset *flag.FlagSet
+	verbose bool
+	options healthcheck.Options
+	timeout int
+	path    string
+}
+
+// NewHealthcheckCommand creates a new healthcheck command.
+func NewHealthcheckCommand() *healthcheckCommand {
+	c := healthcheckCommand{}
+	c.flagset = flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	c.flagset.BoolVar(&c.verbose, "verbose", false, "Use verbose output")
+	c.flagset.StringVar(&c.options.CACert, "cacert", "", "TLS CA file")
+	c.flagset.StringVar(&c.options.Cert, "cert", "", "TLS certificate file")
+	c.flagset.StringVar(&c.options.Key, "key", "", "TLS key file")
+	c.flagset.BoolVar(&c.options.Insecure, "insecure", false, "Skip the verification of the server certificate")
+	c.flagset.StringVar(&c.options.LocalAddr, "local-addr", "", "Local source address")
+	c.flagset.StringVar(&c.options.Unix, "unix", "", "Connect to this unix socket")
+	c.flagset.IntVar(&c.options.Status, "status", 0, "Status code")
+	c.flagset.IntVar(&c.timeout, "timeout", 5, "Timeout in seconds")
+	c.flagset.StringVar(&c.path, "path", "/", "Path requested on the configured servers")
+	c.flagset.Usage = func() {
+		fmt.Println("Usage: neon healthcheck [OPTIONS] [url]")
+		fmt.Println()
+		fmt.Println("Check the health of the server.")
+		fmt.Println()
+		fmt.Println("Without URL, all the servers configured in the local configuration are checked, or the unix")
+		fmt.Println("socket if set. A server responding with the status 503 is not ready and fails the check,")
+		fmt.Println("unless this status is expected.")
+		fmt.Println()
+		fmt.Println("Options:")
+		c.flagset.PrintDefaults()
+		fmt.Println()
+	}
+
+	return &c
+}
+
+// Name returns the command name.
+func (c *healthcheckCommand) Name() string {
+	return c.flagset.Name()
+}
+
+// Description returns the command description.
+func (c *healthcheckCommand) Description() string {
+	return "Check the server health"
+}
+
+// Parse parses the command arguments.
+func (c *healthcheckCommand) Parse(args []string) error {
+	if err := c.flagset.Parse(args); err != nil {
+		return errors.New("parse arguments")
+	}
+	if len(c.flagset.Args()) > 1 {
+		return errors.New("check arguments")
+	}
+	return nil
+}
+
+// Execute executes the command.
+func (c *healthcheckCommand) Execute() error {
+	targets, err := c.targets()
+	if err != nil {
+		fmt.Printf("Failed to find the servers: %v\n", err)
+		return fmt.Errorf("targets: %v", err)
+	}
+
+	c.options.Timeout = time.Duration(c.timeout) * time.Second
+
+	var failed bool
+	for _, target := range targets {
+		if err := healthcheck.Check(context.Background(), target, c.options); err != nil {
+			failed = true
+			if c.verbose {
+				fmt.Printf("%s: %v\n", target, err)
+			}
+			continue
+		}
+		if c.verbose {
+			fmt.Printf("%s: ok\n", target)
+		}
+	}
+	if failed {
+		return errors.New("healthcheck")
+	}
+
+	return nil
+}
+
+// targets returns the URLs to check.
+func (c *healthcheckCommand) targets() ([]string, error) {
+	if len(c.flagset.Args()) > 0 {
+		return []string{c.flagset.Arg(0)}, nil
+	}
+	if c.options.Unix != "" {
+		return []string{"http://localhost" + c.path}, nil
+	}
+
+	config, err := neon.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %v", err)
+	}
+	endpoints, err := config.Endpoints()
+	if err != nil {
+		return nil, fmt.Errorf("endpoints: %v", err)
+	}
+
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []string
+	for _, name := range names {
+		for _, endpoint := range endpoints[name] {
+			target, err := healthcheck.Target(endpoint, c.path)
+			if err != nil {
+				return nil, fmt.Errorf("listener %s: %v", name, err)
+			}
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no server endpoint")
+	}
+
+	return targets, nil
+}
+
+var _ command = (*healthcheckCommand)(nil)
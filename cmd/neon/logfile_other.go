@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// logFileSupported reports whether the logs can be redirected to a file on this platform.
+const logFileSupported = false
+
+// redirectLogs redirects the standard error, used by all loggers, to the given file.
+func redirectLogs(name string) error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+// reopenLogs reopens the log file on SIGUSR1 for the log rotation until stop is closed.
+func reopenLogs(name string, stop <-chan struct{}) {
+	<-stop
+}
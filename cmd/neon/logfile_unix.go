@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/bhuisgen/neon/pkg/statedir"
+)
+
+// logFileSupported reports whether the logs can be redirected to a file on this platform.
+const logFileSupported = true
+
+// redirectLogs redirects the standard error, used by all loggers, to the given file.
+func redirectLogs(name string) error {
+	if err := statedir.MkdirParent(name); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer f.Close()
+
+	if err := unix.Dup2(int(f.Fd()), int(os.Stderr.Fd())); err != nil {
+		return fmt.Errorf("dup: %v", err)
+	}
+
+	return nil
+}
+
+// reopenLogs reopens the log file on SIGUSR1 for the log rotation until stop is closed.
+func reopenLogs(name string, stop <-chan struct{}) {
+	reopen := make(chan os.Signal, 1)
+	signal.Notify(reopen, syscall.SIGUSR1)
+	defer signal.Stop(reopen)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-reopen:
+			if err := redirectLogs(name); err != nil {
+				fmt.Printf("Failed to reopen log file: %v\n", err)
+			}
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/bhuisgen/neon/internal/app/neon"
+	"github.com/bhuisgen/neon/pkg/buildinfo"
 )
 
 // command
@@ -30,6 +30,8 @@ func run() error {
 		NewInitCommand(),
 		NewCheckCommand(),
 		NewServeCommand(),
+		NewHealthcheckCommand(),
+		NewFixturesCommand(),
 		NewVersionCommand(),
 	}
 
@@ -52,7 +54,7 @@ func run() error {
 	flag.Parse()
 
 	if version {
-		fmt.Printf("%s version %s\n", neon.Name, neon.Version)
+		fmt.Println(buildinfo.Get())
 		return nil
 	}
 
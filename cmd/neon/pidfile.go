@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bhuisgen/neon/pkg/statedir"
+)
+
+// writePIDFile writes the current process ID into the given file.
+func writePIDFile(name string) error {
+	if err := statedir.MkdirParent(name); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write file: %v", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("rename file: %v", err)
+	}
+
+	return nil
+}
+
+// removePIDFile removes the given file if it still contains the current process ID.
+//
+// A reloaded instance takes over the file of its parent, which must not remove it on exit.
+func removePIDFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
+	if pid, err := strconv.Atoi(string(bytes.TrimSpace(data))); err != nil || pid != os.Getpid() {
+		return nil
+	}
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("remove file: %v", err)
+	}
+
+	return nil
+}
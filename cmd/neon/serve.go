@@ -5,14 +5,20 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"runtime"
 
 	"github.com/bhuisgen/neon/internal/app/neon"
+	"github.com/bhuisgen/neon/pkg/statedir"
 )
 
 // serveCommand implements the serve command.
 type serveCommand struct {
-	flagset *flag.FlagSet
-	verbose bool
+	flagset  *flag.FlagSet
+	verbose  bool
+	pidFile  string
+	logFile  string
+	stateDir string
 }
 
 // NewServeCommand creates a new serve command.
@@ -20,11 +26,19 @@ func NewServeCommand() *serveCommand {
 	c := serveCommand{}
 	c.flagset = flag.NewFlagSet("serve", flag.ExitOnError)
 	c.flagset.BoolVar(&c.verbose, "verbose", false, "Use verbose output")
+	c.flagset.StringVar(&c.pidFile, "pidfile", "", "Write the process ID to this file")
+	c.flagset.StringVar(&c.logFile, "log-file", "", "Write the logs to this file, reopened on SIGUSR1")
+	c.flagset.StringVar(&c.stateDir, "state-dir", "",
+		"Resolve the relative paths of the written files (pid, logs, access logs) in this directory")
 	c.flagset.Usage = func() {
 		fmt.Println("Usage: neon serve [OPTIONS]")
 		fmt.Println()
 		fmt.Println("Run the server instance.")
 		fmt.Println()
+		fmt.Println("The server writes only to the configured files, whose relative paths are resolved in the")
+		fmt.Println("state directory (default $STATE_DIR or the current directory), and creates their parent")
+		fmt.Println("directories on the first write. The root filesystem can be mounted read-only.")
+		fmt.Println()
 		fmt.Println("Options:")
 		c.flagset.PrintDefaults()
 		fmt.Println()
@@ -51,17 +65,52 @@ func (c *serveCommand) Parse(args []string) error {
 	if len(c.flagset.Args()) > 0 {
 		return errors.New("check arguments")
 	}
+	if c.logFile != "" && !logFileSupported {
+		fmt.Printf("The log file is not supported on %s\n", runtime.GOOS)
+		return fmt.Errorf("log file not supported on %s", runtime.GOOS)
+	}
 	return nil
 }
 
 // Execute executes the command.
 func (c *serveCommand) Execute() error {
+	if c.stateDir != "" {
+		if err := os.Setenv(statedir.EnvKey, c.stateDir); err != nil {
+			fmt.Printf("Failed to set state directory: %v\n", err)
+			return fmt.Errorf("set state dir: %v", err)
+		}
+	}
+
 	config, err := neon.LoadConfig()
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
 		return fmt.Errorf("load config: %v", err)
 	}
 
+	logFile := statedir.Path(c.logFile)
+	if logFile != "" {
+		if err := redirectLogs(logFile); err != nil {
+			fmt.Printf("Failed to open log file: %v\n", err)
+			return fmt.Errorf("open log file: %v", err)
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		go reopenLogs(logFile, stop)
+	}
+
+	pidFile := statedir.Path(c.pidFile)
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			fmt.Printf("Failed to write PID file: %v\n", err)
+			return fmt.Errorf("write pid file: %v", err)
+		}
+		defer func() {
+			if err := removePIDFile(pidFile); err != nil {
+				fmt.Printf("Failed to remove PID file: %v\n", err)
+			}
+		}()
+	}
+
 	if err := neon.New(config).Serve(context.Background()); err != nil {
 		fmt.Printf("Failed to serve: %s\n", err)
 		return fmt.Errorf("serve: %v", err)
@@ -4,11 +4,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"runtime"
-	"runtime/debug"
-	"strings"
 
-	"github.com/bhuisgen/neon/internal/app/neon"
+	"github.com/bhuisgen/neon/pkg/buildinfo"
 )
 
 // versionCommand implements the version command.
@@ -58,15 +55,14 @@ func (c *versionCommand) Parse(args []string) error {
 
 // Execute executes the command.
 func (c *versionCommand) Execute() error {
-	fmt.Printf("%s\n", neon.Name)
-	fmt.Printf("%s:\t\t%s\n", "Version", neon.Version)
-	fmt.Printf("%s:\t\t\t%s\n", "Commit", neon.Commit)
-	fmt.Printf("%s:\t\t\t%s\n", "Built", neon.Date)
-	fmt.Printf("%s:\t\t%s\n", "OS/Arch", strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "/"))
-
-	if buildInfo, ok := debug.ReadBuildInfo(); ok {
-		fmt.Printf("%s:\t\t%s\n", "Go version", buildInfo.GoVersion)
-	}
+	info := buildinfo.Get()
+	fmt.Printf("%s\n", info.Name)
+	fmt.Printf("%s:\t\t%s\n", "Version", info.Version)
+	fmt.Printf("%s:\t\t\t%s\n", "Commit", info.Commit)
+	fmt.Printf("%s:\t\t\t%s\n", "Built", info.Date)
+	fmt.Printf("%s:\t\t%s\n", "OS/Arch", info.Platform)
+	fmt.Printf("%s:\t\t%s\n", "Go version", info.GoVersion)
+	fmt.Printf("%s:\t%s\n", "Engine version", info.EngineVersion)
 
 	return nil
 }
@@ -4,10 +4,12 @@ go 1.22.2
 
 require (
 	github.com/PaesslerAG/jsonpath v0.1.1
+	github.com/andybalholm/brotli v1.1.1
 	github.com/bhuisgen/gomonkey v0.2.0
 	github.com/google/uuid v1.6.0
 	github.com/mitchellh/mapstructure v1.5.0
 	golang.org/x/net v0.24.0
+	golang.org/x/sys v0.19.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -21,9 +21,13 @@ import (
 
 	"github.com/mitchellh/mapstructure"
 
+	"github.com/bhuisgen/neon/pkg/buildinfo"
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/fault"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/redact"
 )
 
 // app implements the app module.
@@ -35,10 +39,29 @@ type app struct {
 
 // appConfig implements the app configuration.
 type appConfig struct {
-	Store   map[string]interface{}
-	Fetcher map[string]interface{}
-	Loader  map[string]interface{}
-	Server  map[string]interface{}
+	Store       map[string]interface{}
+	Fetcher     map[string]interface{}
+	Loader      map[string]interface{}
+	Server      map[string]interface{}
+	Preflight   *string
+	Lazy        *bool
+	Fault       map[string]appFaultConfig
+	Hooks       map[string][]appHookConfig
+	LogLevelTTL *int
+	Redact      *appRedactConfig
+}
+
+// appFaultConfig implements the fault injection configuration of a target.
+type appFaultConfig struct {
+	ErrorRate *int `mapstructure:"errorRate"`
+	DelayRate *int `mapstructure:"delayRate"`
+	Delay     *int `mapstructure:"delay"`
+}
+
+// appRedactConfig implements the redaction configuration of the diagnostics.
+type appRedactConfig struct {
+	Headers []string `mapstructure:"headers"`
+	Cookies []string `mapstructure:"cookies"`
 }
 
 // appState implements the app state.
@@ -53,6 +76,14 @@ type appState struct {
 
 const (
 	appModuleID module.ModuleID = "app"
+
+	appPreflightStrict  string        = "strict"
+	appPreflightWarn    string        = "warn"
+	appPreflightTimeout time.Duration = 30 * time.Second
+
+	appFixturesDefaultDirectory string = "fixtures"
+
+	appConfigDefaultLogLevelTTL int = 600
 )
 
 // ModuleInfo returns the module information.
@@ -88,6 +119,29 @@ func (a *app) Init(config map[string]interface{}) error {
 		}
 	}
 
+	if a.config.Preflight != nil && *a.config.Preflight != appPreflightStrict &&
+		*a.config.Preflight != appPreflightWarn {
+		a.logger.Error("Invalid value", "option", "Preflight", "value", *a.config.Preflight)
+		return errors.New("config")
+	}
+	if a.config.LogLevelTTL == nil {
+		defaultValue := appConfigDefaultLogLevelTTL
+		a.config.LogLevelTTL = &defaultValue
+	}
+	if *a.config.LogLevelTTL < 0 {
+		a.logger.Error("Invalid value", "option", "LogLevelTTL", "value", *a.config.LogLevelTTL)
+		return errors.New("config")
+	}
+	if err := a.initFault(); err != nil {
+		return err
+	}
+	if err := a.initHooks(); err != nil {
+		return err
+	}
+	if err := a.initRedact(); err != nil {
+		return err
+	}
+
 	storeModuleInfo, err := module.Lookup("app.store")
 	if err != nil {
 		return fmt.Errorf("lookup module %s: %w", "app.store", err)
@@ -159,14 +213,18 @@ func (a *app) Check() error {
 
 // Serve executes the instance.
 func (a *app) Serve(ctx context.Context) error {
-	a.logger.Info(fmt.Sprintf("%s version %s, commit %s", Name, Version, Commit))
+	a.logger.Info(buildinfo.Get().String())
 
 	a.logger.Info("Starting instance")
 
 	module.Load()
 
 	if DEBUG {
-		a.logger.Warn("Debug enabled")
+		if DEBUG_UNTIL.IsZero() {
+			a.logger.Warn("Debug enabled")
+		} else {
+			a.logger.Warn("Debug enabled", "until", DEBUG_UNTIL)
+		}
 	}
 
 	if key, ok := os.LookupEnv(childEnvKey); ok {
@@ -176,57 +234,22 @@ func (a *app) Serve(ctx context.Context) error {
 		}
 	}
 
-	if err := a.state.store.Init(a.config.Store); err != nil {
-		a.logger.Error("Failed to init store", "err", err)
-		return fmt.Errorf("init store: %v", err)
-	}
-	if err := a.state.store.Register(a.state.mediator); err != nil {
-		a.logger.Error("Failed to register store", "err", err)
-		return fmt.Errorf("register store: %v", err)
-	}
-
-	if err := a.state.fetcher.Init(a.config.Fetcher); err != nil {
-		return fmt.Errorf("init fetcher: %w", err)
-	}
-	if err := a.state.fetcher.Register(a.state.mediator); err != nil {
-		a.logger.Error("Failed to register fetcher", "err", err)
-		return fmt.Errorf("register fetcher: %v", err)
-	}
-
-	if err := a.state.loader.Init(a.config.Loader); err != nil {
-		a.logger.Error("Failed to init loader", "err", err)
-		return fmt.Errorf("init loader: %v", err)
-	}
-	if err := a.state.loader.Register(a.state.mediator); err != nil {
-		a.logger.Error("Failed to register loader", "err", err)
-		return fmt.Errorf("register loader: %v", err)
-	}
-	if err := a.state.loader.Start(); err != nil {
-		a.logger.Error("Failed to start loader", "err", err)
-		return fmt.Errorf("start loader: %v", err)
-	}
-
-	if err := a.state.server.Init(a.config.Server); err != nil {
-		a.logger.Error("Failed to init server", "err", err)
-		return fmt.Errorf("init server: %v", err)
-	}
-	if err := a.state.server.Register(a.state.mediator); err != nil {
-		a.logger.Error("Failed to register server", "err", err)
-		return fmt.Errorf("register server: %v", err)
-	}
-	if err := a.state.server.Start(); err != nil {
-		a.logger.Error("Failed to start server", "err", err)
-		return fmt.Errorf("start server: %v", err)
+	if err := a.start(); err != nil {
+		return err
 	}
 
 	a.logger.Info("Instance ready")
 
+	_ = a.runHooks(appHookPostReady)
+
 	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM)
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGQUIT)
 	reload := make(chan os.Signal, 1)
 	signal.Notify(reload, syscall.SIGHUP)
+	level := make(chan os.Signal, 1)
+	appNotifyLogLevel(level)
 
 	for {
 		select {
@@ -239,6 +262,7 @@ func (a *app) Serve(ctx context.Context) error {
 
 		case <-exit:
 			a.logger.Info("Signal SIGINT/SIGTERM received, stopping instance")
+			_ = a.runHooks(appHookPreShutdown)
 			if err := a.stop(); err != nil {
 				a.logger.Error("stop instance", "err", err)
 				continue
@@ -246,6 +270,7 @@ func (a *app) Serve(ctx context.Context) error {
 
 		case <-shutdown:
 			a.logger.Info("Signal SIGQUIT received, shutting down instance gracefully")
+			_ = a.runHooks(appHookPreShutdown)
 			if err := a.shutdown(); err != nil {
 				a.logger.Error("shutdown instance", "err", err)
 				continue
@@ -257,6 +282,11 @@ func (a *app) Serve(ctx context.Context) error {
 				a.logger.Error("reload instance", "err", err)
 				continue
 			}
+
+		case <-level:
+			ttl := time.Duration(*a.config.LogLevelTTL) * time.Second
+			a.logger.Warn("Signal SIGUSR2 received, changing log level", "level", log.CycleLevel(ttl), "ttl", ttl)
+			continue
 		}
 
 		break
@@ -265,6 +295,7 @@ func (a *app) Serve(ctx context.Context) error {
 	signal.Stop(exit)
 	signal.Stop(shutdown)
 	signal.Stop(reload)
+	signal.Stop(level)
 
 	module.Unload()
 
@@ -273,6 +304,228 @@ func (a *app) Serve(ctx context.Context) error {
 	return nil
 }
 
+// Fixtures fetches all the resources of the loader rules once and writes them into the given fixtures directory, or
+// into the fixtures directory of the fetcher if empty. It returns the number of resources written.
+//
+// The fixtures mode of the fetcher is disabled to fetch the resources from the providers.
+func (a *app) Fixtures(ctx context.Context, directory string) (int, error) {
+	fetcherConfig := make(map[string]interface{}, len(a.config.Fetcher))
+	for key, value := range a.config.Fetcher {
+		if strings.EqualFold(key, "fixtures") {
+			if v, ok := value.(string); ok && directory == "" {
+				directory = v
+			}
+			continue
+		}
+		fetcherConfig[key] = value
+	}
+	if directory == "" {
+		directory = appFixturesDefaultDirectory
+	}
+
+	if err := a.state.store.Init(a.config.Store); err != nil {
+		return 0, fmt.Errorf("init store: %w", err)
+	}
+	if err := a.state.store.Register(a.state.mediator); err != nil {
+		return 0, fmt.Errorf("register store: %w", err)
+	}
+	if err := a.state.fetcher.Init(fetcherConfig); err != nil {
+		return 0, fmt.Errorf("init fetcher: %w", err)
+	}
+	if err := a.state.fetcher.Register(a.state.mediator); err != nil {
+		return 0, fmt.Errorf("register fetcher: %w", err)
+	}
+	if err := a.state.loader.Init(a.config.Loader); err != nil {
+		return 0, fmt.Errorf("init loader: %w", err)
+	}
+	if err := a.state.loader.Register(a.state.mediator); err != nil {
+		return 0, fmt.Errorf("register loader: %w", err)
+	}
+
+	a.logger.Info("Pulling fixtures", "directory", directory)
+
+	fetcher := newFixtureFetcher(a.state.mediator.Fetcher(), directory)
+	err := a.state.loader.Pull(ctx, fetcher)
+
+	return fetcher.Count(), err
+}
+
+// start initializes and starts all the components of the instance.
+func (a *app) start() error {
+	a.configureFault()
+	a.configureRedact()
+
+	if err := a.state.store.Init(a.config.Store); err != nil {
+		a.logger.Error("Failed to init store", "err", err)
+		return fmt.Errorf("init store: %v", err)
+	}
+	if err := a.state.store.Register(a.state.mediator); err != nil {
+		a.logger.Error("Failed to register store", "err", err)
+		return fmt.Errorf("register store: %v", err)
+	}
+	if err := a.state.store.Import(); err != nil {
+		a.logger.Warn("Failed to import store snapshot", "err", err)
+	}
+
+	if err := a.state.fetcher.Init(a.config.Fetcher); err != nil {
+		return fmt.Errorf("init fetcher: %w", err)
+	}
+	if err := a.state.fetcher.Register(a.state.mediator); err != nil {
+		a.logger.Error("Failed to register fetcher", "err", err)
+		return fmt.Errorf("register fetcher: %v", err)
+	}
+
+	if err := a.state.loader.Init(a.config.Loader); err != nil {
+		a.logger.Error("Failed to init loader", "err", err)
+		return fmt.Errorf("init loader: %v", err)
+	}
+	if err := a.state.loader.Register(a.state.mediator); err != nil {
+		a.logger.Error("Failed to register loader", "err", err)
+		return fmt.Errorf("register loader: %v", err)
+	}
+	if !a.lazy() {
+		if err := a.state.loader.Start(); err != nil {
+			a.logger.Error("Failed to start loader", "err", err)
+			return fmt.Errorf("start loader: %v", err)
+		}
+	}
+
+	if err := a.state.server.Init(a.config.Server); err != nil {
+		a.logger.Error("Failed to init server", "err", err)
+		return fmt.Errorf("init server: %v", err)
+	}
+	stats := pattern.Stats()
+	a.logger.Debug("Patterns compiled", "patterns", stats.Patterns, "compiles", stats.Compiles, "hits", stats.Hits,
+		"errors", stats.Errors)
+	if a.config.Preflight != nil {
+		if err := a.preflight(*a.config.Preflight == appPreflightStrict); err != nil {
+			return fmt.Errorf("preflight: %v", err)
+		}
+	}
+	if a.lazy() {
+		a.state.server.OnFirstRequest(func() {
+			a.logger.Info("First request received, starting loader")
+			if err := a.state.loader.Start(); err != nil {
+				a.logger.Error("Failed to start loader", "err", err)
+			}
+		})
+	}
+	if err := a.runHooks(appHookPreListen); err != nil {
+		return err
+	}
+	if err := a.state.server.Register(a.state.mediator); err != nil {
+		a.logger.Error("Failed to register server", "err", err)
+		return fmt.Errorf("register server: %v", err)
+	}
+	if err := a.state.server.Start(); err != nil {
+		a.logger.Error("Failed to start server", "err", err)
+		return fmt.Errorf("start server: %v", err)
+	}
+
+	return nil
+}
+
+// initFault checks the fault injection configuration.
+func (a *app) initFault() error {
+	var errConfig bool
+
+	for target, config := range a.config.Fault {
+		switch fault.Target(target) {
+		case fault.Fetch, fault.VM, fault.Cache:
+		default:
+			a.logger.Error("Invalid value", "option", "Fault", "value", target)
+			errConfig = true
+		}
+		if config.ErrorRate != nil && (*config.ErrorRate < 0 || *config.ErrorRate > 100) {
+			a.logger.Error("Invalid value", "option", "Fault.ErrorRate", "target", target, "value", *config.ErrorRate)
+			errConfig = true
+		}
+		if config.DelayRate != nil && (*config.DelayRate < 0 || *config.DelayRate > 100) {
+			a.logger.Error("Invalid value", "option", "Fault.DelayRate", "target", target, "value", *config.DelayRate)
+			errConfig = true
+		}
+		if config.Delay != nil && *config.Delay < 0 {
+			a.logger.Error("Invalid value", "option", "Fault.Delay", "target", target, "value", *config.Delay)
+			errConfig = true
+		}
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	return nil
+}
+
+// configureFault enables the configured fault injection.
+func (a *app) configureFault() {
+	rules := make(map[fault.Target]fault.Rule, len(a.config.Fault))
+	for target, config := range a.config.Fault {
+		var rule fault.Rule
+		if config.ErrorRate != nil {
+			rule.ErrorRate = *config.ErrorRate
+		}
+		if config.DelayRate != nil {
+			rule.DelayRate = *config.DelayRate
+		}
+		if config.Delay != nil {
+			rule.Delay = time.Duration(*config.Delay) * time.Millisecond
+		}
+		rules[fault.Target(target)] = rule
+
+		a.logger.Warn("Fault injection enabled", "target", target, "errorRate", rule.ErrorRate,
+			"delayRate", rule.DelayRate, "delay", rule.Delay.Milliseconds())
+	}
+	fault.Configure(rules)
+}
+
+// initRedact checks the redaction configuration.
+func (a *app) initRedact() error {
+	if a.config.Redact == nil {
+		return nil
+	}
+
+	var errConfig bool
+
+	for index, expr := range a.config.Redact.Headers {
+		if _, err := pattern.Compile(expr); err != nil {
+			a.logger.Error("Invalid regular expression", "rule", index+1, "option", "Redact.Headers", "value", expr,
+				"err", err)
+			errConfig = true
+		}
+	}
+	for index, expr := range a.config.Redact.Cookies {
+		if _, err := pattern.Compile(expr); err != nil {
+			a.logger.Error("Invalid regular expression", "rule", index+1, "option", "Redact.Cookies", "value", expr,
+				"err", err)
+			errConfig = true
+		}
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	return nil
+}
+
+// configureRedact applies the configured redaction rules.
+func (a *app) configureRedact() {
+	var headers, cookies []string
+	if a.config.Redact != nil {
+		headers = a.config.Redact.Headers
+		cookies = a.config.Redact.Cookies
+	}
+	if err := redact.Configure(headers, cookies); err != nil {
+		a.logger.Error("Failed to configure redaction", "err", err)
+	}
+}
+
+// lazy returns true if the loader must be started only on the first request.
+func (a *app) lazy() bool {
+	return a.config.Lazy != nil && *a.config.Lazy
+}
+
 // stop stops the instance.
 func (a *app) stop() error {
 	if err := a.state.server.Stop(); err != nil {
@@ -285,6 +538,13 @@ func (a *app) stop() error {
 			return fmt.Errorf("stop loader: %v", err)
 		}
 	}
+	a.export()
+	if a.state.store != nil {
+		if err := a.state.store.Stop(); err != nil {
+			a.logger.Error("Failed to stop store", "err", err)
+			return fmt.Errorf("stop store: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -294,6 +554,11 @@ func (a *app) shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
+	return a.shutdownContext(ctx)
+}
+
+// shutdownContext stops the instance gracefully until the given context is done.
+func (a *app) shutdownContext(ctx context.Context) error {
 	if err := a.state.server.Shutdown(ctx); err != nil {
 		a.logger.Error("Failed to shutdown server", "err", err)
 		return fmt.Errorf("shutdown server: %w", err)
@@ -304,10 +569,27 @@ func (a *app) shutdown() error {
 			return fmt.Errorf("stop loader: %v", err)
 		}
 	}
+	a.export()
+	if a.state.store != nil {
+		if err := a.state.store.Stop(); err != nil {
+			a.logger.Error("Failed to stop store", "err", err)
+			return fmt.Errorf("stop store: %v", err)
+		}
+	}
 
 	return nil
 }
 
+// export saves the store snapshot once the instance is stopped.
+func (a *app) export() {
+	if a.state.store == nil {
+		return
+	}
+	if err := a.state.store.Export(); err != nil {
+		a.logger.Warn("Failed to export store snapshot", "err", err)
+	}
+}
+
 // reload reloads the instance.
 func (a *app) reload() error {
 	ch := make(chan string)
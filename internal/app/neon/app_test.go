@@ -31,6 +31,171 @@ func TestAppInit(t *testing.T) {
 				state:  &appState{},
 			},
 		},
+		{
+			name: "preflight",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"preflight": "strict",
+				},
+			},
+		},
+		{
+			name: "error invalid preflight",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"preflight": "invalid",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "log level ttl",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"logLevelTTL": 60,
+				},
+			},
+		},
+		{
+			name: "error invalid log level ttl",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"logLevelTTL": -1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "redact",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"redact": map[string]interface{}{
+						"headers": []string{"^x-client-ip$"},
+						"cookies": []string{"^theme$"},
+					},
+				},
+			},
+		},
+		{
+			name: "error invalid redact",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"redact": map[string]interface{}{
+						"headers": []string{"("},
+						"cookies": []string{"("},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "fault",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"fault": map[string]interface{}{
+						"fetch": map[string]interface{}{
+							"errorRate": 10,
+							"delayRate": 20,
+							"delay":     500,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error invalid fault",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"fault": map[string]interface{}{
+						"unknown": map[string]interface{}{
+							"errorRate": 101,
+							"delayRate": -1,
+							"delay":     -1,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hooks",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"hooks": map[string]interface{}{
+						"preListen": []map[string]interface{}{
+							{
+								"command":  []string{"true"},
+								"timeout":  5,
+								"required": true,
+							},
+						},
+						"postReady": []map[string]interface{}{
+							{
+								"url":    "http://localhost/register",
+								"method": "PUT",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error invalid hooks",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &appState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"hooks": map[string]interface{}{
+						"unknown": []map[string]interface{}{
+							{
+								"command": []string{"true"},
+								"url":     "localhost",
+								"timeout": 0,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1,25 +1,31 @@
 package neon
 
 import (
+	"bytes"
 	"embed"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	"gopkg.in/yaml.v3"
 )
 
 // config implements the configuration.
 type config struct {
-	parser     configParser
-	data       map[string]interface{}
-	osReadFile func(name string) ([]byte, error)
+	parser      configParser
+	data        map[string]interface{}
+	osReadFile  func(name string) ([]byte, error)
+	sopsDecrypt func(name string, data []byte) ([]byte, error)
 }
 
 const (
 	configDefaultFile string = "neon.yaml"
+	configDefaultSops string = "sops"
 )
 
 // configOsReadFile redirects to os.ReadFile.
@@ -27,11 +33,70 @@ func configOsReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
+// configSopsDecrypt decrypts the given sops encrypted YAML data with the sops binary.
+//
+// The binary is set by the CONFIG_SOPS environment variable. The keys are found by sops from its own environment
+// variables like SOPS_AGE_KEY, SOPS_AGE_KEY_FILE or the AWS, GCP and Azure credentials for the KMS keys. The
+// CONFIG_SOPS_AGE_KEY_FILE environment variable sets the age key file only for the decryption of the configuration.
+//
+// The data is passed to sops in a temporary YAML file as /dev/stdin is not available on all platforms.
+func configSopsDecrypt(name string, data []byte) ([]byte, error) {
+	bin := configDefaultSops
+	if v, ok := os.LookupEnv("CONFIG_SOPS"); ok && v != "" {
+		bin = v
+	}
+
+	f, err := os.CreateTemp("", "neon-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("decrypt file %s: %v", name, err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypt file %s: %v", name, err)
+	}
+
+	cmd := exec.Command(bin, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", f.Name())
+	cmd.Env = os.Environ()
+	if v, ok := os.LookupEnv("CONFIG_SOPS_AGE_KEY_FILE"); ok && v != "" {
+		cmd.Env = append(cmd.Env, "SOPS_AGE_KEY_FILE="+v)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("decrypt file %s: %v: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("decrypt file %s: %v", name, err)
+	}
+
+	return out, nil
+}
+
+// configSopsEncrypted returns true if the given YAML data is a sops encrypted document.
+func configSopsEncrypted(data []byte) bool {
+	var y struct {
+		Sops struct {
+			Mac string `yaml:"mac"`
+		} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		return false
+	}
+
+	return y.Sops.Mac != ""
+}
+
 // newConfig creates a new config.
 func newConfig(parser configParser) *config {
 	return &config{
-		parser:     parser,
-		osReadFile: configOsReadFile,
+		parser:      parser,
+		osReadFile:  configOsReadFile,
+		sopsDecrypt: configSopsDecrypt,
 	}
 }
 
@@ -67,6 +132,8 @@ func (p *configParserYAML) parse(data []byte, c *config) error {
 var _ configParser = (*configParserYAML)(nil)
 
 // LoadConfig loads the configuration.
+//
+// A configuration file encrypted with sops is decrypted transparently.
 func LoadConfig() (*config, error) {
 	name := configDefaultFile
 	if v, ok := os.LookupEnv("CONFIG_FILE"); ok && v != "" {
@@ -79,11 +146,38 @@ func LoadConfig() (*config, error) {
 
 	c := newConfig(newConfigParserYAML())
 
+	if err := c.load(name); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load reads and parses the given configuration file.
+func (c *config) load(name string) error {
 	data, err := c.osReadFile(name)
 	if err != nil {
-		return nil, fmt.Errorf("read file %s: %v", name, err)
+		return fmt.Errorf("read file %s: %v", name, err)
+	}
+
+	if configSopsEncrypted(data) {
+		data, err = c.sopsDecrypt(name, data)
+		if err != nil {
+			return err
+		}
 	}
 
+	if err := c.parser.parse(data, c); err != nil {
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	return nil
+}
+
+// ParseConfig parses the given YAML configuration data.
+func ParseConfig(data []byte) (*config, error) {
+	c := newConfig(newConfigParserYAML())
+
 	if err := c.parser.parse(data, c); err != nil {
 		return nil, fmt.Errorf("parse config: %v", err)
 	}
@@ -91,6 +185,18 @@ func LoadConfig() (*config, error) {
 	return c, nil
 }
 
+// Endpoints returns the URLs of the endpoints of each listener read from the configuration.
+func (c *config) Endpoints() (map[string][]string, error) {
+	var appConfig struct {
+		Server map[string]interface{} `mapstructure:"server"`
+	}
+	if err := mapstructure.Decode(c.data["app"], &appConfig); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+
+	return serverConfigEndpoints(appConfig.Server)
+}
+
 //go:embed templates/config/*
 var configTemplates embed.FS
 
@@ -112,6 +218,9 @@ func GenerateConfig(template string) error {
 	if err != nil {
 		return fmt.Errorf("read file %s: %v", src, err)
 	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return fmt.Errorf("create directory %s: %v", filepath.Dir(dst), err)
+	}
 	if err := os.WriteFile(dst, data, 0600); err != nil {
 		return fmt.Errorf("write file %s: %v", dst, err)
 	}
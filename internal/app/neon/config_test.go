@@ -1,8 +1,10 @@
 package neon
 
 import (
+	"errors"
 	"os"
 	"path"
+	"reflect"
 	"testing"
 )
 
@@ -73,6 +75,89 @@ app:
 	}
 }
 
+func TestConfigLoad(t *testing.T) {
+	encrypted := `
+app:
+  server: ENC[AES256_GCM,data:test,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:test,type:str]
+  version: 3.8.1
+`
+	tests := []struct {
+		name        string
+		data        string
+		sopsDecrypt func(name string, data []byte) ([]byte, error)
+		want        map[string]interface{}
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			data: "app:\n  server: test\n",
+			want: map[string]interface{}{
+				"app": map[string]interface{}{"server": "test"},
+			},
+		},
+		{
+			name: "sops",
+			data: encrypted,
+			sopsDecrypt: func(name string, data []byte) ([]byte, error) {
+				return []byte("app:\n  server: test\n"), nil
+			},
+			want: map[string]interface{}{
+				"app": map[string]interface{}{"server": "test"},
+			},
+		},
+		{
+			name: "error decrypt",
+			data: encrypted,
+			sopsDecrypt: func(name string, data []byte) ([]byte, error) {
+				return nil, errors.New("test error")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig(newConfigParserYAML())
+			c.osReadFile = func(name string) ([]byte, error) {
+				return []byte(tt.data), nil
+			}
+			if tt.sopsDecrypt != nil {
+				c.sopsDecrypt = tt.sopsDecrypt
+			}
+			if err := c.load("test.yaml"); (err != nil) != tt.wantErr {
+				t.Errorf("config.load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(c.data, tt.want) {
+				t.Errorf("config.load() data = %v, want %v", c.data, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSopsDecrypt(t *testing.T) {
+	name := path.Join(t.TempDir(), "sops")
+	script := "#!/bin/sh\n[ \"$1\" = \"--decrypt\" ] || exit 1\necho \"key: $SOPS_AGE_KEY_FILE\"\ncat \"$6\"\n"
+	if err := os.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_SOPS", name)
+	t.Setenv("CONFIG_SOPS_AGE_KEY_FILE", "keys.txt")
+
+	got, err := configSopsDecrypt("test.yaml", []byte("data: test\n"))
+	if err != nil {
+		t.Fatalf("configSopsDecrypt() error = %v", err)
+	}
+	if want := "key: keys.txt\ndata: test\n"; string(got) != want {
+		t.Errorf("configSopsDecrypt() = %q, want %q", got, want)
+	}
+
+	t.Setenv("CONFIG_SOPS", path.Join(t.TempDir(), "missing"))
+	if _, err := configSopsDecrypt("test.yaml", nil); err == nil {
+		t.Errorf("configSopsDecrypt() error = %v, wantErr %v", err, true)
+	}
+}
+
 func TestGenerateConfig(t *testing.T) {
 	name := path.Join(t.TempDir(), "test.yaml")
 	t.Setenv("CONFIG_FILE", name)
@@ -100,3 +185,75 @@ func TestGenerateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			data: map[string]interface{}{
+				"app": map[string]interface{}{
+					"server": map[string]interface{}{
+						"listeners": map[string]interface{}{
+							"http": map[string]interface{}{
+								"local": map[string]interface{}{
+									"listenAddr": "127.0.0.1",
+									"listenPort": 8080,
+								},
+							},
+							"https": map[string]interface{}{
+								"tls": map[string]interface{}{
+									"listen": []string{"127.0.0.1:8443", "[::1]:8443"},
+								},
+							},
+							"redirect": map[string]interface{}{
+								"redirect": nil,
+							},
+						},
+					},
+				},
+			},
+			want: map[string][]string{
+				"http":     {"http://127.0.0.1:8080"},
+				"https":    {"https://127.0.0.1:8443", "https://[::1]:8443"},
+				"redirect": {"http://:80"},
+			},
+		},
+		{
+			name: "error invalid listener config",
+			data: map[string]interface{}{
+				"app": map[string]interface{}{
+					"server": map[string]interface{}{
+						"listeners": map[string]interface{}{
+							"http": map[string]interface{}{
+								"local": map[string]interface{}{
+									"listenPort": "invalid",
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{
+				data: tt.data,
+			}
+			got, err := c.Endpoints()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Endpoints() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Endpoints() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
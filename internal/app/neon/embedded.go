@@ -0,0 +1,96 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+// Embedded
+type Embedded interface {
+	Start() error
+	Handler(listener string) (http.Handler, error)
+	Shutdown(ctx context.Context) error
+}
+
+// embedded implements an instance embedded into another program.
+type embedded struct {
+	app *app
+}
+
+// NewEmbedded creates a new embedded instance.
+//
+// The instance does not handle the process signals and its listeners are served from the given network listeners,
+// indexed by listener name, instead of opening their own sockets. The listeners configured with the embedded module
+// have no socket at all and their requests must be passed to the handlers returned by Handler, from an httptest
+// server or a serverless adapter.
+func NewEmbedded(config *config, listeners map[string][]net.Listener) (Embedded, error) {
+	appModuleInfo, err := module.Lookup(appModuleID)
+	if err != nil {
+		return nil, fmt.Errorf("lookup module %s: %w", appModuleID, err)
+	}
+	a, ok := appModuleInfo.NewInstance().(*app)
+	if !ok {
+		return nil, errors.New("invalid app")
+	}
+	cfg, ok := config.data["app"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("missing app configuration")
+	}
+	if err := a.Init(cfg); err != nil {
+		return nil, fmt.Errorf("init app: %w", err)
+	}
+	a.state.listeners = listeners
+
+	return &embedded{
+		app: a,
+	}, nil
+}
+
+// Start starts the instance.
+func (e *embedded) Start() error {
+	e.app.logger.Info("Starting embedded instance")
+
+	module.Load()
+
+	if err := e.app.start(); err != nil {
+		return err
+	}
+
+	e.app.logger.Info("Instance ready")
+
+	_ = e.app.runHooks(appHookPostReady)
+
+	return nil
+}
+
+// Handler returns the handler serving the requests of the given listener.
+func (e *embedded) Handler(listener string) (http.Handler, error) {
+	handler, err := e.app.state.server.Handler(listener)
+	if err != nil {
+		return nil, fmt.Errorf("get handler: %w", err)
+	}
+
+	return handler, nil
+}
+
+// Shutdown stops the instance gracefully until the given context is done.
+//
+// The modules stay loaded until the program exits as the JavaScript engine cannot be initialized again.
+func (e *embedded) Shutdown(ctx context.Context) error {
+	_ = e.app.runHooks(appHookPreShutdown)
+
+	if err := e.app.shutdownContext(ctx); err != nil {
+		return err
+	}
+
+	e.app.logger.Info("Instance terminated")
+
+	return nil
+}
+
+var _ Embedded = (*embedded)(nil)
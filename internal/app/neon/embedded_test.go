@@ -0,0 +1,91 @@
+package neon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(file, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`
+app:
+  store:
+    storage:
+      memory:
+  server:
+    listeners:
+      embedded:
+        embedded:
+      network:
+        local:
+          listen:
+            - %s
+    sites:
+      main:
+        listeners:
+          - embedded
+          - network
+        routes:
+          default:
+            handler:
+              file:
+                path: %s
+`, ln.Addr().String(), file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewEmbedded(config, map[string][]net.Listener{
+		"network": {ln},
+	})
+	if err != nil {
+		t.Fatalf("NewEmbedded() error = %v", err)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("embedded.Start() error = %v", err)
+	}
+	defer func() {
+		if err := e.Shutdown(context.Background()); err != nil {
+			t.Errorf("embedded.Shutdown() error = %v", err)
+		}
+	}()
+
+	handler, err := e.Handler("embedded")
+	if err != nil {
+		t.Fatalf("embedded.Handler() error = %v", err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "test" {
+		t.Errorf("embedded handler response = %d %q, want %d %q", w.Code, w.Body.String(), http.StatusOK, "test")
+	}
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("network listener error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "test" {
+		t.Errorf("network listener response = %d %q, want %d %q", resp.StatusCode, body, http.StatusOK, "test")
+	}
+
+	if _, err := e.Handler("missing"); err == nil {
+		t.Errorf("embedded.Handler() error = %v, wantErr %v", err, true)
+	}
+}
@@ -1,7 +1,17 @@
 package neon
 
+import (
+	"time"
+)
+
 var (
-	DEBUG bool = false
+	DEBUG       bool      = false
+	DEBUG_UNTIL time.Time = time.Time{}
 
 	CHILD_SOCKET string = "neon.sock"
 )
+
+// debugEnabled returns true if the debug mode is enabled and not expired.
+func debugEnabled() bool {
+	return DEBUG && (DEBUG_UNTIL.IsZero() || time.Now().Before(DEBUG_UNTIL))
+}
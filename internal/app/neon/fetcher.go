@@ -7,10 +7,12 @@ import (
 	"log/slog"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/fault"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
 )
@@ -26,6 +28,7 @@ type fetcher struct {
 // fetcherConfig implements the fetcher configuration.
 type fetcherConfig struct {
 	Providers map[string]map[string]map[string]interface{} `mapstructure:"providers"`
+	Fixtures  *string                                      `mapstructure:"fixtures"`
 }
 
 // fetcherState implements the fetcher state.
@@ -71,6 +74,16 @@ func (f *fetcher) Init(config map[string]interface{}) error {
 
 	var errConfig bool
 
+	if f.config.Fixtures != nil {
+		if *f.config.Fixtures == "" {
+			f.logger.Error("Invalid value", "option", "Fixtures", "value", *f.config.Fixtures)
+			errConfig = true
+		} else {
+			f.logger.Warn("Fixtures mode enabled, the resources are read from the fixtures directory",
+				"directory", *f.config.Fixtures)
+		}
+	}
+
 	for provider, providerConfig := range f.config.Providers {
 		for moduleName, moduleConfig := range providerConfig {
 			moduleInfo, err := module.Lookup(module.ModuleID("app.fetcher.provider." + moduleName))
@@ -117,6 +130,8 @@ func (f *fetcher) Register(app core.App) error {
 }
 
 // Fetch fetches a resource from his name, provider and configuration.
+//
+// In fixtures mode, the resource is read from the fixtures directory instead of being fetched by the provider.
 func (f *fetcher) Fetch(ctx context.Context, name string, provider string, config map[string]interface{}) (
 	*core.Resource, error) {
 	f.logger.Debug("Fetching resource", "name", name, "provider", provider)
@@ -128,14 +143,53 @@ func (f *fetcher) Fetch(ctx context.Context, name string, provider string, confi
 		return nil, errors.New("provider not found")
 	}
 
-	resource, err := module.Fetch(ctx, name, config)
+	if err := fault.Inject(ctx, fault.Fetch); err != nil {
+		return nil, fmt.Errorf("fetch resource %s: %w", name, err)
+	}
+	var resource *core.Resource
+	var err error
+	if f.config != nil && f.config.Fixtures != nil {
+		resource, err = fixtureRead(*f.config.Fixtures, name)
+	} else {
+		resource, err = module.Fetch(ctx, name, config)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("fetch resource %s: %w", name, err)
 	}
+	if resource.Time.IsZero() {
+		resource.Time = time.Now()
+	}
 
 	return resource, nil
 }
 
+// Preflight checks the reachability of a resource from his name, provider and configuration.
+func (f *fetcher) Preflight(ctx context.Context, name string, provider string, config map[string]interface{}) error {
+	f.mu.RLock()
+	module, ok := f.state.providers[provider]
+	f.mu.RUnlock()
+	if !ok {
+		return errors.New("provider not found")
+	}
+
+	if f.config != nil && f.config.Fixtures != nil {
+		if _, err := fixtureRead(*f.config.Fixtures, name); err != nil {
+			return fmt.Errorf("preflight resource %s: %w", name, err)
+		}
+		return nil
+	}
+
+	preflight, ok := module.(core.FetcherProviderPreflightModule)
+	if !ok {
+		return nil
+	}
+	if err := preflight.Preflight(ctx, name, config); err != nil {
+		return fmt.Errorf("preflight resource %s: %w", name, err)
+	}
+
+	return nil
+}
+
 var _ Fetcher = (*fetcher)(nil)
 
 // fetcherMediator implements the fetcher mediator.
@@ -159,4 +213,13 @@ func (m *fetcherMediator) Fetch(ctx context.Context, name string, provider strin
 	return m.fetcher.Fetch(ctx, name, provider, config)
 }
 
+// Preflight checks the reachability of a resource from his name, provider and configuration.
+func (m *fetcherMediator) Preflight(ctx context.Context, name string, provider string,
+	config map[string]interface{}) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.fetcher.Preflight(ctx, name, provider, config)
+}
+
 var _ core.Fetcher = (*fetcherMediator)(nil)
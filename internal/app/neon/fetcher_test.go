@@ -2,12 +2,15 @@ package neon
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/fault"
 )
 
 func TestFetcherInit(t *testing.T) {
@@ -67,6 +70,31 @@ func TestFetcherInit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "fixtures",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &fetcherState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"fixtures": "fixtures",
+				},
+			},
+		},
+		{
+			name: "error invalid fixtures",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &fetcherState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"fixtures": "",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "error unregistered provider module",
 			fields: fields{
@@ -262,9 +290,71 @@ func TestFetcherFetch(t *testing.T) {
 				t.Errorf("fetcher.Fetch() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if got != nil {
+				if got.Time.IsZero() {
+					t.Errorf("fetcher.Fetch() time = %v, want not zero", got.Time)
+				}
+				got.Time = time.Time{}
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("fetcher.Fetch() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestFetcherFetchFixtures(t *testing.T) {
+	directory := t.TempDir()
+	if err := fixtureWrite(directory, "test", &core.Resource{Data: [][]byte{[]byte("fixture")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fetcher{
+		config: &fetcherConfig{
+			Fixtures: stringPtr(directory),
+		},
+		logger: slog.Default(),
+		state: &fetcherState{
+			providers: map[string]core.FetcherProviderModule{
+				"test": testFetcherProviderModule{
+					errFetch: true,
+				},
+			},
+		},
+		mu: &sync.RWMutex{},
+	}
+	got, err := f.Fetch(context.Background(), "test", "test", nil)
+	if err != nil {
+		t.Errorf("fetcher.Fetch() error = %v", err)
+		return
+	}
+	if want := [][]byte{[]byte("fixture")}; !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("fetcher.Fetch() data = %v, want %v", got.Data, want)
+	}
+	if _, err := f.Fetch(context.Background(), "other", "test", nil); err == nil {
+		t.Errorf("fetcher.Fetch() error = %v, wantErr %v", err, true)
+	}
+	if err := f.Preflight(context.Background(), "other", "test", nil); err == nil {
+		t.Errorf("fetcher.Preflight() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestFetcherFetchFault(t *testing.T) {
+	fault.Configure(map[fault.Target]fault.Rule{
+		fault.Fetch: {ErrorRate: 100},
+	})
+	defer fault.Configure(nil)
+
+	f := &fetcher{
+		logger: slog.Default(),
+		state: &fetcherState{
+			providers: map[string]core.FetcherProviderModule{
+				"test": testFetcherProviderModule{},
+			},
+		},
+		mu: &sync.RWMutex{},
+	}
+	if _, err := f.Fetch(context.Background(), "test", "test", nil); !errors.Is(err, fault.ErrInjected) {
+		t.Errorf("fetcher.Fetch() error = %v, wantErr %v", err, fault.ErrInjected)
+	}
+}
@@ -0,0 +1,110 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+// fixtureFetcher implements a fetcher writing the fetched resources into a fixtures directory.
+type fixtureFetcher struct {
+	fetcher   core.Fetcher
+	directory string
+	names     map[string]struct{}
+	mu        sync.Mutex
+}
+
+// newFixtureFetcher creates a new fixture fetcher.
+func newFixtureFetcher(fetcher core.Fetcher, directory string) *fixtureFetcher {
+	return &fixtureFetcher{
+		fetcher:   fetcher,
+		directory: directory,
+		names:     make(map[string]struct{}),
+	}
+}
+
+// Fetch fetches a resource from his name, provider and configuration, and writes it into the fixtures directory.
+func (f *fixtureFetcher) Fetch(ctx context.Context, name string, provider string, config map[string]interface{}) (
+	*core.Resource, error) {
+	resource, err := f.fetcher.Fetch(ctx, name, provider, config)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := fixtureWrite(f.directory, name, resource); err != nil {
+		return nil, fmt.Errorf("write fixture %s: %w", name, err)
+	}
+	f.names[name] = struct{}{}
+
+	return resource, nil
+}
+
+// Preflight checks the reachability of a resource from his name, provider and configuration.
+func (f *fixtureFetcher) Preflight(ctx context.Context, name string, provider string,
+	config map[string]interface{}) error {
+	return f.fetcher.Preflight(ctx, name, provider, config)
+}
+
+// Count returns the number of resources written.
+func (f *fixtureFetcher) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.names)
+}
+
+var _ core.Fetcher = (*fixtureFetcher)(nil)
+
+// fixtureWrite writes a resource into the given fixtures directory.
+//
+// The resource is stored in a directory named after the escaped resource name, with one file per data item named
+// after its index. The files of a previous write are removed.
+func fixtureWrite(directory string, name string, resource *core.Resource) error {
+	dir := filepath.Join(directory, url.PathEscape(name))
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("remove directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	for index, data := range resource.Data {
+		if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(index)), data, 0644); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fixtureRead reads a resource from the given fixtures directory.
+func fixtureRead(directory string, name string) (*core.Resource, error) {
+	dir := filepath.Join(directory, url.PathEscape(name))
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("fixture not found: %w", err)
+	}
+
+	resource := &core.Resource{}
+	for index := 0; ; index++ {
+		data, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(index)))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		resource.Data = append(resource.Data, data)
+	}
+
+	return resource, nil
+}
@@ -0,0 +1,110 @@
+package neon
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+func TestFixtureWriteRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		data     [][]byte
+	}{
+		{
+			name:     "default",
+			resource: "test",
+			data:     [][]byte{[]byte(`{"page":1}`), []byte(`{"page":2}`)},
+		},
+		{
+			name:     "escaped name",
+			resource: "pages/1",
+			data:     [][]byte{[]byte("test")},
+		},
+		{
+			name:     "empty",
+			resource: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			if err := fixtureWrite(directory, tt.resource, &core.Resource{Data: [][]byte{[]byte("a"), []byte("b"),
+				[]byte("c")}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := fixtureWrite(directory, tt.resource, &core.Resource{Data: tt.data}); err != nil {
+				t.Errorf("fixtureWrite() error = %v", err)
+				return
+			}
+			got, err := fixtureRead(directory, tt.resource)
+			if err != nil {
+				t.Errorf("fixtureRead() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got.Data, tt.data) {
+				t.Errorf("fixtureRead() data = %q, want %q", got.Data, tt.data)
+			}
+		})
+	}
+}
+
+func TestFixtureReadNotFound(t *testing.T) {
+	if _, err := fixtureRead(t.TempDir(), "test"); err == nil {
+		t.Errorf("fixtureRead() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestFixtureFetcherFetch(t *testing.T) {
+	tests := []struct {
+		name      string
+		errFetch  bool
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "default",
+			wantCount: 1,
+		},
+		{
+			name:     "error fetch",
+			errFetch: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			f := newFixtureFetcher(&fetcher{
+				logger: slog.Default(),
+				state: &fetcherState{
+					providers: map[string]core.FetcherProviderModule{
+						"test": testFetcherProviderModule{
+							errFetch: tt.errFetch,
+						},
+					},
+				},
+				mu: &sync.RWMutex{},
+			}, directory)
+			for i := 0; i < 2; i++ {
+				if _, err := f.Fetch(context.Background(), "test", "test", nil); (err != nil) != tt.wantErr {
+					t.Errorf("fixtureFetcher.Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if got := f.Count(); got != tt.wantCount {
+				t.Errorf("fixtureFetcher.Count() = %v, want %v", got, tt.wantCount)
+			}
+			_, err := os.Stat(filepath.Join(directory, "test", "0"))
+			if (err == nil) != (tt.wantCount > 0) {
+				t.Errorf("fixtureFetcher.Fetch() fixture error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package neon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/buildinfo"
+)
+
+// appHookConfig implements the configuration of a lifecycle hook.
+type appHookConfig struct {
+	Command  []string `mapstructure:"command"`
+	URL      *string  `mapstructure:"url"`
+	Method   *string  `mapstructure:"method"`
+	Timeout  *int     `mapstructure:"timeout"`
+	Required *bool    `mapstructure:"required"`
+}
+
+// appHookPayload implements the payload sent to a HTTP hook.
+type appHookPayload struct {
+	Hook    string `json:"hook"`
+	Version string `json:"version"`
+	PID     int    `json:"pid"`
+}
+
+const (
+	appHookPreListen   string = "preListen"
+	appHookPostReady   string = "postReady"
+	appHookPreShutdown string = "preShutdown"
+
+	appHookConfigDefaultMethod  string = http.MethodPost
+	appHookConfigDefaultTimeout int    = 10
+
+	appHookEnvKey    string = "NEON_HOOK"
+	appHookOutputMax int    = 1024
+)
+
+// initHooks checks the lifecycle hooks configuration.
+func (a *app) initHooks() error {
+	var errConfig bool
+
+	for point, hooks := range a.config.Hooks {
+		switch point {
+		case appHookPreListen, appHookPostReady, appHookPreShutdown:
+		default:
+			a.logger.Error("Invalid value", "option", "Hooks", "value", point)
+			errConfig = true
+		}
+		for index, hook := range hooks {
+			if (len(hook.Command) == 0) == (hook.URL == nil) {
+				a.logger.Error("Missing option or value", "option", "Hooks.Command/Hooks.URL", "hook", point,
+					"index", index+1)
+				errConfig = true
+			}
+			if len(hook.Command) > 0 && hook.Command[0] == "" {
+				a.logger.Error("Invalid value", "option", "Hooks.Command", "hook", point, "index", index+1,
+					"value", hook.Command)
+				errConfig = true
+			}
+			if hook.URL != nil {
+				if u, err := url.Parse(*hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+					u.Host == "" {
+					a.logger.Error("Invalid value", "option", "Hooks.URL", "hook", point, "index", index+1,
+						"value", *hook.URL)
+					errConfig = true
+				}
+			}
+			if hook.Method != nil && *hook.Method == "" {
+				a.logger.Error("Invalid value", "option", "Hooks.Method", "hook", point, "index", index+1,
+					"value", *hook.Method)
+				errConfig = true
+			}
+			if hook.Timeout != nil && *hook.Timeout <= 0 {
+				a.logger.Error("Invalid value", "option", "Hooks.Timeout", "hook", point, "index", index+1,
+					"value", *hook.Timeout)
+				errConfig = true
+			}
+		}
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	return nil
+}
+
+// runHooks executes in order the hooks of the given lifecycle point.
+//
+// A failed hook is only reported unless it is required, in which case the next hooks are not executed and the error
+// is returned.
+func (a *app) runHooks(point string) error {
+	for index, hook := range a.config.Hooks[point] {
+		start := time.Now()
+		err := runHook(point, hook)
+		if err == nil {
+			a.logger.Info("Hook executed", "hook", point, "index", index+1, "duration", time.Since(start))
+			continue
+		}
+		if hook.Required != nil && *hook.Required {
+			a.logger.Error("Hook failed", "hook", point, "index", index+1, "err", err)
+			return fmt.Errorf("hook %s %d: %v", point, index+1, err)
+		}
+		a.logger.Warn("Hook failed", "hook", point, "index", index+1, "err", err)
+	}
+
+	return nil
+}
+
+// runHook executes a hook until its timeout.
+func runHook(point string, hook appHookConfig) error {
+	timeout := appHookConfigDefaultTimeout
+	if hook.Timeout != nil {
+		timeout = *hook.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	if len(hook.Command) > 0 {
+		cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+		cmd.Env = append(os.Environ(), appHookEnvKey+"="+point)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			out := strings.TrimSpace(string(output))
+			if len(out) > appHookOutputMax {
+				out = out[:appHookOutputMax]
+			}
+			return fmt.Errorf("execute command: %v: %s", err, out)
+		}
+
+		return nil
+	}
+
+	method := appHookConfigDefaultMethod
+	if hook.Method != nil {
+		method = *hook.Method
+	}
+	buf, err := json.Marshal(appHookPayload{
+		Hook:    point,
+		Version: buildinfo.Get().Version,
+		PID:     os.Getpid(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, *hook.URL, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("request error %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package neon
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAppRunHooks(t *testing.T) {
+	var payload appHookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		hooks   []appHookConfig
+		wantErr bool
+	}{
+		{
+			name: "command",
+			hooks: []appHookConfig{
+				{
+					Command: []string{"sh", "-c", `test "$NEON_HOOK" = preListen`},
+				},
+			},
+		},
+		{
+			name: "url",
+			hooks: []appHookConfig{
+				{
+					URL:    stringPtr(server.URL),
+					Method: stringPtr(http.MethodPut),
+				},
+			},
+		},
+		{
+			name: "failure not required",
+			hooks: []appHookConfig{
+				{
+					Command: []string{"false"},
+				},
+			},
+		},
+		{
+			name: "failure required",
+			hooks: []appHookConfig{
+				{
+					Command:  []string{"false"},
+					Required: boolPtr(true),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "timeout",
+			hooks: []appHookConfig{
+				{
+					Command:  []string{"sleep", "5"},
+					Timeout:  intPtr(1),
+					Required: boolPtr(true),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "url error",
+			hooks: []appHookConfig{
+				{
+					URL:      stringPtr(server.URL),
+					Method:   stringPtr(http.MethodPost),
+					Required: boolPtr(true),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &app{
+				config: &appConfig{
+					Hooks: map[string][]appHookConfig{
+						appHookPreListen: tt.hooks,
+					},
+				},
+				logger: slog.Default(),
+			}
+			if err := a.runHooks(appHookPreListen); (err != nil) != tt.wantErr {
+				t.Errorf("app.runHooks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if payload.Hook != appHookPreListen {
+		t.Errorf("app.runHooks() payload = %+v", payload)
+	}
+}
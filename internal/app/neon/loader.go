@@ -2,10 +2,13 @@ package neon
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,15 +17,17 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/throttle"
 )
 
 // loader implements the loader.
 type loader struct {
-	config *loaderConfig
-	logger *slog.Logger
-	state  *loaderState
-	mu     *sync.RWMutex
-	stop   chan struct{}
+	config  *loaderConfig
+	logger  *slog.Logger
+	state   *loaderState
+	mu      *sync.RWMutex
+	stop    chan struct{}
+	started bool
 }
 
 // loaderConfig implements the loader configuration.
@@ -33,29 +38,45 @@ type loaderConfig struct {
 	ExecWorkers          *int                                         `mapstructure:"execWorkers"`
 	ExecMaxOps           *int                                         `mapstructure:"execMaxOps"`
 	ExecMaxDelay         *int                                         `mapstructure:"execMaxDelay"`
+	AnomalyItemsDrop     *int                                         `mapstructure:"anomalyItemsDrop"`
+	AnomalyBytesDrop     *int                                         `mapstructure:"anomalyBytesDrop"`
+	AnomalySkip          *bool                                        `mapstructure:"anomalySkip"`
 	Rules                map[string]map[string]map[string]interface{} `mapstructure:"rules"`
 }
 
 // loaderState implements the loader state.
 type loaderState struct {
-	parsers  map[string]core.LoaderParserModule
-	store    core.Store
-	fetcher  core.Fetcher
-	mediator *loaderMediator
-	failsafe bool
+	parsers       map[string]core.LoaderParserModule
+	store         core.Store
+	fetcher       core.Fetcher
+	mediator      *loaderMediator
+	failsafe      bool
+	hashes        map[string][sha256.Size]byte
+	stats         map[string]loaderRuleStats
+	baselines     map[string]loaderRuleStats
+	throttled     map[string]time.Time
+	muStats       sync.RWMutex
+	subscribers   []func(names []string)
+	muSubscribers sync.RWMutex
 }
 
 const (
 	loaderModuleID module.ModuleID = "app.loader"
 
-	loaderConfigDefaultExecStartup          int = 15
-	loaderConfigDefaultExecInterval         int = 900
-	loaderConfigDefaultExecFailsafeInterval int = 300
-	loaderConfigDefaultExecWorkers          int = 1
-	loaderConfigDefaultExecMaxOps           int = 100
-	loaderConfigDefaultExecMaxDelay         int = 60
+	loaderConfigDefaultExecStartup          int  = 15
+	loaderConfigDefaultExecInterval         int  = 900
+	loaderConfigDefaultExecFailsafeInterval int  = 300
+	loaderConfigDefaultExecWorkers          int  = 1
+	loaderConfigDefaultExecMaxOps           int  = 100
+	loaderConfigDefaultExecMaxDelay         int  = 60
+	loaderConfigDefaultAnomalyItemsDrop     int  = 0
+	loaderConfigDefaultAnomalyBytesDrop     int  = 0
+	loaderConfigDefaultAnomalySkip          bool = false
 )
 
+// errLoaderThrottled is the result of a rule execution throttled by an upstream.
+var errLoaderThrottled = errors.New("execution throttled")
+
 // ModuleInfo returns the module information.
 func (l loader) ModuleInfo() module.ModuleInfo {
 	return module.ModuleInfo{
@@ -66,7 +87,10 @@ func (l loader) ModuleInfo() module.ModuleInfo {
 			return &loader{
 				logger: slog.New(log.NewHandler(os.Stderr, string(loaderModuleID), nil)),
 				state: &loaderState{
-					parsers: make(map[string]core.LoaderParserModule),
+					parsers:   make(map[string]core.LoaderParserModule),
+					hashes:    make(map[string][sha256.Size]byte),
+					stats:     make(map[string]loaderRuleStats),
+					baselines: make(map[string]loaderRuleStats),
 				},
 				mu:   &sync.RWMutex{},
 				stop: make(chan struct{}),
@@ -138,6 +162,26 @@ func (l *loader) Init(config map[string]interface{}) error {
 		l.logger.Error("Invalid value", "option", "ExecMaxDelay", "value", *l.config.ExecMaxDelay)
 		errConfig = true
 	}
+	if l.config.AnomalyItemsDrop == nil {
+		defaultValue := loaderConfigDefaultAnomalyItemsDrop
+		l.config.AnomalyItemsDrop = &defaultValue
+	}
+	if *l.config.AnomalyItemsDrop < 0 || *l.config.AnomalyItemsDrop > 100 {
+		l.logger.Error("Invalid value", "option", "AnomalyItemsDrop", "value", *l.config.AnomalyItemsDrop)
+		errConfig = true
+	}
+	if l.config.AnomalyBytesDrop == nil {
+		defaultValue := loaderConfigDefaultAnomalyBytesDrop
+		l.config.AnomalyBytesDrop = &defaultValue
+	}
+	if *l.config.AnomalyBytesDrop < 0 || *l.config.AnomalyBytesDrop > 100 {
+		l.logger.Error("Invalid value", "option", "AnomalyBytesDrop", "value", *l.config.AnomalyBytesDrop)
+		errConfig = true
+	}
+	if l.config.AnomalySkip == nil {
+		defaultValue := loaderConfigDefaultAnomalySkip
+		l.config.AnomalySkip = &defaultValue
+	}
 
 	for ruleName, ruleConfig := range l.config.Rules {
 		for moduleName, moduleConfig := range ruleConfig {
@@ -191,6 +235,10 @@ func (l *loader) Start() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.started {
+		return nil
+	}
+
 	if len(l.config.Rules) > 0 {
 		if *l.config.ExecStartup == 0 && *l.config.ExecInterval == 0 {
 			l.logger.Warn("Periodic execution disabled")
@@ -206,6 +254,7 @@ func (l *loader) Start() error {
 			l.logger.Info("Starting loader")
 
 			l.execute(l.stop)
+			l.started = true
 		}
 	}
 
@@ -217,16 +266,132 @@ func (l *loader) Stop() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if len(l.config.Rules) > 0 && (*l.config.ExecStartup > 0 || *l.config.ExecInterval > 0) {
+	if l.started {
 		l.logger.Info("Stopping loader")
 
 		l.stop <- struct{}{}
+		l.started = false
+	}
+
+	return nil
+}
+
+// Subscribe registers a function called with the names of the changed resources.
+func (l *loader) Subscribe(fn func(names []string)) {
+	l.state.muSubscribers.Lock()
+	defer l.state.muSubscribers.Unlock()
+
+	l.state.subscribers = append(l.state.subscribers, fn)
+}
+
+// Preflight checks the reachability of the resources of all rules.
+func (l *loader) Preflight(ctx context.Context) error {
+	var errs []error
+	for ruleName, parser := range l.state.parsers {
+		preflight, ok := parser.(core.LoaderParserPreflightModule)
+		if !ok {
+			continue
+		}
+		errs = append(errs, preflightErrors("rule "+ruleName, preflight.Preflight(ctx, l.state.fetcher))...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Pull executes all the rules once with the given fetcher.
+//
+// The rules are executed in order and a failed rule does not stop the execution of the next ones.
+func (l *loader) Pull(ctx context.Context, fetcher core.Fetcher) error {
+	ruleNames := make([]string, 0, len(l.state.parsers))
+	for ruleName := range l.state.parsers {
+		ruleNames = append(ruleNames, ruleName)
+	}
+	sort.Strings(ruleNames)
+
+	var errs []error
+	for _, ruleName := range ruleNames {
+		l.logger.Debug("Pulling rule", "rule", ruleName)
+
+		if err := l.state.parsers[ruleName].Parse(ctx, l.state.store, fetcher); err != nil {
+			l.logger.Error("Failed to pull rule", "rule", ruleName, "err", err)
+			errs = append(errs, fmt.Errorf("rule %s: %w", ruleName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// notify notifies the subscribers of the changed resources.
+func (l *loader) notify(names []string) {
+	l.state.muSubscribers.RLock()
+	defer l.state.muSubscribers.RUnlock()
+
+	for _, fn := range l.state.subscribers {
+		fn(names)
+	}
+}
+
+// Stats returns the statistics of the last execution of each rule.
+func (l *loader) Stats() map[string]loaderRuleStats {
+	l.state.muStats.RLock()
+	defer l.state.muStats.RUnlock()
+
+	stats := make(map[string]loaderRuleStats, len(l.state.stats))
+	for name, s := range l.state.stats {
+		stats[name] = s
+	}
+
+	return stats
+}
+
+// commit records the statistics of the staged resources of a rule and stores them unless an anomaly is detected and
+// the previous data must be kept.
+func (l *loader) commit(ruleName string, store *loaderRuleStore) error {
+	stats := store.Stats()
+
+	l.state.muStats.Lock()
+	if l.state.stats == nil {
+		l.state.stats = make(map[string]loaderRuleStats)
+	}
+	if l.state.baselines == nil {
+		l.state.baselines = make(map[string]loaderRuleStats)
+	}
+	if baseline, ok := l.state.baselines[ruleName]; ok {
+		reason := loaderAnomaly(baseline, stats, *l.config.AnomalyItemsDrop, *l.config.AnomalyBytesDrop)
+		if reason != "" {
+			stats.Anomaly = true
+			stats.Skipped = *l.config.AnomalySkip
+
+			l.logger.Warn("Anomaly detected", "rule", ruleName, "reason", reason, "items", stats.Items,
+				"previousItems", baseline.Items, "bytes", stats.Bytes, "previousBytes", baseline.Bytes,
+				"skipped", stats.Skipped)
+		}
+	}
+	l.state.stats[ruleName] = stats
+	if !stats.Skipped {
+		l.state.baselines[ruleName] = stats
+	}
+	l.state.muStats.Unlock()
+
+	l.logger.Debug("Rule executed", "rule", ruleName, "items", stats.Items, "bytes", stats.Bytes,
+		"anomaly", stats.Anomaly)
+
+	if stats.Skipped {
+		return errors.New("anomaly detected")
+	}
+
+	if err := store.Commit(); err != nil {
+		l.logger.Error("Failed to store resources", "rule", ruleName, "err", err)
+		return err
 	}
 
 	return nil
 }
 
 // execute loads all resources data.
+//
+// A rule throttled by an upstream is skipped by the next executions and executed alone once the delay given by the
+// upstream is elapsed.
 func (l *loader) execute(stop <-chan struct{}) {
 	startup := true
 	var delay time.Duration
@@ -236,12 +401,18 @@ func (l *loader) execute(stop <-chan struct{}) {
 		delay = time.Duration(*l.config.ExecInterval) * time.Second
 	}
 	ticker := time.NewTicker(delay)
+	retry := time.NewTimer(time.Hour)
+	retry.Stop()
 
 	go func() {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		worker := func(ctx context.Context, jobs <-chan string, results chan<- error) {
+		if l.state.hashes == nil {
+			l.state.hashes = make(map[string][sha256.Size]byte)
+		}
+
+		worker := func(ctx context.Context, store core.Store, jobs <-chan string, results chan<- error) {
 			for ruleName := range jobs {
 				parser, ok := l.state.parsers[ruleName]
 				if !ok {
@@ -250,15 +421,118 @@ func (l *loader) execute(stop <-chan struct{}) {
 					results <- err
 					continue
 				}
-				if err := parser.Parse(ctx, l.state.store, l.state.fetcher); err != nil {
-					l.logger.Error("Execution error", "rule", ruleName, "err", err)
+				ruleCtx := throttle.NewContext(ctx)
+				ruleStore := newLoaderRuleStore(store)
+				if err := parser.Parse(ruleCtx, ruleStore, l.state.fetcher); err != nil {
+					if until, ok := throttle.Until(ruleCtx); ok {
+						l.throttle(ruleName, until)
+						l.logger.Warn("Execution throttled", "rule", ruleName, "retryAt", until, "err", err)
+						err = errLoaderThrottled
+					} else {
+						l.logger.Error("Execution error", "rule", ruleName, "err", err)
+					}
+					if err := ruleStore.Commit(); err != nil {
+						l.logger.Error("Failed to store resources", "rule", ruleName, "err", err)
+					}
 					results <- err
 					continue
 				}
-				results <- nil
+				results <- l.commit(ruleName, ruleStore)
 			}
 		}
 
+		run := func(rules []string) bool {
+			startTime := time.Now()
+
+			rulesCount := len(rules)
+			jobs := make(chan string, rulesCount)
+			results := make(chan error, rulesCount)
+			store := newLoaderStore(l.state.store, l.state.hashes)
+
+			for w := 1; w <= *l.config.ExecWorkers; w++ {
+				go worker(ctx, store, jobs, results)
+			}
+
+			ops := 0
+
+			for _, ruleName := range rules {
+				ops += 1
+
+				if *l.config.ExecMaxOps > 0 && ops > *l.config.ExecMaxOps {
+					l.logger.Warn("Max operations per execution reached, delaying execution", "delay", l.config.ExecMaxDelay)
+
+					time.Sleep(time.Duration(*l.config.ExecMaxDelay) * time.Second)
+					ops = 1
+				}
+
+				jobs <- ruleName
+			}
+
+			close(jobs)
+
+			success := 0
+			failure := 0
+			throttled := 0
+
+			for job := 1; job <= rulesCount; job++ {
+				select {
+				case <-stop:
+					return false
+				case <-ctx.Done():
+					return false
+				case err := <-results:
+					if errors.Is(err, errLoaderThrottled) {
+						throttled += 1
+					} else if err != nil {
+						failure += 1
+					} else {
+						success += 1
+					}
+				}
+			}
+
+			l.logger.Info("Execution done", "total", rulesCount, "success", success, "failure", failure,
+				"throttled", throttled, "duration", time.Since(startTime).Round(time.Second))
+
+			if changes := store.Changes(); len(changes) > 0 {
+				l.logger.Info("Resources changed", "count", len(changes))
+
+				l.notify(changes)
+			}
+			if success == rulesCount && rulesCount == len(l.config.Rules) {
+				store.Prune()
+			}
+
+			if failure > 0 && !l.state.failsafe && *l.config.ExecFailsafeInterval > 0 {
+				l.logger.Warn("Last execution failed, enabling failsafe mode")
+
+				ticker.Reset(time.Duration(*l.config.ExecFailsafeInterval) * time.Second)
+				l.state.failsafe = true
+			}
+			if failure == 0 && l.state.failsafe {
+				l.logger.Warn("Last execution succeeded, disabling failsafe mode")
+
+				if *l.config.ExecInterval > 0 {
+					ticker.Reset(time.Duration(*l.config.ExecInterval) * time.Second)
+				} else {
+					ticker.Stop()
+				}
+				l.state.failsafe = false
+			}
+
+			if !retry.Stop() {
+				select {
+				case <-retry.C:
+				default:
+				}
+			}
+			if next, ok := l.nextThrottled(); ok {
+				retry.Reset(time.Until(next))
+			}
+
+			return true
+		}
+
 	loop:
 		for {
 			select {
@@ -267,8 +541,6 @@ func (l *loader) execute(stop <-chan struct{}) {
 				break loop
 
 			case <-ticker.C:
-				startTime := time.Now()
-
 				l.logger.Debug("Starting new execution")
 
 				if startup {
@@ -280,77 +552,179 @@ func (l *loader) execute(stop <-chan struct{}) {
 					}
 				}
 
-				rulesCount := len(l.config.Rules)
-				jobs := make(chan string, rulesCount)
-				results := make(chan error, rulesCount)
+				rules := make([]string, 0, len(l.config.Rules))
+				for ruleName := range l.config.Rules {
+					rules = append(rules, ruleName)
+				}
+				if !run(l.unthrottled(rules, time.Now())) {
+					break loop
+				}
 
-				for w := 1; w <= *l.config.ExecWorkers; w++ {
-					go worker(ctx, jobs, results)
+			case <-retry.C:
+				rules := l.unthrottled(nil, time.Now())
+				if len(rules) == 0 {
+					continue
 				}
 
-				ops := 0
+				l.logger.Debug("Starting throttled rules execution", "rules", rules)
 
-				for ruleName := range l.config.Rules {
-					ops += 1
+				if !run(rules) {
+					break loop
+				}
+			}
+		}
 
-					if *l.config.ExecMaxOps > 0 && ops > *l.config.ExecMaxOps {
-						l.logger.Warn("Max operations per execution reached, delaying execution", "delay", l.config.ExecMaxDelay)
+		ticker.Stop()
+		retry.Stop()
+	}()
+}
 
-						time.Sleep(time.Duration(*l.config.ExecMaxDelay) * time.Second)
-						ops = 1
-					}
+// throttle records that the given rule is throttled until the given time.
+func (l *loader) throttle(ruleName string, until time.Time) {
+	l.state.muStats.Lock()
+	defer l.state.muStats.Unlock()
 
-					jobs <- ruleName
-				}
+	if l.state.throttled == nil {
+		l.state.throttled = make(map[string]time.Time)
+	}
+	l.state.throttled[ruleName] = until
 
-				close(jobs)
-
-				success := 0
-				failure := 0
-
-				for job := 1; job <= rulesCount; job++ {
-					select {
-					case <-stop:
-						break loop
-					case <-ctx.Done():
-						break loop
-					case err := <-results:
-						if err != nil {
-							failure += 1
-						} else {
-							success += 1
-						}
-					}
-				}
+	if l.state.stats == nil {
+		l.state.stats = make(map[string]loaderRuleStats)
+	}
+	stats := l.state.stats[ruleName]
+	stats.Throttled = true
+	stats.RetryAt = until
+	l.state.stats[ruleName] = stats
+}
 
-				l.logger.Info("Execution done", "total", rulesCount, "success", success, "failure", failure,
-					"duration", time.Since(startTime).Round(time.Second))
+// unthrottled returns the given rules not throttled at the given time, followed by the throttled rules not given whose
+// delay is elapsed. These rules are no longer throttled.
+func (l *loader) unthrottled(rules []string, now time.Time) []string {
+	l.state.muStats.Lock()
+	defer l.state.muStats.Unlock()
+
+	var result []string
+	given := make(map[string]struct{}, len(rules))
+	for _, ruleName := range rules {
+		given[ruleName] = struct{}{}
+		if until, ok := l.state.throttled[ruleName]; ok && until.After(now) {
+			l.logger.Debug("Rule throttled, skipping execution", "rule", ruleName, "retryAt", until)
+			continue
+		}
+		delete(l.state.throttled, ruleName)
+		result = append(result, ruleName)
+	}
+	for ruleName, until := range l.state.throttled {
+		if _, ok := given[ruleName]; ok || until.After(now) {
+			continue
+		}
+		delete(l.state.throttled, ruleName)
+		result = append(result, ruleName)
+	}
 
-				if failure > 0 && !l.state.failsafe && *l.config.ExecFailsafeInterval > 0 {
-					l.logger.Warn("Last execution failed, enabling failsafe mode")
+	return result
+}
 
-					ticker.Reset(time.Duration(*l.config.ExecFailsafeInterval) * time.Second)
-					l.state.failsafe = true
-				}
-				if failure == 0 && l.state.failsafe {
-					l.logger.Warn("Last execution succeeded, disabling failsafe mode")
+// nextThrottled returns the earliest time until which a rule is throttled, or false if no rule is throttled.
+func (l *loader) nextThrottled() (time.Time, bool) {
+	l.state.muStats.RLock()
+	defer l.state.muStats.RUnlock()
 
-					if *l.config.ExecInterval > 0 {
-						ticker.Reset(time.Duration(*l.config.ExecInterval) * time.Second)
-					} else {
-						ticker.Stop()
-					}
-					l.state.failsafe = false
-				}
-			}
+	var next time.Time
+	for _, until := range l.state.throttled {
+		if next.IsZero() || until.Before(next) {
+			next = until
 		}
+	}
 
-		ticker.Stop()
-	}()
+	return next, !next.IsZero()
 }
 
 var _ Loader = (*loader)(nil)
 
+// loaderStore implements a store tracking the changes of the stored resources.
+type loaderStore struct {
+	store   core.Store
+	hashes  map[string][sha256.Size]byte
+	changes map[string]struct{}
+	stored  map[string]struct{}
+	mu      sync.Mutex
+}
+
+// newLoaderStore creates a new store tracking the changes with the given hashes.
+func newLoaderStore(store core.Store, hashes map[string][sha256.Size]byte) *loaderStore {
+	return &loaderStore{
+		store:   store,
+		hashes:  hashes,
+		changes: make(map[string]struct{}),
+		stored:  make(map[string]struct{}),
+	}
+}
+
+// LoadResource loads a resource.
+func (s *loaderStore) LoadResource(name string) (*core.Resource, error) {
+	return s.store.LoadResource(name)
+}
+
+// StoreResource stores a resource and records it as changed if its data
+// differs from the previous stored data.
+func (s *loaderStore) StoreResource(name string, resource *core.Resource) error {
+	if err := s.store.StoreResource(name, resource); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	for _, data := range resource.Data {
+		_ = binary.Write(hash, binary.BigEndian, uint64(len(data)))
+		hash.Write(data)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.hashes[name]; !ok || previous != sum {
+		s.hashes[name] = sum
+		s.changes[name] = struct{}{}
+	}
+	s.stored[name] = struct{}{}
+
+	return nil
+}
+
+// Prune deletes the hashes of the resources which have not been stored.
+//
+// It is called after an execution of all the rules without error, so that the hashes of the resources no longer
+// loaded are deleted with them instead of being kept forever.
+func (s *loaderStore) Prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.hashes {
+		if _, ok := s.stored[name]; !ok {
+			delete(s.hashes, name)
+		}
+	}
+}
+
+// Changes returns the sorted names of the changed resources.
+func (s *loaderStore) Changes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.changes))
+	for name := range s.changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+var _ core.Store = (*loaderStore)(nil)
+
 // loaderMediator implements the loader mediator.
 type loaderMediator struct {
 	loader *loader
@@ -363,4 +737,9 @@ func newLoaderMediator(loader *loader) *loaderMediator {
 	}
 }
 
+// Subscribe registers a function called with the names of the changed resources.
+func (m *loaderMediator) Subscribe(fn func(names []string)) {
+	m.loader.Subscribe(fn)
+}
+
 var _ core.Loader = (*loaderMediator)(nil)
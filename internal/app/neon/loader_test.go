@@ -1,15 +1,29 @@
 package neon
 
 import (
+	"context"
+	"crypto/sha256"
 	"log/slog"
+	"reflect"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/core"
 )
 
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func intPtr(i int) *int {
 	return &i
 }
 
+func stringPtr(s string) *string {
+	return &s
+}
+
 func TestLoaderInit(t *testing.T) {
 	type fields struct {
 		config *loaderConfig
@@ -55,6 +69,9 @@ func TestLoaderInit(t *testing.T) {
 					"execWorkers":          1,
 					"execMaxOps":           100,
 					"execMaxDelay":         1,
+					"anomalyItemsDrop":     50,
+					"anomalyBytesDrop":     50,
+					"anomalySkip":          true,
 					"rules": map[string]interface{}{
 						"test": map[string]interface{}{},
 					},
@@ -74,6 +91,8 @@ func TestLoaderInit(t *testing.T) {
 					"execWorkers":          -1,
 					"execMaxOps":           -1,
 					"execMaxDelay":         -1,
+					"anomalyItemsDrop":     101,
+					"anomalyBytesDrop":     -1,
 				},
 			},
 			wantErr: true,
@@ -183,11 +202,12 @@ func TestLoaderStart(t *testing.T) {
 
 func TestLoaderStop(t *testing.T) {
 	type fields struct {
-		config *loaderConfig
-		logger *slog.Logger
-		state  *loaderState
-		mu     *sync.RWMutex
-		stop   chan struct{}
+		config  *loaderConfig
+		logger  *slog.Logger
+		state   *loaderState
+		mu      *sync.RWMutex
+		stop    chan struct{}
+		started bool
 	}
 	tests := []struct {
 		name    string
@@ -202,10 +222,11 @@ func TestLoaderStop(t *testing.T) {
 					ExecInterval:         intPtr(loaderConfigDefaultExecInterval),
 					ExecFailsafeInterval: intPtr(loaderConfigDefaultExecFailsafeInterval),
 				},
-				logger: slog.Default(),
-				state:  &loaderState{},
-				mu:     &sync.RWMutex{},
-				stop:   make(chan struct{}, 1),
+				logger:  slog.Default(),
+				state:   &loaderState{},
+				mu:      &sync.RWMutex{},
+				stop:    make(chan struct{}, 1),
+				started: true,
 			},
 		},
 		{
@@ -223,6 +244,52 @@ func TestLoaderStop(t *testing.T) {
 			},
 		},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &loader{
+				config:  tt.fields.config,
+				logger:  tt.fields.logger,
+				state:   tt.fields.state,
+				mu:      tt.fields.mu,
+				stop:    tt.fields.stop,
+				started: tt.fields.started,
+			}
+			if err := l.Stop(); (err != nil) != tt.wantErr {
+				t.Errorf("loader.Stop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderSubscribe(t *testing.T) {
+	type fields struct {
+		config *loaderConfig
+		logger *slog.Logger
+		state  *loaderState
+		mu     *sync.RWMutex
+		stop   chan struct{}
+	}
+	type args struct {
+		names []string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   []string
+	}{
+		{
+			name: "default",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &loaderState{},
+			},
+			args: args{
+				names: []string{"test1", "test2"},
+			},
+			want: []string{"test1", "test2"},
+		},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			l := &loader{
@@ -232,9 +299,185 @@ func TestLoaderStop(t *testing.T) {
 				mu:     tt.fields.mu,
 				stop:   tt.fields.stop,
 			}
-			if err := l.Stop(); (err != nil) != tt.wantErr {
-				t.Errorf("loader.Stop() error = %v, wantErr %v", err, tt.wantErr)
+			var got []string
+			l.Subscribe(func(names []string) {
+				got = names
+			})
+			l.notify(tt.args.names)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loader.Subscribe() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderPull(t *testing.T) {
+	tests := []struct {
+		name    string
+		parsers map[string]core.LoaderParserModule
+		wantErr bool
+	}{
+		{
+			name: "default",
+			parsers: map[string]core.LoaderParserModule{
+				"test1": testLoaderParserModule{},
+				"test2": testLoaderParserModule{},
+			},
+		},
+		{
+			name: "error parse",
+			parsers: map[string]core.LoaderParserModule{
+				"test1": testLoaderParserModule{
+					errParse: true,
+				},
+				"test2": testLoaderParserModule{},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &loader{
+				logger: slog.Default(),
+				state: &loaderState{
+					parsers: tt.parsers,
+				},
+			}
+			if err := l.Pull(context.Background(), nil); (err != nil) != tt.wantErr {
+				t.Errorf("loader.Pull() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestLoaderThrottle(t *testing.T) {
+	l := &loader{
+		logger: slog.Default(),
+		state:  &loaderState{},
+	}
+	now := time.Now()
+	l.throttle("test1", now.Add(time.Minute))
+	l.throttle("test2", now.Add(time.Second))
+
+	if got := l.Stats()["test1"]; !got.Throttled || !got.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("loader.Stats() = %+v", got)
+	}
+	if got, ok := l.nextThrottled(); !ok || !got.Equal(now.Add(time.Second)) {
+		t.Errorf("loader.nextThrottled() = %v, %v, want %v, %v", got, ok, now.Add(time.Second), true)
+	}
+	if got := l.unthrottled([]string{"test1", "test2", "test3"}, now); !reflect.DeepEqual(got, []string{"test3"}) {
+		t.Errorf("loader.unthrottled() = %v, want %v", got, []string{"test3"})
+	}
+	if got := l.unthrottled(nil, now.Add(2*time.Second)); !reflect.DeepEqual(got, []string{"test2"}) {
+		t.Errorf("loader.unthrottled() = %v, want %v", got, []string{"test2"})
+	}
+	if got := l.unthrottled([]string{"test1"}, now.Add(2*time.Minute)); !reflect.DeepEqual(got, []string{"test1"}) {
+		t.Errorf("loader.unthrottled() = %v, want %v", got, []string{"test1"})
+	}
+	if _, ok := l.nextThrottled(); ok {
+		t.Errorf("loader.nextThrottled() = %v, want %v", ok, false)
+	}
+}
+
+func TestLoaderStoreStoreResource(t *testing.T) {
+	type fields struct {
+		store  core.Store
+		hashes map[string][sha256.Size]byte
+	}
+	type args struct {
+		resources map[string]*core.Resource
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "new resources",
+			fields: fields{
+				store:  testStoreStorageModule{},
+				hashes: map[string][sha256.Size]byte{},
+			},
+			args: args{
+				resources: map[string]*core.Resource{
+					"test1": {Data: [][]byte{[]byte("test1")}},
+					"test2": {Data: [][]byte{[]byte("test2")}},
+				},
+			},
+			want: []string{"test1", "test2"},
+		},
+		{
+			name: "unchanged resources",
+			fields: fields{
+				store: testStoreStorageModule{},
+				hashes: map[string][sha256.Size]byte{
+					"test1": testLoaderResourceHash(t, [][]byte{[]byte("test1")}),
+					"test2": testLoaderResourceHash(t, [][]byte{[]byte("test2")}),
+				},
+			},
+			args: args{
+				resources: map[string]*core.Resource{
+					"test1": {Data: [][]byte{[]byte("test1")}},
+					"test2": {Data: [][]byte{[]byte("changed")}},
+				},
+			},
+			want: []string{"test2"},
+		},
+		{
+			name: "error store resource",
+			fields: fields{
+				store: testStoreStorageModule{
+					errStoreResource: true,
+				},
+				hashes: map[string][sha256.Size]byte{},
+			},
+			args: args{
+				resources: map[string]*core.Resource{
+					"test": {Data: [][]byte{[]byte("test")}},
+				},
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLoaderStore(tt.fields.store, tt.fields.hashes)
+			for name, resource := range tt.args.resources {
+				if err := s.StoreResource(name, resource); (err != nil) != tt.wantErr {
+					t.Errorf("loaderStore.StoreResource() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if got := s.Changes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loaderStore.Changes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderStorePrune(t *testing.T) {
+	hashes := map[string][sha256.Size]byte{
+		"test1": testLoaderResourceHash(t, [][]byte{[]byte("test1")}),
+		"test2": testLoaderResourceHash(t, [][]byte{[]byte("test2")}),
+	}
+
+	s := newLoaderStore(testStoreStorageModule{}, hashes)
+	if err := s.StoreResource("test1", &core.Resource{Data: [][]byte{[]byte("test1")}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Prune()
+
+	if _, ok := hashes["test1"]; !ok || len(hashes) != 1 {
+		t.Errorf("loaderStore.Prune() hashes = %v", hashes)
+	}
+}
+
+func testLoaderResourceHash(t *testing.T, data [][]byte) [sha256.Size]byte {
+	s := newLoaderStore(testStoreStorageModule{}, map[string][sha256.Size]byte{})
+	if err := s.StoreResource("test", &core.Resource{Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	return s.hashes["test"]
+}
@@ -0,0 +1,123 @@
+package neon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+// loaderRuleStats implements the statistics of the last execution of a rule.
+type loaderRuleStats struct {
+	Items     int
+	Bytes     int
+	Anomaly   bool
+	Skipped   bool
+	Throttled bool
+	RetryAt   time.Time
+}
+
+// loaderRuleStore implements a store staging the resources of a rule until they are committed.
+type loaderRuleStore struct {
+	store     core.Store
+	names     []string
+	resources map[string]*core.Resource
+	bytes     int
+	mu        sync.Mutex
+}
+
+// newLoaderRuleStore creates a new store staging the resources to commit into the given store.
+func newLoaderRuleStore(store core.Store) *loaderRuleStore {
+	return &loaderRuleStore{
+		store:     store,
+		resources: make(map[string]*core.Resource),
+	}
+}
+
+// LoadResource loads a resource, staged or previously stored.
+func (s *loaderRuleStore) LoadResource(name string) (*core.Resource, error) {
+	s.mu.Lock()
+	resource, ok := s.resources[name]
+	s.mu.Unlock()
+	if ok {
+		return resource, nil
+	}
+	return s.store.LoadResource(name)
+}
+
+// StoreResource stages a resource.
+func (s *loaderRuleStore) StoreResource(name string, resource *core.Resource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.resources[name]; ok {
+		s.bytes -= loaderResourceSize(previous)
+	} else {
+		s.names = append(s.names, name)
+	}
+	s.resources[name] = resource
+	s.bytes += loaderResourceSize(resource)
+
+	return nil
+}
+
+// Stats returns the number of items and the payload size of the staged resources.
+func (s *loaderRuleStore) Stats() loaderRuleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return loaderRuleStats{
+		Items: len(s.names),
+		Bytes: s.bytes,
+	}
+}
+
+// Commit stores the staged resources.
+func (s *loaderRuleStore) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, name := range s.names {
+		if err := s.store.StoreResource(name, s.resources[name]); err != nil {
+			errs = append(errs, fmt.Errorf("store resource %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+var _ core.Store = (*loaderRuleStore)(nil)
+
+// loaderResourceSize returns the payload size of a resource.
+func loaderResourceSize(resource *core.Resource) int {
+	var size int
+	for _, data := range resource.Data {
+		size += len(data)
+	}
+	return size
+}
+
+// loaderAnomaly returns the reason of the anomaly between the statistics of the last committed execution and the
+// current one, or an empty string if the drops are below the given percentages.
+//
+// A zero percentage disables the corresponding check.
+func loaderAnomaly(previous loaderRuleStats, current loaderRuleStats, itemsDrop int, bytesDrop int) string {
+	drop := func(previous int, current int) int {
+		if previous <= 0 || current >= previous {
+			return 0
+		}
+		return (previous - current) * 100 / previous
+	}
+
+	if itemsDrop > 0 && drop(previous.Items, current.Items) > itemsDrop {
+		return "items count drop"
+	}
+	if bytesDrop > 0 && drop(previous.Bytes, current.Bytes) > bytesDrop {
+		return "payload size drop"
+	}
+
+	return ""
+}
@@ -0,0 +1,237 @@
+package neon
+
+import (
+	"crypto/sha256"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+func TestLoaderRuleStore(t *testing.T) {
+	tests := []struct {
+		name          string
+		store         core.Store
+		resources     map[string]*core.Resource
+		wantStats     loaderRuleStats
+		wantChanges   []string
+		wantCommitErr bool
+	}{
+		{
+			name:  "default",
+			store: testStoreStorageModule{},
+			resources: map[string]*core.Resource{
+				"test1": {Data: [][]byte{[]byte("test1")}},
+				"test2": {Data: [][]byte{[]byte("test"), []byte("2")}},
+			},
+			wantStats: loaderRuleStats{
+				Items: 2,
+				Bytes: 10,
+			},
+			wantChanges: []string{"test1", "test2"},
+		},
+		{
+			name: "error store resource",
+			store: testStoreStorageModule{
+				errStoreResource: true,
+			},
+			resources: map[string]*core.Resource{
+				"test": {Data: [][]byte{[]byte("test")}},
+			},
+			wantStats: loaderRuleStats{
+				Items: 1,
+				Bytes: 4,
+			},
+			wantChanges:   []string{},
+			wantCommitErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newLoaderStore(tt.store, map[string][sha256.Size]byte{})
+			s := newLoaderRuleStore(store)
+			for name, resource := range tt.resources {
+				if err := s.StoreResource(name, resource); err != nil {
+					t.Errorf("loaderRuleStore.StoreResource() error = %v", err)
+				}
+			}
+			for name, resource := range tt.resources {
+				if got, err := s.LoadResource(name); err != nil || got != resource {
+					t.Errorf("loaderRuleStore.LoadResource() = %v, err %v, want %v", got, err, resource)
+				}
+			}
+			if got := store.Changes(); len(got) != 0 {
+				t.Errorf("loaderStore.Changes() before commit = %v, want none", got)
+			}
+			if got := s.Stats(); !reflect.DeepEqual(got, tt.wantStats) {
+				t.Errorf("loaderRuleStore.Stats() = %v, want %v", got, tt.wantStats)
+			}
+			if err := s.Commit(); (err != nil) != tt.wantCommitErr {
+				t.Errorf("loaderRuleStore.Commit() error = %v, wantErr %v", err, tt.wantCommitErr)
+			}
+			if got := store.Changes(); !reflect.DeepEqual(got, tt.wantChanges) {
+				t.Errorf("loaderStore.Changes() = %v, want %v", got, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestLoaderRuleStoreReplace(t *testing.T) {
+	s := newLoaderRuleStore(testStoreStorageModule{})
+	_ = s.StoreResource("test", &core.Resource{Data: [][]byte{[]byte("test")}})
+	_ = s.StoreResource("test", &core.Resource{Data: [][]byte{[]byte("replaced")}})
+
+	want := loaderRuleStats{
+		Items: 1,
+		Bytes: 8,
+	}
+	if got := s.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaderRuleStore.Stats() = %v, want %v", got, want)
+	}
+}
+
+func TestLoaderAnomaly(t *testing.T) {
+	type args struct {
+		previous  loaderRuleStats
+		current   loaderRuleStats
+		itemsDrop int
+		bytesDrop int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "disabled",
+			args: args{
+				previous: loaderRuleStats{Items: 100, Bytes: 1000},
+				current:  loaderRuleStats{Items: 1, Bytes: 10},
+			},
+		},
+		{
+			name: "below thresholds",
+			args: args{
+				previous:  loaderRuleStats{Items: 100, Bytes: 1000},
+				current:   loaderRuleStats{Items: 50, Bytes: 500},
+				itemsDrop: 50,
+				bytesDrop: 50,
+			},
+		},
+		{
+			name: "items count drop",
+			args: args{
+				previous:  loaderRuleStats{Items: 100, Bytes: 1000},
+				current:   loaderRuleStats{Items: 49, Bytes: 1000},
+				itemsDrop: 50,
+				bytesDrop: 50,
+			},
+			want: "items count drop",
+		},
+		{
+			name: "payload size drop",
+			args: args{
+				previous:  loaderRuleStats{Items: 100, Bytes: 1000},
+				current:   loaderRuleStats{Items: 100, Bytes: 100},
+				itemsDrop: 50,
+				bytesDrop: 50,
+			},
+			want: "payload size drop",
+		},
+		{
+			name: "increase",
+			args: args{
+				previous:  loaderRuleStats{Items: 10, Bytes: 100},
+				current:   loaderRuleStats{Items: 100, Bytes: 1000},
+				itemsDrop: 50,
+				bytesDrop: 50,
+			},
+		},
+		{
+			name: "no previous items",
+			args: args{
+				current:   loaderRuleStats{Items: 0, Bytes: 0},
+				itemsDrop: 50,
+				bytesDrop: 50,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loaderAnomaly(tt.args.previous, tt.args.current, tt.args.itemsDrop, tt.args.bytesDrop)
+			if got != tt.want {
+				t.Errorf("loaderAnomaly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderCommit(t *testing.T) {
+	tests := []struct {
+		name        string
+		skip        bool
+		wantErr     bool
+		wantStats   loaderRuleStats
+		wantChanges []string
+	}{
+		{
+			name: "anomaly",
+			wantStats: loaderRuleStats{
+				Items:   1,
+				Bytes:   4,
+				Anomaly: true,
+			},
+			wantChanges: []string{"test1"},
+		},
+		{
+			name:    "anomaly skipped",
+			skip:    true,
+			wantErr: true,
+			wantStats: loaderRuleStats{
+				Items:   1,
+				Bytes:   4,
+				Anomaly: true,
+				Skipped: true,
+			},
+			wantChanges: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &loader{
+				config: &loaderConfig{
+					AnomalyItemsDrop: intPtr(25),
+					AnomalyBytesDrop: intPtr(0),
+					AnomalySkip:      &tt.skip,
+				},
+				logger: slog.Default(),
+				state: &loaderState{
+					baselines: map[string]loaderRuleStats{
+						"test": {Items: 2, Bytes: 8},
+					},
+				},
+			}
+			store := newLoaderStore(testStoreStorageModule{}, map[string][sha256.Size]byte{})
+			ruleStore := newLoaderRuleStore(store)
+			_ = ruleStore.StoreResource("test1", &core.Resource{Data: [][]byte{[]byte("test")}})
+
+			if err := l.commit("test", ruleStore); (err != nil) != tt.wantErr {
+				t.Errorf("loader.commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := l.Stats()["test"]; !reflect.DeepEqual(got, tt.wantStats) {
+				t.Errorf("loader.Stats() = %v, want %v", got, tt.wantStats)
+			}
+			if got := store.Changes(); !reflect.DeepEqual(got, tt.wantChanges) {
+				t.Errorf("loaderStore.Changes() = %v, want %v", got, tt.wantChanges)
+			}
+			wantBaseline := loaderRuleStats{Items: 2, Bytes: 8}
+			if !tt.skip {
+				wantBaseline = loaderRuleStats{Items: 1, Bytes: 4, Anomaly: true}
+			}
+			if got := l.state.baselines["test"]; !reflect.DeepEqual(got, wantBaseline) {
+				t.Errorf("loader baseline = %v, want %v", got, wantBaseline)
+			}
+		})
+	}
+}
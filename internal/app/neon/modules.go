@@ -9,18 +9,22 @@ import (
 	_ "github.com/bhuisgen/neon/pkg/modules/app/loader/parsers/json"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/loader/parsers/raw"
 
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/listener/listeners/embedded"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/listener/listeners/local"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/listener/listeners/redirect"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/listener/listeners/tls"
 
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/alert"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/compress"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/header"
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/include"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/logger"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/rewrite"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/static"
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/middlewares/useragent"
 
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/file"
-	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/js"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/robots"
 	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/sitemap"
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/status"
 )
@@ -0,0 +1,7 @@
+//go:build cgo && !nojs
+
+package neon
+
+import (
+	_ "github.com/bhuisgen/neon/pkg/modules/app/server/site/handlers/js"
+)
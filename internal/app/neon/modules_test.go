@@ -3,6 +3,7 @@ package neon
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"os"
 	"testing"
@@ -56,7 +57,24 @@ func (m testStoreStorageModule) StoreResource(name string, resource *core.Resour
 	return nil
 }
 
+func (m testStoreStorageModule) Export(w io.Writer) error {
+	_, err := w.Write([]byte("test"))
+	return err
+}
+
+func (m testStoreStorageModule) Import(r io.Reader) error {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if string(buf) != "test" {
+		return errors.New("test error")
+	}
+	return nil
+}
+
 var _ core.StoreStorageModule = (*testStoreStorageModule)(nil)
+var _ core.StoreStorageSnapshotModule = (*testStoreStorageModule)(nil)
 
 type testFetcherProviderModule struct {
 	errInit  bool
@@ -3,6 +3,7 @@ package neon
 import (
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
@@ -15,15 +16,30 @@ func init() {
 
 // New creates a new instance.
 func New(config *config) App {
-	if _, ok := os.LookupEnv("DEBUG"); ok {
+	if v, ok := os.LookupEnv("LOG_LEVEL"); ok {
+		level, err := log.ParseLevel(v)
+		if err != nil {
+			log.Fatalf("Invalid log level: %v", err)
+		}
+		log.SetDefaultLevel(level)
+	}
+	if v, ok := os.LookupEnv("DEBUG"); ok {
 		DEBUG = true
+		// a duration value limits the debug mode, which is then not left enabled forever
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			DEBUG_UNTIL = time.Now().Add(d)
+		}
 	}
 	if v, ok := os.LookupEnv("CHILD_SOCKET"); ok {
 		CHILD_SOCKET = v
 	}
 
 	if DEBUG {
-		log.ProgramLevel.Set(slog.LevelDebug)
+		var ttl time.Duration
+		if !DEBUG_UNTIL.IsZero() {
+			ttl = time.Until(DEBUG_UNTIL)
+		}
+		log.SetLevel(slog.LevelDebug, ttl)
 	}
 
 	appModuleInfo, err := module.Lookup("app")
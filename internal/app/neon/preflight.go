@@ -0,0 +1,57 @@
+package neon
+
+import (
+	"context"
+	"fmt"
+)
+
+// preflight checks the upstream resources and the sites environment before the listeners are opened.
+//
+// All failures are reported at once and they abort the startup only in strict mode.
+func (a *app) preflight(strict bool) error {
+	a.logger.Info("Starting preflight checks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), appPreflightTimeout)
+	defer cancel()
+
+	var errs []error
+	errs = append(errs, preflightErrors("loader", a.state.loader.Preflight(ctx))...)
+	errs = append(errs, preflightErrors("server", a.state.server.Preflight(ctx))...)
+
+	if len(errs) == 0 {
+		a.logger.Info("Preflight checks passed")
+		return nil
+	}
+
+	for _, err := range errs {
+		if strict {
+			a.logger.Error("Preflight check failed", "err", err)
+		} else {
+			a.logger.Warn("Preflight check failed", "err", err)
+		}
+	}
+	if strict {
+		return fmt.Errorf("%d check(s) failed", len(errs))
+	}
+
+	return nil
+}
+
+// preflightErrors flattens the given joined errors, prefixing each error.
+func preflightErrors(prefix string, err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{fmt.Errorf("%s: %w", prefix, err)}
+	}
+
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, preflightErrors(prefix, err)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,56 @@
+package neon
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPreflightErrors(t *testing.T) {
+	type args struct {
+		prefix string
+		err    error
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "nil",
+			args: args{
+				prefix: "test",
+			},
+		},
+		{
+			name: "single",
+			args: args{
+				prefix: "test",
+				err:    errors.New("error"),
+			},
+			want: []string{"test: error"},
+		},
+		{
+			name: "joined",
+			args: args{
+				prefix: "test",
+				err: errors.Join(
+					errors.New("error1"),
+					errors.Join(preflightErrors("nested", errors.New("error2"))...),
+				),
+			},
+			want: []string{"test: error1", "test: nested: error2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range preflightErrors(tt.args.prefix, tt.args.err) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preflightErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,13 +6,18 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
 )
 
 // server implements the server.
@@ -24,8 +29,20 @@ type server struct {
 
 // serverConfig implements the server configuration.
 type serverConfig struct {
-	Listeners map[string]map[string]interface{} `mapstructure:"listeners"`
-	Sites     map[string]map[string]interface{} `mapstructure:"sites"`
+	Listeners             map[string]map[string]interface{} `mapstructure:"listeners"`
+	Sites                 map[string]map[string]interface{} `mapstructure:"sites"`
+	MaxConcurrentRequests *int                              `mapstructure:"maxConcurrentRequests"`
+	QueueMode             *string                           `mapstructure:"queueMode"`
+	QueueSize             *int                              `mapstructure:"queueSize"`
+	QueueTimeout          *int                              `mapstructure:"queueTimeout"`
+	RetryAfter            *int                              `mapstructure:"retryAfter"`
+	Priorities            []serverPriorityConfig            `mapstructure:"priorities"`
+}
+
+// serverPriorityConfig implements a server priority rule configuration.
+type serverPriorityConfig struct {
+	Path     string `mapstructure:"path"`
+	Priority string `mapstructure:"priority"`
 }
 
 // serverState implements the server state.
@@ -34,10 +51,24 @@ type serverState struct {
 	sitesMap       map[string]ServerSite
 	sitesListeners map[string][]ServerListener
 	mediator       *serverMediator
+	limiter        *serverLimiter
+	wake           *serverWake
+}
+
+// serverWake implements the hook called in background on the first request received by any listener.
+type serverWake struct {
+	fn   func()
+	once sync.Once
 }
 
 const (
 	serverModuleID module.ModuleID = "app.server"
+
+	serverConfigDefaultMaxConcurrentRequests int    = 0
+	serverConfigDefaultQueueMode             string = serverLimiterQueueModeFIFO
+	serverConfigDefaultQueueSize             int    = 100
+	serverConfigDefaultQueueTimeout          int    = 1000
+	serverConfigDefaultRetryAfter            int    = 1
 )
 
 // ModuleInfo returns the module information.
@@ -75,12 +106,80 @@ func (s *server) Init(config map[string]interface{}) error {
 
 	var errConfig bool
 
+	if s.config.MaxConcurrentRequests == nil {
+		defaultValue := serverConfigDefaultMaxConcurrentRequests
+		s.config.MaxConcurrentRequests = &defaultValue
+	}
+	if *s.config.MaxConcurrentRequests < 0 {
+		s.logger.Error("Invalid value", "option", "MaxConcurrentRequests", "value", *s.config.MaxConcurrentRequests)
+		errConfig = true
+	}
+	if s.config.QueueMode == nil {
+		defaultValue := serverConfigDefaultQueueMode
+		s.config.QueueMode = &defaultValue
+	}
+	if *s.config.QueueMode != serverLimiterQueueModeFIFO && *s.config.QueueMode != serverLimiterQueueModeLIFO {
+		s.logger.Error("Invalid value", "option", "QueueMode", "value", *s.config.QueueMode)
+		errConfig = true
+	}
+	if s.config.QueueSize == nil {
+		defaultValue := serverConfigDefaultQueueSize
+		s.config.QueueSize = &defaultValue
+	}
+	if *s.config.QueueSize < 0 {
+		s.logger.Error("Invalid value", "option", "QueueSize", "value", *s.config.QueueSize)
+		errConfig = true
+	}
+	if s.config.QueueTimeout == nil {
+		defaultValue := serverConfigDefaultQueueTimeout
+		s.config.QueueTimeout = &defaultValue
+	}
+	if *s.config.QueueTimeout < 0 {
+		s.logger.Error("Invalid value", "option", "QueueTimeout", "value", *s.config.QueueTimeout)
+		errConfig = true
+	}
+	if s.config.RetryAfter == nil {
+		defaultValue := serverConfigDefaultRetryAfter
+		s.config.RetryAfter = &defaultValue
+	}
+	if *s.config.RetryAfter < 0 {
+		s.logger.Error("Invalid value", "option", "RetryAfter", "value", *s.config.RetryAfter)
+		errConfig = true
+	}
+	var rules []serverLimiterRule
+	for index, rule := range s.config.Priorities {
+		re, err := pattern.Compile(rule.Path)
+		if err != nil {
+			s.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path,
+				"err", err)
+			errConfig = true
+			continue
+		}
+		class := priority.Class(rule.Priority)
+		if class != priority.High && class != priority.Low {
+			s.logger.Error("Invalid value", "rule", index+1, "option", "Priority", "value", rule.Priority)
+			errConfig = true
+			continue
+		}
+		rules = append(rules, serverLimiterRule{regexp: re, class: class})
+	}
+	if !errConfig && *s.config.MaxConcurrentRequests > 0 {
+		s.state.limiter = newServerLimiter(s.logger, *s.config.MaxConcurrentRequests, *s.config.QueueSize,
+			*s.config.QueueMode, time.Duration(*s.config.QueueTimeout)*time.Millisecond, *s.config.RetryAfter)
+		s.state.limiter.setRules(rules)
+	}
+
 	if len(s.config.Listeners) == 0 {
 		s.logger.Error("No listener defined")
 		errConfig = true
 	}
+	if s.state.wake == nil {
+		s.state.wake = &serverWake{}
+	}
 	for listenerName, listenerConfig := range s.config.Listeners {
 		listener := newServerListener(listenerName, s)
+		listener.limiter = s.state.limiter
+		listener.wake = s.state.wake
 
 		if listenerConfig == nil {
 			listenerConfig = map[string]interface{}{}
@@ -239,6 +338,16 @@ func (s *server) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Preflight checks the environment of all sites.
+func (s *server) Preflight(ctx context.Context) error {
+	var errs []error
+	for siteName, site := range s.state.sitesMap {
+		errs = append(errs, preflightErrors("site "+siteName, site.Preflight(ctx))...)
+	}
+
+	return errors.Join(errs...)
+}
+
 // Listeners returns the network listeners.
 func (s *server) Listeners() (map[string][]net.Listener, error) {
 	m := make(map[string][]net.Listener, len(s.state.listenersMap))
@@ -252,8 +361,59 @@ func (s *server) Listeners() (map[string][]net.Listener, error) {
 	return m, nil
 }
 
+// serverConfigEndpoints returns the URLs of the endpoints of each listener of the given server configuration, without
+// initializing the server.
+func serverConfigEndpoints(config map[string]interface{}) (map[string][]string, error) {
+	var c serverConfig
+	if err := mapstructure.Decode(config, &c); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+
+	m := make(map[string][]string, len(c.Listeners))
+	for name, listenerConfig := range c.Listeners {
+		endpoints, err := serverListenerConfigEndpoints(listenerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %v", name, err)
+		}
+		m[name] = endpoints
+	}
+
+	return m, nil
+}
+
+// Handler returns the handler serving the requests of the given listener.
+func (s *server) Handler(listener string) (http.Handler, error) {
+	l, ok := s.state.listenersMap[listener]
+	if !ok {
+		return nil, fmt.Errorf("listener %s not found", listener)
+	}
+	handler := l.Handler()
+	if handler == nil {
+		return nil, fmt.Errorf("listener %s not ready", listener)
+	}
+
+	return handler, nil
+}
+
+// OnFirstRequest registers the function called in background on the first request, before the server is started.
+func (s *server) OnFirstRequest(fn func()) {
+	if s.state.wake == nil {
+		s.state.wake = &serverWake{}
+	}
+	s.state.wake.fn = fn
+}
+
 var _ Server = (*server)(nil)
 
+// trigger calls the hook function in background the first time only.
+func (w *serverWake) trigger() {
+	w.once.Do(func() {
+		if w.fn != nil {
+			go w.fn()
+		}
+	})
+}
+
 // serverMediator implements the server mediator.
 type serverMediator struct {
 	server *server
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"log/slog"
 	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
 )
@@ -19,6 +21,7 @@ type testServerServerListener struct {
 	errClose     bool
 	errRemove    bool
 	errListeners bool
+	errHandler   bool
 }
 
 func (l testServerServerListener) Init(config map[string]interface{}) error {
@@ -82,15 +85,23 @@ func (l testServerServerListener) Listeners() ([]net.Listener, error) {
 	return nil, nil
 }
 
+func (l testServerServerListener) Handler() http.Handler {
+	if l.errHandler {
+		return nil
+	}
+	return http.NotFoundHandler()
+}
+
 var _ ServerListener = (*testServerServerListener)(nil)
 
 type testServerServerSite struct {
-	name        string
-	hosts       []string
-	errInit     bool
-	errRegister bool
-	errStart    bool
-	errStop     bool
+	name         string
+	hosts        []string
+	errInit      bool
+	errRegister  bool
+	errStart     bool
+	errStop      bool
+	errPreflight bool
 }
 
 func (s testServerServerSite) Init(config map[string]interface{}) error {
@@ -137,6 +148,13 @@ func (s testServerServerSite) Router() (ServerSiteRouter, error) {
 	return nil, nil
 }
 
+func (s testServerServerSite) Preflight(ctx context.Context) error {
+	if s.errPreflight {
+		return errors.New("test error")
+	}
+	return nil
+}
+
 var _ ServerSite = (*testServerServerSite)(nil)
 
 func TestServerInit(t *testing.T) {
@@ -188,6 +206,85 @@ func TestServerInit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "concurrency limit",
+			fields: fields{
+				logger: slog.Default(),
+				state: &serverState{
+					listenersMap: map[string]ServerListener{},
+					sitesMap:     map[string]ServerSite{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners": map[string]interface{}{
+						"default": map[string]interface{}{
+							"test": map[string]interface{}{},
+						},
+					},
+					"sites": map[string]interface{}{
+						"main": map[string]interface{}{
+							"listeners": []string{"default"},
+						},
+					},
+					"maxConcurrentRequests": 100,
+					"queueMode":             "lifo",
+					"queueSize":             10,
+					"queueTimeout":          500,
+					"retryAfter":            5,
+					"priorities": []map[string]interface{}{
+						{
+							"path":     "^/api/",
+							"priority": "high",
+						},
+						{
+							"path":     "^/feed/",
+							"priority": "low",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error invalid concurrency limit values",
+			fields: fields{
+				logger: slog.Default(),
+				state: &serverState{
+					listenersMap: map[string]ServerListener{},
+					sitesMap:     map[string]ServerSite{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners": map[string]interface{}{
+						"default": map[string]interface{}{
+							"test": map[string]interface{}{},
+						},
+					},
+					"sites": map[string]interface{}{
+						"main": map[string]interface{}{
+							"listeners": []string{"default"},
+						},
+					},
+					"maxConcurrentRequests": -1,
+					"queueMode":             "random",
+					"queueSize":             -1,
+					"queueTimeout":          -1,
+					"retryAfter":            -1,
+					"priorities": []map[string]interface{}{
+						{
+							"path":     "(",
+							"priority": "low",
+						},
+						{
+							"path":     "^/feed/",
+							"priority": "medium",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "error no listener",
 			fields: fields{
@@ -650,6 +747,58 @@ func TestServerStop(t *testing.T) {
 	}
 }
 
+func TestServerPreflight(t *testing.T) {
+	type fields struct {
+		config *serverConfig
+		logger *slog.Logger
+		state  *serverState
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				logger: slog.Default(),
+				state: &serverState{
+					sitesMap: map[string]ServerSite{
+						"test": testServerServerSite{},
+					},
+				},
+			},
+		},
+		{
+			name: "error preflight site",
+			fields: fields{
+				logger: slog.Default(),
+				state: &serverState{
+					sitesMap: map[string]ServerSite{
+						"test1": testServerServerSite{},
+						"test2": testServerServerSite{
+							errPreflight: true,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &server{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				state:  tt.fields.state,
+			}
+			if err := s.Preflight(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("server.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestServerShutdown(t *testing.T) {
 	type fields struct {
 		config *serverConfig
@@ -896,6 +1045,100 @@ func TestServerShutdown(t *testing.T) {
 	}
 }
 
+func TestServerHandler(t *testing.T) {
+	type args struct {
+		listener string
+	}
+	tests := []struct {
+		name    string
+		state   *serverState
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			state: &serverState{
+				listenersMap: map[string]ServerListener{
+					"default": testServerServerListener{},
+				},
+			},
+			args: args{
+				listener: "default",
+			},
+		},
+		{
+			name: "error listener not found",
+			state: &serverState{
+				listenersMap: map[string]ServerListener{},
+			},
+			args: args{
+				listener: "default",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error listener not ready",
+			state: &serverState{
+				listenersMap: map[string]ServerListener{
+					"default": testServerServerListener{
+						errHandler: true,
+					},
+				},
+			},
+			args: args{
+				listener: "default",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &server{
+				logger: slog.Default(),
+				state:  tt.state,
+			}
+			got, err := s.Handler(tt.args.listener)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("server.Handler() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got == nil {
+				t.Errorf("server.Handler() = %v, want %v", got, "not nil")
+			}
+		})
+	}
+}
+
+func TestServerOnFirstRequest(t *testing.T) {
+	s := &server{
+		logger: slog.Default(),
+		state:  &serverState{},
+	}
+	called := make(chan struct{}, 2)
+	s.OnFirstRequest(func() {
+		called <- struct{}{}
+	})
+
+	h := &serverListenerHandler{
+		logger: slog.Default(),
+		wake:   s.state.wake,
+	}
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(testServerListenerHandlerResponseWriter{}, &http.Request{})
+	}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("server.OnFirstRequest() function not called")
+	}
+	select {
+	case <-called:
+		t.Error("server.OnFirstRequest() function called twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
 func TestServerMediatorListeners(t *testing.T) {
 	type fields struct {
 		config *serverConfig
@@ -0,0 +1,185 @@
+package neon
+
+import (
+	"container/list"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
+)
+
+// serverLimiter implements the server concurrency limiter.
+//
+// The high priority requests exceeding the concurrency limit wait in a queue for a free slot. They are shed with a
+// 503 status when the queue is full or when the queue timeout expires. The low priority requests are never queued.
+// They are degraded to be served from a stale cache or shed by the handlers if all the modules of their route handle
+// the degraded requests without doing any work, and are shed otherwise.
+type serverLimiter struct {
+	logger       *slog.Logger
+	rules        []serverLimiterRule
+	ruleSet      *pattern.Set
+	max          int
+	queueSize    int
+	queueLIFO    bool
+	queueTimeout time.Duration
+	retryAfter   int
+	active       int
+	queue        *list.List
+	shed         atomic.Uint64
+	degraded     atomic.Uint64
+	mu           sync.Mutex
+}
+
+// serverLimiterRule implements a priority rule.
+type serverLimiterRule struct {
+	regexp *regexp.Regexp
+	class  priority.Class
+}
+
+const (
+	serverLimiterQueueModeFIFO string = "fifo"
+	serverLimiterQueueModeLIFO string = "lifo"
+)
+
+// serverLimiterRouter is the interface of the routers reporting if the route of a request can be degraded.
+type serverLimiterRouter interface {
+	Degradable(r *http.Request) bool
+}
+
+// newServerLimiter creates a new server limiter.
+func newServerLimiter(logger *slog.Logger, max int, queueSize int, queueMode string, queueTimeout time.Duration,
+	retryAfter int) *serverLimiter {
+	return &serverLimiter{
+		logger:       logger,
+		max:          max,
+		queueSize:    queueSize,
+		queueLIFO:    queueMode == serverLimiterQueueModeLIFO,
+		queueTimeout: queueTimeout,
+		retryAfter:   retryAfter,
+		queue:        list.New(),
+	}
+}
+
+// setRules sets the priority rules.
+func (l *serverLimiter) setRules(rules []serverLimiterRule) {
+	regexps := make([]*regexp.Regexp, 0, len(rules))
+	for _, rule := range rules {
+		regexps = append(regexps, rule.regexp)
+	}
+	l.rules = rules
+	l.ruleSet = pattern.NewSet(regexps)
+}
+
+// Serve serves the request with the given handler if a slot is available, or sheds it.
+func (l *serverLimiter) Serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	class := l.class(r)
+
+	if !l.acquire(r, class == priority.High) {
+		if class == priority.Low && l.degradable(r, next) {
+			n := l.degraded.Add(1)
+			l.logger.Debug("Request degraded", "url", r.URL.Path, "degraded", n)
+
+			next.ServeHTTP(w, r.WithContext(priority.NewContext(r.Context(), class, true, l.retryAfter)))
+			return
+		}
+
+		n := l.shed.Add(1)
+		l.logger.Warn("Request shed", "url", r.URL.Path, "shed", n)
+
+		w.Header().Set("Retry-After", strconv.Itoa(l.retryAfter))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	defer l.release()
+
+	next.ServeHTTP(w, r.WithContext(priority.NewContext(r.Context(), class, false, l.retryAfter)))
+}
+
+// Shed returns the number of shed requests.
+func (l *serverLimiter) Shed() uint64 {
+	return l.shed.Load()
+}
+
+// Degraded returns the number of degraded requests.
+func (l *serverLimiter) Degraded() uint64 {
+	return l.degraded.Load()
+}
+
+// degradable returns true if the request can bypass the concurrency limit to be served by the handler in degraded
+// mode.
+func (l *serverLimiter) degradable(r *http.Request, next http.Handler) bool {
+	router, ok := next.(serverLimiterRouter)
+	return ok && router.Degradable(r)
+}
+
+// class returns the priority class of the request.
+func (l *serverLimiter) class(r *http.Request) priority.Class {
+	if index := l.ruleSet.Next(normalize.Path(r.URL.Path), 0); index >= 0 {
+		return l.rules[index].class
+	}
+
+	return priority.High
+}
+
+// acquire waits if allowed for a free slot and returns false if no slot is available.
+func (l *serverLimiter) acquire(r *http.Request, wait bool) bool {
+	l.mu.Lock()
+	if l.active < l.max {
+		l.active++
+		l.mu.Unlock()
+		return true
+	}
+	if !wait || l.queue.Len() >= l.queueSize {
+		l.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	var e *list.Element
+	if l.queueLIFO {
+		e = l.queue.PushFront(ready)
+	} else {
+		e = l.queue.PushBack(ready)
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// the slot has been handed over while giving up
+		return true
+	default:
+		l.queue.Remove(e)
+		return false
+	}
+}
+
+// release hands over the slot to the next queued request or frees it.
+func (l *serverLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e := l.queue.Front(); e != nil {
+		l.queue.Remove(e)
+		close(e.Value.(chan struct{}))
+		return
+	}
+	l.active--
+}
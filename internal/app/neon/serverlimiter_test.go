@@ -0,0 +1,242 @@
+package neon
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/priority"
+)
+
+func TestServerLimiterServe(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		queueSize  int
+		active     int
+		wantStatus int
+		wantShed   uint64
+	}{
+		{
+			name:       "free slot",
+			max:        1,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "shed queue full",
+			max:        1,
+			queueSize:  0,
+			active:     1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantShed:   1,
+		},
+		{
+			name:       "shed queue timeout",
+			max:        1,
+			queueSize:  1,
+			active:     1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantShed:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newServerLimiter(slog.Default(), tt.max, tt.queueSize, serverLimiterQueueModeFIFO,
+				10*time.Millisecond, 2)
+			l.active = tt.active
+
+			w := httptest.NewRecorder()
+			l.Serve(w, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {}))
+			if w.Code != tt.wantStatus {
+				t.Errorf("serverLimiter.Serve() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "2" {
+				t.Errorf("serverLimiter.Serve() Retry-After = %v, want %v", w.Header().Get("Retry-After"), "2")
+			}
+			if got := l.Shed(); got != tt.wantShed {
+				t.Errorf("serverLimiter.Shed() = %v, want %v", got, tt.wantShed)
+			}
+			if l.active != tt.active || l.queue.Len() != 0 {
+				t.Errorf("serverLimiter state active = %v, queue = %v", l.active, l.queue.Len())
+			}
+		})
+	}
+}
+
+func TestServerLimiterQueueOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueMode string
+		want      []int
+	}{
+		{
+			name:      "fifo",
+			queueMode: serverLimiterQueueModeFIFO,
+			want:      []int{1, 2, 3},
+		},
+		{
+			name:      "lifo",
+			queueMode: serverLimiterQueueModeLIFO,
+			want:      []int{3, 2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newServerLimiter(slog.Default(), 1, 3, tt.queueMode, time.Minute, 1)
+			l.active = 1
+
+			var mu sync.Mutex
+			var got []int
+			var wg sync.WaitGroup
+			for i := 1; i <= 3; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					l.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							mu.Lock()
+							got = append(got, i)
+							mu.Unlock()
+						}))
+				}(i)
+				for {
+					l.mu.Lock()
+					n := l.queue.Len()
+					l.mu.Unlock()
+					if n == i {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			l.release()
+			wg.Wait()
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("serverLimiter order = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("serverLimiter order = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if l.active != 0 {
+				t.Errorf("serverLimiter active = %v, want %v", l.active, 0)
+			}
+		})
+	}
+}
+
+func TestServerLimiterCanceledRequest(t *testing.T) {
+	l := newServerLimiter(slog.Default(), 1, 1, serverLimiterQueueModeFIFO, time.Minute, 1)
+	l.active = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	l.Serve(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("serverLimiter.Serve() status = %v, want %v", w.Code, http.StatusServiceUnavailable)
+	}
+	if l.queue.Len() != 0 {
+		t.Errorf("serverLimiter queue = %v, want %v", l.queue.Len(), 0)
+	}
+}
+
+type testServerLimiterRouter struct {
+	http.HandlerFunc
+	degradable bool
+}
+
+func (r testServerLimiterRouter) Degradable(req *http.Request) bool {
+	return r.degradable
+}
+
+func TestServerLimiterPriority(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		active       int
+		degradable   bool
+		wantClass    priority.Class
+		wantDegraded bool
+		wantStatus   int
+	}{
+		{
+			name:       "high priority",
+			target:     "/page",
+			wantClass:  priority.High,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "low priority",
+			target:     "/bot/page",
+			wantClass:  priority.Low,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "low priority degraded",
+			target:       "//bot/page",
+			active:       1,
+			degradable:   true,
+			wantClass:    priority.Low,
+			wantDegraded: true,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:       "low priority shed",
+			target:     "/bot/page",
+			active:     1,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "high priority shed",
+			target:     "/page",
+			active:     1,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newServerLimiter(slog.Default(), 1, 0, serverLimiterQueueModeFIFO, time.Millisecond, 1)
+			l.setRules([]serverLimiterRule{
+				{
+					regexp: regexp.MustCompile("^/bot/"),
+					class:  priority.Low,
+				},
+			})
+			l.active = tt.active
+
+			var gotClass priority.Class
+			var gotDegraded bool
+			w := httptest.NewRecorder()
+			l.Serve(w, httptest.NewRequest(http.MethodGet, tt.target, nil), testServerLimiterRouter{
+				HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					gotClass = priority.FromContext(r.Context())
+					gotDegraded = priority.Degraded(r.Context())
+				},
+				degradable: tt.degradable,
+			})
+			if w.Code != tt.wantStatus {
+				t.Errorf("serverLimiter.Serve() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && gotClass != tt.wantClass {
+				t.Errorf("serverLimiter.Serve() class = %v, want %v", gotClass, tt.wantClass)
+			}
+			if gotDegraded != tt.wantDegraded {
+				t.Errorf("serverLimiter.Serve() degraded = %v, want %v", gotDegraded, tt.wantDegraded)
+			}
+			if l.active != tt.active {
+				t.Errorf("serverLimiter active = %v, want %v", l.active, tt.active)
+			}
+		})
+	}
+}
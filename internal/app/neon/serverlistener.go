@@ -8,19 +8,42 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 
+	"github.com/mitchellh/mapstructure"
+
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
 )
 
+// serverListenerEndpointConfig implements the listen options of a listener module configuration.
+type serverListenerEndpointConfig struct {
+	Listen     []string `mapstructure:"listen"`
+	ListenAddr *string  `mapstructure:"listenAddr"`
+	ListenPort *int     `mapstructure:"listenPort"`
+}
+
+// serverListenerEndpointDefaults are the scheme and the default listen port of the endpoints of the listener modules.
+var serverListenerEndpointDefaults = map[string]struct {
+	scheme string
+	port   int
+}{
+	"local":    {scheme: "http", port: 80},
+	"redirect": {scheme: "http", port: 80},
+	"tls":      {scheme: "https", port: 443},
+}
+
 // serverListener implements a server listener.
 type serverListener struct {
 	name    string
 	logger  *slog.Logger
 	state   *serverListenerState
 	server  Server
+	limiter *serverLimiter
+	wake    *serverWake
 	mu      sync.RWMutex
 	quit    chan struct{}
 	update  chan chan error
@@ -264,6 +287,52 @@ func (l *serverListener) Listeners() ([]net.Listener, error) {
 	return l.state.mediator.listeners, nil
 }
 
+// serverListenerConfigEndpoints returns the URLs of the endpoints of the given listener configuration, without
+// initializing the listener module.
+func serverListenerConfigEndpoints(config map[string]interface{}) ([]string, error) {
+	var endpoints []string
+	for listener, listenerConfig := range config {
+		defaults, ok := serverListenerEndpointDefaults[listener]
+		if !ok {
+			continue
+		}
+		var c serverListenerEndpointConfig
+		if err := mapstructure.Decode(listenerConfig, &c); err != nil {
+			return nil, fmt.Errorf("parse config: %v", err)
+		}
+
+		addrs := c.Listen
+		if len(addrs) == 0 {
+			var addr string
+			if c.ListenAddr != nil {
+				addr = *c.ListenAddr
+			}
+			port := defaults.port
+			if c.ListenPort != nil {
+				port = *c.ListenPort
+			}
+			addrs = []string{net.JoinHostPort(addr, strconv.Itoa(port))}
+		}
+		for _, addr := range addrs {
+			endpoints = append(endpoints, defaults.scheme+"://"+addr)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// Handler returns the listener handler or nil if the listener is not registered.
+func (l *serverListener) Handler() http.Handler {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.state.handler == nil {
+		return nil
+	}
+
+	return l.state.handler
+}
+
 var _ ServerListener = (*serverListener)(nil)
 
 // serverListenerMediator implements the server listener mediator.
@@ -314,19 +383,27 @@ var _ core.ServerListener = (*serverListenerMediator)(nil)
 
 // serverListenerHandler implements the server listener handler.
 type serverListenerHandler struct {
-	logger *slog.Logger
-	router ServerListenerRouter
+	logger  *slog.Logger
+	router  ServerListenerRouter
+	limiter *serverLimiter
+	wake    *serverWake
 }
 
 // newServerListenerHandler creates a new server listener handler.
 func newServerListenerHandler(l *serverListener) *serverListenerHandler {
 	return &serverListenerHandler{
-		logger: l.logger,
+		logger:  l.logger,
+		limiter: l.limiter,
+		wake:    l.wake,
 	}
 }
 
 // ServeHTTP implements the http handler.
 func (h *serverListenerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.wake != nil {
+		h.wake.trigger()
+	}
+
 	if h.router == nil {
 		h.logger.Error("No router available")
 
@@ -335,6 +412,11 @@ func (h *serverListenerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if h.limiter != nil {
+		h.limiter.Serve(w, r, h.router)
+		return
+	}
+
 	h.router.ServeHTTP(w, r)
 }
 
@@ -365,4 +447,11 @@ func (r *serverListenerRouter) ServeHTTP(w http.ResponseWriter, req *http.Reques
 	r.mux.ServeHTTP(w, req)
 }
 
+// Degradable returns true if the route of the request handles the degraded requests without doing any work.
+func (r *serverListenerRouter) Degradable(req *http.Request) bool {
+	handler, _ := r.mux.Handler(req)
+	d, ok := handler.(priority.Degradable)
+	return ok && d.Degradable()
+}
+
 var _ ServerListenerRouter = (*serverListenerRouter)(nil)
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -808,3 +809,50 @@ func TestServerListenerRouterServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestServerListenerRouterDegradable(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/js/", &serverSiteRoute{
+		Handler:    http.NotFoundHandler(),
+		degradable: true,
+	})
+	mux.Handle("/static/", &serverSiteRoute{
+		Handler: http.NotFoundHandler(),
+	})
+	mux.Handle("/other/", http.NotFoundHandler())
+
+	tests := []struct {
+		name   string
+		target string
+		want   bool
+	}{
+		{
+			name:   "degradable route",
+			target: "/js/page",
+			want:   true,
+		},
+		{
+			name:   "not degradable route",
+			target: "/static/page",
+		},
+		{
+			name:   "unknown handler",
+			target: "/other/page",
+		},
+		{
+			name:   "no route",
+			target: "/page",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &serverListenerRouter{
+				logger: slog.Default(),
+				mux:    mux,
+			}
+			if got := l.Degradable(httptest.NewRequest(http.MethodGet, tt.target, nil)); got != tt.want {
+				t.Errorf("serverListenerRouter.Degradable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,35 @@
 package neon
 
 import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"path"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mitchellh/mapstructure"
 
+	"github.com/bhuisgen/neon/pkg/access"
+	"github.com/bhuisgen/neon/pkg/buildinfo"
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/priority"
+	"github.com/bhuisgen/neon/pkg/redact"
+	"github.com/bhuisgen/neon/pkg/render"
+	"github.com/bhuisgen/neon/pkg/trace"
 )
 
 // serverSite implements a server site.
@@ -31,9 +44,14 @@ type serverSite struct {
 
 // serverSiteConfig implements the server site configuration.
 type serverSiteConfig struct {
-	Listeners []string                         `mapstructure:"listeners"`
-	Hosts     []string                         `mapstructure:"hosts"`
-	Routes    map[string]serverSiteRouteConfig `mapstructure:"routes"`
+	Listeners       []string                         `mapstructure:"listeners"`
+	Hosts           []string                         `mapstructure:"hosts"`
+	Routes          map[string]serverSiteRouteConfig `mapstructure:"routes"`
+	DebugToken      *string                          `mapstructure:"debugToken"`
+	DebugAllowedIPs []string                         `mapstructure:"debugAllowedIPs"`
+	ErrorHeaders    []string                         `mapstructure:"errorHeaders"`
+	BuildHeader     *bool                            `mapstructure:"buildHeader"`
+	SlowLog         *int                             `mapstructure:"slowLog"`
 }
 
 // serverSiteRouteConfig implements a server site route configuration.
@@ -50,6 +68,7 @@ type serverSiteState struct {
 	routes      []string
 	routesMap   map[string]serverSiteRouteState
 	store       core.Store
+	loader      core.Loader
 	server      core.Server
 	mediator    *serverSiteMediator
 	middleware  *serverSiteMiddleware
@@ -99,6 +118,24 @@ func (s *serverSite) Init(config map[string]interface{}) error {
 		s.logger.Error("No listener defined")
 		errConfig = true
 	}
+	if s.config.DebugToken != nil && *s.config.DebugToken == "" {
+		s.logger.Error("Invalid value", "option", "DebugToken", "value", *s.config.DebugToken)
+		errConfig = true
+	}
+	if _, err := access.ParseList(s.config.DebugAllowedIPs); err != nil {
+		s.logger.Error("Invalid value", "option", "DebugAllowedIPs", "value", s.config.DebugAllowedIPs, "err", err)
+		errConfig = true
+	}
+	for index, header := range s.config.ErrorHeaders {
+		if header == "" || header == "*" {
+			s.logger.Error("Invalid value", "option", "ErrorHeaders", "index", index+1, "value", header)
+			errConfig = true
+		}
+	}
+	if s.config.SlowLog != nil && *s.config.SlowLog <= 0 {
+		s.logger.Error("Invalid value", "option", "SlowLog", "value", *s.config.SlowLog)
+		errConfig = true
+	}
 
 	s.state.listeners = append(s.state.listeners, s.config.Listeners...)
 	s.state.hosts = append(s.state.hosts, s.config.Hosts...)
@@ -182,6 +219,7 @@ func (s *serverSite) Register(app core.App) error {
 	s.logger.Debug("Registering site")
 
 	s.state.store = app.Store()
+	s.state.loader = app.Loader()
 	s.state.server = app.Server()
 
 	mediator := newServerSiteMediator(s, app)
@@ -258,6 +296,29 @@ func (s *serverSite) Stop() error {
 	return nil
 }
 
+// Preflight checks the environment of all middlewares and handlers.
+func (s *serverSite) Preflight(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, route := range s.state.routes {
+		for middlewareName, middleware := range s.state.routesMap[route].middlewares {
+			preflight, ok := middleware.(core.PreflightModule)
+			if !ok {
+				continue
+			}
+			errs = append(errs, preflightErrors("route "+route+": middleware "+middlewareName,
+				preflight.Preflight(ctx))...)
+		}
+		if preflight, ok := s.state.routesMap[route].handler.(core.PreflightModule); ok {
+			errs = append(errs, preflightErrors("route "+route+": handler", preflight.Preflight(ctx))...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // Name returns the site name.
 func (s *serverSite) Name() string {
 	s.mu.RLock()
@@ -317,6 +378,18 @@ func (s *serverSite) buildRouter() (*serverSiteRouter, error) {
 		return findRouteMiddlewares(path.Dir(route))
 	}
 
+	// the degraded requests of a route bypass the concurrency limit only if all its modules handle them without work
+	var findRouteMiddlewaresDegradable func(route string) bool
+	findRouteMiddlewaresDegradable = func(route string) bool {
+		if _, ok := s.state.mediator.routesMiddlewares[route]; ok {
+			return serverSiteMiddlewaresDegradable(s.state.routesMap[route].middlewares)
+		}
+		if route == "/" {
+			return serverSiteMiddlewaresDegradable(s.state.routesMap[serverSiteRouteDefault].middlewares)
+		}
+		return findRouteMiddlewaresDegradable(path.Dir(route))
+	}
+
 	for _, route := range s.state.routes {
 		if !strings.HasPrefix(route, "/") {
 			continue
@@ -328,11 +401,15 @@ func (s *serverSite) buildRouter() (*serverSiteRouter, error) {
 		} else {
 			handler = s.state.handler
 		}
+		degradable := serverSiteModuleDegradable(handler) && findRouteMiddlewaresDegradable(route)
 		routeMiddlewares := findRouteMiddlewares(route)
 		for i := len(routeMiddlewares) - 1; i >= 0; i-- {
 			handler = routeMiddlewares[i](handler)
 		}
-		routes[route] = s.state.middleware.Handler(handler)
+		routes[route] = &serverSiteRoute{
+			Handler:    s.state.middleware.Handler(handler),
+			degradable: degradable,
+		}
 	}
 
 	if _, ok := s.state.mediator.routesHandler["/"]; !ok {
@@ -343,11 +420,15 @@ func (s *serverSite) buildRouter() (*serverSiteRouter, error) {
 		} else {
 			handler = s.state.handler
 		}
+		degradable := serverSiteModuleDegradable(handler) && findRouteMiddlewaresDegradable("/")
 		routeMiddlewares := findRouteMiddlewares("/")
 		for i := len(routeMiddlewares) - 1; i >= 0; i-- {
 			handler = routeMiddlewares[i](handler)
 		}
-		routes["/"] = s.state.middleware.Handler(handler)
+		routes["/"] = &serverSiteRoute{
+			Handler:    s.state.middleware.Handler(handler),
+			degradable: degradable,
+		}
 	}
 
 	router := newServerSiteRouter(s)
@@ -409,6 +490,11 @@ func (m *serverSiteMediator) Store() core.Store {
 	return m.site.state.store
 }
 
+// Returns the loader.
+func (m *serverSiteMediator) Loader() core.Loader {
+	return m.site.state.loader
+}
+
 // Returns the server.
 func (m *serverSiteMediator) Server() core.Server {
 	return m.site.state.server
@@ -465,30 +551,80 @@ var _ core.ServerSite = (*serverSiteMediator)(nil)
 
 // serverSiteMiddleware implements the server site middleware.
 type serverSiteMiddleware struct {
-	logger *slog.Logger
+	logger       *slog.Logger
+	debugToken   string
+	debugAllow   *access.List
+	errorHeaders []string
+	buildHeader  string
+	slowLog      time.Duration
+	slowLogger   *slog.Logger
 }
 
 const (
-	serverSiteMiddlewareHeaderRequestId string = "X-Request-ID"
-	serverSiteMiddlewareHeaderServer    string = "Server"
+	serverSiteMiddlewareHeaderRequestId  string = "X-Request-ID"
+	serverSiteMiddlewareHeaderServer     string = "Server"
+	serverSiteMiddlewareHeaderDebugToken string = "X-Neon-Debug-Token"
+	serverSiteMiddlewareHeaderDebugTrace string = "X-Neon-Debug-Trace"
+	serverSiteMiddlewareHeaderBuild      string = "X-Neon-Build"
 
 	serverSiteMiddlewareHeaderServerValue string = "neon"
+
+	serverSiteMiddlewareDebugParam     string = "__neon_debug"
+	serverSiteMiddlewareDebugModeBody  string = "body"
+	serverSiteMiddlewareDebugTraceName string = "site"
+	serverSiteMiddlewareSlowLogID      string = "app.server.site.slowlog"
 )
 
+// serverSiteMiddlewareErrorHeaders are the headers always kept in an error or default response in addition to the
+// configured ones.
+var serverSiteMiddlewareErrorHeaders = []string{
+	serverSiteMiddlewareHeaderRequestId,
+	serverSiteMiddlewareHeaderServer,
+	serverSiteMiddlewareHeaderBuild,
+	"Content-Encoding",
+	"Vary",
+}
+
 // newServerSiteMiddleware creates the server site middleware.
 func newServerSiteMiddleware(s *serverSite) *serverSiteMiddleware {
-	return &serverSiteMiddleware{
+	m := &serverSiteMiddleware{
 		logger: s.logger,
 	}
+	if s.config != nil && s.config.DebugToken != nil {
+		m.debugToken = *s.config.DebugToken
+	}
+	if s.config != nil && len(s.config.DebugAllowedIPs) > 0 {
+		m.debugAllow, _ = access.ParseList(s.config.DebugAllowedIPs)
+	}
+	if s.config != nil {
+		m.errorHeaders = s.config.ErrorHeaders
+	}
+	if s.config != nil && s.config.BuildHeader != nil && *s.config.BuildHeader {
+		info := buildinfo.Get()
+		m.buildHeader = fmt.Sprintf("%s (%s)", info.Version, info.Commit)
+	}
+	if s.config != nil && s.config.SlowLog != nil {
+		m.slowLog = time.Duration(*s.config.SlowLog) * time.Millisecond
+		m.slowLogger = slog.New(log.NewHandler(os.Stderr, serverSiteMiddlewareSlowLogID, nil)).With("name", s.name)
+	}
+
+	return m
 }
 
 // Handler implements the middleware handler.
 func (m *serverSiteMiddleware) Handler(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		w := &serverSiteResponseWriter{
+			ResponseWriter: rw,
+			header:         http.Header{},
+			errorHeaders:   m.errorHeaders,
+		}
+
 		defer func() {
 			if err := recover(); err != nil {
+				w.ResetHeader()
 				w.WriteHeader(http.StatusInternalServerError)
-				if !DEBUG {
+				if !debugEnabled() {
 					m.logger.Error("Error handler", "err", err)
 				} else {
 					m.logger.Error("Error handler", "err", err, "stack", string(debug.Stack()))
@@ -498,6 +634,26 @@ func (m *serverSiteMiddleware) Handler(next http.Handler) http.Handler {
 
 		w.Header().Set(serverSiteMiddlewareHeaderServer, serverSiteMiddlewareHeaderServerValue)
 		w.Header().Set(serverSiteMiddlewareHeaderRequestId, uuid.NewString())
+		if m.buildHeader != "" {
+			w.Header().Set(serverSiteMiddlewareHeaderBuild, m.buildHeader)
+		}
+
+		normalize.URL(r.URL)
+
+		mode, debug := m.debugMode(r)
+		var t *trace.Trace
+		if debug || m.slowLog > 0 {
+			t = trace.New()
+			r = r.WithContext(trace.NewContext(r.Context(), t))
+		}
+		if m.slowLog > 0 {
+			defer m.logSlow(w, r, t, time.Now())
+		}
+
+		if debug {
+			m.serveDebug(w, r, next, mode)
+			return
+		}
 
 		next.ServeHTTP(w, r)
 	}
@@ -505,6 +661,176 @@ func (m *serverSiteMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// debugMode returns the debug trace mode if the request asks for a trace and is allowed to get it.
+//
+// A request from a client address not allowed is always refused, even with a debug build.
+func (m *serverSiteMiddleware) debugMode(r *http.Request) (string, bool) {
+	mode := r.URL.Query().Get(serverSiteMiddlewareDebugParam)
+	if mode == "" || mode == "0" {
+		return "", false
+	}
+	if m.debugAllow != nil && !m.debugAllow.Allowed(r) {
+		return "", false
+	}
+
+	if debugEnabled() {
+		return mode, true
+	}
+	if m.debugToken != "" && subtle.ConstantTimeCompare(
+		[]byte(r.Header.Get(serverSiteMiddlewareHeaderDebugToken)), []byte(m.debugToken)) == 1 {
+		return mode, true
+	}
+
+	return "", false
+}
+
+// serveDebug serves the request with the debug trace of its context returned as a header or appended to the response
+// body.
+//
+// The trace is appended to the body of an unencoded response only, so that the request is served without its accepted
+// encodings in this mode, and the trace is returned as a header if the response is encoded anyway.
+func (m *serverSiteMiddleware) serveDebug(w http.ResponseWriter, r *http.Request, next http.Handler, mode string) {
+	t := trace.FromContext(r.Context())
+	t.Add(serverSiteMiddlewareDebugTraceName, "Request received", "method", r.Method, "host", r.Host,
+		"path", r.URL.Path, "headers", redact.Header(r.Header))
+
+	if mode == serverSiteMiddlewareDebugModeBody {
+		r = r.Clone(render.NewAcceptEncodingContext(r.Context(), ""))
+		r.Header.Del("Accept-Encoding")
+	}
+
+	rw := render.NewRenderWriter()
+	next.ServeHTTP(rw, r)
+	rr := rw.Render()
+
+	t.Add(serverSiteMiddlewareDebugTraceName, "Response sent", "status", rr.StatusCode(), "size", len(rr.Body()),
+		"headers", redact.Header(rr.Header()))
+
+	buf, err := json.Marshal(t)
+	if err != nil {
+		m.logger.Error("Failed to marshal debug trace", "err", err)
+	}
+
+	for key, values := range rr.Header() {
+		w.Header()[key] = values
+	}
+	body := rr.Body()
+	encoding := rr.Header().Get("Content-Encoding")
+	if mode == serverSiteMiddlewareDebugModeBody && (encoding == "" || encoding == "identity") {
+		w.Header().Del("Content-Length")
+		body = append(append(body, '\n'), buf...)
+	} else {
+		w.Header().Set(serverSiteMiddlewareHeaderDebugTrace, string(buf))
+	}
+	w.WriteHeader(rr.StatusCode())
+	if _, err := w.Write(body); err != nil {
+		m.logger.Error("Failed to write response", "err", err)
+	}
+}
+
+// logSlow writes the slow log record of the request served with the given trace if its duration since the given start
+// time exceeds the threshold.
+//
+// The record is written apart from the access log and holds the trace events of the request, with the matched rules,
+// the cache decisions and the timings of the renderers.
+func (m *serverSiteMiddleware) logSlow(w *serverSiteResponseWriter, r *http.Request, t *trace.Trace, start time.Time) {
+	duration := time.Since(start)
+	if duration < m.slowLog {
+		return
+	}
+
+	buf, err := json.Marshal(t)
+	if err != nil {
+		m.logger.Error("Failed to marshal slow log trace", "err", err)
+	}
+
+	m.slowLogger.Warn("Slow request", "id", w.Header().Get(serverSiteMiddlewareHeaderRequestId), "method", r.Method,
+		"host", r.Host, "path", r.URL.Path, "status", w.statusCode, "size", w.size,
+		"duration", duration.Milliseconds(), "trace", string(buf))
+}
+
+// serverSiteResponseWriter implements a response writer buffering the headers until the response is written.
+type serverSiteResponseWriter struct {
+	http.ResponseWriter
+	header       http.Header
+	errorHeaders []string
+	wroteHeader  bool
+	statusCode   int
+	size         int
+}
+
+// Header returns the response headers.
+func (w *serverSiteResponseWriter) Header() http.Header {
+	if w.wroteHeader {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+// WriteHeader sends the buffered headers with the provided status code.
+func (w *serverSiteResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.statusCode = statusCode
+		header := w.ResponseWriter.Header()
+		for key, values := range w.header {
+			header[key] = values
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the response data.
+func (w *serverSiteResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// ResetHeader removes the buffered headers not allowed in an error or default response.
+func (w *serverSiteResponseWriter) ResetHeader() {
+	if w.wroteHeader {
+		return
+	}
+	allowed := make([]string, 0, len(serverSiteMiddlewareErrorHeaders)+len(w.errorHeaders))
+	allowed = append(allowed, serverSiteMiddlewareErrorHeaders...)
+	allowed = append(allowed, w.errorHeaders...)
+	render.FilterHeader(w.header, allowed)
+}
+
+// Flush sends the buffered data.
+func (w *serverSiteResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the client take over the connection.
+func (w *serverSiteResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	c, rw, err := h.Hijack()
+	if err != nil {
+		return c, rw, fmt.Errorf("hijack: %w", err)
+	}
+	return c, rw, nil
+}
+
+// Unwrap returns the original response writer.
+func (w *serverSiteResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var _ render.HeaderResetter = (*serverSiteResponseWriter)(nil)
+
 // serverSiteHandler implements the default server site handler.
 type serverSiteHandler struct {
 	logger *slog.Logger
@@ -521,9 +847,43 @@ func newServerSiteHandler(s *serverSite) *serverSiteHandler {
 func (h *serverSiteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Error("No handler available")
 
+	render.ResetHeader(w)
 	http.NotFound(w, r)
 }
 
+// Degradable implements priority.Degradable.
+func (h *serverSiteHandler) Degradable() bool {
+	return true
+}
+
+// serverSiteRoute implements a server site route handler.
+type serverSiteRoute struct {
+	http.Handler
+	degradable bool
+}
+
+// Degradable implements priority.Degradable.
+func (r *serverSiteRoute) Degradable() bool {
+	return r.degradable
+}
+
+// serverSiteModuleDegradable returns true if the module handles the degraded requests without doing any work.
+func serverSiteModuleDegradable(module interface{}) bool {
+	d, ok := module.(priority.Degradable)
+	return ok && d.Degradable()
+}
+
+// serverSiteMiddlewaresDegradable returns true if all the middlewares handle the degraded requests without doing any
+// work.
+func serverSiteMiddlewaresDegradable(middlewares map[string]core.ServerSiteMiddlewareModule) bool {
+	for _, middleware := range middlewares {
+		if !serverSiteModuleDegradable(middleware) {
+			return false
+		}
+	}
+	return true
+}
+
 // serverSiteRouter implements the server site router.
 type serverSiteRouter struct {
 	logger *slog.Logger
@@ -1,14 +1,23 @@
 package neon
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bhuisgen/neon/pkg/access"
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/redact"
+	"github.com/bhuisgen/neon/pkg/render"
+	"github.com/bhuisgen/neon/pkg/trace"
 )
 
 type testServerSiteResponseWriter struct {
@@ -85,6 +94,74 @@ func TestServerSiteInit(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "error invalid debug token",
+			fields: fields{
+				name:   "main",
+				logger: slog.Default(),
+				state: &serverSiteState{
+					routesMap: map[string]serverSiteRouteState{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners":  []string{"test"},
+					"debugToken": "",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error invalid debug allowed IPs",
+			fields: fields{
+				name:   "main",
+				logger: slog.Default(),
+				state: &serverSiteState{
+					routesMap: map[string]serverSiteRouteState{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners":       []string{"test"},
+					"debugAllowedIPs": []string{"localhost"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error invalid error headers",
+			fields: fields{
+				name:   "main",
+				logger: slog.Default(),
+				state: &serverSiteState{
+					routesMap: map[string]serverSiteRouteState{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners":    []string{"test"},
+					"errorHeaders": []string{"Cache-Control", ""},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error invalid slow log",
+			fields: fields{
+				name:   "main",
+				logger: slog.Default(),
+				state: &serverSiteState{
+					routesMap: map[string]serverSiteRouteState{},
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"listeners": []string{"test"},
+					"slowLog":   0,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "error unregistered modules",
 			fields: fields{
@@ -763,35 +840,298 @@ func TestServerSiteRouterRoutes(t *testing.T) {
 
 func TestServerSiteMiddlewareHandler(t *testing.T) {
 	type fields struct {
-		logger *slog.Logger
+		logger       *slog.Logger
+		debugToken   string
+		debugAllow   []string
+		errorHeaders []string
+		buildHeader  string
 	}
 	type args struct {
-		next http.Handler
+		next   http.Handler
+		target string
+		header http.Header
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   http.Handler
+		name       string
+		fields     fields
+		args       args
+		wantTrace  bool
+		wantRedact bool
+		wantBody   string
+		wantPrefix string
+		wantStatus int
+		wantHeader http.Header
 	}{
 		{
 			name: "default",
 			fields: fields{
 				logger: slog.Default(),
 			},
+			args: args{
+				target: "/",
+			},
+		},
+		{
+			name: "panic after header set",
+			fields: fields{
+				logger:       slog.Default(),
+				errorHeaders: []string{"Cache-*"},
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Set-Cookie", "session=test")
+					w.Header().Set("Cache-Control", "no-store")
+					panic("test")
+				}),
+				target: "/",
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantHeader: http.Header{
+				"Set-Cookie":    nil,
+				"Cache-Control": []string{"no-store"},
+			},
+		},
+		{
+			name: "error after header set",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Set-Cookie", "session=test")
+					w.Header().Set("Vary", "Accept-Encoding")
+					render.ResetHeader(w)
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}),
+				target: "/",
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantHeader: http.Header{
+				"Set-Cookie": nil,
+				"Vary":       []string{"Accept-Encoding"},
+			},
+		},
+		{
+			name: "header written before panic",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Set-Cookie", "session=test")
+					_, _ = w.Write([]byte("test"))
+					render.ResetHeader(w)
+				}),
+				target: "/",
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "test",
+			wantHeader: http.Header{
+				"Set-Cookie": []string{"session=test"},
+			},
+		},
+		{
+			name: "debug without token",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=1",
+			},
+			wantBody: "test",
+		},
+		{
+			name: "debug header",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=1",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+				},
+			},
+			wantTrace: true,
+			wantBody:  "test",
+		},
+		{
+			name: "debug header redacted",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					w.Header().Set("Set-Cookie", "session=secret")
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=1",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+					"Authorization":                      []string{"Bearer secret"},
+				},
+			},
+			wantTrace:  true,
+			wantRedact: true,
+			wantBody:   "test",
+		},
+		{
+			name: "debug address denied",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+				debugAllow: []string{"10.0.0.0/8"},
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=1",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+				},
+			},
+			wantBody: "test",
+		},
+		{
+			name: "debug body",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=body",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+				},
+			},
+		},
+		{
+			name: "debug body encoding",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					if render.NegotiateEncoding(r, "gzip") == "gzip" {
+						w.Header().Set("Content-Encoding", "gzip")
+						gw := gzip.NewWriter(w)
+						_, _ = gw.Write([]byte("test"))
+						_ = gw.Close()
+						return
+					}
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=body",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+					"Accept-Encoding":                    []string{"gzip"},
+				},
+			},
+			wantPrefix: "test\n",
+		},
+		{
+			name: "debug body precompressed",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					if encoding := render.NegotiateEncoding(r, "br", "gzip"); encoding != "" {
+						w.Header().Set("Content-Encoding", encoding)
+						_, _ = w.Write([]byte(encoding))
+						return
+					}
+					_, _ = w.Write([]byte("test"))
+				}),
+				target: "/?__neon_debug=body",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+					"Accept-Encoding":                    []string{"br, gzip"},
+				},
+			},
+			wantPrefix: "test\n",
+		},
+		{
+			name: "debug body precompressed encoded",
+			fields: fields{
+				logger:     slog.Default(),
+				debugToken: "token",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					trace.FromContext(r.Context()).Add("test", "Test event")
+					w.Header().Set("Content-Encoding", "br")
+					_, _ = w.Write([]byte("br"))
+				}),
+				target: "/?__neon_debug=body",
+				header: http.Header{
+					serverSiteMiddlewareHeaderDebugToken: []string{"token"},
+					"Accept-Encoding":                    []string{"br, gzip"},
+				},
+			},
+			wantTrace: true,
+			wantBody:  "br",
+		},
+		{
+			name: "build header",
+			fields: fields{
+				logger:      slog.Default(),
+				buildHeader: "dev (-)",
+			},
+			args: args{
+				next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					render.ResetHeader(w)
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}),
+				target: "/",
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantHeader: http.Header{
+				serverSiteMiddlewareHeaderBuild: []string{"dev (-)"},
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			var debugAllow *access.List
+			if len(tt.fields.debugAllow) > 0 {
+				debugAllow, _ = access.ParseList(tt.fields.debugAllow)
+			}
 			m := &serverSiteMiddleware{
-				logger: tt.fields.logger,
+				logger:       tt.fields.logger,
+				debugToken:   tt.fields.debugToken,
+				debugAllow:   debugAllow,
+				errorHeaders: tt.fields.errorHeaders,
+				buildHeader:  tt.fields.buildHeader,
 			}
 			h := m.Handler(tt.args.next)
 			w := httptest.NewRecorder()
-			r, err := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+			r, err := http.NewRequestWithContext(context.Background(), "GET", tt.args.target, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
+			for key, values := range tt.args.header {
+				r.Header[key] = values
+			}
 			h.ServeHTTP(w, r)
 			if v := w.Header().Get(serverSiteMiddlewareHeaderServer); v != serverSiteMiddlewareHeaderServerValue {
 				t.Errorf("missing header")
@@ -799,6 +1139,77 @@ func TestServerSiteMiddlewareHandler(t *testing.T) {
 			if v := w.Header().Get(serverSiteMiddlewareHeaderRequestId); v == "" {
 				t.Errorf("missing header")
 			}
+			if v := w.Header().Get(serverSiteMiddlewareHeaderDebugTrace); (v != "") != tt.wantTrace {
+				t.Errorf("debug trace header got %v, wantTrace %v", v, tt.wantTrace)
+			} else if tt.wantTrace && !strings.Contains(v, "Test event") {
+				t.Errorf("debug trace header got %v", v)
+			} else if tt.wantRedact && (strings.Contains(v, "secret") || !strings.Contains(v, redact.Redacted)) {
+				t.Errorf("debug trace header got %v", v)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body got %v, want %v", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantPrefix != "" && !strings.HasPrefix(w.Body.String(), tt.wantPrefix) {
+				t.Errorf("body got %v, want prefix %v", w.Body.String(), tt.wantPrefix)
+			}
+			if tt.args.target == "/?__neon_debug=body" && !tt.wantTrace && !strings.Contains(w.Body.String(), "Test event") {
+				t.Errorf("body got %v", w.Body.String())
+			}
+			if tt.wantStatus != 0 && w.Code != tt.wantStatus {
+				t.Errorf("status got %v, want %v", w.Code, tt.wantStatus)
+			}
+			for key, values := range tt.wantHeader {
+				if got := w.Header().Values(key); !reflect.DeepEqual(got, values) {
+					t.Errorf("header %s got %v, want %v", key, got, values)
+				}
+			}
+		})
+	}
+}
+
+func TestServerSiteMiddlewareSlowLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		slowLog time.Duration
+		delay   time.Duration
+		want    bool
+	}{
+		{
+			name:    "fast request",
+			slowLog: time.Minute,
+		},
+		{
+			name:    "slow request",
+			slowLog: time.Millisecond,
+			delay:   5 * time.Millisecond,
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			m := &serverSiteMiddleware{
+				logger:     slog.Default(),
+				slowLog:    tt.slowLog,
+				slowLogger: slog.New(log.NewHandler(&buf, serverSiteMiddlewareSlowLogID, nil)),
+			}
+			h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace.FromContext(r.Context()).Add("test", "Test event")
+				time.Sleep(tt.delay)
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("test"))
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			got := buf.String()
+			if (got != "") != tt.want {
+				t.Errorf("slow log got %v, want %v", got, tt.want)
+			}
+			if tt.want && (!strings.Contains(got, "Slow request") || !strings.Contains(got, "status=202") ||
+				!strings.Contains(got, "size=4") || !strings.Contains(got, "Test event")) {
+				t.Errorf("slow log got %v", got)
+			}
 		})
 	}
 }
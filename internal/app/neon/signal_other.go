@@ -0,0 +1,14 @@
+//go:build !windows
+
+package neon
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// appNotifyLogLevel relays the log level signal SIGUSR2 to the given channel.
+func appNotifyLogLevel(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package neon
+
+import (
+	"os"
+)
+
+// appNotifyLogLevel does nothing as there is no log level signal on Windows.
+func appNotifyLogLevel(c chan<- os.Signal) {
+}
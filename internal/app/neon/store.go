@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/mitchellh/mapstructure"
@@ -12,6 +13,7 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/statedir"
 )
 
 // store implements the store
@@ -23,7 +25,8 @@ type store struct {
 
 // storeConfig implements the store configuration
 type storeConfig struct {
-	Storage map[string]map[string]interface{} `mapstructure:"storage"`
+	Storage  map[string]map[string]interface{} `mapstructure:"storage"`
+	Snapshot *string                           `mapstructure:"snapshot"`
 }
 
 // storeState implements the store state
@@ -102,6 +105,15 @@ func (s *store) Init(config map[string]interface{}) error {
 
 		break
 	}
+	if s.config.Snapshot != nil {
+		if *s.config.Snapshot == "" {
+			s.logger.Error("Invalid value", "option", "Snapshot", "value", *s.config.Snapshot)
+			errConfig = true
+		} else if _, ok := s.state.storage.(core.StoreStorageSnapshotModule); s.state.storage != nil && !ok {
+			s.logger.Error("Snapshot not supported by storage module")
+			errConfig = true
+		}
+	}
 
 	if errConfig {
 		return errors.New("config")
@@ -140,6 +152,84 @@ func (s *store) StoreResource(name string, resource *core.Resource) error {
 	return nil
 }
 
+// Import restores the resources of the snapshot file if configured and existing.
+func (s *store) Import() error {
+	if s.config == nil || s.config.Snapshot == nil {
+		return nil
+	}
+	storage, ok := s.state.storage.(core.StoreStorageSnapshotModule)
+	if !ok {
+		return nil
+	}
+
+	name := statedir.Path(*s.config.Snapshot)
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("open snapshot: %v", err)
+	}
+	defer f.Close()
+
+	if err := storage.Import(f); err != nil {
+		return fmt.Errorf("import snapshot: %v", err)
+	}
+
+	s.logger.Info("Snapshot imported", "file", name)
+
+	return nil
+}
+
+// Export saves the stored resources to the snapshot file if configured.
+//
+// The snapshot is written to a temporary file renamed once complete, so that an interrupted export never replaces the
+// previous snapshot.
+func (s *store) Export() error {
+	if s.config == nil || s.config.Snapshot == nil {
+		return nil
+	}
+	storage, ok := s.state.storage.(core.StoreStorageSnapshotModule)
+	if !ok {
+		return nil
+	}
+
+	name := statedir.Path(*s.config.Snapshot)
+	if err := statedir.MkdirParent(name); err != nil {
+		return fmt.Errorf("export snapshot: %v", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := storage.Export(f); err != nil {
+		f.Close()
+		return fmt.Errorf("export snapshot: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %v", err)
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("rename snapshot: %v", err)
+	}
+
+	s.logger.Info("Snapshot exported", "file", name)
+
+	return nil
+}
+
+// Stop stops the background tasks of the storage.
+func (s *store) Stop() error {
+	storage, ok := s.state.storage.(core.StoreStorageStopModule)
+	if !ok {
+		return nil
+	}
+
+	return storage.Stop()
+}
+
 var _ Store = (*store)(nil)
 
 // storeMediator implements the store mediator.
@@ -2,6 +2,8 @@ package neon
 
 import (
 	"log/slog"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -58,6 +60,37 @@ func TestStoreInit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "snapshot",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &storeState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"storage": map[string]interface{}{
+						"test": map[string]interface{}{},
+					},
+					"snapshot": "store.snapshot",
+				},
+			},
+		},
+		{
+			name: "error invalid snapshot",
+			fields: fields{
+				logger: slog.Default(),
+				state:  &storeState{},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"storage": map[string]interface{}{
+						"test": map[string]interface{}{},
+					},
+					"snapshot": "",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "error no storage",
 			fields: fields{
@@ -266,3 +299,36 @@ func TestStoreStoreResource(t *testing.T) {
 		})
 	}
 }
+
+func TestStoreExportImport(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "state", "store.snapshot")
+	s := &store{
+		config: &storeConfig{
+			Snapshot: &name,
+		},
+		logger: slog.Default(),
+		state: &storeState{
+			storage: &testStoreStorageModule{},
+		},
+	}
+
+	if err := s.Import(); err != nil {
+		t.Errorf("store.Import() without snapshot error = %v", err)
+	}
+	if err := s.Export(); err != nil {
+		t.Fatalf("store.Export() error = %v", err)
+	}
+	if _, err := os.Stat(name); err != nil {
+		t.Fatalf("store.Export() snapshot not written, err = %v", err)
+	}
+	if err := s.Import(); err != nil {
+		t.Errorf("store.Import() error = %v", err)
+	}
+
+	if err := os.WriteFile(name, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Import(); err == nil {
+		t.Errorf("store.Import() error = %v, wantErr %v", err, true)
+	}
+}
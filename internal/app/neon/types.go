@@ -13,6 +13,7 @@ type App interface {
 	core.Module
 	Check() error
 	Serve(ctx context.Context) error
+	Fixtures(ctx context.Context, directory string) (int, error)
 }
 
 // Store
@@ -20,12 +21,16 @@ type Store interface {
 	core.AppModule
 	LoadResource(name string) (*core.Resource, error)
 	StoreResource(name string, resource *core.Resource) error
+	Import() error
+	Export() error
+	Stop() error
 }
 
 // Fetcher
 type Fetcher interface {
 	core.AppModule
 	Fetch(ctx context.Context, name string, provider string, config map[string]interface{}) (*core.Resource, error)
+	Preflight(ctx context.Context, name string, provider string, config map[string]interface{}) error
 }
 
 // Loader
@@ -33,6 +38,9 @@ type Loader interface {
 	core.AppModule
 	Start() error
 	Stop() error
+	Subscribe(fn func(names []string))
+	Preflight(ctx context.Context) error
+	Pull(ctx context.Context, fetcher core.Fetcher) error
 }
 
 // Server
@@ -42,6 +50,9 @@ type Server interface {
 	Stop() error
 	Shutdown(ctx context.Context) error
 	Listeners() (map[string][]net.Listener, error)
+	Handler(listener string) (http.Handler, error)
+	OnFirstRequest(fn func())
+	Preflight(ctx context.Context) error
 }
 
 // ServerListener
@@ -56,6 +67,7 @@ type ServerListener interface {
 	Link(site ServerSite) error
 	Unlink(site ServerSite) error
 	Listeners() ([]net.Listener, error)
+	Handler() http.Handler
 }
 
 // ServerListenerRouter
@@ -73,6 +85,7 @@ type ServerSite interface {
 	Listeners() []string
 	Hosts() []string
 	Router() (ServerSiteRouter, error)
+	Preflight(ctx context.Context) error
 }
 
 // ServerSiteRouter
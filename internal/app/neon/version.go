@@ -1,8 +1,17 @@
 package neon
 
+import (
+	"github.com/bhuisgen/neon/pkg/buildinfo"
+)
+
 var (
 	Name    string = "Neon"
 	Version string = "dev"
 	Commit  string = "-"
 	Date    string = "-"
 )
+
+// init initializes the build information.
+func init() {
+	buildinfo.Set(Name, Version, Commit, Date)
+}
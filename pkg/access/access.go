@@ -0,0 +1,67 @@
+package access
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// List implements a list of allowed client addresses.
+type List struct {
+	prefixes []netip.Prefix
+}
+
+// ParseList parses a list of IP addresses or CIDR prefixes.
+func ParseList(items []string) (*List, error) {
+	l := &List{}
+	for _, item := range items {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("parse prefix: %v", err)
+			}
+			l.prefixes = append(l.prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("parse address: %v", err)
+		}
+		l.prefixes = append(l.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return l, nil
+}
+
+// Allowed returns true if the client address of the request is in the list.
+func (l *List) Allowed(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Token returns true if the request holds the given bearer token in its Authorization header.
+func Token(r *http.Request, token string) bool {
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(value)), []byte(token)) == 1
+}
@@ -0,0 +1,124 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []string
+		wantErr bool
+	}{
+		{
+			name:  "default",
+			items: []string{"127.0.0.1", "10.0.0.0/8", "::1", "fd00::/8"},
+		},
+		{
+			name:    "invalid address",
+			items:   []string{"localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid prefix",
+			items:   []string{"10.0.0.0/33"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseList(tt.items); (err != nil) != tt.wantErr {
+				t.Errorf("ParseList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListAllowed(t *testing.T) {
+	l, err := ParseList([]string{"127.0.0.1", "10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       bool
+	}{
+		{
+			name:       "address",
+			remoteAddr: "127.0.0.1:1234",
+			want:       true,
+		},
+		{
+			name:       "prefix",
+			remoteAddr: "10.1.2.3:1234",
+			want:       true,
+		},
+		{
+			name:       "ipv6 prefix",
+			remoteAddr: "[fd00::1]:1234",
+			want:       true,
+		},
+		{
+			name:       "mapped address",
+			remoteAddr: "[::ffff:10.1.2.3]:1234",
+			want:       true,
+		},
+		{
+			name:       "denied",
+			remoteAddr: "192.168.1.1:1234",
+		},
+		{
+			name:       "invalid address",
+			remoteAddr: "invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if got := l.Allowed(r); got != tt.want {
+				t.Errorf("List.Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          bool
+	}{
+		{
+			name:          "default",
+			authorization: "Bearer secret",
+			want:          true,
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer invalid",
+		},
+		{
+			name:          "invalid scheme",
+			authorization: "Basic secret",
+		},
+		{
+			name: "missing header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if got := Token(r, "secret"); got != tt.want {
+				t.Errorf("Token() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package access provides the access control of the restricted endpoints.
+package access
@@ -0,0 +1,64 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Info implements the build information.
+type Info struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	Date          string `json:"date"`
+	GoVersion     string `json:"goVersion"`
+	EngineVersion string `json:"engineVersion"`
+	Platform      string `json:"platform"`
+}
+
+var (
+	info = Info{
+		Name:    "-",
+		Version: "-",
+		Commit:  "-",
+		Date:    "-",
+	}
+	mu            sync.RWMutex
+	engineOnce    sync.Once
+	engineVersion string
+)
+
+// Set sets the build metadata of the program, usually injected at link time.
+func Set(name string, version string, commit string, date string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	info.Name = name
+	info.Version = version
+	info.Commit = commit
+	info.Date = date
+}
+
+// Get returns the build information.
+func Get() Info {
+	engineOnce.Do(func() {
+		engineVersion = engine()
+	})
+
+	mu.RLock()
+	i := info
+	mu.RUnlock()
+
+	i.GoVersion = runtime.Version()
+	i.EngineVersion = engineVersion
+	i.Platform = runtime.GOOS + "/" + runtime.GOARCH
+
+	return i
+}
+
+// String returns the build information on a single line.
+func (i Info) String() string {
+	return fmt.Sprintf("%s version %s, commit %s, built %s, %s, engine %s, %s", i.Name, i.Version, i.Commit, i.Date,
+		i.GoVersion, i.EngineVersion, i.Platform)
+}
@@ -0,0 +1,25 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	Set("test", "1.0.0", "abcdef", "2024-01-01")
+
+	got := Get()
+	if got.Name != "test" || got.Version != "1.0.0" || got.Commit != "abcdef" || got.Date != "2024-01-01" {
+		t.Errorf("Get() = %+v", got)
+	}
+	if got.GoVersion != runtime.Version() {
+		t.Errorf("Get() GoVersion = %v, want %v", got.GoVersion, runtime.Version())
+	}
+	if got.EngineVersion == "" {
+		t.Errorf("Get() EngineVersion = %v, want %v", got.EngineVersion, "not empty")
+	}
+	if want := "test version 1.0.0, commit abcdef, built 2024-01-01, " + runtime.Version() + ", engine " +
+		got.EngineVersion + ", " + runtime.GOOS + "/" + runtime.GOARCH; got.String() != want {
+		t.Errorf("Info.String() = %v, want %v", got.String(), want)
+	}
+}
@@ -0,0 +1,3 @@
+// Package buildinfo provides the build metadata of the running binary, exposed to the operators and the applications
+// so that fleet audits can verify exactly what is running where.
+package buildinfo
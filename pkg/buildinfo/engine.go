@@ -0,0 +1,12 @@
+//go:build cgo && !nojs
+
+package buildinfo
+
+import (
+	"github.com/bhuisgen/gomonkey"
+)
+
+// engine returns the version of the JavaScript engine.
+func engine() string {
+	return gomonkey.Version()
+}
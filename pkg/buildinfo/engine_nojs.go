@@ -0,0 +1,11 @@
+//go:build !cgo || nojs
+
+package buildinfo
+
+// engineNone is the engine version of a binary built without the JavaScript engine.
+const engineNone string = "none"
+
+// engine returns the version of the JavaScript engine.
+func engine() string {
+	return engineNone
+}
@@ -11,6 +11,10 @@ type Fetcher interface {
 	// Fetch fetches a resource from his name, provider and configuration.
 	Fetch(ctx context.Context, name string, provider string,
 		config map[string]interface{}) (*Resource, error)
+	// Preflight checks the reachability of a resource from his name, provider
+	// and configuration.
+	Preflight(ctx context.Context, name string, provider string,
+		config map[string]interface{}) error
 }
 
 // FetcherProviderModule is the interface of a provider module.
@@ -21,3 +25,11 @@ type FetcherProviderModule interface {
 	Fetch(ctx context.Context, name string, config map[string]interface{}) (
 		*Resource, error)
 }
+
+// FetcherProviderPreflightModule is the optional interface of a provider
+// module checking the reachability of a resource.
+type FetcherProviderPreflightModule interface {
+	// Preflight checks the reachability of a resource with the given
+	// configuration.
+	Preflight(ctx context.Context, name string, config map[string]interface{}) error
+}
@@ -11,7 +11,13 @@ import (
 // Each loader rule is processed by a parser module which triggers the fetcher
 // component to fetch resources and next the state component to store the
 // resources into the server state.
+//
+// After each execution, the loader notifies its subscribers with the names of
+// the resources whose data has changed since the previous execution.
 type Loader interface {
+	// Subscribe registers a function called with the names of the changed
+	// resources.
+	Subscribe(fn func(names []string))
 }
 
 // LoaderParserModule
@@ -21,3 +27,10 @@ type LoaderParserModule interface {
 	// Parse parses a resource.
 	Parse(ctx context.Context, store Store, fetcher Fetcher) error
 }
+
+// LoaderParserPreflightModule is the optional interface of a parser module
+// checking the reachability of its resources.
+type LoaderParserPreflightModule interface {
+	// Preflight checks the reachability of the parser resources.
+	Preflight(ctx context.Context, fetcher Fetcher) error
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+
 	"github.com/bhuisgen/neon/pkg/module"
 )
 
@@ -11,3 +13,10 @@ type Module interface {
 	// Init initializes a module with the given configuration.
 	Init(config map[string]interface{}) error
 }
+
+// PreflightModule is the optional interface of a module checking its
+// environment before the server starts.
+type PreflightModule interface {
+	// Preflight checks the module environment.
+	Preflight(ctx context.Context) error
+}
@@ -8,4 +8,6 @@ type Resource struct {
 	Data [][]byte
 	// The time-to-leave.
 	TTL time.Duration
+	// The fetch time.
+	Time time.Time
 }
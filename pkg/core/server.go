@@ -50,6 +50,8 @@ type ServerSite interface {
 	IsDefault() bool
 	// Store returns the store.
 	Store() Store
+	// Loader returns the loader.
+	Loader() Loader
 	// Server returns the server.
 	Server() Server
 	// RegisterMiddleware registers a middleware.
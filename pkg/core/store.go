@@ -1,5 +1,7 @@
 package core
 
+import "io"
+
 // Store is the interface of the store component.
 //
 // The store is responsible of the server state.
@@ -19,3 +21,19 @@ type StoreStorageModule interface {
 	// Store a resource.
 	StoreResource(name string, resource *Resource) error
 }
+
+// StoreStorageSnapshotModule is the optional interface of a storage module
+// exporting its resources to restore them on the next start.
+type StoreStorageSnapshotModule interface {
+	// Export writes the stored resources.
+	Export(w io.Writer) error
+	// Import stores the resources read from an export.
+	Import(r io.Reader) error
+}
+
+// StoreStorageStopModule is the optional interface of a storage module
+// running background tasks to stop on shutdown.
+type StoreStorageStopModule interface {
+	// Stop stops the background tasks of the storage.
+	Stop() error
+}
@@ -0,0 +1,6 @@
+// Package fault provides the fault injection used to verify the resilience of the server.
+//
+// The faults are disabled by default and must never be enabled in production. Once configured, a percentage of the
+// operations of each target is delayed or failed randomly, to check that the fallbacks like the stale cache serving or
+// the client-side rendering actually work.
+package fault
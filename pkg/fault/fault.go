@@ -0,0 +1,81 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Target is the kind of the operations receiving the faults.
+type Target string
+
+const (
+	// Fetch is the target of the resource fetches.
+	Fetch Target = "fetch"
+	// VM is the target of the VM executions.
+	VM Target = "vm"
+	// Cache is the target of the render cache operations.
+	Cache Target = "cache"
+)
+
+// ErrInjected is the error returned by a failed operation.
+var ErrInjected = errors.New("injected fault")
+
+// Rule implements the faults of a target.
+type Rule struct {
+	// The percentage of failed operations.
+	ErrorRate int
+	// The percentage of delayed operations.
+	DelayRate int
+	// The delay of the delayed operations.
+	Delay time.Duration
+}
+
+var (
+	rules  atomic.Pointer[map[Target]Rule]
+	random = rand.Intn
+)
+
+// Configure replaces the rules of all targets. An empty map disables the fault injection.
+func Configure(r map[Target]Rule) {
+	if len(r) == 0 {
+		rules.Store(nil)
+		return
+	}
+
+	m := make(map[Target]Rule, len(r))
+	for target, rule := range r {
+		m[target] = rule
+	}
+	rules.Store(&m)
+}
+
+// Inject delays randomly the operation of the given target, then returns ErrInjected if it must fail or the context
+// error if the context is done during the delay.
+func Inject(ctx context.Context, target Target) error {
+	m := rules.Load()
+	if m == nil {
+		return nil
+	}
+	rule, ok := (*m)[target]
+	if !ok {
+		return nil
+	}
+
+	if rule.Delay > 0 && random(100) < rule.DelayRate {
+		t := time.NewTimer(rule.Delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	if random(100) < rule.ErrorRate {
+		return ErrInjected
+	}
+
+	return nil
+}
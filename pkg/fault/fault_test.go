@@ -0,0 +1,79 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   map[Target]Rule
+		target  Target
+		random  int
+		wantErr error
+	}{
+		{
+			name:   "disabled",
+			target: Fetch,
+		},
+		{
+			name: "other target",
+			rules: map[Target]Rule{
+				VM: {ErrorRate: 100},
+			},
+			target: Fetch,
+		},
+		{
+			name: "error",
+			rules: map[Target]Rule{
+				Fetch: {ErrorRate: 50},
+			},
+			target:  Fetch,
+			random:  49,
+			wantErr: ErrInjected,
+		},
+		{
+			name: "no error",
+			rules: map[Target]Rule{
+				Fetch: {ErrorRate: 50},
+			},
+			target: Fetch,
+			random: 50,
+		},
+		{
+			name: "delay",
+			rules: map[Target]Rule{
+				Cache: {DelayRate: 100, Delay: time.Millisecond},
+			},
+			target: Cache,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			random = func(n int) int { return tt.random }
+			defer Configure(nil)
+
+			Configure(tt.rules)
+			if err := Inject(context.Background(), tt.target); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Inject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInjectCanceled(t *testing.T) {
+	random = func(n int) int { return 0 }
+	defer Configure(nil)
+
+	Configure(map[Target]Rule{
+		VM: {DelayRate: 100, Delay: time.Minute},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Inject(ctx, VM); !errors.Is(err, context.Canceled) {
+		t.Errorf("Inject() error = %v, wantErr %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,5 @@
+// Package healthcheck provides the health checks of a running server shared by the neon healthcheck command and the
+// standalone healthcheck binary.
+//
+// It only depends on the standard library so that the standalone binary stays small.
+package healthcheck
@@ -0,0 +1,149 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Options implements the options of a health check.
+type Options struct {
+	// CACert is the TLS CA file verifying the server certificate.
+	CACert string
+	// Cert is the TLS client certificate file.
+	Cert string
+	// Key is the TLS client key file.
+	Key string
+	// Insecure disables the verification of the server certificate.
+	Insecure bool
+	// LocalAddr is the local source address.
+	LocalAddr string
+	// Unix is the path of an unix socket to connect to instead of the URL host.
+	Unix string
+	// Status is the expected status code. If zero, any status code is accepted except the not ready one.
+	Status int
+	// Timeout is the timeout of the check.
+	Timeout time.Duration
+}
+
+// ErrNotReady is the error returned when the server is alive but not ready to serve the requests.
+var ErrNotReady = errors.New("not ready")
+
+// Check performs a request to the given URL of a server.
+//
+// A server responding with the status 503 Service Unavailable has no router ready yet or sheds the requests, and is
+// reported as not ready unless this status is explicitly expected.
+func Check(ctx context.Context, url string, options Options) error {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: options.Insecure,
+	}
+	if options.CACert != "" {
+		ca, err := os.ReadFile(options.CACert)
+		if err != nil {
+			return fmt.Errorf("read ca file: %v", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(ca) {
+			return errors.New("append ca")
+		}
+
+		tlsConfig.RootCAs = caCertPool
+	}
+	if options.Cert != "" && options.Key != "" {
+		c, err := tls.LoadX509KeyPair(options.Cert, options.Key)
+		if err != nil {
+			return fmt.Errorf("load keypair: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{c}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: options.Timeout,
+	}
+	if options.LocalAddr != "" {
+		ip := net.ParseIP(options.LocalAddr)
+		if ip == nil {
+			return fmt.Errorf("invalid local address: %s", options.LocalAddr)
+		}
+
+		dialer.LocalAddr = &net.TCPAddr{
+			IP: ip,
+		}
+	}
+	// the checked servers are local so the proxy of the environment is never used
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   options.Timeout,
+		ResponseHeaderTimeout: options.Timeout,
+		ExpectContinueTimeout: options.Timeout,
+		ForceAttemptHTTP2:     true,
+	}
+	if options.Unix != "" {
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", options.Unix)
+		}
+	}
+
+	client := http.Client{
+		Transport: transport,
+		Timeout:   options.Timeout,
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %v", err)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("send request: %v", err)
+	}
+	defer response.Body.Close()
+	if _, err := io.Copy(io.Discard, response.Body); err != nil {
+		return fmt.Errorf("read response: %v", err)
+	}
+
+	if options.Status > 0 {
+		if response.StatusCode != options.Status {
+			return fmt.Errorf("status code: %d", response.StatusCode)
+		}
+		return nil
+	}
+	if response.StatusCode == http.StatusServiceUnavailable {
+		return ErrNotReady
+	}
+
+	return nil
+}
+
+// Target returns the URL of the given path on a server endpoint, connecting to the loopback address if the endpoint
+// listens on all the addresses.
+func Target(endpoint string, path string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint: %v", err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint host: %v", err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	u.Host = net.JoinHostPort(host, port)
+	u.Path = path
+
+	return u.String(), nil
+}
@@ -0,0 +1,133 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		options Options
+		wantErr error
+	}{
+		{
+			name:   "default",
+			status: http.StatusOK,
+		},
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "not ready",
+			status:  http.StatusServiceUnavailable,
+			wantErr: ErrNotReady,
+		},
+		{
+			name:   "expected status",
+			status: http.StatusServiceUnavailable,
+			options: Options{
+				Status: http.StatusServiceUnavailable,
+			},
+		},
+		{
+			name:   "error unexpected status",
+			status: http.StatusNotFound,
+			options: Options{
+				Status: http.StatusOK,
+			},
+			wantErr: errors.New("status code: 404"),
+		},
+		{
+			name:   "error local address",
+			status: http.StatusOK,
+			options: Options{
+				LocalAddr: "invalid",
+			},
+			wantErr: errors.New("invalid local address: invalid"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			tt.options.Timeout = time.Second
+			err := Check(context.Background(), server.URL, tt.options)
+			if (err != nil) != (tt.wantErr != nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckUnix(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "neon.sock")
+	ln, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	if err := Check(context.Background(), "http://localhost/", Options{Unix: name, Timeout: time.Second}); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		path     string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "all addresses",
+			endpoint: "http://:8080",
+			path:     "/",
+			want:     "http://localhost:8080/",
+		},
+		{
+			name:     "unspecified address",
+			endpoint: "https://[::]:8443",
+			path:     "/status",
+			want:     "https://localhost:8443/status",
+		},
+		{
+			name:     "address",
+			endpoint: "http://127.0.0.1:8080",
+			path:     "/",
+			want:     "http://127.0.0.1:8080/",
+		},
+		{
+			name:     "error missing port",
+			endpoint: "http://localhost",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Target(tt.endpoint, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Target() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Target() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package httpcache provides a shared HTTP cache of the upstream responses keyed by their full URL, following the
+// standard Cache-Control, Expires and Vary semantics.
+package httpcache
@@ -0,0 +1,321 @@
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache implements a shared cache of HTTP responses keyed by their full URL.
+//
+// The least recently used URLs are evicted once the maximum number of entries is reached.
+type Cache struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	mu         sync.Mutex
+	now        func() time.Time
+}
+
+// entry implements the responses cached for an URL, one for each variant selected by the Vary header.
+type entry struct {
+	key      string
+	vary     []string
+	variants map[string]*response
+}
+
+// response implements a cached response.
+type response struct {
+	status  int
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// New creates a new cache of the given maximum number of entries.
+func New(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Len returns the number of entries of the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// get returns a new response from the fresh cached response matching the request.
+func (c *Cache) get(req *http.Request) (*http.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[req.URL.String()]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	variant := variantKey(e.vary, req.Header)
+	r, ok := e.variants[variant]
+	if !ok {
+		return nil, false
+	}
+	now := c.now()
+	if !now.Before(r.expires) {
+		delete(e.variants, variant)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+
+	header := r.header.Clone()
+	header.Set("Age", strconv.Itoa(int(now.Sub(r.stored).Seconds())))
+
+	return &http.Response{
+		Status:        strconv.Itoa(r.status) + " " + http.StatusText(r.status),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}, true
+}
+
+// set stores the response of the request, fresh for the given duration.
+func (c *Cache) set(req *http.Request, resp *http.Response, body []byte, age time.Duration, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := req.URL.String()
+	vary := varyHeaders(resp.Header)
+	now := c.now()
+
+	element, ok := c.entries[key]
+	var e *entry
+	if ok {
+		e = element.Value.(*entry)
+		if strings.Join(e.vary, ",") != strings.Join(vary, ",") {
+			e.vary = vary
+			e.variants = make(map[string]*response)
+		}
+		for variant, r := range e.variants {
+			if !now.Before(r.expires) {
+				delete(e.variants, variant)
+			}
+		}
+		c.lru.MoveToFront(element)
+	} else {
+		e = &entry{
+			key:      key,
+			vary:     vary,
+			variants: make(map[string]*response),
+		}
+		c.entries[key] = c.lru.PushFront(e)
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Age")
+	e.variants[variantKey(vary, req.Header)] = &response{
+		status:  resp.StatusCode,
+		header:  header,
+		body:    body,
+		stored:  now.Add(-age),
+		expires: now.Add(ttl),
+	}
+
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// Transport implements a http.RoundTripper serving the fresh responses from a cache.
+//
+// Only the GET requests without conditional or range headers are served from the cache. The responses are stored only
+// if they are explicitly fresh for a shared cache.
+type Transport struct {
+	Cache     *Cache
+	Transport http.RoundTripper
+}
+
+// RoundTrip executes a single HTTP transaction, served from the cache if possible.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if !cacheable(req) {
+		return transport.RoundTrip(req)
+	}
+	if _, ok := parseCacheControl(req.Header)["no-cache"]; !ok {
+		if resp, ok := t.Cache.get(req); ok {
+			return resp, nil
+		}
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	age, ttl, ok := freshness(req, resp, t.Cache.now())
+	if !ok {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.Cache.set(req, resp, body, age, ttl)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return resp, nil
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// cacheable returns true if the request can be served from the cache.
+func cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, name := range []string{"Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since"} {
+		if req.Header.Get(name) != "" {
+			return false
+		}
+	}
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+
+	return true
+}
+
+// freshness returns the current age and the remaining freshness lifetime of the response, or false if the response
+// cannot be stored by a shared cache.
+func freshness(req *http.Request, resp *http.Response, now time.Time) (time.Duration, time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return 0, 0, false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return 0, 0, false
+		}
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return 0, 0, false
+		}
+	}
+	_, public := cc["public"]
+	_, shared := cc["s-maxage"]
+	if req.Header.Get("Authorization") != "" && !public && !shared {
+		return 0, 0, false
+	}
+
+	var lifetime time.Duration
+	if v, ok := cc["s-maxage"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, false
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v, ok := cc["max-age"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, false
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v := resp.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0, 0, false
+		}
+		date := now
+		if v := resp.Header.Get("Date"); v != "" {
+			if d, err := http.ParseTime(v); err == nil {
+				date = d
+			}
+		}
+		lifetime = expires.Sub(date)
+	} else {
+		return 0, 0, false
+	}
+
+	var age time.Duration
+	if v := resp.Header.Get("Age"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			age = time.Duration(seconds) * time.Second
+		}
+	}
+	if lifetime <= age {
+		return 0, 0, false
+	}
+
+	return age, lifetime - age, true
+}
+
+// parseCacheControl returns the directives of the Cache-Control header.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(v), "\"")
+		}
+	}
+
+	return directives
+}
+
+// varyHeaders returns the canonical names of the headers listed in the Vary header.
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+// variantKey returns the key of the variant selected by the values of the given request headers.
+func variantKey(vary []string, header http.Header) string {
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
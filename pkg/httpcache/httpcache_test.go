@@ -0,0 +1,207 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheControl  string
+		expires       string
+		vary          string
+		status        int
+		requests      []http.Header
+		wantUpstreams int64
+	}{
+		{
+			name:          "default",
+			cacheControl:  "max-age=60",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 1,
+		},
+		{
+			name:          "shared max age",
+			cacheControl:  "max-age=0, s-maxage=60",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 1,
+		},
+		{
+			name:          "expires",
+			expires:       time.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 1,
+		},
+		{
+			name:          "no freshness",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "no store",
+			cacheControl:  "no-store",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "private",
+			cacheControl:  "private, max-age=60",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "error status",
+			cacheControl:  "max-age=60",
+			status:        http.StatusInternalServerError,
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 2,
+		},
+		{
+			name:         "vary",
+			cacheControl: "max-age=60",
+			vary:         "Accept-Language",
+			requests: []http.Header{
+				{"Accept-Language": {"en"}},
+				{"Accept-Language": {"fr"}},
+				{"Accept-Language": {"en"}},
+			},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "vary all",
+			cacheControl:  "max-age=60",
+			vary:          "*",
+			requests:      []http.Header{{}, {}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "authorization",
+			cacheControl:  "max-age=60",
+			requests:      []http.Header{{"Authorization": {"Bearer test"}}, {"Authorization": {"Bearer test"}}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "authorization public",
+			cacheControl:  "public, max-age=60",
+			requests:      []http.Header{{"Authorization": {"Bearer test"}}, {"Authorization": {"Bearer test"}}},
+			wantUpstreams: 1,
+		},
+		{
+			name:          "request no cache",
+			cacheControl:  "max-age=60",
+			requests:      []http.Header{{}, {"Cache-Control": {"no-cache"}}},
+			wantUpstreams: 2,
+		},
+		{
+			name:          "conditional request",
+			cacheControl:  "max-age=60",
+			requests:      []http.Header{{}, {"If-None-Match": {"\"test\""}}},
+			wantUpstreams: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreams atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreams.Add(1)
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				if tt.expires != "" {
+					w.Header().Set("Expires", tt.expires)
+				}
+				if tt.vary != "" {
+					w.Header().Set("Vary", tt.vary)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = w.Write([]byte("test"))
+			}))
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: &Transport{
+					Cache:     New(10),
+					Transport: server.Client().Transport,
+				},
+			}
+			for _, header := range tt.requests {
+				req, err := http.NewRequest(http.MethodGet, server.URL+"/test?id=1", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header = header
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("Transport.RoundTrip() error = %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if string(body) != "test" {
+					t.Errorf("Transport.RoundTrip() body = %s, want %s", body, "test")
+				}
+			}
+			if got := upstreams.Load(); got != tt.wantUpstreams {
+				t.Errorf("Transport.RoundTrip() upstreams = %v, want %v", got, tt.wantUpstreams)
+			}
+		})
+	}
+}
+
+func TestCacheExpire(t *testing.T) {
+	now := time.Now()
+	c := New(10)
+	c.now = func() time.Time {
+		return now
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/test", nil)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+	}
+	c.set(req, resp, []byte("test"), 10*time.Second, time.Minute)
+
+	got, ok := c.get(req)
+	if !ok {
+		t.Fatalf("Cache.get() = %v, want %v", ok, true)
+	}
+	if age := got.Header.Get("Age"); age != "10" {
+		t.Errorf("Cache.get() Age = %v, want %v", age, "10")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get(req); ok {
+		t.Errorf("Cache.get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := New(2)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+	}
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+		if _, ok := c.get(req); !ok {
+			c.set(req, resp, nil, 0, time.Minute)
+		}
+	}
+
+	if got := c.Len(); got != 2 {
+		t.Errorf("Cache.Len() = %v, want %v", got, 2)
+	}
+	if _, ok := c.get(httptest.NewRequest(http.MethodGet, "http://localhost/b", nil)); ok {
+		t.Errorf("Cache.get() = %v, want %v", ok, false)
+	}
+	if _, ok := c.get(httptest.NewRequest(http.MethodGet, "http://localhost/a", nil)); !ok {
+		t.Errorf("Cache.get() = %v, want %v", ok, true)
+	}
+}
@@ -0,0 +1,2 @@
+// Package include provides the primitives to embed the responses of internal routes into a response.
+package include
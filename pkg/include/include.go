@@ -0,0 +1,67 @@
+package include
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPath is the error returned when an include path is not an internal route.
+var ErrInvalidPath = errors.New("invalid include path")
+
+// includeTagPrefix is the prefix of an include tag used to skip the responses without any include.
+const includeTagPrefix = "<neon-include "
+
+// includeTagRegexp matches an include tag.
+var includeTagRegexp = regexp.MustCompile(`<neon-include src="([^"]*)"></neon-include>`)
+
+// includeContextKey is the context key of a sub-request.
+type includeContextKey struct{}
+
+// Tag returns the tag including the response of the given internal route.
+func Tag(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return "", ErrInvalidPath
+	}
+
+	return `<neon-include src="` + html.EscapeString(path) + `"></neon-include>`, nil
+}
+
+// Contains returns true if the given body contains at least an include tag.
+func Contains(body []byte) bool {
+	return bytes.Contains(body, []byte(includeTagPrefix))
+}
+
+// Replace replaces each include tag of the body by the data returned for its path.
+//
+// At most max tags are replaced if max is positive, the remaining tags being removed.
+func Replace(body []byte, max int, fn func(path string) []byte) []byte {
+	var count int
+
+	return includeTagRegexp.ReplaceAllFunc(body, func(tag []byte) []byte {
+		count++
+		if max > 0 && count > max {
+			return nil
+		}
+		path := html.UnescapeString(string(includeTagRegexp.FindSubmatch(tag)[1]))
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+			return nil
+		}
+
+		return fn(path)
+	})
+}
+
+// NewContext returns a new context marking a sub-request.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeContextKey{}, true)
+}
+
+// FromContext returns true if the context is the one of a sub-request.
+func FromContext(ctx context.Context) bool {
+	sub, _ := ctx.Value(includeContextKey{}).(bool)
+	return sub
+}
@@ -0,0 +1,79 @@
+package include
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			path: "/fragments/header?a=1&b=2",
+			want: `<neon-include src="/fragments/header?a=1&amp;b=2"></neon-include>`,
+		},
+		{
+			name:    "relative path",
+			path:    "fragments/header",
+			wantErr: true,
+		},
+		{
+			name:    "external url",
+			path:    "//localhost/header",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Tag(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Tag() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Tag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tag, _ := Tag("/header?a=1&b=2")
+	body := []byte("<body>" + tag + tag + `<neon-include src="//external"></neon-include></body>`)
+	if !Contains(body) {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+
+	var paths []string
+	got := Replace(body, 0, func(path string) []byte {
+		paths = append(paths, path)
+		return []byte("<header>")
+	})
+	if want := "<body><header><header></body>"; string(got) != want {
+		t.Errorf("Replace() = %s, want %s", got, want)
+	}
+	if len(paths) != 2 || paths[0] != "/header?a=1&b=2" {
+		t.Errorf("Replace() paths = %v", paths)
+	}
+
+	got = Replace(body, 1, func(path string) []byte {
+		return []byte("<header>")
+	})
+	if want := "<body><header></body>"; string(got) != want {
+		t.Errorf("Replace() = %s, want %s", got, want)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got {
+		t.Errorf("FromContext() got %v, want %v", got, false)
+	}
+	if got := FromContext(NewContext(context.Background())); !got {
+		t.Errorf("FromContext() got %v, want %v", got, true)
+	}
+}
@@ -0,0 +1,2 @@
+// Package listen provides the binding of the addresses of the listener modules.
+package listen
@@ -0,0 +1,47 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+// Listen binds the given addresses and registers their network listeners to the given server listener.
+//
+// The inherited network listeners of the server listener are reused if there is one for each address. If an address
+// cannot be bound, the network listeners already bound are closed to release their addresses and none is registered.
+func Listen(listener core.ServerListener, addrs []string,
+	listen func(network string, addr string) (net.Listener, error)) ([]net.Listener, error) {
+	if inherited := listener.Listeners(); len(inherited) > 0 && len(inherited) == len(addrs) {
+		return inherited, nil
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := listen("tcp", addr)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("listen %s: %v", addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	for _, ln := range listeners {
+		if err := listener.RegisterListener(ln); err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("register listener: %v", err)
+		}
+	}
+
+	return listeners, nil
+}
+
+// closeListeners closes the given network listeners.
+func closeListeners(listeners []net.Listener) {
+	for _, ln := range listeners {
+		if ln != nil {
+			_ = ln.Close()
+		}
+	}
+}
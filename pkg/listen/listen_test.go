@@ -0,0 +1,112 @@
+package listen
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+type testServerListener struct {
+	listeners   []net.Listener
+	registered  []net.Listener
+	errRegister bool
+}
+
+func (l *testServerListener) Name() string {
+	return "test"
+}
+
+func (l *testServerListener) Listeners() []net.Listener {
+	return l.listeners
+}
+
+func (l *testServerListener) RegisterListener(listener net.Listener) error {
+	if l.errRegister {
+		return errors.New("test error")
+	}
+	l.registered = append(l.registered, listener)
+	return nil
+}
+
+var _ core.ServerListener = (*testServerListener)(nil)
+
+type testListener struct {
+	net.Listener
+	closed bool
+}
+
+func (l *testListener) Close() error {
+	l.closed = true
+	return nil
+}
+
+func TestListen(t *testing.T) {
+	inherited := []net.Listener{&testListener{}, &testListener{}}
+
+	tests := []struct {
+		name           string
+		listener       *testServerListener
+		addrs          []string
+		errAddr        string
+		wantListeners  int
+		wantRegistered int
+		wantErr        bool
+	}{
+		{
+			name:           "default",
+			listener:       &testServerListener{},
+			addrs:          []string{"0.0.0.0:80", "[::]:80"},
+			wantListeners:  2,
+			wantRegistered: 2,
+		},
+		{
+			name: "inherited",
+			listener: &testServerListener{
+				listeners: inherited,
+			},
+			addrs:         []string{"0.0.0.0:80", "[::]:80"},
+			wantListeners: 2,
+		},
+		{
+			name:     "error listen",
+			listener: &testServerListener{},
+			addrs:    []string{"0.0.0.0:80", "[::]:80"},
+			errAddr:  "[::]:80",
+			wantErr:  true,
+		},
+		{
+			name: "error register",
+			listener: &testServerListener{
+				errRegister: true,
+			},
+			addrs:   []string{"0.0.0.0:80", "[::]:80"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bound []*testListener
+			got, err := Listen(tt.listener, tt.addrs, func(network, addr string) (net.Listener, error) {
+				if addr == tt.errAddr {
+					return nil, errors.New("test error")
+				}
+				ln := &testListener{}
+				bound = append(bound, ln)
+				return ln, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Listen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantListeners || len(tt.listener.registered) != tt.wantRegistered {
+				t.Errorf("Listen() listeners = %v, registered = %v", got, tt.listener.registered)
+			}
+			for _, ln := range bound {
+				if ln.closed != tt.wantErr {
+					t.Errorf("Listen() closed = %v, want %v", ln.closed, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	defaultLevel slog.Level = slog.LevelInfo
+	levelExpires time.Time
+	levelTimer   *time.Timer
+	muLevel      sync.Mutex
+
+	// levelCycle is the order of the levels cycled towards more verbosity.
+	levelCycle = []slog.Level{slog.LevelError, slog.LevelWarn, slog.LevelInfo, slog.LevelDebug}
+)
+
+// ParseLevel parses a level name like debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return level, fmt.Errorf("invalid level %s", s)
+	}
+
+	return level, nil
+}
+
+// SetDefaultLevel sets the common log level and the level restored once a temporary level expires.
+func SetDefaultLevel(level slog.Level) {
+	muLevel.Lock()
+	defer muLevel.Unlock()
+
+	defaultLevel = level
+	stopLevelTimer()
+	ProgramLevel.Set(level)
+}
+
+// SetLevel sets the common log level for the given duration, after which the default level is restored. A zero
+// duration sets the level until the next change.
+func SetLevel(level slog.Level, ttl time.Duration) {
+	muLevel.Lock()
+	defer muLevel.Unlock()
+
+	setLevel(level, ttl)
+}
+
+// CycleLevel sets the next more verbose common log level for the given duration, wrapping from debug to error, and
+// returns it.
+func CycleLevel(ttl time.Duration) slog.Level {
+	muLevel.Lock()
+	defer muLevel.Unlock()
+
+	current := ProgramLevel.Level()
+	next := levelCycle[0]
+	for index, level := range levelCycle {
+		if level == current {
+			next = levelCycle[(index+1)%len(levelCycle)]
+			break
+		}
+	}
+	setLevel(next, ttl)
+
+	return next
+}
+
+// Level returns the common log level and the time at which the default level is restored, zero if the level is not
+// temporary.
+func Level() (slog.Level, time.Time) {
+	muLevel.Lock()
+	defer muLevel.Unlock()
+
+	return ProgramLevel.Level(), levelExpires
+}
+
+// setLevel sets the common log level for the given duration.
+//
+// The level lock must be held.
+func setLevel(level slog.Level, ttl time.Duration) {
+	stopLevelTimer()
+	ProgramLevel.Set(level)
+	if ttl <= 0 || level == defaultLevel {
+		return
+	}
+
+	levelExpires = time.Now().Add(ttl)
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		muLevel.Lock()
+		defer muLevel.Unlock()
+
+		if levelTimer != timer {
+			return
+		}
+		levelTimer = nil
+		levelExpires = time.Time{}
+		ProgramLevel.Set(defaultLevel)
+	})
+	levelTimer = timer
+}
+
+// stopLevelTimer cancels the restoration of the default level.
+//
+// The level lock must be held.
+func stopLevelTimer() {
+	if levelTimer != nil {
+		levelTimer.Stop()
+		levelTimer = nil
+	}
+	levelExpires = time.Time{}
+}
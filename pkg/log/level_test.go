@@ -0,0 +1,77 @@
+package log
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    slog.Level
+		wantErr bool
+	}{
+		{
+			name: "debug",
+			s:    "debug",
+			want: slog.LevelDebug,
+		},
+		{
+			name: "upper case",
+			s:    "WARN",
+			want: slog.LevelWarn,
+		},
+		{
+			name:    "invalid",
+			s:       "verbose",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	SetDefaultLevel(slog.LevelInfo)
+	defer SetDefaultLevel(slog.LevelInfo)
+
+	SetLevel(slog.LevelDebug, 20*time.Millisecond)
+	if level, expires := Level(); level != slog.LevelDebug || expires.IsZero() {
+		t.Errorf("Level() = %v, %v", level, expires)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if level, expires := Level(); level != slog.LevelInfo || !expires.IsZero() {
+		t.Errorf("Level() = %v, %v, want %v, %v", level, expires, slog.LevelInfo, time.Time{})
+	}
+
+	SetLevel(slog.LevelWarn, 0)
+	if level, expires := Level(); level != slog.LevelWarn || !expires.IsZero() {
+		t.Errorf("Level() = %v, %v, want %v, %v", level, expires, slog.LevelWarn, time.Time{})
+	}
+}
+
+func TestCycleLevel(t *testing.T) {
+	SetDefaultLevel(slog.LevelInfo)
+	defer SetDefaultLevel(slog.LevelInfo)
+
+	for _, want := range []slog.Level{slog.LevelDebug, slog.LevelError, slog.LevelWarn, slog.LevelInfo} {
+		if got := CycleLevel(time.Minute); got != want {
+			t.Errorf("CycleLevel() = %v, want %v", got, want)
+		}
+	}
+	if _, expires := Level(); !expires.IsZero() {
+		t.Errorf("Level() expires = %v, want %v", expires, time.Time{})
+	}
+}
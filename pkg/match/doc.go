@@ -0,0 +1,2 @@
+// Package match provides the request constraints shared by the rules of the server modules.
+package match
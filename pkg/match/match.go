@@ -0,0 +1,84 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/bhuisgen/neon/pkg/pattern"
+)
+
+// Constraint implements the host and method constraints of a rule.
+type Constraint struct {
+	host    *regexp.Regexp
+	methods []string
+}
+
+// NewConstraint returns the constraint of the given host regular expression and methods, or nil if the rule has no
+// constraint.
+func NewConstraint(host string, methods []string) (*Constraint, error) {
+	if host == "" && len(methods) == 0 {
+		return nil, nil
+	}
+
+	c := &Constraint{}
+	if host != "" {
+		re, err := pattern.Compile(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host: %v", err)
+		}
+		c.host = re
+	}
+	for _, method := range methods {
+		if method == "" || strings.ContainsAny(method, " \t\r\n") {
+			return nil, errors.New("invalid method")
+		}
+		c.methods = append(c.methods, strings.ToUpper(method))
+	}
+
+	return c, nil
+}
+
+// HasHost returns true if the constraint matches the request host.
+func (c *Constraint) HasHost() bool {
+	return c != nil && c.host != nil
+}
+
+// Match returns true if the request satisfies the constraint. A nil constraint matches all requests.
+//
+// The host is matched without its port and in lowercase.
+func (c *Constraint) Match(r *http.Request) bool {
+	if c == nil {
+		return true
+	}
+	if c.host != nil && !c.host.MatchString(Host(r)) {
+		return false
+	}
+	if len(c.methods) > 0 {
+		var found bool
+		for _, method := range c.methods {
+			if method == r.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Host returns the host of the request without its port and in lowercase.
+func Host(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+	}
+
+	return strings.ToLower(host)
+}
@@ -0,0 +1,117 @@
+package match
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		methods []string
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "none",
+			wantNil: true,
+		},
+		{
+			name:    "host and methods",
+			host:    `^example\.com$`,
+			methods: []string{"get", "HEAD"},
+		},
+		{
+			name:    "invalid host",
+			host:    "(",
+			wantNil: true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid method",
+			methods: []string{""},
+			wantNil: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewConstraint(tt.host, tt.methods)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("NewConstraint() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestConstraintMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		methods []string
+		r       *http.Request
+		want    bool
+	}{
+		{
+			name: "none",
+			r:    &http.Request{Method: http.MethodGet, Host: "example.com"},
+			want: true,
+		},
+		{
+			name: "host",
+			host: `^example\.com$`,
+			r:    &http.Request{Method: http.MethodGet, Host: "EXAMPLE.com:8080"},
+			want: true,
+		},
+		{
+			name: "host mismatch",
+			host: `^example\.com$`,
+			r:    &http.Request{Method: http.MethodGet, Host: "example.org"},
+		},
+		{
+			name:    "method",
+			methods: []string{"get"},
+			r:       &http.Request{Method: http.MethodGet, Host: "example.com"},
+			want:    true,
+		},
+		{
+			name:    "method mismatch",
+			methods: []string{"post"},
+			r:       &http.Request{Method: http.MethodGet, Host: "example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConstraint(tt.host, tt.methods)
+			if err != nil {
+				t.Fatalf("NewConstraint() error = %v", err)
+			}
+			if got := c.Match(tt.r); got != tt.want {
+				t.Errorf("Constraint.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "example.com", want: "example.com"},
+		{host: "Example.com:8080", want: "example.com"},
+		{host: "[::1]:8080", want: "::1"},
+		{host: "[::1]", want: "::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := Host(&http.Request{Host: tt.host}); got != tt.want {
+				t.Errorf("Host() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package metrics provides the counters of the process exported by the status handler.
+package metrics
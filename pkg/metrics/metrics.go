@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var counters sync.Map
+
+// Counter returns the counter of the given name, which is created on the first call and shared by all the callers.
+func Counter(name string) *atomic.Uint64 {
+	if counter, ok := counters.Load(name); ok {
+		return counter.(*atomic.Uint64)
+	}
+	counter, _ := counters.LoadOrStore(name, new(atomic.Uint64))
+	return counter.(*atomic.Uint64)
+}
+
+// Counters returns the current values of the counters by name.
+func Counters() map[string]uint64 {
+	values := make(map[string]uint64)
+	counters.Range(func(key, value any) bool {
+		values[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return values
+}
@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	counter := Counter("test.counter")
+	counter.Add(2)
+
+	if got := Counter("test.counter"); got != counter {
+		t.Errorf("Counter() = %p, want %p", got, counter)
+	}
+	if got := Counters()["test.counter"]; got != 2 {
+		t.Errorf("Counters() = %v, want %v", got, 2)
+	}
+}
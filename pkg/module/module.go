@@ -29,6 +29,8 @@ type ModuleInfo struct {
 var (
 	modules     = make(map[ModuleID]ModuleInfo)
 	modulesLock sync.RWMutex
+	loads       int
+	loadsLock   sync.Mutex
 )
 
 // Register registers a module.
@@ -53,7 +55,17 @@ func Lookup(id ModuleID) (ModuleInfo, error) {
 }
 
 // Load loads all the registered modules.
+//
+// The loads are counted so that several instances can run in the same process, the modules are loaded only once.
 func Load() {
+	loadsLock.Lock()
+	defer loadsLock.Unlock()
+
+	loads++
+	if loads > 1 {
+		return
+	}
+
 	modulesLock.RLock()
 	for _, m := range modules {
 		m.LoadModule()
@@ -62,7 +74,20 @@ func Load() {
 }
 
 // Unload unload all the registered modules.
+//
+// The modules are unloaded only by the call matching the first load.
 func Unload() {
+	loadsLock.Lock()
+	defer loadsLock.Unlock()
+
+	if loads == 0 {
+		return
+	}
+	loads--
+	if loads > 0 {
+		return
+	}
+
 	modulesLock.RLock()
 	for _, m := range modules {
 		m.UnloadModule()
@@ -86,3 +86,31 @@ func TestGetModule(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadUnload(t *testing.T) {
+	var loaded, unloaded int
+	modulesLock.Lock()
+	modules = map[ModuleID]ModuleInfo{
+		"test": {
+			ID:           "test",
+			LoadModule:   func() { loaded++ },
+			UnloadModule: func() { unloaded++ },
+		},
+	}
+	modulesLock.Unlock()
+
+	Load()
+	Load()
+	if loaded != 1 {
+		t.Errorf("Load() loaded = %v, want %v", loaded, 1)
+	}
+	Unload()
+	if unloaded != 0 {
+		t.Errorf("Unload() unloaded = %v, want %v", unloaded, 0)
+	}
+	Unload()
+	Unload()
+	if unloaded != 1 {
+		t.Errorf("Unload() unloaded = %v, want %v", unloaded, 1)
+	}
+}
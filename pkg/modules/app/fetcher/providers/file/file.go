@@ -19,6 +19,7 @@ type fileProvider struct {
 	config     *fileProviderConfig
 	logger     *slog.Logger
 	osReadFile func(name string) ([]byte, error)
+	osOpen     func(name string) (*os.File, error)
 }
 
 // fileProviderConfig implements the file provider configuration.
@@ -39,6 +40,11 @@ func fileOsReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
+// fileOsOpen redirects to os.Open.
+func fileOsOpen(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
 // init initializes the package.
 func init() {
 	module.Register(fileProvider{})
@@ -54,6 +60,7 @@ func (p fileProvider) ModuleInfo() module.ModuleInfo {
 			return &fileProvider{
 				logger:     slog.New(log.NewHandler(os.Stderr, string(fileModuleID), nil)),
 				osReadFile: fileOsReadFile,
+				osOpen:     fileOsOpen,
 			}
 		},
 	}
@@ -93,4 +100,21 @@ func (p *fileProvider) Fetch(ctx context.Context, name string, config map[string
 	}, nil
 }
 
+// Preflight checks that the resource file is readable.
+func (p *fileProvider) Preflight(ctx context.Context, name string, config map[string]interface{}) error {
+	var cfg fileResourceConfig
+	if err := mapstructure.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("parse resource %s config: %v", name, err)
+	}
+
+	f, err := p.osOpen(cfg.Path)
+	if err != nil {
+		return fmt.Errorf("open file %s: %v", cfg.Path, err)
+	}
+	_ = f.Close()
+
+	return nil
+}
+
 var _ core.FetcherProviderModule = (*fileProvider)(nil)
+var _ core.FetcherProviderPreflightModule = (*fileProvider)(nil)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"os"
 	"reflect"
 	"testing"
 
@@ -110,3 +111,60 @@ func TestFileProviderFetch(t *testing.T) {
 		})
 	}
 }
+
+func TestFileProviderPreflight(t *testing.T) {
+	type fields struct {
+		config     *fileProviderConfig
+		logger     *slog.Logger
+		osReadFile func(name string) ([]byte, error)
+		osOpen     func(name string) (*os.File, error)
+	}
+	type args struct {
+		ctx    context.Context
+		name   string
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				osOpen: func(name string) (*os.File, error) {
+					return os.CreateTemp(t.TempDir(), "test")
+				},
+			},
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+		{
+			name: "error open file",
+			fields: fields{
+				osOpen: func(name string) (*os.File, error) {
+					return nil, errors.New("test error")
+				},
+			},
+			args: args{
+				config: map[string]interface{}{},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fileProvider{
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				osReadFile: tt.fields.osReadFile,
+				osOpen:     tt.fields.osOpen,
+			}
+			if err := f.Preflight(tt.args.ctx, tt.args.name, tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("fileProvider.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
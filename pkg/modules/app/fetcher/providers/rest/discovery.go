@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// restDiscovery implements the discovery of the endpoints of the resources referencing a SRV record.
+//
+// The endpoints of a service are resolved again once the TTL is expired, and an endpoint failing a request is skipped
+// until the end of the cooldown period unless all the endpoints are failing.
+type restDiscovery struct {
+	resolver  *net.Resolver
+	lookupSRV func(ctx context.Context, resolver *net.Resolver, name string) ([]*net.SRV, error)
+	ttl       time.Duration
+	cooldown  time.Duration
+	services  map[string]*restService
+	mu        *sync.Mutex
+}
+
+// restService implements the endpoints of a discovered service.
+type restService struct {
+	endpoints []*restEndpoint
+	expire    time.Time
+	next      int
+}
+
+// restEndpoint implements an endpoint of a discovered service.
+type restEndpoint struct {
+	addr     string
+	priority uint16
+	weight   uint16
+	down     time.Time
+}
+
+const (
+	restDiscoverySchemePrefix string = "srv+"
+)
+
+// restNetLookupSRV redirects to net.Resolver.LookupSRV.
+func restNetLookupSRV(ctx context.Context, resolver *net.Resolver, name string) ([]*net.SRV, error) {
+	_, addrs, err := resolver.LookupSRV(ctx, "", "", name)
+	return addrs, err
+}
+
+// newRestDiscovery creates a new discovery using the given DNS server address or the system resolver if empty.
+func newRestDiscovery(server string, ttl time.Duration, cooldown time.Duration,
+	lookupSRV func(ctx context.Context, resolver *net.Resolver, name string) ([]*net.SRV, error)) *restDiscovery {
+	resolver := net.DefaultResolver
+	if server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return &restDiscovery{
+		resolver:  resolver,
+		lookupSRV: lookupSRV,
+		ttl:       ttl,
+		cooldown:  cooldown,
+		services:  make(map[string]*restService),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// restDiscoveryService returns the scheme and the service name of the given URL if it references a SRV record.
+func restDiscoveryService(u *url.URL) (string, string, bool) {
+	scheme, ok := strings.CutPrefix(u.Scheme, restDiscoverySchemePrefix)
+	if !ok || (scheme != "http" && scheme != "https") || u.Hostname() == "" {
+		return "", "", false
+	}
+
+	return scheme, u.Hostname(), true
+}
+
+// resolve returns the address of the next endpoint of the given service.
+//
+// The last known endpoints are kept if the service cannot be resolved again.
+func (d *restDiscovery) resolve(ctx context.Context, name string) (string, error) {
+	now := time.Now()
+
+	d.mu.Lock()
+	service, ok := d.services[name]
+	d.mu.Unlock()
+	if !ok || !service.expire.After(now) {
+		records, err := d.lookupSRV(ctx, d.resolver, name)
+		if err != nil || len(records) == 0 {
+			if !ok {
+				if err == nil {
+					err = errors.New("no endpoint")
+				}
+				return "", fmt.Errorf("resolve service %s: %v", name, err)
+			}
+		} else {
+			d.update(name, records, now)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.services[name].pick(now), nil
+}
+
+// update replaces the endpoints of a service, keeping the failure state of the endpoints still present.
+func (d *restDiscovery) update(name string, records []*net.SRV, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	down := make(map[string]time.Time)
+	if service, ok := d.services[name]; ok {
+		for _, endpoint := range service.endpoints {
+			down[endpoint.addr] = endpoint.down
+		}
+	}
+
+	service := &restService{
+		expire: now.Add(d.ttl),
+	}
+	for _, record := range records {
+		addr := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+		service.endpoints = append(service.endpoints, &restEndpoint{
+			addr:     addr,
+			priority: record.Priority,
+			weight:   record.Weight,
+			down:     down[addr],
+		})
+	}
+	sort.SliceStable(service.endpoints, func(i, j int) bool {
+		return service.endpoints[i].priority < service.endpoints[j].priority
+	})
+	d.services[name] = service
+}
+
+// fail marks the given endpoint of a service as failing until the end of the cooldown period.
+func (d *restDiscovery) fail(name string, addr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	service, ok := d.services[name]
+	if !ok {
+		return
+	}
+	for _, endpoint := range service.endpoints {
+		if endpoint.addr == addr {
+			endpoint.down = time.Now().Add(d.cooldown)
+		}
+	}
+}
+
+// pick returns the next endpoint of the service in weighted round-robin order among the available endpoints of the
+// best priority, or among all the endpoints of the best priority if none is available.
+func (s *restService) pick(now time.Time) string {
+	candidates := s.candidates(func(endpoint *restEndpoint) bool {
+		return !endpoint.down.After(now)
+	})
+	if len(candidates) == 0 {
+		candidates = s.candidates(func(endpoint *restEndpoint) bool {
+			return true
+		})
+	}
+
+	var total int
+	for _, endpoint := range candidates {
+		total += max(int(endpoint.weight), 1)
+	}
+	index := s.next % total
+	s.next++
+	for _, endpoint := range candidates {
+		index -= max(int(endpoint.weight), 1)
+		if index < 0 {
+			return endpoint.addr
+		}
+	}
+
+	return candidates[0].addr
+}
+
+// candidates returns the endpoints of the best priority among the endpoints matching the given filter.
+func (s *restService) candidates(filter func(endpoint *restEndpoint) bool) []*restEndpoint {
+	var candidates []*restEndpoint
+	for _, endpoint := range s.endpoints {
+		if !filter(endpoint) {
+			continue
+		}
+		if len(candidates) > 0 && endpoint.priority != candidates[0].priority {
+			break
+		}
+		candidates = append(candidates, endpoint)
+	}
+
+	return candidates
+}
@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testRestDiscoveryLookupSRV(records []*net.SRV, err error) func(ctx context.Context, resolver *net.Resolver,
+	name string) ([]*net.SRV, error) {
+	return func(ctx context.Context, resolver *net.Resolver, name string) ([]*net.SRV, error) {
+		return records, err
+	}
+}
+
+func TestRestDiscoveryService(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		wantScheme  string
+		wantService string
+		wantOk      bool
+	}{
+		{
+			name:        "default",
+			url:         "srv+https://_api._tcp.backend.service.consul/api/v1",
+			wantScheme:  "https",
+			wantService: "_api._tcp.backend.service.consul",
+			wantOk:      true,
+		},
+		{
+			name: "not discovered",
+			url:  "https://backend/api/v1",
+		},
+		{
+			name: "invalid scheme",
+			url:  "srv+ftp://_api._tcp.backend/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scheme, service, ok := restDiscoveryService(u)
+			if scheme != tt.wantScheme || service != tt.wantService || ok != tt.wantOk {
+				t.Errorf("restDiscoveryService() = %v, %v, %v, want %v, %v, %v", scheme, service, ok, tt.wantScheme,
+					tt.wantService, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRestDiscoveryResolve(t *testing.T) {
+	d := newRestDiscovery("", time.Minute, time.Minute, testRestDiscoveryLookupSRV([]*net.SRV{
+		{Target: "backup.", Port: 8080, Priority: 20, Weight: 1},
+		{Target: "a.", Port: 8080, Priority: 10, Weight: 2},
+		{Target: "b.", Port: 8080, Priority: 10, Weight: 1},
+	}, nil))
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		addr, err := d.resolve(context.Background(), "service")
+		if err != nil {
+			t.Fatalf("restDiscovery.resolve() error = %v", err)
+		}
+		got = append(got, addr)
+	}
+	if want := []string{"a:8080", "a:8080", "b:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restDiscovery.resolve() = %v, want %v", got, want)
+	}
+
+	d.fail("service", "a:8080")
+	if addr, _ := d.resolve(context.Background(), "service"); addr != "b:8080" {
+		t.Errorf("restDiscovery.resolve() = %v, want %v", addr, "b:8080")
+	}
+	d.fail("service", "b:8080")
+	if addr, _ := d.resolve(context.Background(), "service"); addr != "backup:8080" {
+		t.Errorf("restDiscovery.resolve() = %v, want %v", addr, "backup:8080")
+	}
+	d.fail("service", "backup:8080")
+	if addr, _ := d.resolve(context.Background(), "service"); addr != "a:8080" && addr != "b:8080" {
+		t.Errorf("restDiscovery.resolve() = %v, want a failing endpoint of the best priority", addr)
+	}
+}
+
+func TestRestDiscoveryResolveError(t *testing.T) {
+	d := newRestDiscovery("", 0, time.Minute, testRestDiscoveryLookupSRV(nil, errors.New("test error")))
+	if _, err := d.resolve(context.Background(), "service"); err == nil {
+		t.Errorf("restDiscovery.resolve() error = %v, want error", err)
+	}
+
+	d.update("service", []*net.SRV{{Target: "a.", Port: 8080}}, time.Now().Add(-time.Hour))
+	if addr, err := d.resolve(context.Background(), "service"); err != nil || addr != "a:8080" {
+		t.Errorf("restDiscovery.resolve() = %v, %v, want last known endpoint", addr, err)
+	}
+}
+
+func TestRestProviderFetchDiscovery(t *testing.T) {
+	retry, retryDelay := 3, 0
+	var hosts []string
+	p := &restProvider{
+		config: &restProviderConfig{
+			Retry:      &retry,
+			RetryDelay: &retryDelay,
+		},
+		logger:                    slog.Default(),
+		httpNewRequestWithContext: restHttpNewRequestWithContext,
+		httpClientDo: func(client *http.Client, req *http.Request) (*http.Response, error) {
+			hosts = append(hosts, req.URL.Scheme+"://"+req.URL.Host)
+			if req.URL.Host == "a:8080" {
+				return nil, errors.New("test error")
+			}
+			return &http.Response{
+				Body:       http.NoBody,
+				StatusCode: http.StatusOK,
+			}, nil
+		},
+		ioReadAll: func(r io.Reader) ([]byte, error) {
+			return nil, nil
+		},
+		discovery: newRestDiscovery("", time.Minute, time.Minute, testRestDiscoveryLookupSRV([]*net.SRV{
+			{Target: "a.", Port: 8080, Priority: 10},
+			{Target: "b.", Port: 8080, Priority: 10},
+		}, nil)),
+	}
+
+	config := map[string]interface{}{
+		"URL": "srv+http://_api._tcp.backend/api",
+	}
+	if _, err := p.Fetch(context.Background(), "test", config); err != nil {
+		t.Errorf("restProvider.Fetch() error = %v", err)
+	}
+	if _, err := p.Fetch(context.Background(), "test", config); err != nil {
+		t.Errorf("restProvider.Fetch() error = %v", err)
+	}
+	if want := []string{"http://a:8080", "http://b:8080", "http://b:8080"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("restProvider.Fetch() hosts = %v, want %v", hosts, want)
+	}
+}
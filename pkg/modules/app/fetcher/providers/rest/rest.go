@@ -12,16 +12,20 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PaesslerAG/jsonpath"
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/httpcache"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/throttle"
 )
 
 // restProvider implements the rest provider.
@@ -29,6 +33,9 @@ type restProvider struct {
 	config                         *restProviderConfig
 	logger                         *slog.Logger
 	client                         http.Client
+	clients                        *sync.Map
+	tlsConfig                      *tls.Config
+	cache                          *httpcache.Cache
 	osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 	osReadFile                     func(name string) ([]byte, error)
 	osClose                        func(f *os.File) error
@@ -38,6 +45,11 @@ type restProvider struct {
 	httpNewRequestWithContext      func(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error)
 	httpClientDo                   func(client *http.Client, req *http.Request) (*http.Response, error)
 	ioReadAll                      func(r io.Reader) ([]byte, error)
+	netLookupHost                  func(ctx context.Context, host string) ([]string, error)
+	netLookupSRV                   func(ctx context.Context, resolver *net.Resolver, name string) ([]*net.SRV, error)
+	discovery                      *restDiscovery
+	throttled                      *sync.Map
+	sigV4Cache                     *restSigV4Cache
 }
 
 // restProviderConfig implements the rest provider configuration.
@@ -47,14 +59,22 @@ type restProviderConfig struct {
 	TLSKeyFiles         *[]string         `mapstructure:"tlsKeyFiles"`
 	Timeout             *int              `mapstructure:"timeout"`
 	ConnectTimeout      *int              `mapstructure:"connectTimeout"`
+	FallbackDelay       *int              `mapstructure:"fallbackDelay"`
+	LocalAddr           *string           `mapstructure:"localAddr"`
 	MaxIdleConns        *int              `mapstructure:"maxIdleConns"`
 	MaxIdleConnsPerHost *int              `mapstructure:"maxIdleConnsPerHost"`
 	IdleConnTimeout     *int              `mapstructure:"idleConnTimeout"`
 	MaxConnsPerHost     *int              `mapstructure:"maxConnsPerHost"`
 	Retry               *int              `mapstructure:"retry"`
 	RetryDelay          *int              `mapstructure:"retryDelay"`
+	RetryAfterMax       *int              `mapstructure:"retryAfterMax"`
 	Headers             map[string]string `mapstructure:"headers"`
 	Params              map[string]string `mapstructure:"params"`
+	DiscoveryServer     *string           `mapstructure:"discoveryServer"`
+	DiscoveryTTL        *int              `mapstructure:"discoveryTTL"`
+	DiscoveryCooldown   *int              `mapstructure:"discoveryCooldown"`
+	Cache               *bool             `mapstructure:"cache"`
+	CacheMaxItems       *int              `mapstructure:"cacheMaxItems"`
 }
 
 // restResourceConfig implements the rest resource configuration.
@@ -66,19 +86,27 @@ type restResourceConfig struct {
 	Next       *bool             `mapstructure:"next"`
 	NextParser *string           `mapstructure:"nextParser"`
 	NextFilter *string           `mapstructure:"nextFilter"`
+	Signer     *restSignerConfig `mapstructure:"signer"`
+	LocalAddr  *string           `mapstructure:"localAddr"`
 }
 
 const (
 	restModuleID module.ModuleID = "app.fetcher.provider.rest"
 
-	restConfigDefaultTimeout             int = 15
-	restConfigDefaultConnectTimeout      int = 5
-	restConfigDefaultMaxIdleConns        int = 100
-	restConfigDefaultMaxIdleConnsPerHost int = 100
-	restConfigDefaultIdleConnTimeout     int = 60
-	restConfigDefaultMaxConnsPerHost     int = 100
-	restConfigDefaultRetry               int = 3
-	restConfigDefaultRetryDelay          int = 1
+	restConfigDefaultTimeout             int  = 15
+	restConfigDefaultConnectTimeout      int  = 5
+	restConfigDefaultFallbackDelay       int  = 300
+	restConfigDefaultMaxIdleConns        int  = 100
+	restConfigDefaultMaxIdleConnsPerHost int  = 100
+	restConfigDefaultIdleConnTimeout     int  = 60
+	restConfigDefaultMaxConnsPerHost     int  = 100
+	restConfigDefaultRetry               int  = 3
+	restConfigDefaultRetryDelay          int  = 1
+	restConfigDefaultRetryAfterMax       int  = 10
+	restConfigDefaultDiscoveryTTL        int  = 30
+	restConfigDefaultDiscoveryCooldown   int  = 10
+	restConfigDefaultCache               bool = false
+	restConfigDefaultCacheMaxItems       int  = 1000
 
 	restResourceNextParserHeader  string = "header"
 	restResourceNextParserBody    string = "body"
@@ -132,6 +160,11 @@ func restIoReadAll(r io.Reader) ([]byte, error) {
 	return io.ReadAll(r)
 }
 
+// restNetLookupHost redirects to net.Resolver.LookupHost.
+func restNetLookupHost(ctx context.Context, host string) ([]string, error) {
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
 // init initializes the package.
 func init() {
 	module.Register(restProvider{})
@@ -155,6 +188,11 @@ func (p restProvider) ModuleInfo() module.ModuleInfo {
 				httpClientDo:                   restHttpClientDo,
 				httpNewRequestWithContext:      restHttpNewRequestWithContext,
 				ioReadAll:                      restIoReadAll,
+				netLookupHost:                  restNetLookupHost,
+				netLookupSRV:                   restNetLookupSRV,
+				throttled:                      &sync.Map{},
+				clients:                        &sync.Map{},
+				sigV4Cache:                     &restSigV4Cache{},
 			}
 		},
 	}
@@ -270,6 +308,18 @@ func (p *restProvider) Init(config map[string]interface{}) error {
 		p.logger.Error("Invalid value", "option", "ConnectTimeout", "value", *p.config.Timeout)
 		errConfig = true
 	}
+	if p.config.FallbackDelay == nil {
+		defaultValue := restConfigDefaultFallbackDelay
+		p.config.FallbackDelay = &defaultValue
+	}
+	if *p.config.FallbackDelay < 0 {
+		p.logger.Error("Invalid value", "option", "FallbackDelay", "value", *p.config.FallbackDelay)
+		errConfig = true
+	}
+	if p.config.LocalAddr != nil && net.ParseIP(*p.config.LocalAddr) == nil {
+		p.logger.Error("Invalid value", "option", "LocalAddr", "value", *p.config.LocalAddr)
+		errConfig = true
+	}
 	if p.config.MaxIdleConns == nil {
 		defaultValue := restConfigDefaultMaxIdleConns
 		p.config.MaxIdleConns = &defaultValue
@@ -318,6 +368,14 @@ func (p *restProvider) Init(config map[string]interface{}) error {
 		p.logger.Error("Invalid value", "option", "RetryDelay", "value", *p.config.RetryDelay)
 		errConfig = true
 	}
+	if p.config.RetryAfterMax == nil {
+		defaultValue := restConfigDefaultRetryAfterMax
+		p.config.RetryAfterMax = &defaultValue
+	}
+	if *p.config.RetryAfterMax < 0 {
+		p.logger.Error("Invalid value", "option", "RetryAfterMax", "value", *p.config.RetryAfterMax)
+		errConfig = true
+	}
 	for k := range p.config.Headers {
 		if k == "" {
 			p.logger.Error("Invalid key", "option", "Headers", "key", k)
@@ -330,6 +388,40 @@ func (p *restProvider) Init(config map[string]interface{}) error {
 			errConfig = true
 		}
 	}
+	if p.config.DiscoveryServer != nil {
+		if _, _, err := net.SplitHostPort(*p.config.DiscoveryServer); err != nil {
+			p.logger.Error("Invalid value", "option", "DiscoveryServer", "value", *p.config.DiscoveryServer)
+			errConfig = true
+		}
+	}
+	if p.config.DiscoveryTTL == nil {
+		defaultValue := restConfigDefaultDiscoveryTTL
+		p.config.DiscoveryTTL = &defaultValue
+	}
+	if *p.config.DiscoveryTTL < 0 {
+		p.logger.Error("Invalid value", "option", "DiscoveryTTL", "value", *p.config.DiscoveryTTL)
+		errConfig = true
+	}
+	if p.config.DiscoveryCooldown == nil {
+		defaultValue := restConfigDefaultDiscoveryCooldown
+		p.config.DiscoveryCooldown = &defaultValue
+	}
+	if *p.config.DiscoveryCooldown < 0 {
+		p.logger.Error("Invalid value", "option", "DiscoveryCooldown", "value", *p.config.DiscoveryCooldown)
+		errConfig = true
+	}
+	if p.config.Cache == nil {
+		defaultValue := restConfigDefaultCache
+		p.config.Cache = &defaultValue
+	}
+	if p.config.CacheMaxItems == nil {
+		defaultValue := restConfigDefaultCacheMaxItems
+		p.config.CacheMaxItems = &defaultValue
+	}
+	if *p.config.CacheMaxItems <= 0 {
+		p.logger.Error("Invalid value", "option", "CacheMaxItems", "value", *p.config.CacheMaxItems)
+		errConfig = true
+	}
 
 	if errConfig {
 		return errors.New("config")
@@ -363,13 +455,39 @@ func (p *restProvider) Init(config map[string]interface{}) error {
 		}
 	}
 
-	p.client = http.Client{
+	p.tlsConfig = tlsConfig
+	if *p.config.Cache {
+		p.cache = httpcache.New(*p.config.CacheMaxItems)
+	}
+	p.client = *p.newClient(p.config.LocalAddr)
+
+	var discoveryServer string
+	if p.config.DiscoveryServer != nil {
+		discoveryServer = *p.config.DiscoveryServer
+	}
+	p.discovery = newRestDiscovery(discoveryServer, time.Duration(*p.config.DiscoveryTTL)*time.Second,
+		time.Duration(*p.config.DiscoveryCooldown)*time.Second, p.netLookupSRV)
+
+	return nil
+}
+
+// newClient returns a new HTTP client binding its connections to the given local address.
+func (p *restProvider) newClient(localAddr *string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:       time.Duration(*p.config.ConnectTimeout) * time.Second,
+		FallbackDelay: time.Duration(*p.config.FallbackDelay) * time.Millisecond,
+	}
+	if localAddr != nil {
+		dialer.LocalAddr = &net.TCPAddr{
+			IP: net.ParseIP(*localAddr),
+		}
+	}
+
+	client := &http.Client{
 		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout: time.Duration(*p.config.ConnectTimeout) * time.Second,
-			}).Dial,
-			TLSClientConfig:       tlsConfig,
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       p.tlsConfig,
 			TLSHandshakeTimeout:   time.Duration(*p.config.Timeout) * time.Second,
 			ResponseHeaderTimeout: time.Duration(*p.config.Timeout) * time.Second,
 			ExpectContinueTimeout: time.Duration(*p.config.Timeout) * time.Second,
@@ -381,8 +499,31 @@ func (p *restProvider) Init(config map[string]interface{}) error {
 		},
 		Timeout: time.Duration(*p.config.Timeout) * time.Second,
 	}
+	if p.cache != nil {
+		// the fresh upstream responses are shared by all the resources fetching the same URL
+		client.Transport = &httpcache.Transport{
+			Cache:     p.cache,
+			Transport: client.Transport,
+		}
+	}
 
-	return nil
+	return client
+}
+
+// resourceClient returns the HTTP client of a resource.
+//
+// The resources binding their connections to another local address than the provider use a dedicated client, as the
+// idle connections of a transport are reused regardless of their local address.
+func (p *restProvider) resourceClient(config *restResourceConfig) *http.Client {
+	if config.LocalAddr == nil || p.clients == nil ||
+		p.config.LocalAddr != nil && *p.config.LocalAddr == *config.LocalAddr {
+		return &p.client
+	}
+	if client, ok := p.clients.Load(*config.LocalAddr); ok {
+		return client.(*http.Client)
+	}
+	client, _ := p.clients.LoadOrStore(*config.LocalAddr, p.newClient(config.LocalAddr))
+	return client.(*http.Client)
 }
 
 // Fetch fetches a resource.
@@ -396,6 +537,14 @@ func (p *restProvider) Fetch(ctx context.Context, name string, config map[string
 		defaultValue := restResourceDefaultMethod
 		cfg.Method = &defaultValue
 	}
+	if cfg.Signer != nil {
+		if err := cfg.Signer.validate(); err != nil {
+			return nil, fmt.Errorf("parse resource %s config: signer: %v", name, err)
+		}
+	}
+	if cfg.LocalAddr != nil && net.ParseIP(*cfg.LocalAddr) == nil {
+		return nil, fmt.Errorf("parse resource %s config: invalid local address %s", name, *cfg.LocalAddr)
+	}
 	if cfg.Next != nil {
 		defaultValue := restResourceDefaultNextParser
 		cfg.NextParser = &defaultValue
@@ -468,6 +617,12 @@ func parseLinkNextFromBody(body []byte, filter string) string {
 }
 
 // fetchResource fetches the resource
+//
+// If the resource URL references a SRV record, each attempt is sent to the next endpoint of the service and a failing
+// endpoint is skipped by the next requests until the end of the discovery cooldown.
+//
+// A throttled request is retried after the delay given by the upstream if it does not exceed the maximum delay,
+// otherwise the host is throttled and no request is sent to it until the end of this delay.
 func (p *restProvider) fetchResource(ctx context.Context, config *restResourceConfig) ([]byte, http.Header, error) {
 	req, err := p.httpNewRequestWithContext(ctx, *config.Method, config.URL, nil)
 	if err != nil {
@@ -491,13 +646,50 @@ func (p *restProvider) fetchResource(ctx context.Context, config *restResourceCo
 		req.Header.Set(key, value)
 	}
 
+	scheme, service, discovered := restDiscoveryService(req.URL)
+	if discovered && p.discovery == nil {
+		return nil, nil, errors.New("discovery not available")
+	}
+
 	var attempt int
 	for {
 		attempt += 1
 		startTime := time.Now()
 
-		response, err := p.httpClientDo(&p.client, req)
+		if discovered {
+			addr, err := p.discovery.resolve(ctx, service)
+			if err != nil {
+				p.logger.Error("Failed to discover endpoint", "service", service, "err", err)
+				return nil, nil, fmt.Errorf("discover endpoint: %v", err)
+			}
+			req.URL.Scheme = scheme
+			req.URL.Host = addr
+			req.Host = ""
+		}
+
+		if until, ok := p.throttledUntil(req.URL.Host, startTime); ok {
+			throttle.Report(ctx, req.URL.Host, until)
+			p.logger.Warn("Request throttled", "method", req.Method, "url", req.URL.String(), "until", until)
+			return nil, nil, throttle.ErrThrottled
+		}
+
+		if config.Signer != nil {
+			if err := p.sign(req, config.Signer, startTime, os.Getenv); err != nil {
+				p.logger.Error("Failed to sign request", "err", err)
+				return nil, nil, fmt.Errorf("sign request: %v", err)
+			}
+		}
+
+		response, err := p.httpClientDo(p.resourceClient(config), req)
 		if err != nil {
+			if discovered {
+				p.discovery.fail(service, req.URL.Host)
+				if attempt < *p.config.Retry {
+					p.logger.Warn("Retrying request", "method", req.Method, "url", req.URL.String(), "err", err,
+						"attempt", attempt, "retries", *p.config.Retry)
+					continue
+				}
+			}
 			p.logger.Error("Failed to send request", "err", err)
 			return nil, nil, fmt.Errorf("send requests: %v", err)
 		}
@@ -513,15 +705,37 @@ func (p *restProvider) fetchResource(ctx context.Context, config *restResourceCo
 
 		switch response.StatusCode {
 		case 429, 500, 502, 503, 504:
+			if discovered && response.StatusCode != http.StatusTooManyRequests {
+				p.discovery.fail(service, req.URL.Host)
+			}
+			delay := time.Duration(*p.config.RetryDelay) * time.Second
+			if response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable {
+				if retryAfter, ok := throttle.ParseRetryAfter(response.Header.Get("Retry-After"), time.Now()); ok {
+					if retryAfter > time.Duration(*p.config.RetryAfterMax)*time.Second {
+						until := time.Now().Add(retryAfter)
+						p.throttle(req.URL.Host, until)
+						throttle.Report(ctx, req.URL.Host, until)
+						p.logger.Warn("Upstream throttled", "method", req.Method, "url", req.URL.String(),
+							"code", response.StatusCode, "retryAfter", retryAfter)
+						return nil, nil, fmt.Errorf("request error %d: %w", response.StatusCode, throttle.ErrThrottled)
+					}
+					delay = retryAfter
+				}
+			}
 			if attempt >= *p.config.Retry {
 				p.logger.Error("Request error", "method", req.Method, "url", req.URL.String(), "code", response.StatusCode)
 				return nil, nil, fmt.Errorf("request error %d", response.StatusCode)
 			}
 
 			p.logger.Warn("Retrying request", "method", req.Method, "url", req.URL.String(), "code", response.StatusCode,
-				"attempt", attempt, "retries", *p.config.Retry, "delay", *p.config.RetryDelay)
+				"attempt", attempt, "retries", *p.config.Retry, "delay", delay)
 
-			time.Sleep(time.Duration(*p.config.RetryDelay) * time.Second)
+			select {
+			case <-ctx.Done():
+				p.logger.Error("Request canceled", "method", req.Method, "url", req.URL.String(), "err", ctx.Err())
+				return nil, nil, fmt.Errorf("request canceled: %v", ctx.Err())
+			case <-time.After(delay):
+			}
 
 			continue
 
@@ -536,4 +750,74 @@ func (p *restProvider) fetchResource(ctx context.Context, config *restResourceCo
 	}
 }
 
+// throttle throttles the requests to the given host until the given time.
+func (p *restProvider) throttle(host string, until time.Time) {
+	if p.throttled == nil {
+		return
+	}
+	p.throttled.Store(host, until)
+}
+
+// throttledUntil returns the time until which the requests to the given host are throttled, or false if the host is
+// not throttled at the given time.
+func (p *restProvider) throttledUntil(host string, now time.Time) (time.Time, bool) {
+	if p.throttled == nil {
+		return time.Time{}, false
+	}
+	value, ok := p.throttled.Load(host)
+	if !ok {
+		return time.Time{}, false
+	}
+	until := value.(time.Time)
+	if !until.After(now) {
+		p.throttled.CompareAndDelete(host, value)
+		return time.Time{}, false
+	}
+
+	return until, true
+}
+
+// Preflight checks that the resource host is resolvable and reachable.
+func (p *restProvider) Preflight(ctx context.Context, name string, config map[string]interface{}) error {
+	var cfg restResourceConfig
+	if err := mapstructure.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("parse resource %s config: %v", name, err)
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %s", cfg.URL)
+	}
+	if cfg.LocalAddr != nil && net.ParseIP(*cfg.LocalAddr) == nil {
+		return fmt.Errorf("invalid local address %s", *cfg.LocalAddr)
+	}
+
+	if scheme, service, ok := restDiscoveryService(u); ok {
+		if p.discovery == nil {
+			return errors.New("discovery not available")
+		}
+		addr, err := p.discovery.resolve(ctx, service)
+		if err != nil {
+			return fmt.Errorf("discover service %s: %v", service, err)
+		}
+		u.Scheme = scheme
+		u.Host = addr
+	} else if _, err := p.netLookupHost(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("resolve host %s: %v", u.Hostname(), err)
+	}
+
+	req, err := p.httpNewRequestWithContext(ctx, http.MethodHead, u.Scheme+"://"+u.Host, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %v", err)
+	}
+	response, err := p.httpClientDo(p.resourceClient(&cfg), req)
+	if err != nil {
+		return fmt.Errorf("request host %s: %v", u.Host, err)
+	}
+	_ = response.Body.Close()
+
+	return nil
+}
+
 var _ core.FetcherProviderModule = (*restProvider)(nil)
+var _ core.FetcherProviderPreflightModule = (*restProvider)(nil)
@@ -8,13 +8,19 @@ import (
 	"io"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/throttle"
 )
 
 type testRestProviderFileInfo struct {
@@ -123,18 +129,26 @@ func TestRestProviderInit(t *testing.T) {
 					"TLSKeyFiles":         []string{"key.pem"},
 					"Timeout":             15,
 					"ConnectTimeout":      5,
+					"FallbackDelay":       300,
+					"LocalAddr":           "::1",
 					"MaxIdleConns":        100,
 					"MaxIdleConnsPerHost": 4,
 					"IdleConnTimeout":     60,
 					"MaxConnsPerHost":     100,
 					"Retry":               3,
 					"RetryDelay":          1,
+					"RetryAfterMax":       10,
 					"Headers:": map[string]string{
 						"header": "value",
 					},
 					"Params": map[string]string{
 						"header": "value",
 					},
+					"DiscoveryServer":   "127.0.0.1:8600",
+					"DiscoveryTTL":      30,
+					"DiscoveryCooldown": 10,
+					"Cache":             true,
+					"CacheMaxItems":     1000,
 				},
 			},
 		},
@@ -159,18 +173,25 @@ func TestRestProviderInit(t *testing.T) {
 					"TLSKeyFiles":         []string{""},
 					"Timeout":             -1,
 					"ConnectTimeout":      -1,
+					"FallbackDelay":       -1,
+					"LocalAddr":           "invalid",
 					"MaxIdleConns":        -1,
 					"MaxIdleConnsPerHost": -1,
 					"IdleConnTimeout":     -1,
 					"MaxConnsPerHost":     -1,
 					"Retry":               -1,
 					"RetryDelay":          -1,
+					"RetryAfterMax":       -1,
 					"Headers": map[string]string{
 						"": "",
 					},
 					"params": map[string]string{
 						"": "",
 					},
+					"DiscoveryServer":   "invalid",
+					"DiscoveryTTL":      -1,
+					"DiscoveryCooldown": -1,
+					"CacheMaxItems":     0,
 				},
 			},
 			wantErr: true,
@@ -325,6 +346,55 @@ func TestRestProviderFetch(t *testing.T) {
 				name: "test",
 			},
 		},
+		{
+			name: "signer",
+			fields: fields{
+				config:                    &restProviderConfig{},
+				logger:                    slog.Default(),
+				httpNewRequestWithContext: restHttpNewRequestWithContext,
+				httpClientDo: func(client *http.Client, req *http.Request) (*http.Response, error) {
+					if req.Header.Get("X-Signature") == "" {
+						return nil, errors.New("test error")
+					}
+					return &http.Response{
+						Body:       http.NoBody,
+						StatusCode: http.StatusOK,
+					}, nil
+				},
+				ioReadAll: func(r io.Reader) ([]byte, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"URL": "http://localhost/test",
+					"Signer": map[string]interface{}{
+						"HMAC": map[string]interface{}{
+							"Key": "secret",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error signer config",
+			fields: fields{
+				config: &restProviderConfig{},
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"Signer": map[string]interface{}{
+						"HMAC": map[string]interface{}{},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "error http create request",
 			fields: fields{
@@ -415,6 +485,278 @@ func TestRestProviderFetch(t *testing.T) {
 	}
 }
 
+func TestRestProviderFetchRetryAfter(t *testing.T) {
+	retry, retryDelay, retryAfterMax := 3, 0, 1
+	var requests int
+	p := &restProvider{
+		config: &restProviderConfig{
+			Retry:         &retry,
+			RetryDelay:    &retryDelay,
+			RetryAfterMax: &retryAfterMax,
+		},
+		logger:                    slog.Default(),
+		httpNewRequestWithContext: restHttpNewRequestWithContext,
+		httpClientDo: func(client *http.Client, req *http.Request) (*http.Response, error) {
+			requests++
+			retryAfter := req.URL.Query().Get("retryAfter")
+			if retryAfter != "" && requests == 1 {
+				return &http.Response{
+					Header:     http.Header{"Retry-After": []string{retryAfter}},
+					Body:       http.NoBody,
+					StatusCode: http.StatusTooManyRequests,
+				}, nil
+			}
+			return &http.Response{
+				Body:       http.NoBody,
+				StatusCode: http.StatusOK,
+			}, nil
+		},
+		ioReadAll: func(r io.Reader) ([]byte, error) {
+			return nil, nil
+		},
+		throttled: &sync.Map{},
+	}
+
+	if _, err := p.Fetch(context.Background(), "test", map[string]interface{}{
+		"URL": "http://short/?retryAfter=0",
+	}); err != nil || requests != 2 {
+		t.Errorf("restProvider.Fetch() error = %v, requests = %v", err, requests)
+	}
+
+	requests = 0
+	ctx := throttle.NewContext(context.Background())
+	if _, err := p.Fetch(ctx, "test", map[string]interface{}{
+		"URL": "http://long/?retryAfter=120",
+	}); !errors.Is(err, throttle.ErrThrottled) || requests != 1 {
+		t.Errorf("restProvider.Fetch() error = %v, requests = %v", err, requests)
+	}
+	if until, ok := throttle.Until(ctx); !ok || until.Before(time.Now().Add(time.Minute)) {
+		t.Errorf("throttle.Until() = %v, %v", until, ok)
+	}
+
+	if _, err := p.Fetch(context.Background(), "test", map[string]interface{}{
+		"URL": "http://long/",
+	}); !errors.Is(err, throttle.ErrThrottled) || requests != 1 {
+		t.Errorf("restProvider.Fetch() error = %v, requests = %v", err, requests)
+	}
+}
+
+func TestRestProviderFetchCache(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("test"))
+	}))
+	defer server.Close()
+
+	p, ok := restProvider{}.ModuleInfo().NewInstance().(*restProvider)
+	if !ok {
+		t.Fatal("restProvider.NewInstance() invalid instance")
+	}
+	if err := p.Init(map[string]interface{}{
+		"Cache": true,
+	}); err != nil {
+		t.Fatalf("restProvider.Init() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resource, err := p.Fetch(context.Background(), "test", map[string]interface{}{
+			"URL": server.URL + "/test",
+		})
+		if err != nil {
+			t.Fatalf("restProvider.Fetch() error = %v", err)
+		}
+		if len(resource.Data) != 1 || string(resource.Data[0]) != "test" {
+			t.Errorf("restProvider.Fetch() data = %v", resource.Data)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("restProvider.Fetch() requests = %v, want %v", got, 1)
+	}
+}
+
+func TestRestProviderFetchLocalAddr(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("loopback range not available")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		_, _ = w.Write([]byte(host))
+	}))
+	defer server.Close()
+
+	p, ok := restProvider{}.ModuleInfo().NewInstance().(*restProvider)
+	if !ok {
+		t.Fatal("restProvider.NewInstance() invalid instance")
+	}
+	if err := p.Init(map[string]interface{}{
+		"LocalAddr": "127.0.0.1",
+	}); err != nil {
+		t.Fatalf("restProvider.Init() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "provider",
+			config: map[string]interface{}{
+				"URL": server.URL,
+			},
+			want: "127.0.0.1",
+		},
+		{
+			name: "resource",
+			config: map[string]interface{}{
+				"URL":       server.URL,
+				"LocalAddr": "127.0.0.2",
+			},
+			want: "127.0.0.2",
+		},
+		{
+			name: "error invalid local address",
+			config: map[string]interface{}{
+				"URL":       server.URL,
+				"LocalAddr": "invalid",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := p.Fetch(context.Background(), "test", tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("restProvider.Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && string(resource.Data[0]) != tt.want {
+				t.Errorf("restProvider.Fetch() data = %s, want %s", resource.Data[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestRestProviderPreflight(t *testing.T) {
+	type fields struct {
+		config                    *restProviderConfig
+		logger                    *slog.Logger
+		client                    http.Client
+		httpNewRequestWithContext func(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error)
+		httpClientDo              func(client *http.Client, req *http.Request) (*http.Response, error)
+		netLookupHost             func(ctx context.Context, host string) ([]string, error)
+	}
+	type args struct {
+		ctx    context.Context
+		name   string
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				logger:                    slog.Default(),
+				httpNewRequestWithContext: restHttpNewRequestWithContext,
+				httpClientDo: func(client *http.Client, req *http.Request) (*http.Response, error) {
+					if req.Method != http.MethodHead || req.URL.String() != "http://localhost:8080" {
+						return nil, errors.New("test error")
+					}
+					return &http.Response{
+						Body:       http.NoBody,
+						StatusCode: http.StatusMethodNotAllowed,
+					}, nil
+				},
+				netLookupHost: func(ctx context.Context, host string) ([]string, error) {
+					return []string{"127.0.0.1"}, nil
+				},
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"URL": "http://localhost:8080/test",
+				},
+			},
+		},
+		{
+			name: "error invalid url",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"URL": "/test",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error lookup host",
+			fields: fields{
+				logger: slog.Default(),
+				netLookupHost: func(ctx context.Context, host string) ([]string, error) {
+					return nil, errors.New("test error")
+				},
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"URL": "http://localhost/test",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error request",
+			fields: fields{
+				logger:                    slog.Default(),
+				httpNewRequestWithContext: restHttpNewRequestWithContext,
+				httpClientDo: func(client *http.Client, req *http.Request) (*http.Response, error) {
+					return nil, errors.New("test error")
+				},
+				netLookupHost: func(ctx context.Context, host string) ([]string, error) {
+					return []string{"127.0.0.1"}, nil
+				},
+			},
+			args: args{
+				ctx:  context.Background(),
+				name: "test",
+				config: map[string]interface{}{
+					"URL": "http://localhost/test",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &restProvider{
+				config:                    tt.fields.config,
+				logger:                    tt.fields.logger,
+				client:                    tt.fields.client,
+				httpNewRequestWithContext: tt.fields.httpNewRequestWithContext,
+				httpClientDo:              tt.fields.httpClientDo,
+				netLookupHost:             tt.fields.netLookupHost,
+			}
+			if err := p.Preflight(tt.args.ctx, tt.args.name, tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("restProvider.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestParseLinkNextFromHeader(t *testing.T) {
 	type args struct {
 		headers http.Header
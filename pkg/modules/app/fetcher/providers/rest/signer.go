@@ -0,0 +1,510 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// restSignerConfig implements the resource request signer configuration.
+type restSignerConfig struct {
+	SigV4 *restSigV4Config `mapstructure:"sigv4"`
+	HMAC  *restHMACConfig  `mapstructure:"hmac"`
+}
+
+// restSigV4Config implements the AWS Signature Version 4 signer configuration.
+type restSigV4Config struct {
+	Region          string  `mapstructure:"region"`
+	Service         string  `mapstructure:"service"`
+	AccessKeyID     *string `mapstructure:"accessKeyId"`
+	SecretAccessKey *string `mapstructure:"secretAccessKey"`
+	SessionToken    *string `mapstructure:"sessionToken"`
+	Profile         *string `mapstructure:"profile"`
+}
+
+// restHMACConfig implements the HMAC signer configuration.
+type restHMACConfig struct {
+	Key             string  `mapstructure:"key"`
+	KeyID           *string `mapstructure:"keyId"`
+	Algorithm       *string `mapstructure:"algorithm"`
+	Header          *string `mapstructure:"header"`
+	TimestampHeader *string `mapstructure:"timestampHeader"`
+}
+
+// restSigV4Credentials implements the AWS credentials.
+type restSigV4Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// restSigV4Cache implements the cache of the resolved AWS credentials.
+type restSigV4Cache struct {
+	credentials map[string]*restSigV4Credentials
+	mu          sync.Mutex
+}
+
+const (
+	restSigV4Algorithm      string = "AWS4-HMAC-SHA256"
+	restSigV4DefaultProfile string = "default"
+	restSigV4IMDSEndpoint   string = "http://169.254.169.254"
+	restSigV4IMDSTokenTTL   string = "21600"
+
+	restSigV4IMDSTimeout time.Duration = 2 * time.Second
+	restSigV4CacheTTL    time.Duration = 5 * time.Minute
+	restSigV4CacheWindow time.Duration = time.Minute
+
+	restHMACAlgorithmSHA256        string = "sha256"
+	restHMACAlgorithmSHA512        string = "sha512"
+	restHMACDefaultAlgorithm       string = restHMACAlgorithmSHA256
+	restHMACDefaultHeader          string = "X-Signature"
+	restHMACDefaultTimestampHeader string = "X-Signature-Timestamp"
+)
+
+// validate checks the signer configuration.
+func (c *restSignerConfig) validate() error {
+	if c.SigV4 != nil && c.HMAC != nil {
+		return errors.New("multiple signers")
+	}
+	if c.SigV4 != nil {
+		if c.SigV4.Region == "" {
+			return errors.New("missing sigv4 region")
+		}
+		if c.SigV4.Service == "" {
+			return errors.New("missing sigv4 service")
+		}
+		if (c.SigV4.AccessKeyID == nil) != (c.SigV4.SecretAccessKey == nil) {
+			return errors.New("missing sigv4 access key")
+		}
+	}
+	if c.HMAC != nil {
+		if c.HMAC.Key == "" {
+			return errors.New("missing hmac key")
+		}
+		if c.HMAC.Algorithm != nil && *c.HMAC.Algorithm != restHMACAlgorithmSHA256 &&
+			*c.HMAC.Algorithm != restHMACAlgorithmSHA512 {
+			return fmt.Errorf("invalid hmac algorithm %s", *c.HMAC.Algorithm)
+		}
+	}
+
+	return nil
+}
+
+// sign signs the request.
+func (p *restProvider) sign(req *http.Request, config *restSignerConfig, t time.Time,
+	getenv func(string) string) error {
+	switch {
+	case config.SigV4 != nil:
+		creds, err := p.sigV4Credentials(req.Context(), config.SigV4, t, getenv)
+		if err != nil {
+			return fmt.Errorf("resolve credentials: %v", err)
+		}
+		signSigV4(req, config.SigV4, creds, t)
+	case config.HMAC != nil:
+		signHMAC(req, config.HMAC, t)
+	}
+
+	return nil
+}
+
+// sigV4Credentials returns the AWS credentials of the signer configuration.
+//
+// The credentials resolved from the environment, the shared credentials file or the instance metadata service are
+// cached by profile until they expire, or for a few minutes if they have no expiration, so that the retries and the
+// next requests do not resolve them again.
+func (p *restProvider) sigV4Credentials(ctx context.Context, config *restSigV4Config, t time.Time,
+	getenv func(string) string) (*restSigV4Credentials, error) {
+	if config.AccessKeyID != nil && config.SecretAccessKey != nil || p.sigV4Cache == nil {
+		return resolveSigV4Credentials(ctx, config, getenv, p.osReadFile, p.fetchSigV4IMDSCredentials)
+	}
+
+	var key string
+	if config.Profile != nil {
+		key = *config.Profile
+	}
+
+	p.sigV4Cache.mu.Lock()
+	defer p.sigV4Cache.mu.Unlock()
+
+	if creds, ok := p.sigV4Cache.credentials[key]; ok && t.Before(creds.Expiration.Add(-restSigV4CacheWindow)) {
+		return creds, nil
+	}
+
+	creds, err := resolveSigV4Credentials(ctx, config, getenv, p.osReadFile, p.fetchSigV4IMDSCredentials)
+	if err != nil {
+		return nil, err
+	}
+	if creds.Expiration.IsZero() {
+		creds.Expiration = t.Add(restSigV4CacheTTL + restSigV4CacheWindow)
+	}
+	if p.sigV4Cache.credentials == nil {
+		p.sigV4Cache.credentials = make(map[string]*restSigV4Credentials)
+	}
+	p.sigV4Cache.credentials[key] = creds
+
+	return creds, nil
+}
+
+// resolveSigV4Credentials resolves the AWS credentials from the configuration, the environment, the shared
+// credentials file or the instance metadata service.
+//
+// The instance metadata service is used only if no profile is selected and the default profile is not found in the
+// shared credentials file.
+func resolveSigV4Credentials(ctx context.Context, config *restSigV4Config, getenv func(string) string,
+	readFile func(name string) ([]byte, error),
+	fetchIMDS func(ctx context.Context, endpoint string) (*restSigV4Credentials, error)) (*restSigV4Credentials, error) {
+	if config.AccessKeyID != nil && config.SecretAccessKey != nil {
+		creds := &restSigV4Credentials{
+			AccessKeyID:     *config.AccessKeyID,
+			SecretAccessKey: *config.SecretAccessKey,
+		}
+		if config.SessionToken != nil {
+			creds.SessionToken = *config.SessionToken
+		}
+		return creds, nil
+	}
+
+	if config.Profile == nil {
+		if id, secret := getenv("AWS_ACCESS_KEY_ID"), getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+			return &restSigV4Credentials{
+				AccessKeyID:     id,
+				SecretAccessKey: secret,
+				SessionToken:    getenv("AWS_SESSION_TOKEN"),
+			}, nil
+		}
+	}
+
+	profile := restSigV4DefaultProfile
+	if config.Profile != nil {
+		profile = *config.Profile
+	} else if v := getenv("AWS_PROFILE"); v != "" {
+		profile = v
+	}
+	selected := config.Profile != nil || getenv("AWS_PROFILE") != ""
+
+	name := getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if name == "" {
+		if home := getenv("HOME"); home != "" {
+			name = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+
+	if name != "" {
+		data, err := readFile(name)
+		if err != nil && (selected || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("read file %s: %v", name, err)
+		}
+		if err == nil {
+			if creds := parseSigV4CredentialsFile(data, profile); creds != nil {
+				return creds, nil
+			}
+			if selected {
+				return nil, fmt.Errorf("profile %s not found", profile)
+			}
+		}
+	} else if selected {
+		return nil, errors.New("no credentials found")
+	}
+
+	if strings.EqualFold(getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, errors.New("no credentials found")
+	}
+	endpoint := getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = restSigV4IMDSEndpoint
+	}
+	creds, err := fetchIMDS(ctx, strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata: %v", err)
+	}
+
+	return creds, nil
+}
+
+// fetchSigV4IMDSCredentials fetches the credentials of the instance role from the instance metadata service, using a
+// session token as required by IMDSv2.
+func (p *restProvider) fetchSigV4IMDSCredentials(ctx context.Context, endpoint string) (*restSigV4Credentials, error) {
+	ctx, cancel := context.WithTimeout(ctx, restSigV4IMDSTimeout)
+	defer cancel()
+
+	client := &http.Client{}
+	request := func(method string, path string, header string, value string) ([]byte, error) {
+		req, err := p.httpNewRequestWithContext(ctx, method, endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(header, value)
+		response, err := p.httpClientDo(client, req)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		body, err := p.ioReadAll(response.Body)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("request %s error %d", path, response.StatusCode)
+		}
+		return body, nil
+	}
+
+	token, err := request(http.MethodPut, "/latest/api/token", "X-Aws-Ec2-Metadata-Token-Ttl-Seconds",
+		restSigV4IMDSTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("get token: %v", err)
+	}
+	roles, err := request(http.MethodGet, "/latest/meta-data/iam/security-credentials/", "X-Aws-Ec2-Metadata-Token",
+		string(token))
+	if err != nil {
+		return nil, fmt.Errorf("get role: %v", err)
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if role == "" {
+		return nil, errors.New("no instance role")
+	}
+	data, err := request(http.MethodGet, "/latest/meta-data/iam/security-credentials/"+role,
+		"X-Aws-Ec2-Metadata-Token", string(token))
+	if err != nil {
+		return nil, fmt.Errorf("get credentials: %v", err)
+	}
+
+	var result struct {
+		Code            string
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse credentials: %v", err)
+	}
+	if result.Code != "Success" || result.AccessKeyId == "" || result.SecretAccessKey == "" {
+		return nil, fmt.Errorf("invalid credentials of role %s", role)
+	}
+
+	return &restSigV4Credentials{
+		AccessKeyID:     result.AccessKeyId,
+		SecretAccessKey: result.SecretAccessKey,
+		SessionToken:    result.Token,
+		Expiration:      result.Expiration,
+	}, nil
+}
+
+// parseSigV4CredentialsFile parses the credentials of a profile from a shared credentials file.
+func parseSigV4CredentialsFile(data []byte, profile string) *restSigV4Credentials {
+	var creds *restSigV4Credentials
+	var section string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if creds == nil {
+			creds = &restSigV4Credentials{}
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+
+	if creds == nil || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil
+	}
+
+	return creds
+}
+
+// signSigV4 signs the request with AWS Signature Version 4.
+func signSigV4(req *http.Request, config *restSigV4Config, creds *restSigV4Credentials, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := hex.EncodeToString(sha256Sum(nil))
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if config.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{
+		"host": host,
+	}
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		if key != "content-type" && !strings.HasPrefix(key, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i := range values {
+			trimmed[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+		headers[key] = strings.Join(trimmed, ",")
+	}
+	signedHeaders := make([]string, 0, len(headers))
+	for key := range headers {
+		signedHeaders = append(signedHeaders, key)
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, key := range signedHeaders {
+		canonicalHeaders.WriteString(key + ":" + headers[key] + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalPath(req.URL.Path, config.Service != "s3"),
+		sigV4CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/" + config.Service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		restSigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(sha256Sum([]byte(canonicalRequest))),
+	}, "\n")
+
+	key := hmacSum(sha256.New, []byte("AWS4"+creds.SecretAccessKey), []byte(date))
+	key = hmacSum(sha256.New, key, []byte(config.Region))
+	key = hmacSum(sha256.New, key, []byte(config.Service))
+	key = hmacSum(sha256.New, key, []byte("aws4_request"))
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", restSigV4Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+signature)
+}
+
+// sigV4CanonicalPath returns the canonical URI of a path.
+func sigV4CanonicalPath(path string, double bool) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = sigV4Escape(segments[i])
+		if double {
+			segments[i] = sigV4Escape(segments[i])
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sigV4CanonicalQuery returns the canonical query string.
+func sigV4CanonicalQuery(query map[string][]string) string {
+	params := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// sigV4Escape escapes a string with the RFC 3986 unreserved characters.
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// signHMAC signs the request with a HMAC of the method, the request URI and the timestamp.
+func signHMAC(req *http.Request, config *restHMACConfig, t time.Time) {
+	algorithm := restHMACDefaultAlgorithm
+	if config.Algorithm != nil {
+		algorithm = *config.Algorithm
+	}
+	h := sha256.New
+	if algorithm == restHMACAlgorithmSHA512 {
+		h = sha512.New
+	}
+	header := restHMACDefaultHeader
+	if config.Header != nil {
+		header = *config.Header
+	}
+	timestampHeader := restHMACDefaultTimestampHeader
+	if config.TimestampHeader != nil {
+		timestampHeader = *config.TimestampHeader
+	}
+
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	message := req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp
+	signature := hex.EncodeToString(hmacSum(h, []byte(config.Key), []byte(message)))
+	if config.KeyID != nil {
+		signature = *config.KeyID + ":" + signature
+	}
+
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(header, signature)
+}
+
+// sha256Sum returns the SHA-256 checksum of the data.
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// hmacSum returns the HMAC of the data.
+func hmacSum(h func() hash.Hash, key []byte, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,429 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestRestSignerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  restSignerConfig
+		wantErr bool
+	}{
+		{
+			name: "sigv4",
+			config: restSignerConfig{
+				SigV4: &restSigV4Config{
+					Region:  "us-east-1",
+					Service: "es",
+				},
+			},
+		},
+		{
+			name: "hmac",
+			config: restSignerConfig{
+				HMAC: &restHMACConfig{
+					Key:       "secret",
+					Algorithm: stringPtr(restHMACAlgorithmSHA512),
+				},
+			},
+		},
+		{
+			name: "error multiple signers",
+			config: restSignerConfig{
+				SigV4: &restSigV4Config{
+					Region:  "us-east-1",
+					Service: "es",
+				},
+				HMAC: &restHMACConfig{
+					Key: "secret",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error sigv4 missing region",
+			config: restSignerConfig{
+				SigV4: &restSigV4Config{
+					Service: "es",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error sigv4 missing secret access key",
+			config: restSignerConfig{
+				SigV4: &restSigV4Config{
+					Region:      "us-east-1",
+					Service:     "es",
+					AccessKeyID: stringPtr("AKIDEXAMPLE"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error hmac invalid algorithm",
+			config: restSignerConfig{
+				HMAC: &restHMACConfig{
+					Key:       "secret",
+					Algorithm: stringPtr("md5"),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("restSignerConfig.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveSigV4Credentials(t *testing.T) {
+	credentialsFile := []byte(`[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = SECRETDEFAULT
+
+[test]
+aws_access_key_id = AKIDTEST
+aws_secret_access_key = SECRETTEST
+aws_session_token = TOKENTEST
+`)
+	readFile := func(name string) ([]byte, error) {
+		switch name {
+		case "/home/test/.aws/credentials":
+			return credentialsFile, nil
+		case "/home/none/.aws/credentials":
+			return nil, fs.ErrNotExist
+		}
+		return nil, errors.New("test error")
+	}
+	fetchIMDS := func(ctx context.Context, endpoint string) (*restSigV4Credentials, error) {
+		if endpoint != restSigV4IMDSEndpoint {
+			return nil, errors.New("test error")
+		}
+		return &restSigV4Credentials{
+			AccessKeyID:     "AKIDIMDS",
+			SecretAccessKey: "SECRETIMDS",
+			SessionToken:    "TOKENIMDS",
+		}, nil
+	}
+
+	type args struct {
+		config *restSigV4Config
+		env    map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *restSigV4Credentials
+		wantErr bool
+	}{
+		{
+			name: "config",
+			args: args{
+				config: &restSigV4Config{
+					AccessKeyID:     stringPtr("AKIDCONFIG"),
+					SecretAccessKey: stringPtr("SECRETCONFIG"),
+				},
+			},
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDCONFIG",
+				SecretAccessKey: "SECRETCONFIG",
+			},
+		},
+		{
+			name: "environment",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"AWS_ACCESS_KEY_ID":     "AKIDENV",
+					"AWS_SECRET_ACCESS_KEY": "SECRETENV",
+					"AWS_SESSION_TOKEN":     "TOKENENV",
+				},
+			},
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDENV",
+				SecretAccessKey: "SECRETENV",
+				SessionToken:    "TOKENENV",
+			},
+		},
+		{
+			name: "shared credentials file",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"HOME": "/home/test",
+				},
+			},
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDDEFAULT",
+				SecretAccessKey: "SECRETDEFAULT",
+			},
+		},
+		{
+			name: "shared credentials file profile",
+			args: args{
+				config: &restSigV4Config{
+					Profile: stringPtr("test"),
+				},
+				env: map[string]string{
+					"HOME":                  "/home/test",
+					"AWS_ACCESS_KEY_ID":     "AKIDENV",
+					"AWS_SECRET_ACCESS_KEY": "SECRETENV",
+				},
+			},
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDTEST",
+				SecretAccessKey: "SECRETTEST",
+				SessionToken:    "TOKENTEST",
+			},
+		},
+		{
+			name: "instance metadata",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"HOME": "/home/none",
+				},
+			},
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDIMDS",
+				SecretAccessKey: "SECRETIMDS",
+				SessionToken:    "TOKENIMDS",
+			},
+		},
+		{
+			name: "error instance metadata disabled",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"AWS_EC2_METADATA_DISABLED": "true",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error instance metadata",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"AWS_EC2_METADATA_SERVICE_ENDPOINT": "http://invalid",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error profile selected file not found",
+			args: args{
+				config: &restSigV4Config{
+					Profile: stringPtr("test"),
+				},
+				env: map[string]string{
+					"HOME": "/home/none",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error profile not found",
+			args: args{
+				config: &restSigV4Config{
+					Profile: stringPtr("invalid"),
+				},
+				env: map[string]string{
+					"HOME": "/home/test",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error read file",
+			args: args{
+				config: &restSigV4Config{},
+				env: map[string]string{
+					"AWS_SHARED_CREDENTIALS_FILE": "/invalid",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.args.env[key]
+			}
+			got, err := resolveSigV4Credentials(context.Background(), tt.args.config, getenv, readFile, fetchIMDS)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveSigV4Credentials() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveSigV4Credentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestProviderSigV4Credentials(t *testing.T) {
+	var reads int
+	p := &restProvider{
+		osReadFile: func(name string) ([]byte, error) {
+			reads++
+			return []byte("[default]\naws_access_key_id = AKIDTEST\naws_secret_access_key = SECRETTEST\n"), nil
+		},
+		sigV4Cache: &restSigV4Cache{},
+	}
+	getenv := func(key string) string {
+		if key == "HOME" {
+			return "/home/test"
+		}
+		return ""
+	}
+	now := time.Now()
+
+	for _, tt := range []struct {
+		t         time.Time
+		wantReads int
+	}{
+		{t: now, wantReads: 1},
+		{t: now.Add(time.Minute), wantReads: 1},
+		{t: now.Add(restSigV4CacheTTL + time.Second), wantReads: 2},
+	} {
+		creds, err := p.sigV4Credentials(context.Background(), &restSigV4Config{}, tt.t, getenv)
+		if err != nil {
+			t.Fatalf("restProvider.sigV4Credentials() error = %v", err)
+		}
+		if creds.AccessKeyID != "AKIDTEST" || reads != tt.wantReads {
+			t.Errorf("restProvider.sigV4Credentials() = %v, reads = %d, want %d", creds, reads, tt.wantReads)
+		}
+	}
+}
+
+func TestRestProviderFetchSigV4IMDSCredentials(t *testing.T) {
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		role    string
+		code    string
+		want    *restSigV4Credentials
+		wantErr bool
+	}{
+		{
+			name: "default",
+			role: "test",
+			code: "Success",
+			want: &restSigV4Credentials{
+				AccessKeyID:     "AKIDIMDS",
+				SecretAccessKey: "SECRETIMDS",
+				SessionToken:    "TOKENIMDS",
+				Expiration:      expiration,
+			},
+		},
+		{
+			name:    "error no role",
+			code:    "Success",
+			wantErr: true,
+		},
+		{
+			name:    "error credentials",
+			role:    "test",
+			code:    "Failure",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+					w.Write([]byte("token"))
+				case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "token":
+					w.WriteHeader(http.StatusUnauthorized)
+				case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+					w.Write([]byte(tt.role))
+				case r.URL.Path == "/latest/meta-data/iam/security-credentials/test":
+					w.Write([]byte(`{"Code":"` + tt.code + `","AccessKeyId":"AKIDIMDS","SecretAccessKey":"SECRETIMDS",` +
+						`"Token":"TOKENIMDS","Expiration":"2030-01-01T00:00:00Z"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			p := &restProvider{
+				httpNewRequestWithContext: restHttpNewRequestWithContext,
+				httpClientDo:              restHttpClientDo,
+				ioReadAll:                 restIoReadAll,
+			}
+			got, err := p.fetchSigV4IMDSCredentials(context.Background(), server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("restProvider.fetchSigV4IMDSCredentials() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restProvider.fetchSigV4IMDSCredentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignSigV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signSigV4(req, &restSigV4Config{
+		Region:  "us-east-1",
+		Service: "iam",
+	}, &restSigV4Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("signSigV4() got %v, want %v", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("signSigV4() got %v, want %v", got, "20150830T123600Z")
+	}
+}
+
+func TestSignHMAC(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/test?key=value", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signHMAC(req, &restHMACConfig{
+		Key:   "secret",
+		KeyID: stringPtr("test"),
+	}, time.Unix(1700000000, 0))
+
+	if got := req.Header.Get(restHMACDefaultTimestampHeader); got != "1700000000" {
+		t.Errorf("signHMAC() got %v, want %v", got, "1700000000")
+	}
+	want := "test:a00cc4aa55ab0e48bd73da08bbcef77301a0d61a3b3e3e803a600ab3d5b4e453"
+	if got := req.Header.Get(restHMACDefaultHeader); got != want {
+		t.Errorf("signHMAC() got %v, want %v", got, want)
+	}
+}
@@ -202,7 +202,24 @@ func (p *jsonParser) executeResourceFromItem(ctx context.Context, store core.Sto
 	return nil
 }
 
+// Preflight checks the reachability of the resource.
+func (p *jsonParser) Preflight(ctx context.Context, fetcher core.Fetcher) error {
+	for resourceName, resource := range p.config.Resource {
+		for resourceProvider, config := range resource {
+			resourceConfig, _ := config.(map[string]interface{})
+			if err := fetcher.Preflight(ctx, resourceName, resourceProvider, resourceConfig); err != nil {
+				return fmt.Errorf("preflight resource %s: %v", resourceName, err)
+			}
+			break
+		}
+		break
+	}
+
+	return nil
+}
+
 var _ core.LoaderParserModule = (*jsonParser)(nil)
+var _ core.LoaderParserPreflightModule = (*jsonParser)(nil)
 
 // replaceParameters returns a copy of the string s with all its parameters replaced.
 func replaceParameters(s string, params map[string]interface{}) string {
@@ -32,8 +32,9 @@ func (s *testJSONParserStore) StoreResource(name string, resource *core.Resource
 var _ core.Store = (*testJSONParserStore)(nil)
 
 type testJSONParserFetcher struct {
-	resource *core.Resource
-	errFetch bool
+	resource     *core.Resource
+	errFetch     bool
+	errPreflight bool
 }
 
 func (f *testJSONParserFetcher) Fetch(ctx context.Context, name string, provider string, config map[string]interface{}) (
@@ -44,6 +45,14 @@ func (f *testJSONParserFetcher) Fetch(ctx context.Context, name string, provider
 	return f.resource, nil
 }
 
+func (f *testJSONParserFetcher) Preflight(ctx context.Context, name string, provider string,
+	config map[string]interface{}) error {
+	if f.errPreflight {
+		return errors.New("test error")
+	}
+	return nil
+}
+
 var _ core.Fetcher = (*testJSONParserFetcher)(nil)
 
 func TestJSONParserInit(t *testing.T) {
@@ -303,3 +312,69 @@ func TestJSONParserParse(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONParserPreflight(t *testing.T) {
+	type fields struct {
+		config *jsonParserConfig
+		logger *slog.Logger
+	}
+	type args struct {
+		ctx     context.Context
+		fetcher core.Fetcher
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				config: &jsonParserConfig{
+					Resource: map[string]map[string]interface{}{
+						"test": {
+							"provider": map[string]interface{}{},
+						},
+					},
+				},
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx:     context.Background(),
+				fetcher: &testJSONParserFetcher{},
+			},
+		},
+		{
+			name: "error preflight",
+			fields: fields{
+				config: &jsonParserConfig{
+					Resource: map[string]map[string]interface{}{
+						"test": {
+							"provider": map[string]interface{}{},
+						},
+					},
+				},
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx: context.Background(),
+				fetcher: &testJSONParserFetcher{
+					errPreflight: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &jsonParser{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+			}
+			if err := p.Preflight(tt.args.ctx, tt.args.fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("jsonParser.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
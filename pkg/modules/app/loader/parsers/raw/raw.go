@@ -101,4 +101,21 @@ func (p *rawParser) Parse(ctx context.Context, store core.Store, fetcher core.Fe
 	return nil
 }
 
+// Preflight checks the reachability of the resource.
+func (p *rawParser) Preflight(ctx context.Context, fetcher core.Fetcher) error {
+	for resourceName, resource := range p.config.Resource {
+		for resourceProvider, config := range resource {
+			resourceConfig, _ := config.(map[string]interface{})
+			if err := fetcher.Preflight(ctx, resourceName, resourceProvider, resourceConfig); err != nil {
+				return fmt.Errorf("preflight resource %s: %v", resourceName, err)
+			}
+			break
+		}
+		break
+	}
+
+	return nil
+}
+
 var _ core.LoaderParserModule = (*rawParser)(nil)
+var _ core.LoaderParserPreflightModule = (*rawParser)(nil)
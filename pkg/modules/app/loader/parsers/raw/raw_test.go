@@ -31,7 +31,8 @@ func (s *testRawParserStore) StoreResource(name string, resource *core.Resource)
 var _ core.Store = (*testRawParserStore)(nil)
 
 type testRawParserFetcher struct {
-	errFetch bool
+	errFetch     bool
+	errPreflight bool
 }
 
 func (f *testRawParserFetcher) Fetch(ctx context.Context, name string, provider string, config map[string]interface{}) (
@@ -42,6 +43,14 @@ func (f *testRawParserFetcher) Fetch(ctx context.Context, name string, provider
 	return &core.Resource{}, nil
 }
 
+func (f *testRawParserFetcher) Preflight(ctx context.Context, name string, provider string,
+	config map[string]interface{}) error {
+	if f.errPreflight {
+		return errors.New("test error")
+	}
+	return nil
+}
+
 var _ core.Fetcher = (*testRawParserFetcher)(nil)
 
 func TestRawParserInit(t *testing.T) {
@@ -211,3 +220,69 @@ func TestRawParserParse(t *testing.T) {
 		})
 	}
 }
+
+func TestRawParserPreflight(t *testing.T) {
+	type fields struct {
+		config *rawParserConfig
+		logger *slog.Logger
+	}
+	type args struct {
+		ctx     context.Context
+		fetcher core.Fetcher
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				config: &rawParserConfig{
+					Resource: map[string]map[string]interface{}{
+						"test": {
+							"provider": map[string]interface{}{},
+						},
+					},
+				},
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx:     context.Background(),
+				fetcher: &testRawParserFetcher{},
+			},
+		},
+		{
+			name: "error preflight",
+			fields: fields{
+				config: &rawParserConfig{
+					Resource: map[string]map[string]interface{}{
+						"test": {
+							"provider": map[string]interface{}{},
+						},
+					},
+				},
+				logger: slog.Default(),
+			},
+			args: args{
+				ctx: context.Background(),
+				fetcher: &testRawParserFetcher{
+					errPreflight: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &rawParser{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+			}
+			if err := p.Preflight(tt.args.ctx, tt.args.fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("rawParser.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package embedded implements a listener without network socket, served only by the program embedding the instance.
+package embedded
@@ -0,0 +1,84 @@
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+// embeddedListener implements the embedded listener.
+type embeddedListener struct {
+	config *embeddedListenerConfig
+	logger *slog.Logger
+}
+
+// embeddedListenerConfig implements the embedded listener configuration.
+type embeddedListenerConfig struct {
+}
+
+const (
+	embeddedModuleID module.ModuleID = "app.server.listener.embedded"
+)
+
+// init initializes the package.
+func init() {
+	module.Register(embeddedListener{})
+}
+
+// ModuleInfo returns the module information.
+func (l embeddedListener) ModuleInfo() module.ModuleInfo {
+	return module.ModuleInfo{
+		ID:           embeddedModuleID,
+		LoadModule:   func() {},
+		UnloadModule: func() {},
+		NewInstance: func() module.Module {
+			return &embeddedListener{
+				logger: slog.New(log.NewHandler(os.Stderr, string(embeddedModuleID), nil)),
+			}
+		},
+	}
+}
+
+// Init initializes the listener.
+func (l *embeddedListener) Init(config map[string]interface{}) error {
+	if err := mapstructure.Decode(config, &l.config); err != nil {
+		l.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	return nil
+}
+
+// Register registers the listener.
+func (l *embeddedListener) Register(listener core.ServerListener) error {
+	return nil
+}
+
+// Serve accepts incoming connections.
+//
+// The listener has no network socket, the requests are passed by the embedding program to the listener handler.
+func (l *embeddedListener) Serve(handler http.Handler) error {
+	l.logger.Info("Starting accepting embedded requests")
+
+	return nil
+}
+
+// Shutdown shutdowns the listener gracefully.
+func (l *embeddedListener) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the listener.
+func (l *embeddedListener) Close() error {
+	return nil
+}
+
+var _ core.ServerListenerModule = (*embeddedListener)(nil)
@@ -0,0 +1,105 @@
+package embedded
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+type testEmbeddedListener struct {
+}
+
+func (l testEmbeddedListener) Name() string {
+	return "test"
+}
+
+func (l testEmbeddedListener) Listeners() []net.Listener {
+	return nil
+}
+
+func (l testEmbeddedListener) RegisterListener(listener net.Listener) error {
+	return nil
+}
+
+var _ core.ServerListener = (*testEmbeddedListener)(nil)
+
+func TestEmbeddedListenerModuleInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		want module.ModuleInfo
+	}{
+		{
+			name: "default",
+			want: module.ModuleInfo{
+				ID:          embeddedModuleID,
+				NewInstance: func() module.Module { return &embeddedListener{} },
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := embeddedListener{}
+			got := l.ModuleInfo()
+			if got.ID != tt.want.ID {
+				t.Errorf("embeddedListener.ModuleInfo() = %v, want %v", got.ID, tt.want.ID)
+			}
+			if instance := got.NewInstance(); instance == nil {
+				t.Errorf("embeddedListener.NewInstance() = %v, want %v", instance, "not nil")
+			}
+		})
+	}
+}
+
+func TestEmbeddedListenerInit(t *testing.T) {
+	type args struct {
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "minimal",
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &embeddedListener{
+				logger: slog.Default(),
+			}
+			if err := l.Init(tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("embeddedListener.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmbeddedListenerServe(t *testing.T) {
+	l := &embeddedListener{
+		logger: slog.Default(),
+	}
+	if err := l.Init(map[string]interface{}{}); err != nil {
+		t.Fatalf("embeddedListener.Init() error = %v", err)
+	}
+	if err := l.Register(testEmbeddedListener{}); err != nil {
+		t.Errorf("embeddedListener.Register() error = %v", err)
+	}
+	if err := l.Serve(http.NotFoundHandler()); err != nil {
+		t.Errorf("embeddedListener.Serve() error = %v", err)
+	}
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Errorf("embeddedListener.Shutdown() error = %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("embeddedListener.Close() error = %v", err)
+	}
+}
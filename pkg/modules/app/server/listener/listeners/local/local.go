@@ -8,11 +8,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/listen"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
 )
@@ -21,7 +23,7 @@ import (
 type localListener struct {
 	config             *localListenerConfig
 	logger             *slog.Logger
-	listener           net.Listener
+	listeners          []net.Listener
 	server             *http.Server
 	osReadFile         func(name string) ([]byte, error)
 	netListen          func(network string, addr string) (net.Listener, error)
@@ -32,12 +34,13 @@ type localListener struct {
 
 // localListenerConfig implements the local listener configuration.
 type localListenerConfig struct {
-	ListenAddr        *string `mapstructure:"listenAddr"`
-	ListenPort        *int    `mapstructure:"listenPort"`
-	ReadTimeout       *int    `mapstructure:"readTimeout"`
-	ReadHeaderTimeout *int    `mapstructure:"readHeaderTimeout"`
-	WriteTimeout      *int    `mapstructure:"writeTimeout"`
-	IdleTimeout       *int    `mapstructure:"idleTimeout"`
+	Listen            []string `mapstructure:"listen"`
+	ListenAddr        *string  `mapstructure:"listenAddr"`
+	ListenPort        *int     `mapstructure:"listenPort"`
+	ReadTimeout       *int     `mapstructure:"readTimeout"`
+	ReadHeaderTimeout *int     `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      *int     `mapstructure:"writeTimeout"`
+	IdleTimeout       *int     `mapstructure:"idleTimeout"`
 }
 
 const (
@@ -121,6 +124,12 @@ func (l *localListener) Init(config map[string]interface{}) error {
 		l.logger.Error("Invalid value", "option", "ListenPort", "value", *l.config.ListenPort)
 		errConfig = true
 	}
+	for _, addr := range l.config.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			l.logger.Error("Invalid value", "option", "Listen", "value", addr)
+			errConfig = true
+		}
+	}
 	if l.config.ReadTimeout == nil {
 		defaultValue := localConfigDefaultReadTimeout
 		l.config.ReadTimeout = &defaultValue
@@ -163,29 +172,21 @@ func (l *localListener) Init(config map[string]interface{}) error {
 
 // Register registers the listener.
 func (l *localListener) Register(listener core.ServerListener) error {
-	listeners := listener.Listeners()
-	if len(listeners) == 1 {
-		l.listener = listeners[0]
-		return nil
-	}
-
-	var err error
-	l.listener, err = l.netListen("tcp", fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort))
+	listeners, err := listen.Listen(listener, l.addrs(), l.netListen)
 	if err != nil {
-		return fmt.Errorf("listen: %v", err)
-	}
-
-	if err = listener.RegisterListener(l.listener); err != nil {
-		return fmt.Errorf("register listener: %v", err)
+		return err
 	}
+	l.listeners = listeners
 
 	return nil
 }
 
 // Serve accepts incoming connections.
 func (l *localListener) Serve(handler http.Handler) error {
+	addrs := l.addrs()
+
 	l.server = &http.Server{
-		Addr:                         fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort),
+		Addr:                         addrs[0],
 		Handler:                      handler,
 		ReadTimeout:                  time.Duration(*l.config.ReadTimeout) * time.Second,
 		ReadHeaderTimeout:            time.Duration(*l.config.ReadHeaderTimeout) * time.Second,
@@ -194,15 +195,22 @@ func (l *localListener) Serve(handler http.Handler) error {
 		DisableGeneralOptionsHandler: true,
 	}
 
-	go func() {
-		l.logger.Info("Starting accepting connections", "addr", l.server.Addr)
+	for i, ln := range l.listeners {
+		addr := l.server.Addr
+		if i < len(addrs) {
+			addr = addrs[i]
+		}
 
-		if err := l.httpServerServe(l.server, l.listener); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				l.logger.Error("Service error", "err", err)
+		go func() {
+			l.logger.Info("Starting accepting connections", "addr", addr)
+
+			if err := l.httpServerServe(l.server, ln); err != nil {
+				if !errors.Is(err, http.ErrServerClosed) {
+					l.logger.Error("Service error", "err", err)
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	return nil
 }
@@ -225,4 +233,13 @@ func (l *localListener) Close() error {
 	return nil
 }
 
+// addrs returns the listen addresses.
+func (l *localListener) addrs() []string {
+	if len(l.config.Listen) > 0 {
+		return l.config.Listen
+	}
+
+	return []string{net.JoinHostPort(*l.config.ListenAddr, strconv.Itoa(*l.config.ListenPort))}
+}
+
 var _ core.ServerListenerModule = (*localListener)(nil)
@@ -45,7 +45,7 @@ func TestLocalListenerModuleInfo(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -71,7 +71,7 @@ func TestLocalListenerModuleInfo(t *testing.T) {
 			l := localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -94,7 +94,7 @@ func TestLocalListenerInit(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -124,6 +124,7 @@ func TestLocalListenerInit(t *testing.T) {
 			name: "full",
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"0.0.0.0:8080", "[::]:8080"},
 					"ListenAddr":        "0.0.0.0",
 					"ListenPort":        8080,
 					"ReadTimeout":       30,
@@ -140,6 +141,7 @@ func TestLocalListenerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"invalid"},
 					"ListenPort":        -1,
 					"ReadTimeout":       -1,
 					"ReadHeaderTimeout": -1,
@@ -155,7 +157,7 @@ func TestLocalListenerInit(t *testing.T) {
 			l := &localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -174,7 +176,7 @@ func TestLocalListenerRegister(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -206,6 +208,22 @@ func TestLocalListenerRegister(t *testing.T) {
 				listener: testLocalListener{},
 			},
 		},
+		{
+			name: "listen addresses",
+			fields: fields{
+				config: &localListenerConfig{
+					Listen:     []string{"0.0.0.0:8080", "[::]:8080"},
+					ListenAddr: stringPtr(localConfigDefaultListenAddr),
+					ListenPort: intPtr(localConfigDefaultListenPort),
+				},
+				netListen: func(network, addr string) (net.Listener, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				listener: testLocalListener{},
+			},
+		},
 		{
 			name: "error listen",
 			fields: fields{
@@ -246,7 +264,7 @@ func TestLocalListenerRegister(t *testing.T) {
 			l := &localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -265,7 +283,7 @@ func TestLocalListenerServe(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -305,7 +323,7 @@ func TestLocalListenerServe(t *testing.T) {
 			l := &localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -324,7 +342,7 @@ func TestLocalListenerShutdown(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -364,7 +382,7 @@ func TestLocalListenerShutdown(t *testing.T) {
 			l := &localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -383,7 +401,7 @@ func TestLocalListenerClose(t *testing.T) {
 	type fields struct {
 		config             *localListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -419,7 +437,7 @@ func TestLocalListenerClose(t *testing.T) {
 			l := &localListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -9,11 +9,13 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/listen"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
 )
@@ -22,7 +24,7 @@ import (
 type redirectListener struct {
 	config             *redirectListenerConfig
 	logger             *slog.Logger
-	listener           net.Listener
+	listeners          []net.Listener
 	server             *http.Server
 	osReadFile         func(name string) ([]byte, error)
 	netListen          func(network string, addr string) (net.Listener, error)
@@ -33,13 +35,14 @@ type redirectListener struct {
 
 // redirectListenerConfig implements the redirect listener configuration.
 type redirectListenerConfig struct {
-	ListenAddr        *string `mapstructure:"listenAddr"`
-	ListenPort        *int    `mapstructure:"listenPort"`
-	ReadTimeout       *int    `mapstructure:"readTimeout"`
-	ReadHeaderTimeout *int    `mapstructure:"readHeaderTimeout"`
-	WriteTimeout      *int    `mapstructure:"writeTimeout"`
-	IdleTimeout       *int    `mapstructure:"idleTimeout"`
-	RedirectPort      *int    `mapstructure:"redirectPort"`
+	Listen            []string `mapstructure:"listen"`
+	ListenAddr        *string  `mapstructure:"listenAddr"`
+	ListenPort        *int     `mapstructure:"listenPort"`
+	ReadTimeout       *int     `mapstructure:"readTimeout"`
+	ReadHeaderTimeout *int     `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      *int     `mapstructure:"writeTimeout"`
+	IdleTimeout       *int     `mapstructure:"idleTimeout"`
+	RedirectPort      *int     `mapstructure:"redirectPort"`
 }
 
 const (
@@ -123,6 +126,12 @@ func (l *redirectListener) Init(config map[string]interface{}) error {
 		l.logger.Error("Invalid value", "option", "ListenPort", "value", *l.config.ListenPort)
 		errConfig = true
 	}
+	for _, addr := range l.config.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			l.logger.Error("Invalid value", "option", "Listen", "value", addr)
+			errConfig = true
+		}
+	}
 	if l.config.ReadTimeout == nil {
 		defaultValue := redirectConfigDefaultReadTimeout
 		l.config.ReadTimeout = &defaultValue
@@ -169,29 +178,21 @@ func (l *redirectListener) Init(config map[string]interface{}) error {
 
 // Register registers the listener.
 func (l *redirectListener) Register(listener core.ServerListener) error {
-	listeners := listener.Listeners()
-	if len(listeners) == 1 {
-		l.listener = listeners[0]
-		return nil
-	}
-
-	var err error
-	l.listener, err = l.netListen("tcp", fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort))
+	listeners, err := listen.Listen(listener, l.addrs(), l.netListen)
 	if err != nil {
-		return fmt.Errorf("listen: %v", err)
-	}
-
-	if err = listener.RegisterListener(l.listener); err != nil {
-		return fmt.Errorf("register listener: %v", err)
+		return err
 	}
+	l.listeners = listeners
 
 	return nil
 }
 
 // Serve accepts incoming connections.
 func (l *redirectListener) Serve(handler http.Handler) error {
+	addrs := l.addrs()
+
 	l.server = &http.Server{
-		Addr:                         fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort),
+		Addr:                         addrs[0],
 		Handler:                      http.HandlerFunc(l.redirectHandler),
 		ReadTimeout:                  time.Duration(*l.config.ReadTimeout) * time.Second,
 		ReadHeaderTimeout:            time.Duration(*l.config.ReadHeaderTimeout) * time.Second,
@@ -200,15 +201,22 @@ func (l *redirectListener) Serve(handler http.Handler) error {
 		DisableGeneralOptionsHandler: true,
 	}
 
-	go func() {
-		l.logger.Info("Starting listener", "addr", l.server.Addr)
+	for i, ln := range l.listeners {
+		addr := l.server.Addr
+		if i < len(addrs) {
+			addr = addrs[i]
+		}
+
+		go func() {
+			l.logger.Info("Starting listener", "addr", addr)
 
-		if err := l.httpServerServe(l.server, l.listener); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				l.logger.Error("Service error", "err", err)
+			if err := l.httpServerServe(l.server, ln); err != nil {
+				if !errors.Is(err, http.ErrServerClosed) {
+					l.logger.Error("Service error", "err", err)
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	return nil
 }
@@ -240,11 +248,14 @@ func (l *redirectListener) redirectHandler(w http.ResponseWriter, r *http.Reques
 
 	host, _, err := net.SplitHostPort(r.Host)
 	if err != nil {
-		host = r.Host
+		host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
 	}
 
 	var target string
 	if l.config.RedirectPort == nil {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
 		target = "https://" + host + r.URL.RequestURI()
 	} else {
 		target = "https://" + net.JoinHostPort(host, strconv.Itoa(*l.config.RedirectPort)) + r.URL.RequestURI()
@@ -253,4 +264,13 @@ func (l *redirectListener) redirectHandler(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, target, http.StatusFound)
 }
 
+// addrs returns the listen addresses.
+func (l *redirectListener) addrs() []string {
+	if len(l.config.Listen) > 0 {
+		return l.config.Listen
+	}
+
+	return []string{net.JoinHostPort(*l.config.ListenAddr, strconv.Itoa(*l.config.ListenPort))}
+}
+
 var _ core.ServerListenerModule = (*redirectListener)(nil)
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/bhuisgen/neon/pkg/core"
@@ -45,7 +46,7 @@ func TestRedirectListenerModuleInfo(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -71,7 +72,7 @@ func TestRedirectListenerModuleInfo(t *testing.T) {
 			l := redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -94,7 +95,7 @@ func TestRedirectListenerInit(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -124,6 +125,7 @@ func TestRedirectListenerInit(t *testing.T) {
 			name: "full",
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"0.0.0.0:8080", "[::]:8080"},
 					"ListenAddr":        "0.0.0.0",
 					"ListenPort":        8080,
 					"ReadTimeout":       30,
@@ -141,6 +143,7 @@ func TestRedirectListenerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"invalid"},
 					"ListenPort":        -1,
 					"ReadTimeout":       -1,
 					"ReadHeaderTimeout": -1,
@@ -157,7 +160,7 @@ func TestRedirectListenerInit(t *testing.T) {
 			l := &redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -176,7 +179,7 @@ func TestRedirectListenerRegister(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -208,6 +211,22 @@ func TestRedirectListenerRegister(t *testing.T) {
 				listener: testRedirectListener{},
 			},
 		},
+		{
+			name: "listen addresses",
+			fields: fields{
+				config: &redirectListenerConfig{
+					Listen:     []string{"0.0.0.0:8080", "[::]:8080"},
+					ListenAddr: stringPtr(redirectConfigDefaultListenAddr),
+					ListenPort: intPtr(redirectConfigDefaultListenPort),
+				},
+				netListen: func(network, addr string) (net.Listener, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				listener: testRedirectListener{},
+			},
+		},
 		{
 			name: "error listen",
 			fields: fields{
@@ -248,7 +267,7 @@ func TestRedirectListenerRegister(t *testing.T) {
 			l := &redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -267,7 +286,7 @@ func TestRedirectListenerServe(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -307,7 +326,7 @@ func TestRedirectListenerServe(t *testing.T) {
 			l := &redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -326,7 +345,7 @@ func TestRedirectListenerShutdown(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -366,7 +385,7 @@ func TestRedirectListenerShutdown(t *testing.T) {
 			l := &redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -385,7 +404,7 @@ func TestRedirectListenerClose(t *testing.T) {
 	type fields struct {
 		config             *redirectListenerConfig
 		logger             *slog.Logger
-		listener           net.Listener
+		listeners          []net.Listener
 		server             *http.Server
 		osReadFile         func(name string) ([]byte, error)
 		netListen          func(network string, addr string) (net.Listener, error)
@@ -421,7 +440,7 @@ func TestRedirectListenerClose(t *testing.T) {
 			l := &redirectListener{
 				config:             tt.fields.config,
 				logger:             tt.fields.logger,
-				listener:           tt.fields.listener,
+				listeners:          tt.fields.listeners,
 				server:             tt.fields.server,
 				osReadFile:         tt.fields.osReadFile,
 				netListen:          tt.fields.netListen,
@@ -435,3 +454,77 @@ func TestRedirectListenerClose(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectListenerRedirectHandler(t *testing.T) {
+	type fields struct {
+		config *redirectListenerConfig
+	}
+	type args struct {
+		host string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   string
+	}{
+		{
+			name: "default",
+			fields: fields{
+				config: &redirectListenerConfig{},
+			},
+			args: args{
+				host: "example.com:80",
+			},
+			want: "https://example.com/test",
+		},
+		{
+			name: "redirect port",
+			fields: fields{
+				config: &redirectListenerConfig{
+					RedirectPort: intPtr(8443),
+				},
+			},
+			args: args{
+				host: "example.com",
+			},
+			want: "https://example.com:8443/test",
+		},
+		{
+			name: "ipv6",
+			fields: fields{
+				config: &redirectListenerConfig{},
+			},
+			args: args{
+				host: "[::1]:80",
+			},
+			want: "https://[::1]/test",
+		},
+		{
+			name: "ipv6 without port",
+			fields: fields{
+				config: &redirectListenerConfig{
+					RedirectPort: intPtr(8443),
+				},
+			},
+			args: args{
+				host: "[::1]",
+			},
+			want: "https://[::1]:8443/test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &redirectListener{
+				config: tt.fields.config,
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/test", nil)
+			r.Host = tt.args.host
+			l.redirectHandler(w, r)
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("redirectListener.redirectHandler() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
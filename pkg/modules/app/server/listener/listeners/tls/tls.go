@@ -11,11 +11,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/listen"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
 )
@@ -24,7 +26,7 @@ import (
 type tlsListener struct {
 	config                         *tlsListenerConfig
 	logger                         *slog.Logger
-	listener                       net.Listener
+	listeners                      []net.Listener
 	server                         *http.Server
 	osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 	osReadFile                     func(name string) ([]byte, error)
@@ -40,6 +42,7 @@ type tlsListener struct {
 
 // tlsListenerConfig implements the tls listener configuration.
 type tlsListenerConfig struct {
+	Listen            []string  `mapstructure:"listen"`
 	ListenAddr        *string   `mapstructure:"listenAddr"`
 	ListenPort        *int      `mapstructure:"listenPort"`
 	CAFiles           *[]string `mapstructure:"caFiles"`
@@ -170,6 +173,12 @@ func (l *tlsListener) Init(config map[string]interface{}) error {
 		l.logger.Error("Invalid value", "option", "ListenPort", "value", *l.config.ListenPort)
 		errConfig = true
 	}
+	for _, addr := range l.config.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			l.logger.Error("Invalid value", "option", "Listen", "value", addr)
+			errConfig = true
+		}
+	}
 	if l.config.CAFiles != nil {
 		for _, item := range *l.config.CAFiles {
 			if item == "" {
@@ -310,30 +319,24 @@ func (l *tlsListener) Init(config map[string]interface{}) error {
 }
 
 // Register registers the listener.
+//
+// All the listen addresses share the TLS configuration of the listener.
 func (l *tlsListener) Register(listener core.ServerListener) error {
-	listeners := listener.Listeners()
-	if len(listeners) == 1 {
-		l.listener = listeners[0]
-		return nil
-	}
-
-	var err error
-	l.listener, err = l.netListen("tcp", fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort))
+	listeners, err := listen.Listen(listener, l.addrs(), l.netListen)
 	if err != nil {
-		return fmt.Errorf("listen: %v", err)
-	}
-
-	if err := listener.RegisterListener(l.listener); err != nil {
-		return fmt.Errorf("register listener: %v", err)
+		return err
 	}
+	l.listeners = listeners
 
 	return nil
 }
 
 // Serve accepts incoming connections.
 func (l *tlsListener) Serve(handler http.Handler) error {
+	addrs := l.addrs()
+
 	l.server = &http.Server{
-		Addr:                         fmt.Sprintf("%s:%d", *l.config.ListenAddr, *l.config.ListenPort),
+		Addr:                         addrs[0],
 		Handler:                      handler,
 		ReadTimeout:                  time.Duration(*l.config.ReadTimeout) * time.Second,
 		ReadHeaderTimeout:            time.Duration(*l.config.ReadHeaderTimeout) * time.Second,
@@ -385,15 +388,22 @@ func (l *tlsListener) Serve(handler http.Handler) error {
 
 	l.server.TLSConfig = tlsConfig
 
-	go func() {
-		l.logger.Info("Starting accepting connections", "addr", l.server.Addr)
+	for i, ln := range l.listeners {
+		addr := l.server.Addr
+		if i < len(addrs) {
+			addr = addrs[i]
+		}
+
+		go func() {
+			l.logger.Info("Starting accepting connections", "addr", addr)
 
-		if err := l.httpServerServeTLS(l.server, l.listener, "", ""); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				l.logger.Error("Service error", "err", err)
+			if err := l.httpServerServeTLS(l.server, ln, "", ""); err != nil {
+				if !errors.Is(err, http.ErrServerClosed) {
+					l.logger.Error("Service error", "err", err)
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	return nil
 }
@@ -416,4 +426,13 @@ func (l *tlsListener) Close() error {
 	return nil
 }
 
+// addrs returns the listen addresses.
+func (l *tlsListener) addrs() []string {
+	if len(l.config.Listen) > 0 {
+		return l.config.Listen
+	}
+
+	return []string{net.JoinHostPort(*l.config.ListenAddr, strconv.Itoa(*l.config.ListenPort))}
+}
+
 var _ core.ServerListenerModule = (*tlsListener)(nil)
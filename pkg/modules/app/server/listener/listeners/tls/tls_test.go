@@ -84,7 +84,7 @@ func TestTLSListenerModuleInfo(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -115,7 +115,7 @@ func TestTLSListenerModuleInfo(t *testing.T) {
 			l := tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -143,7 +143,7 @@ func TestTLSListenerInit(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -202,6 +202,7 @@ func TestTLSListenerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"0.0.0.0:8080", "[::]:8080"},
 					"ListenAddr":        "0.0.0.0",
 					"ListenPort":        443,
 					"CAFiles":           []string{"ca.pem"},
@@ -231,6 +232,7 @@ func TestTLSListenerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
+					"Listen":            []string{"invalid"},
 					"ListenAddr":        "",
 					"ListenPort":        -1,
 					"CAFiles":           []string{""},
@@ -322,7 +324,7 @@ func TestTLSListenerInit(t *testing.T) {
 			l := &tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -346,7 +348,7 @@ func TestTLSListenerRegister(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -383,6 +385,22 @@ func TestTLSListenerRegister(t *testing.T) {
 				listener: testTLSListener{},
 			},
 		},
+		{
+			name: "listen addresses",
+			fields: fields{
+				config: &tlsListenerConfig{
+					Listen:     []string{"0.0.0.0:8080", "[::]:8080"},
+					ListenAddr: stringPtr(tlsConfigDefaultListenAddr),
+					ListenPort: intPtr(tlsConfigDefaultListenPort),
+				},
+				netListen: func(network, addr string) (net.Listener, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				listener: testTLSListener{},
+			},
+		},
 		{
 			name: "error listen",
 			fields: fields{
@@ -423,7 +441,7 @@ func TestTLSListenerRegister(t *testing.T) {
 			l := &tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -447,7 +465,7 @@ func TestTLSListenerServe(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -492,7 +510,7 @@ func TestTLSListenerServe(t *testing.T) {
 			l := &tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -516,7 +534,7 @@ func TestTLSListenerShutdown(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -561,7 +579,7 @@ func TestTLSListenerShutdown(t *testing.T) {
 			l := &tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -585,7 +603,7 @@ func TestTLSListenerClose(t *testing.T) {
 	type fields struct {
 		config                         *tlsListenerConfig
 		logger                         *slog.Logger
-		listener                       net.Listener
+		listeners                      []net.Listener
 		server                         *http.Server
 		osOpenFile                     func(name string, flag int, perm fs.FileMode) (*os.File, error)
 		osReadFile                     func(name string) ([]byte, error)
@@ -626,7 +644,7 @@ func TestTLSListenerClose(t *testing.T) {
 			l := &tlsListener{
 				config:                         tt.fields.config,
 				logger:                         tt.fields.logger,
-				listener:                       tt.fields.listener,
+				listeners:                      tt.fields.listeners,
 				server:                         tt.fields.server,
 				osOpenFile:                     tt.fields.osOpenFile,
 				osReadFile:                     tt.fields.osReadFile,
@@ -17,6 +17,7 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
 	"github.com/bhuisgen/neon/pkg/render"
 )
 
@@ -38,25 +39,28 @@ type fileHandler struct {
 
 // fileHandlerConfig implements the file handler configuration.
 type fileHandlerConfig struct {
-	Path        string  `mapstructure:"path"`
-	ContentType *string `mapstructure:"contentType"`
-	StatusCode  *int    `mapstructure:"statusCode"`
-	Cache       *bool   `mapstructure:"cache"`
-	CacheTTL    *int    `mapstructure:"cacheTTL"`
+	Path         string  `mapstructure:"path"`
+	ContentType  *string `mapstructure:"contentType"`
+	StatusCode   *int    `mapstructure:"statusCode"`
+	Cache        *bool   `mapstructure:"cache"`
+	CacheTTL     *int    `mapstructure:"cacheTTL"`
+	CacheHeaders *bool   `mapstructure:"cacheHeaders"`
 }
 
 // fileHandlerCache implments the file handler cache.
 type fileHandlerCache struct {
 	render render.Render
+	stored time.Time
 	expire time.Time
 }
 
 const (
 	fileModuleID module.ModuleID = "app.server.site.handler.file"
 
-	fileConfigDefaultStatusCode int  = 200
-	fileConfigDefaultCache      bool = false
-	fileConfigDefaultCacheTTL   int  = 60
+	fileConfigDefaultStatusCode   int  = 200
+	fileConfigDefaultCache        bool = false
+	fileConfigDefaultCacheTTL     int  = 60
+	fileConfigDefaultCacheHeaders bool = false
 )
 
 // fileOsOpenFile redirects to os.OpenFile.
@@ -157,6 +161,10 @@ func (h *fileHandler) Init(config map[string]interface{}) error {
 	if *h.config.CacheTTL <= 0 {
 		h.logger.Error("Invalid value", "option", "CacheTTL", "value", *h.config.CacheTTL)
 	}
+	if h.config.CacheHeaders == nil {
+		defaultValue := fileConfigDefaultCacheHeaders
+		h.config.CacheHeaders = &defaultValue
+	}
 
 	if errConfig {
 		return errors.New("config")
@@ -199,6 +207,11 @@ func (h *fileHandler) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (h *fileHandler) Degradable() bool {
+	return true
+}
+
 // ServeHTTP implements the http handler.
 func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
@@ -207,11 +220,16 @@ func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	degraded := priority.Degraded(r.Context())
+
 	if *h.config.Cache {
 		h.muCache.RLock()
-		if h.cache != nil && h.cache.expire.After(time.Now()) {
-			render := h.cache.render
+		if h.cache != nil && (degraded || h.cache.expire.After(time.Now())) {
+			cache := h.cache
 			h.muCache.RUnlock()
+			render := cache.render
+
+			h.setCacheHeader(w, cache)
 
 			for key, values := range render.Header() {
 				for _, value := range values {
@@ -231,8 +249,16 @@ func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.muCache.RUnlock()
 	}
 
+	if degraded {
+		priority.Shed(w, r)
+
+		h.logger.Debug("Request shed", "url", r.URL.Path)
+
+		return
+	}
+
 	if err := h.read(); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
@@ -241,7 +267,7 @@ func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	render, err := h.render(r)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
@@ -250,11 +276,15 @@ func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if *h.config.Cache {
 		h.muCache.Lock()
+		now := time.Now()
 		h.cache = &fileHandlerCache{
 			render: render,
-			expire: time.Now().Add(time.Duration(*h.config.CacheTTL) * time.Second),
+			stored: now,
+			expire: now.Add(time.Duration(*h.config.CacheTTL) * time.Second),
 		}
 		h.muCache.Unlock()
+
+		h.setCacheHeader(w, nil)
 	}
 
 	for key, values := range render.Header() {
@@ -271,19 +301,54 @@ func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Render completed", "url", r.URL.Path, "status", render.StatusCode(), "cache", false)
 }
 
+// setCacheHeader sets the cache debugging headers of the response served from the given cached render or rendered
+// for the request if nil.
+func (h *fileHandler) setCacheHeader(w http.ResponseWriter, cache *fileHandlerCache) {
+	if !*h.config.CacheHeaders {
+		return
+	}
+
+	if cache == nil {
+		render.SetCacheHeader(w, render.CacheMiss, time.Time{}, "")
+		return
+	}
+	status := render.CacheHit
+	if !cache.expire.After(time.Now()) {
+		status = render.CacheStale
+	}
+	render.SetCacheHeader(w, status, cache.stored, "")
+}
+
+// serveError writes an error response without the headers set before the failure.
+func (h *fileHandler) serveError(w http.ResponseWriter, statusCode int) {
+	render.ResetHeader(w)
+	w.WriteHeader(statusCode)
+}
+
 // read reads the file.
+//
+// The file is read again when its modification time changes, including to an older time as a copy preserving the
+// times would set. While the file is missing or locked during a swap, the previous content is kept if any.
 func (h *fileHandler) read() error {
 	fileInfo, err := h.osStat(h.config.Path)
 	if err != nil {
+		if h.loaded() {
+			h.logger.Warn("Failed to stat file, keeping previous content", "file", h.config.Path, "err", err)
+			return nil
+		}
 		h.logger.Error("Failed to stat file", "file", h.config.Path, "err", err)
 		return fmt.Errorf("stat file %s: %v", h.config.Path, err)
 	}
 
 	h.muFile.RLock()
-	if h.fileInfo == nil || fileInfo.ModTime().After(*h.fileInfo) {
+	if h.fileInfo == nil || !fileInfo.ModTime().Equal(*h.fileInfo) {
 		h.muFile.RUnlock()
 		buf, err := h.osReadFile(h.config.Path)
 		if err != nil {
+			if h.loaded() {
+				h.logger.Warn("Failed to read file, keeping previous content", "file", h.config.Path, "err", err)
+				return nil
+			}
 			h.logger.Error("Failed to read file", "file", h.config.Path, "err", err)
 			return fmt.Errorf("read file %s: %v", h.config.Path, err)
 		}
@@ -300,6 +365,14 @@ func (h *fileHandler) read() error {
 	return nil
 }
 
+// loaded reports whether the file has already been read.
+func (h *fileHandler) loaded() bool {
+	h.muFile.RLock()
+	defer h.muFile.RUnlock()
+
+	return h.fileInfo != nil
+}
+
 // render makes a new render.
 func (h *fileHandler) render(_ *http.Request) (render.Render, error) {
 	rw := h.rwPool.Get()
@@ -333,3 +406,4 @@ func (h *fileHandler) render(_ *http.Request) (render.Render, error) {
 }
 
 var _ core.ServerSiteHandlerModule = (*fileHandler)(nil)
+var _ priority.Degradable = (*fileHandler)(nil)
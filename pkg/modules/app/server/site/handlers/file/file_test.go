@@ -1,10 +1,12 @@
 package file
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"sync"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
 	"github.com/bhuisgen/neon/pkg/render"
 )
 
@@ -242,10 +245,11 @@ func TestFileHandlerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
-					"Path":       "file",
-					"StatusCode": 404,
-					"Cache":      true,
-					"CacheTTL":   60,
+					"Path":         "file",
+					"StatusCode":   404,
+					"Cache":        true,
+					"CacheTTL":     60,
+					"CacheHeaders": true,
 				},
 			},
 		},
@@ -577,10 +581,11 @@ func TestFileHandlerServeHTTP(t *testing.T) {
 			name: "default",
 			fields: fields{
 				config: &fileHandlerConfig{
-					Path:       "test",
-					StatusCode: intPtr(404),
-					Cache:      boolPtr(true),
-					CacheTTL:   intPtr(60),
+					Path:         "test",
+					StatusCode:   intPtr(404),
+					Cache:        boolPtr(true),
+					CacheTTL:     intPtr(60),
+					CacheHeaders: boolPtr(false),
 				},
 				logger:  slog.Default(),
 				muFile:  &sync.RWMutex{},
@@ -609,6 +614,62 @@ func TestFileHandlerServeHTTP(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "degraded stale cache",
+			fields: fields{
+				config: &fileHandlerConfig{
+					Path:         "test",
+					StatusCode:   intPtr(200),
+					Cache:        boolPtr(true),
+					CacheTTL:     intPtr(60),
+					CacheHeaders: boolPtr(false),
+				},
+				logger:  slog.Default(),
+				muFile:  &sync.RWMutex{},
+				rwPool:  render.NewRenderWriterPool(),
+				muCache: &sync.RWMutex{},
+				cache: &fileHandlerCache{
+					render: render.NewRenderWriter().Render(),
+					expire: time.Now().Add(-time.Minute),
+				},
+			},
+			args: args{
+				w: testFileHandlerResponseWriter{},
+				r: (&http.Request{
+					Method: http.MethodGet,
+					URL: &url.URL{
+						Path: "/test",
+					},
+				}).WithContext(priority.NewContext(context.Background(), priority.Low, true, 1)),
+			},
+		},
+		{
+			name: "degraded shed",
+			fields: fields{
+				config: &fileHandlerConfig{
+					Path:         "test",
+					StatusCode:   intPtr(200),
+					Cache:        boolPtr(true),
+					CacheTTL:     intPtr(60),
+					CacheHeaders: boolPtr(false),
+				},
+				logger:  slog.Default(),
+				muFile:  &sync.RWMutex{},
+				rwPool:  render.NewRenderWriterPool(),
+				muCache: &sync.RWMutex{},
+			},
+			args: args{
+				w: testFileHandlerResponseWriter{
+					header: http.Header{},
+				},
+				r: (&http.Request{
+					Method: http.MethodGet,
+					URL: &url.URL{
+						Path: "/test",
+					},
+				}).WithContext(priority.NewContext(context.Background(), priority.Low, true, 1)),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -630,3 +691,173 @@ func TestFileHandlerServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestFileHandlerSetCacheHeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheHeaders bool
+		cache        *fileHandlerCache
+		want         string
+	}{
+		{
+			name: "disabled",
+			cache: &fileHandlerCache{
+				stored: time.Now(),
+				expire: time.Now().Add(time.Minute),
+			},
+		},
+		{
+			name:         "miss",
+			cacheHeaders: true,
+			want:         "MISS",
+		},
+		{
+			name:         "hit",
+			cacheHeaders: true,
+			cache: &fileHandlerCache{
+				stored: time.Now(),
+				expire: time.Now().Add(time.Minute),
+			},
+			want: "HIT",
+		},
+		{
+			name:         "stale",
+			cacheHeaders: true,
+			cache: &fileHandlerCache{
+				stored: time.Now().Add(-2 * time.Minute),
+				expire: time.Now().Add(-time.Minute),
+			},
+			want: "STALE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &fileHandler{
+				config: &fileHandlerConfig{
+					CacheHeaders: boolPtr(tt.cacheHeaders),
+				},
+			}
+			w := httptest.NewRecorder()
+			h.setCacheHeader(w, tt.cache)
+			if got := w.Header().Get("X-Cache"); got != tt.want {
+				t.Errorf("setCacheHeader() X-Cache = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileHandlerRead(t *testing.T) {
+	previous := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type fields struct {
+		file       []byte
+		fileInfo   *time.Time
+		osReadFile func(name string) ([]byte, error)
+		osStat     func(name string) (fs.FileInfo, error)
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				osReadFile: func(name string) ([]byte, error) {
+					return []byte("new"), nil
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testFileHandlerFileInfo{modTime: previous}, nil
+				},
+			},
+			wantFile: "new",
+		},
+		{
+			name: "unchanged",
+			fields: fields{
+				file:     []byte("old"),
+				fileInfo: &previous,
+				osReadFile: func(name string) ([]byte, error) {
+					return []byte("new"), nil
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testFileHandlerFileInfo{modTime: previous}, nil
+				},
+			},
+			wantFile: "old",
+		},
+		{
+			name: "replaced with older time",
+			fields: fields{
+				file:     []byte("old"),
+				fileInfo: &previous,
+				osReadFile: func(name string) ([]byte, error) {
+					return []byte("new"), nil
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testFileHandlerFileInfo{modTime: previous.Add(-time.Hour)}, nil
+				},
+			},
+			wantFile: "new",
+		},
+		{
+			name: "file locked during swap",
+			fields: fields{
+				file:     []byte("old"),
+				fileInfo: &previous,
+				osReadFile: func(name string) ([]byte, error) {
+					return nil, errors.New("test error")
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testFileHandlerFileInfo{modTime: previous.Add(time.Hour)}, nil
+				},
+			},
+			wantFile: "old",
+		},
+		{
+			name: "file missing during swap",
+			fields: fields{
+				file:     []byte("old"),
+				fileInfo: &previous,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+			wantFile: "old",
+		},
+		{
+			name: "error read file",
+			fields: fields{
+				osReadFile: func(name string) ([]byte, error) {
+					return nil, errors.New("test error")
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testFileHandlerFileInfo{modTime: previous}, nil
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &fileHandler{
+				config: &fileHandlerConfig{
+					Path: "test",
+				},
+				logger:     slog.Default(),
+				file:       tt.fields.file,
+				fileInfo:   tt.fields.fileInfo,
+				muFile:     &sync.RWMutex{},
+				osReadFile: tt.fields.osReadFile,
+				osStat:     tt.fields.osStat,
+			}
+			if err := h.read(); (err != nil) != tt.wantErr {
+				t.Errorf("fileHandler.read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := string(h.file); got != tt.wantFile {
+				t.Errorf("fileHandler.read() file = %v, want %v", got, tt.wantFile)
+			}
+		})
+	}
+}
@@ -15,6 +15,48 @@ export interface Resource {
   error: string | null;
 }
 
+/**
+ * Build information interface.
+ *
+ * @interface BuildInfo
+ */
+interface BuildInfo {
+  /**
+   * The program name.
+   */
+  name: string;
+
+  /**
+   * The program version.
+   */
+  version: string;
+
+  /**
+   * The commit of the build.
+   */
+  commit: string;
+
+  /**
+   * The build date.
+   */
+  date: string;
+
+  /**
+   * The Go version of the build.
+   */
+  goVersion: string;
+
+  /**
+   * The JavaScript engine version.
+   */
+  engineVersion: string;
+
+  /**
+   * The build platform.
+   */
+  platform: string;
+}
+
 /**
  * Site interface.
  *
@@ -58,6 +100,8 @@ interface Site {
 interface Handler {
   /**
    * Returns the handler state.
+   *
+   * @returns {Record<string, Resource>} The state resources.
    */
   state(): Record<string, Resource>;
 }
@@ -109,9 +153,24 @@ interface Request {
   query(): Record<string, string[]>;
 
   /**
-   * Returns the request headers.
+   * Returns the request headers allowed by the handler configuration.
    */
   headers(): Record<string, string[]>;
+
+  /**
+   * Returns the first value of an allowed request header.
+   *
+   * @param name the header name
+   * @returns {string | null} The header value or null if absent or not allowed.
+   */
+  header(name: string): string | null;
+
+  /**
+   * Returns the client device class.
+   *
+   * @returns {string} The device class: "mobile", "tablet", "desktop" or "bot".
+   */
+  device(): string;
 }
 
 /**
@@ -128,6 +187,21 @@ interface Response {
    */
   render(content: string, status: number): void;
 
+  /**
+   * Marks the response as not found.
+   *
+   * The response status code is forced to 404 and the render is cached with
+   * the not found TTL.
+   */
+  notFound(): void;
+
+  /**
+   * Marks the response as done.
+   *
+   * The render is finalized without waiting for the pending timers.
+   */
+  done(): void;
+
   /**
    * Redirects the client to another URL.
    *
@@ -136,6 +210,40 @@ interface Response {
    */
   redirect(url: string, status: number): void;
 
+  /**
+   * Records a performance mark at the current time of the execution.
+   *
+   * The elapsed time since the start of the execution is reported in the
+   * access log and the debug trace.
+   *
+   * @param name the mark name
+   */
+  mark(name: string): void;
+
+  /**
+   * Records a performance measure between two marks.
+   *
+   * The measure starts at the start of the execution if no start mark is
+   * given and ends at the current time if no end mark is given. Its duration
+   * is reported in the access log and the debug trace.
+   *
+   * @param name the measure name
+   * @param start the start mark name
+   * @param end the end mark name
+   */
+  measure(name: string, start?: string, end?: string): void;
+
+  /**
+   * Returns the tag embedding the response of an internal route.
+   *
+   * The tag is replaced server-side by the include middleware with the
+   * response of the route, cached with its own TTL.
+   *
+   * @param path the route path
+   * @returns the include tag
+   */
+  include(path: string): string;
+
   /**
    * Sets a response header.
    *
@@ -201,4 +309,16 @@ export interface Server {
    * The response object.
    */
   response: Response;
+
+  /**
+   * Returns the build information of the server.
+   *
+   * @returns {BuildInfo} The build information.
+   */
+  buildInfo(): BuildInfo;
 }
+
+/**
+ * Server state global, each resource being converted on its first access.
+ */
+export declare const serverState: Readonly<Record<string, Resource>>;
@@ -0,0 +1,69 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/trace"
+)
+
+// JSBudget implements a render budget of the routes matching a path.
+type JSBudget struct {
+	Path       string  `mapstructure:"path"`
+	HTMLBytes  *int    `mapstructure:"htmlBytes"`
+	StateBytes *int    `mapstructure:"stateBytes"`
+	RenderTime *int    `mapstructure:"renderTime"`
+	Mode       *string `mapstructure:"mode"`
+}
+
+// jsBudgetUsage implements the measured usage of a render compared to the budgets.
+type jsBudgetUsage struct {
+	htmlBytes  int
+	stateBytes int
+	renderTime time.Duration
+}
+
+const (
+	jsBudgetModeWarn    string = "warn"
+	jsBudgetModeEnforce string = "enforce"
+
+	jsBudgetHeader string = "X-Neon-Budget"
+)
+
+// checkBudgets compares the usage of the render of the request to the matching budgets and returns the violations,
+// and true if one of them is enforced.
+//
+// Each violation is logged so that the weight regressions of the renders stay visible.
+func (h *jsHandler) checkBudgets(r *http.Request, usage jsBudgetUsage) ([]string, bool) {
+	var violations []string
+	var enforced bool
+
+	path := normalize.Path(r.URL.Path)
+	for index := h.budgetRuleSet.Next(path, 0); index >= 0; index = h.budgetRuleSet.Next(path, index+1) {
+		budget := h.config.Budgets[index]
+
+		check := func(metric string, value int, limit *int) {
+			if limit == nil || value <= *limit {
+				return
+			}
+			violations = append(violations, fmt.Sprintf("%s=%d/%d", metric, value, *limit))
+			if *budget.Mode == jsBudgetModeEnforce {
+				enforced = true
+			}
+
+			h.logger.Warn("Render budget exceeded", "url", r.URL.Path, "budget", index+1, "metric", metric,
+				"value", value, "limit", *limit, "mode", *budget.Mode)
+			trace.FromContext(r.Context()).Add(string(jsModuleID), "Render budget exceeded", "budget", index+1,
+				"metric", metric, "value", value, "limit", *limit)
+		}
+		check("htmlBytes", usage.htmlBytes, budget.HTMLBytes)
+		check("stateBytes", usage.stateBytes, budget.StateBytes)
+		check("renderTime", int(usage.renderTime.Milliseconds()), budget.RenderTime)
+	}
+
+	return violations, enforced
+}
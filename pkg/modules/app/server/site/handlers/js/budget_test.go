@@ -0,0 +1,79 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/pattern"
+)
+
+func TestJSHandlerCheckBudgets(t *testing.T) {
+	tests := []struct {
+		name           string
+		budgets        []JSBudget
+		target         string
+		usage          jsBudgetUsage
+		wantViolations []string
+		wantEnforced   bool
+	}{
+		{
+			name: "within budget",
+			budgets: []JSBudget{
+				{Path: "^/", HTMLBytes: intPtr(100), StateBytes: intPtr(10), RenderTime: intPtr(50),
+					Mode: stringPtr(jsBudgetModeWarn)},
+			},
+			target: "/test",
+			usage:  jsBudgetUsage{htmlBytes: 100, stateBytes: 10, renderTime: 50 * time.Millisecond},
+		},
+		{
+			name: "warn",
+			budgets: []JSBudget{
+				{Path: "^/", HTMLBytes: intPtr(100), StateBytes: intPtr(10), RenderTime: intPtr(50),
+					Mode: stringPtr(jsBudgetModeWarn)},
+			},
+			target:         "/test",
+			usage:          jsBudgetUsage{htmlBytes: 101, stateBytes: 11, renderTime: 51 * time.Millisecond},
+			wantViolations: []string{"htmlBytes=101/100", "stateBytes=11/10", "renderTime=51/50"},
+		},
+		{
+			name: "enforce",
+			budgets: []JSBudget{
+				{Path: "^/other", HTMLBytes: intPtr(10), Mode: stringPtr(jsBudgetModeEnforce)},
+				{Path: "^/test", HTMLBytes: intPtr(10), Mode: stringPtr(jsBudgetModeEnforce)},
+			},
+			target:         "/test",
+			usage:          jsBudgetUsage{htmlBytes: 20},
+			wantViolations: []string{"htmlBytes=20/10"},
+			wantEnforced:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var regexps []*regexp.Regexp
+			for _, budget := range tt.budgets {
+				regexps = append(regexps, regexp.MustCompile(budget.Path))
+			}
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Budgets: tt.budgets,
+				},
+				logger:        slog.Default(),
+				budgetRuleSet: pattern.NewSet(regexps),
+			}
+			violations, enforced := h.checkBudgets(httptest.NewRequest(http.MethodGet, tt.target, nil), tt.usage)
+			if !reflect.DeepEqual(violations, tt.wantViolations) {
+				t.Errorf("jsHandler.checkBudgets() violations = %v, want %v", violations, tt.wantViolations)
+			}
+			if enforced != tt.wantEnforced {
+				t.Errorf("jsHandler.checkBudgets() enforced = %v, want %v", enforced, tt.wantEnforced)
+			}
+		})
+	}
+}
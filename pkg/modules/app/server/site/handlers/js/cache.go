@@ -1,71 +1,130 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
 	"container/list"
 	"sync"
+
+	"github.com/bhuisgen/neon/pkg/tinylfu"
 )
 
 // Cache
 type Cache interface {
 	Get(key string) any
-	Set(key string, value any)
+	Set(key string, value any, size int)
 	Remove(key string)
+	RemoveFunc(fn func(key string, value any) bool)
 	Clear()
+	Stats() CacheStats
+}
+
+// CacheStats implements the cache occupancy and efficiency statistics.
+type CacheStats struct {
+	Entries    int
+	Bytes      int
+	Evictions  uint64
+	Hits       uint64
+	Misses     uint64
+	Rejections uint64
 }
 
-// cache implements a LRU cache.
+// HitRatio returns the ratio of the lookups returning an object.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cache implements a LRU cache with an optional TinyLFU admission policy.
 type cache struct {
-	capacity int
-	m        map[string]*cacheItem
-	l        *list.List
-	mu       sync.RWMutex
+	capacity   int
+	bytes      int
+	evictions  uint64
+	hits       uint64
+	misses     uint64
+	rejections uint64
+	m          map[string]*cacheItem
+	l          *list.List
+	filter     *tinylfu.Filter
+	mu         sync.Mutex
 }
 
 // item implements the value in the map.
 type cacheItem struct {
-	v any
-	e *list.Element
+	v    any
+	size int
+	e    *list.Element
 }
 
+const (
+	cachePolicyLRU     string = "lru"
+	cachePolicyTinyLFU string = "tinylfu"
+)
+
 // newCache creates a new cache instance.
-func newCache(capacity int) *cache {
-	return &cache{
+//
+// With the TinyLFU policy, a new object evicting another one is only stored if it is accessed more frequently than
+// the evicted object.
+func newCache(capacity int, policy string) *cache {
+	c := &cache{
 		capacity: capacity,
 		m:        make(map[string]*cacheItem, capacity),
 		l:        list.New(),
 	}
+	if policy == cachePolicyTinyLFU {
+		c.filter = tinylfu.New(capacity)
+	}
+	return c
 }
 
 // Get returns the object with the given key.
 func (c *cache) Get(key string) any {
-	c.mu.RLock()
-	if i, ok := c.m[key]; ok {
-		c.l.MoveToFront(i.e)
-		c.mu.RUnlock()
-		return i.v
-	} else {
-		c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.filter != nil {
+		c.filter.Record(key)
+	}
+
+	i, ok := c.m[key]
+	if !ok {
+		c.misses++
 		return nil
 	}
+	c.l.MoveToFront(i.e)
+	c.hits++
+
+	return i.v
 }
 
-// Set stores a object with the given key.
-func (c *cache) Set(key string, value any) {
+// Set stores a object with the given key and size.
+func (c *cache) Set(key string, value any, size int) {
 	c.mu.Lock()
 	if i, ok := c.m[key]; ok {
+		c.bytes += size - i.size
 		i.v = value
+		i.size = size
 		c.l.MoveToFront(i.e)
-		c.m[key] = i
 	} else {
 		if c.l.Len() >= c.capacity {
-			value := c.l.Remove(c.l.Back())
-			delete(c.m, value.(string))
+			victim := c.l.Back()
+			if c.filter != nil && !c.filter.Admit(key, victim.Value.(string)) {
+				c.rejections++
+				c.mu.Unlock()
+				return
+			}
+			c.remove(victim.Value.(string), c.m[victim.Value.(string)])
+			c.evictions++
 		}
 		e := c.l.PushFront(key)
 		c.m[key] = &cacheItem{
-			v: value,
-			e: e,
+			v:    value,
+			size: size,
+			e:    e,
 		}
+		c.bytes += size
 	}
 	c.mu.Unlock()
 }
@@ -74,8 +133,18 @@ func (c *cache) Set(key string, value any) {
 func (c *cache) Remove(key string) {
 	c.mu.Lock()
 	if i, ok := c.m[key]; ok {
-		c.l.Remove(i.e)
-		delete(c.m, key)
+		c.remove(key, i)
+	}
+	c.mu.Unlock()
+}
+
+// RemoveFunc removes all objects for which the given function returns true.
+func (c *cache) RemoveFunc(fn func(key string, value any) bool) {
+	c.mu.Lock()
+	for key, node := range c.m {
+		if fn(key, node.v) {
+			c.remove(key, node)
+		}
 	}
 	c.mu.Unlock()
 }
@@ -84,10 +153,31 @@ func (c *cache) Remove(key string) {
 func (c *cache) Clear() {
 	c.mu.Lock()
 	for key, node := range c.m {
-		c.l.Remove(node.e)
-		delete(c.m, key)
+		c.remove(key, node)
 	}
 	c.mu.Unlock()
 }
 
+// Stats returns the cache statistics.
+func (c *cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Entries:    c.l.Len(),
+		Bytes:      c.bytes,
+		Evictions:  c.evictions,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Rejections: c.rejections,
+	}
+}
+
+// remove removes an item from the cache. The caller must hold the lock.
+func (c *cache) remove(key string, i *cacheItem) {
+	c.l.Remove(i.e)
+	delete(c.m, key)
+	c.bytes -= i.size
+}
+
 var _ Cache = (*cache)(nil)
@@ -1,3 +1,5 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
@@ -8,8 +10,8 @@ func TestCacheGet(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache(1)
-	cache.Set(key, value)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value, len(value))
 
 	if v := cache.Get(key); v != value {
 		t.Errorf("c.Get() got %v, want %v", v, value)
@@ -20,8 +22,8 @@ func TestCacheGet_InvalidKey(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache(1)
-	cache.Set(key, value)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value, len(value))
 
 	if v := cache.Get("invalid"); v != nil {
 		t.Errorf("c.Get() got %v, want %v", v, nil)
@@ -32,8 +34,8 @@ func TestCacheSet(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache(1)
-	cache.Set(key, value)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value, len(value))
 
 	if v := cache.Get(key); v != value {
 		t.Errorf("c.Get() got %v, want %v", v, value)
@@ -45,9 +47,9 @@ func TestCacheSet_ExistingKey(t *testing.T) {
 	value1 := "value1"
 	value2 := "value2"
 
-	cache := newCache(1)
-	cache.Set(key, value1)
-	cache.Set(key, value2)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value1, len(value1))
+	cache.Set(key, value2, len(value2))
 
 	if v := cache.Get(key); v != value2 {
 		t.Errorf("c.Get() got %v, want %v", v, value2)
@@ -60,9 +62,9 @@ func TestCacheSet_MaxCapacity(t *testing.T) {
 	value1 := "value1"
 	value2 := "value2"
 
-	cache := newCache(1)
-	cache.Set(key1, value1)
-	cache.Set(key2, value2)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key1, value1, len(value1))
+	cache.Set(key2, value2, len(value2))
 
 	if v := cache.Get(key1); v != nil {
 		t.Errorf("c.Get() got %v, want %v", v, value2)
@@ -76,8 +78,8 @@ func TestCacheRemove(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache(1)
-	cache.Set(key, value)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value, len(value))
 
 	cache.Remove(key)
 	if v := cache.Get("invalid"); v != nil {
@@ -89,11 +91,80 @@ func TestCacheClear(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache(1)
-	cache.Set(key, value)
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set(key, value, len(value))
 	cache.Clear()
 
 	if v := cache.Get("invalid"); v != nil {
 		t.Errorf("c.Get() got %v, want %v", v, nil)
 	}
 }
+
+func TestCacheRemoveFunc(t *testing.T) {
+	key1 := "test1"
+	key2 := "test2"
+	value1 := "value1"
+	value2 := "value2"
+
+	cache := newCache(2, cachePolicyLRU)
+	cache.Set(key1, value1, len(value1))
+	cache.Set(key2, value2, len(value2))
+
+	cache.RemoveFunc(func(key string, value any) bool {
+		return value == value1
+	})
+	if v := cache.Get(key1); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if v := cache.Get(key2); v != value2 {
+		t.Errorf("c.Get() got %v, want %v", v, value2)
+	}
+}
+
+func TestCacheSet_TinyLFU(t *testing.T) {
+	cache := newCache(1, cachePolicyTinyLFU)
+	cache.Set("test1", "value1", 6)
+	for i := 0; i < 3; i++ {
+		cache.Get("test1")
+	}
+	cache.Set("test2", "value2", 6)
+
+	if v := cache.Get("test2"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if v := cache.Get("test1"); v != "value1" {
+		t.Errorf("c.Get() got %v, want %v", v, "value1")
+	}
+
+	for i := 0; i < 8; i++ {
+		cache.Get("test2")
+	}
+	cache.Set("test2", "value2", 6)
+
+	if v := cache.Get("test2"); v != "value2" {
+		t.Errorf("c.Get() got %v, want %v", v, "value2")
+	}
+	if s := cache.Stats(); s.Entries != 1 || s.Rejections != 1 || s.Evictions != 1 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cache := newCache(1, cachePolicyLRU)
+	cache.Set("test", "value", 5)
+	cache.Get("test")
+	cache.Get("invalid")
+
+	want := CacheStats{
+		Entries: 1,
+		Bytes:   5,
+		Hits:    1,
+		Misses:  1,
+	}
+	if s := cache.Stats(); s != want {
+		t.Errorf("c.Stats() got %v, want %v", s, want)
+	}
+	if r := cache.Stats().HitRatio(); r != 0.5 {
+		t.Errorf("c.Stats().HitRatio() got %v, want %v", r, 0.5)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+// JSCanary implements the canary verifier configuration.
+type JSCanary struct {
+	Bundle string   `mapstructure:"bundle"`
+	Path   *string  `mapstructure:"path"`
+	Token  string   `mapstructure:"token"`
+	MaxVMs *int     `mapstructure:"maxVMs"`
+	Routes []string `mapstructure:"routes"`
+}
+
+// jsCanaryReport implements the canary verifier report.
+type jsCanaryReport struct {
+	Bundle           string                `json:"bundle"`
+	Candidate        string                `json:"candidate"`
+	Total            int                   `json:"total"`
+	Mismatches       int                   `json:"mismatches"`
+	StatusMismatches int                   `json:"statusMismatches"`
+	Errors           int                   `json:"errors"`
+	Routes           []jsCanaryRouteReport `json:"routes"`
+}
+
+// jsCanaryRouteReport implements the canary verifier report of a route.
+type jsCanaryRouteReport struct {
+	Path            string        `json:"path"`
+	Status          int           `json:"status,omitempty"`
+	CandidateStatus int           `json:"candidateStatus,omitempty"`
+	Equal           bool          `json:"equal"`
+	Diff            *jsCanaryDiff `json:"diff,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// jsCanaryDiff implements the difference between two renders.
+type jsCanaryDiff struct {
+	Line    int      `json:"line"`
+	Removed []string `json:"removed"`
+	Added   []string `json:"added"`
+}
+
+const (
+	jsCanaryHeaderToken  string = "X-Neon-Canary-Token"
+	jsCanaryParamSample  string = "sample"
+	jsCanaryDiffMaxLines int    = 20
+)
+
+// serveCanary renders the canary routes with the current and the candidate bundles and writes the report.
+func (h *jsHandler) serveCanary(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(jsCanaryHeaderToken)), []byte(h.config.Canary.Token)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if err := h.read(); err != nil {
+		h.serveError(w, http.StatusServiceUnavailable)
+
+		h.logger.Error("Canary error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
+
+		return
+	}
+	candidate, err := h.osReadFile(h.config.Canary.Bundle)
+	if err != nil {
+		h.serveError(w, http.StatusServiceUnavailable)
+
+		h.logger.Error("Failed to read canary bundle file", "file", h.config.Canary.Bundle, "err", err)
+
+		return
+	}
+
+	routes := h.config.Canary.Routes
+	if n, err := strconv.Atoi(r.URL.Query().Get(jsCanaryParamSample)); err == nil && n > 0 && n < len(routes) {
+		sample := make([]string, len(routes))
+		copy(sample, routes)
+		rand.Shuffle(len(sample), func(i, j int) {
+			sample[i], sample[j] = sample[j], sample[i]
+		})
+		routes = sample[:n]
+	}
+
+	report := h.canary(r, routes, candidate)
+
+	h.logger.Info("Canary verification completed", "candidate", h.config.Canary.Bundle, "total", report.Total,
+		"mismatches", report.Mismatches, "statusMismatches", report.StatusMismatches, "errors", report.Errors)
+
+	buf, err := json.Marshal(report)
+	if err != nil {
+		h.serveError(w, http.StatusInternalServerError)
+
+		h.logger.Error("Failed to marshal canary report", "err", err)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf); err != nil {
+		h.logger.Error("Failed to write canary report", "err", err)
+	}
+}
+
+// canary renders the given routes with the current and the candidate bundles and compares the renders.
+func (h *jsHandler) canary(r *http.Request, routes []string, candidate []byte) *jsCanaryReport {
+	report := &jsCanaryReport{
+		Bundle:    h.config.Bundle,
+		Candidate: h.config.Canary.Bundle,
+		Total:     len(routes),
+	}
+
+	for _, route := range routes {
+		result := jsCanaryRouteReport{
+			Path: route,
+		}
+
+		u, err := url.ParseRequestURI(route)
+		if err != nil {
+			result.Error = err.Error()
+			report.Errors++
+			report.Routes = append(report.Routes, result)
+			continue
+		}
+		req := r.Clone(r.Context())
+		req.URL = u
+		req.RequestURI = route
+		req.Header.Del(jsCanaryHeaderToken)
+
+		current, _, err := h.render(req)
+		if err != nil {
+			result.Error = "bundle: " + err.Error()
+			report.Errors++
+			report.Routes = append(report.Routes, result)
+			continue
+		}
+		next, _, err := h.renderBundle(req, h.config.Canary.Bundle, candidate, nil, vmSemaphore(h.canaryVMs))
+		if err != nil {
+			result.Error = "candidate: " + err.Error()
+			report.Errors++
+			report.Routes = append(report.Routes, result)
+			continue
+		}
+
+		result.Status = current.StatusCode()
+		result.CandidateStatus = next.StatusCode()
+		result.Diff = jsCanaryDiffRenders(current, next)
+		result.Equal = result.Status == result.CandidateStatus && result.Diff == nil
+		if result.Status != result.CandidateStatus {
+			report.StatusMismatches++
+		}
+		if !result.Equal {
+			report.Mismatches++
+		}
+
+		report.Routes = append(report.Routes, result)
+	}
+
+	return report
+}
+
+// jsCanaryDiffRenders returns the difference between the content of two renders or nil if they are equal.
+func jsCanaryDiffRenders(a render.Render, b render.Render) *jsCanaryDiff {
+	content := func(r render.Render) []byte {
+		if r.Redirect() {
+			return []byte("redirect: " + r.RedirectURL())
+		}
+		return r.Body()
+	}
+
+	return jsCanaryDiffLines(content(a), content(b))
+}
+
+// jsCanaryDiffLines returns the differing lines between the common prefix and suffix of two contents or nil if they
+// are equal.
+func jsCanaryDiffLines(a []byte, b []byte) *jsCanaryDiff {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	linesA := strings.Split(string(a), "\n")
+	linesB := strings.Split(string(b), "\n")
+
+	var prefix int
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	truncate := func(lines []string) []string {
+		if len(lines) > jsCanaryDiffMaxLines {
+			return lines[:jsCanaryDiffMaxLines]
+		}
+		return lines
+	}
+
+	return &jsCanaryDiff{
+		Line:    prefix + 1,
+		Removed: truncate(linesA[prefix : len(linesA)-suffix]),
+		Added:   truncate(linesB[prefix : len(linesB)-suffix]),
+	}
+}
@@ -0,0 +1,206 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+func TestJSHandlerServeCanary(t *testing.T) {
+	tests := []struct {
+		name                 string
+		candidate            string
+		token                string
+		target               string
+		wantStatus           int
+		wantTotal            int
+		wantMismatches       int
+		wantStatusMismatches int
+	}{
+		{
+			name:           "same bundle",
+			candidate:      "test/default/bundle.js",
+			token:          "token",
+			target:         "/__neon/canary",
+			wantStatus:     http.StatusOK,
+			wantTotal:      2,
+			wantMismatches: 0,
+		},
+		{
+			name:                 "status mismatches",
+			candidate:            "test/notfound/bundle.js",
+			token:                "token",
+			target:               "/__neon/canary",
+			wantStatus:           http.StatusOK,
+			wantTotal:            2,
+			wantMismatches:       2,
+			wantStatusMismatches: 2,
+		},
+		{
+			name:                 "sample",
+			candidate:            "test/notfound/bundle.js",
+			token:                "token",
+			target:               "/__neon/canary?sample=1",
+			wantStatus:           http.StatusOK,
+			wantTotal:            1,
+			wantMismatches:       1,
+			wantStatusMismatches: 1,
+		},
+		{
+			name:       "error invalid token",
+			candidate:  "test/default/bundle.js",
+			token:      "invalid",
+			target:     "/__neon/canary",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "error read candidate",
+			candidate:  "test/unknown/bundle.js",
+			token:      "token",
+			target:     "/__neon/canary",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Index:            "test/default/index.html",
+					IndexTemplate:    boolPtr(false),
+					Bundle:           "test/default/bundle.js",
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(1),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(false),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					CacheCompress:    boolPtr(false),
+					Canary: &JSCanary{
+						Bundle: tt.candidate,
+						Path:   stringPtr("/__neon/canary"),
+						Token:  "token",
+						MaxVMs: intPtr(1),
+						Routes: []string{"/", "/test?page=1"},
+					},
+				},
+				logger:    slog.Default(),
+				muIndex:   &sync.RWMutex{},
+				muBundle:  &sync.RWMutex{},
+				vms:       make(chan struct{}, 1),
+				canaryVMs: make(chan struct{}, 1),
+				rwPool:    render.NewRenderWriterPool(),
+				cache:     newCache(1, cachePolicyLRU),
+				site:      testJSHandlerServerSite{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+				jsonMarshal: json.Marshal,
+			}
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Header.Set(jsCanaryHeaderToken, tt.token)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("jsHandler.ServeHTTP() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var report jsCanaryReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Total != tt.wantTotal || report.Mismatches != tt.wantMismatches ||
+				report.StatusMismatches != tt.wantStatusMismatches || report.Errors != 0 {
+				t.Errorf("jsHandler.ServeHTTP() report = %+v", report)
+			}
+		})
+	}
+}
+
+func TestJSCanaryDiffLines(t *testing.T) {
+	type args struct {
+		a []byte
+		b []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want *jsCanaryDiff
+	}{
+		{
+			name: "equal",
+			args: args{
+				a: []byte("<html>\n<body>test</body>\n</html>"),
+				b: []byte("<html>\n<body>test</body>\n</html>"),
+			},
+		},
+		{
+			name: "changed line",
+			args: args{
+				a: []byte("<html>\n<body>test</body>\n</html>"),
+				b: []byte("<html>\n<body>candidate</body>\n</html>"),
+			},
+			want: &jsCanaryDiff{
+				Line:    2,
+				Removed: []string{"<body>test</body>"},
+				Added:   []string{"<body>candidate</body>"},
+			},
+		},
+		{
+			name: "added lines",
+			args: args{
+				a: []byte("<html>\n</html>"),
+				b: []byte("<html>\n<head></head>\n<body></body>\n</html>"),
+			},
+			want: &jsCanaryDiff{
+				Line:    2,
+				Removed: []string{},
+				Added:   []string{"<head></head>", "<body></body>"},
+			},
+		},
+		{
+			name: "repeated lines",
+			args: args{
+				a: []byte("a\nb\nb"),
+				b: []byte("a\nb"),
+			},
+			want: &jsCanaryDiff{
+				Line:    3,
+				Removed: []string{"b"},
+				Added:   []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsCanaryDiffLines(tt.args.a, tt.args.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jsCanaryDiffLines() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
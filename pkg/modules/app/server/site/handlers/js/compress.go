@@ -0,0 +1,57 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	jsEncodingBrotli string = "br"
+	jsEncodingGzip   string = "gzip"
+
+	jsCompressBrotliLevel int = 9
+	jsCompressGzipLevel   int = gzip.BestCompression
+	jsCompressMinSize     int = 256
+)
+
+// jsEncodings contains the pre-compressed encodings by order of preference.
+var jsEncodings = []string{jsEncodingBrotli, jsEncodingGzip}
+
+// jsCompress returns the pre-compressed variants of the given body by encoding or nil if the body is too small to be
+// worth compressing.
+func jsCompress(body []byte) (map[string][]byte, error) {
+	if len(body) < jsCompressMinSize {
+		return nil, nil
+	}
+
+	variants := make(map[string][]byte, len(jsEncodings))
+
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, jsCompressBrotliLevel)
+	if _, err := bw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	variants[jsEncodingBrotli] = br.Bytes()
+
+	var gz bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&gz, jsCompressGzipLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	variants[jsEncodingGzip] = gz.Bytes()
+
+	return variants, nil
+}
@@ -0,0 +1,45 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestJSCompress(t *testing.T) {
+	body := []byte(strings.Repeat("<div>test</div>", 100))
+
+	got, err := jsCompress(body)
+	if err != nil {
+		t.Fatalf("jsCompress() error = %v", err)
+	}
+
+	br, err := io.ReadAll(brotli.NewReader(bytes.NewReader(got[jsEncodingBrotli])))
+	if err != nil || !bytes.Equal(br, body) {
+		t.Errorf("jsCompress() brotli variant = %v, err %v", br, err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(got[jsEncodingGzip]))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	gz, err := io.ReadAll(gr)
+	if err != nil || !bytes.Equal(gz, body) {
+		t.Errorf("jsCompress() gzip variant = %v, err %v", gz, err)
+	}
+}
+
+func TestJSCompress_Small(t *testing.T) {
+	got, err := jsCompress([]byte("test"))
+	if err != nil {
+		t.Fatalf("jsCompress() error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("jsCompress() = %v, want %v", got, nil)
+	}
+}
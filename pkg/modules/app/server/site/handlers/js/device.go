@@ -0,0 +1,44 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	deviceClassBot     string = "bot"
+	deviceClassMobile  string = "mobile"
+	deviceClassTablet  string = "tablet"
+	deviceClassDesktop string = "desktop"
+)
+
+var (
+	deviceBotRegexp    = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|facebookexternalhit|headlesschrome|lighthouse`)
+	deviceTabletRegexp = regexp.MustCompile(`(?i)ipad|tablet|kindle|silk|playbook`)
+	deviceMobileRegexp = regexp.MustCompile(`(?i)mobi|iphone|ipod|android|blackberry|iemobile|opera mini|windows phone`)
+)
+
+// deviceClass returns the device class of the client from the user agent and the client hints.
+func deviceClass(r *http.Request) string {
+	ua := r.UserAgent()
+
+	if deviceBotRegexp.MatchString(ua) {
+		return deviceClassBot
+	}
+
+	if r.Header.Get("Sec-CH-UA-Mobile") == "?1" {
+		return deviceClassMobile
+	}
+
+	if deviceTabletRegexp.MatchString(ua) || strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile") {
+		return deviceClassTablet
+	}
+	if deviceMobileRegexp.MatchString(ua) {
+		return deviceClassMobile
+	}
+
+	return deviceClassDesktop
+}
@@ -0,0 +1,91 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeviceClass(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{
+			name: "default",
+			want: deviceClassDesktop,
+		},
+		{
+			name: "desktop",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+					"Chrome/120.0.0.0 Safari/537.36"},
+			},
+			want: deviceClassDesktop,
+		},
+		{
+			name: "mobile",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 " +
+					"(KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"},
+			},
+			want: deviceClassMobile,
+		},
+		{
+			name: "mobile android",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) " +
+					"Chrome/120.0.0.0 Mobile Safari/537.36"},
+			},
+			want: deviceClassMobile,
+		},
+		{
+			name: "mobile client hints",
+			header: http.Header{
+				"User-Agent":       []string{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36"},
+				"Sec-Ch-Ua-Mobile": []string{"?1"},
+			},
+			want: deviceClassMobile,
+		},
+		{
+			name: "tablet",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 " +
+					"(KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"},
+			},
+			want: deviceClassTablet,
+		},
+		{
+			name: "tablet android",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 " +
+					"(KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+			},
+			want: deviceClassTablet,
+		},
+		{
+			name: "bot",
+			header: http.Header{
+				"User-Agent": []string{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+			},
+			want: deviceClassBot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header = tt.header
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			if got := deviceClass(r); got != tt.want {
+				t.Errorf("deviceClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,2 +1,10 @@
 // Package js implements the js handler.
+//
+// The handler is excluded from the binaries built with the nojs build tag or without cgo, which do not require the
+// JavaScript engine library.
+//
+// The promises, timers and microtasks of the bundles are polyfilled and their jobs are run by the VM before the render
+// is finalized. The engine bindings do not run the jobs of the native promises, so that the bundles using native async
+// functions or await expressions are rejected when they are read and must be transpiled to promise chains or
+// generators.
 package js
@@ -1,7 +1,11 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
 	"errors"
+	"strings"
+	"unicode"
 )
 
 // domElement implement a DOM element.
@@ -42,8 +46,28 @@ func (e *domElement) GetAttribute(name string) string {
 }
 
 // SetAttribute sets the given attribute value.
-func (e *domElement) SetAttribute(key string, value string) {
+func (e *domElement) SetAttribute(key string, value string) error {
+	if !validAttributeName(key) {
+		return errors.New("invalid attribute name")
+	}
 	e.m[key] = value
+	return nil
+}
+
+// validAttributeName returns true if the given name is a valid HTML attribute name.
+//
+// The attribute names are rendered as is, contrary to the attribute values which are escaped.
+func validAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) || unicode.IsSpace(r) || strings.ContainsRune("\"'<>/=", r) ||
+			r == unicode.ReplacementChar {
+			return false
+		}
+	}
+	return true
 }
 
 // domElementList implements a list of DOM elements.
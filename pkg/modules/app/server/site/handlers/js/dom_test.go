@@ -1,3 +1,5 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
@@ -173,18 +175,68 @@ func TestDOMElementSetAttribute(t *testing.T) {
 		value string
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
 	}{
 		{
 			name: "default",
 			fields: fields{
 				id: "test",
-				m: map[string]string{
-					"key": "value",
-				},
+				m:  map[string]string{},
+			},
+			args: args{
+				key:   "key",
+				value: "value",
+			},
+		},
+		{
+			name: "unsafe value",
+			fields: fields{
+				id: "test",
+				m:  map[string]string{},
+			},
+			args: args{
+				key:   "content",
+				value: `"><script>alert(1)</script>`,
+			},
+		},
+		{
+			name: "error empty name",
+			fields: fields{
+				id: "test",
+				m:  map[string]string{},
 			},
+			args: args{
+				key:   "",
+				value: "value",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error invalid name",
+			fields: fields{
+				id: "test",
+				m:  map[string]string{},
+			},
+			args: args{
+				key:   `content="x"><script>alert(1)</script><meta name`,
+				value: "value",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error control character",
+			fields: fields{
+				id: "test",
+				m:  map[string]string{},
+			},
+			args: args{
+				key:   "key\x00",
+				value: "value",
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -193,7 +245,9 @@ func TestDOMElementSetAttribute(t *testing.T) {
 				id: tt.fields.id,
 				m:  tt.fields.m,
 			}
-			e.SetAttribute(tt.args.key, tt.args.value)
+			if err := e.SetAttribute(tt.args.key, tt.args.value); (err != nil) != tt.wantErr {
+				t.Errorf("domElement.SetAttribute() error = %v, wantErr %v", err, tt.wantErr)
+			}
 		})
 	}
 }
@@ -0,0 +1,173 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+// JSEntry implements a bundle entry executed instead of the bundle for the matching paths.
+type JSEntry struct {
+	Path   string `mapstructure:"path"`
+	Bundle string `mapstructure:"bundle"`
+}
+
+// jsBundleEntry implements the loaded content of a bundle entry.
+type jsBundleEntry struct {
+	name    string
+	bundle  []byte
+	info    *time.Time
+	stencil *vmStencil
+	mu      *sync.RWMutex
+}
+
+// newJSBundleEntry creates a new bundle entry of the given file.
+func newJSBundleEntry(name string) *jsBundleEntry {
+	return &jsBundleEntry{
+		name: name,
+		mu:   new(sync.RWMutex),
+	}
+}
+
+// entry returns the first bundle entry matching the request path, or nil if the bundle must be executed.
+func (h *jsHandler) entry(r *http.Request) *jsBundleEntry {
+	if index := h.entryRuleSet.Next(normalize.Path(r.URL.Path), 0); index >= 0 {
+		return h.entries[index]
+	}
+
+	return nil
+}
+
+// readEntries reads the files of all the bundle entries.
+func (h *jsHandler) readEntries() error {
+	for _, e := range h.entries {
+		if err := h.readEntry(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readEntry reads the file of the given bundle entry.
+func (h *jsHandler) readEntry(e *jsBundleEntry) error {
+	e.mu.RLock()
+	current := e.info
+	e.mu.RUnlock()
+
+	info, err := h.osStat(e.name)
+	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to stat bundle entry file, keeping previous content", "file", e.name, "err", err)
+			return nil
+		}
+		h.logger.Error("Failed to stat bundle entry file", "file", e.name, "err", err)
+		return fmt.Errorf("stat file %s: %v", e.name, err)
+	}
+	if current != nil && info.ModTime().Equal(*current) {
+		return nil
+	}
+
+	buf, err := h.osReadFile(e.name)
+	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to read bundle entry file, keeping previous content", "file", e.name, "err", err)
+			return nil
+		}
+		h.logger.Error("Failed to read bundle entry file", "file", e.name, "err", err)
+		return fmt.Errorf("read file %s: %v", e.name, err)
+	}
+	if err := vmCheckAsync(buf); err != nil {
+		if current != nil {
+			h.logger.Warn("Unsupported bundle entry file, keeping previous content", "file", e.name, "err", err)
+			return nil
+		}
+		h.logger.Error("Unsupported bundle entry file", "file", e.name, "err", err)
+		return fmt.Errorf("check file %s: %v", e.name, err)
+	}
+
+	e.mu.Lock()
+	e.bundle = buf
+	i := info.ModTime()
+	e.info = &i
+	e.stencil.release()
+	e.stencil = nil
+	e.mu.Unlock()
+
+	if *h.config.VMStencil {
+		go h.compileEntryStencil(e, buf, i)
+	}
+
+	return nil
+}
+
+// compileEntryStencil compiles in background the given bundle entry to a stencil shared by the next VMs.
+func (h *jsHandler) compileEntryStencil(e *jsBundleEntry, bundle []byte, modTime time.Time) {
+	start := time.Now()
+	stencil, err := vmCompileStencil(e.name, bundle)
+	if err != nil {
+		h.logger.Error("Failed to compile bundle entry stencil", "file", e.name, "err", err)
+		return
+	}
+
+	e.mu.Lock()
+	if e.info == nil || !e.info.Equal(modTime) {
+		e.mu.Unlock()
+		stencil.release()
+		return
+	}
+	e.stencil = stencil
+	e.mu.Unlock()
+
+	h.logger.Debug("Bundle entry stencil compiled", "file", e.name, "duration", time.Since(start).Milliseconds())
+}
+
+// healEntry drops the given stencil of a bundle entry after a crash of the engine if it is still the current one.
+func (h *jsHandler) healEntry(stencil *vmStencil) {
+	for _, e := range h.entries {
+		e.mu.Lock()
+		if e.stencil != stencil {
+			e.mu.Unlock()
+			continue
+		}
+		e.stencil.release()
+		e.stencil = nil
+		bundle := e.bundle
+		info := e.info
+		e.mu.Unlock()
+
+		if *h.config.VMStencil && info != nil {
+			go h.compileEntryStencil(e, bundle, *info)
+		}
+
+		return
+	}
+}
+
+// releaseEntries releases the loaded content of all the bundle entries.
+func (h *jsHandler) releaseEntries() {
+	for _, e := range h.entries {
+		e.mu.Lock()
+		e.info = nil
+		e.stencil.release()
+		e.stencil = nil
+		e.mu.Unlock()
+	}
+}
+
+// renderEntry makes a new render with the given bundle entry and returns it with the names of the used resources.
+func (h *jsHandler) renderEntry(r *http.Request, e *jsBundleEntry) (render.Render, []string, error) {
+	e.mu.RLock()
+	bundle := e.bundle
+	stencil := e.stencil.acquire()
+	e.mu.RUnlock()
+	defer stencil.release()
+
+	return h.renderBundle(r, e.name, bundle, stencil, h.slots())
+}
@@ -0,0 +1,155 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+func TestJSHandlerEntry(t *testing.T) {
+	h := &jsHandler{
+		entries: []*jsBundleEntry{newJSBundleEntry("home.js"), newJSBundleEntry("product.js")},
+		entryRuleSet: pattern.NewSet([]*regexp.Regexp{
+			regexp.MustCompile("^/$"),
+			regexp.MustCompile("^/product/"),
+		}),
+	}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "home",
+			path: "/",
+			want: "home.js",
+		},
+		{
+			name: "product",
+			path: "/product/1",
+			want: "product.js",
+		},
+		{
+			name: "bundle",
+			path: "/test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if e := h.entry(httptest.NewRequest(http.MethodGet, tt.path, nil)); e != nil {
+				got = e.name
+			}
+			if got != tt.want {
+				t.Errorf("jsHandler.entry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSHandlerReadEntry(t *testing.T) {
+	h := &jsHandler{
+		config: &jsHandlerConfig{
+			VMStencil: boolPtr(false),
+		},
+		logger: slog.Default(),
+		osReadFile: func(name string) ([]byte, error) {
+			return os.ReadFile(name)
+		},
+		osStat: func(name string) (fs.FileInfo, error) {
+			return os.Stat(name)
+		},
+	}
+
+	e := newJSBundleEntry("test/entry/entry-home.js")
+	if err := h.readEntry(e); err != nil {
+		t.Fatalf("jsHandler.readEntry() error = %v", err)
+	}
+	if e.info == nil || !strings.Contains(string(e.bundle), "entry") {
+		t.Errorf("jsHandler.readEntry() bundle = %s", e.bundle)
+	}
+
+	e.name = "test/entry/missing.js"
+	if err := h.readEntry(e); err != nil {
+		t.Errorf("jsHandler.readEntry() error = %v, want previous content kept", err)
+	}
+
+	if err := h.readEntry(newJSBundleEntry("test/entry/missing.js")); err == nil {
+		t.Errorf("jsHandler.readEntry() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestJSHandlerRenderEntry(t *testing.T) {
+	h := &jsHandler{
+		config: &jsHandlerConfig{
+			Index:         "test/entry/index.html",
+			IndexTemplate: boolPtr(false),
+			Bundle:        "test/entry/bundle.js",
+			Env:           stringPtr("test"),
+			Container:     stringPtr("root"),
+			State:         stringPtr("state"),
+			VMMaxHeapSize: intPtr(0),
+			VMStackSize:   intPtr(0),
+			VMTimeout:     intPtr(1000),
+			VMGracePeriod: intPtr(0),
+			VMCPUBudget:   intPtr(0),
+			VMStencil:     boolPtr(false),
+		},
+		logger:       slog.Default(),
+		muIndex:      &sync.RWMutex{},
+		muBundle:     &sync.RWMutex{},
+		entries:      []*jsBundleEntry{newJSBundleEntry("test/entry/entry-home.js")},
+		entryRuleSet: pattern.NewSet([]*regexp.Regexp{regexp.MustCompile("^/$")}),
+		vms:          make(chan struct{}, 1),
+		rwPool:       render.NewRenderWriterPool(),
+		site:         testJSHandlerServerSite{},
+		osReadFile: func(name string) ([]byte, error) {
+			return os.ReadFile(name)
+		},
+		osStat: func(name string) (fs.FileInfo, error) {
+			return os.Stat(name)
+		},
+	}
+	if err := h.read(); err != nil {
+		t.Fatalf("jsHandler.read() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "entry",
+			path: "/",
+			want: "<p>entry</p>",
+		},
+		{
+			name: "bundle",
+			path: "/test",
+			want: "<p>test</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := h.render(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatalf("jsHandler.render() error = %v", err)
+			}
+			if !strings.Contains(string(got.Body()), tt.want) {
+				t.Errorf("jsHandler.render() body = %s, want %s", got.Body(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// jsonHTMLReplacer escapes the characters of a JSON document which are unsafe inside a HTML script element.
+	jsonHTMLReplacer = strings.NewReplacer(
+		"<", `\u003c`,
+		">", `\u003e`,
+		"&", `\u0026`,
+		"\u2028", `\u2028`,
+		"\u2029", `\u2029`,
+	)
+	// scriptHTMLRegexp matches the sequences which end or alter a HTML script element.
+	scriptHTMLRegexp = regexp.MustCompile(`(?i)<(/script|!--)`)
+)
+
+// escapeJSONForHTML escapes a JSON document to be safely embedded into a HTML script element.
+//
+// The escaped characters can only occur inside the JSON strings, so the document decodes to the same value.
+func escapeJSONForHTML(b []byte) string {
+	return jsonHTMLReplacer.Replace(string(b))
+}
+
+// escapeScriptForHTML escapes a script to be safely embedded into a HTML script element.
+func escapeScriptForHTML(s string) string {
+	return scriptHTMLRegexp.ReplaceAllString(s, `<\$1`)
+}
@@ -0,0 +1,98 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEscapeJSONForHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "default",
+			data: []byte(`{"key":"value"}`),
+			want: `{"key":"value"}`,
+		},
+		{
+			name: "script end tag",
+			data: []byte(`{"key":"</script><script>alert(1)</script>"}`),
+			want: `{"key":"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"}`,
+		},
+		{
+			name: "html comment",
+			data: []byte(`{"key":"<!--"}`),
+			want: `{"key":"\u003c!--"}`,
+		},
+		{
+			name: "ampersand",
+			data: []byte(`{"key":"a&b"}`),
+			want: `{"key":"a\u0026b"}`,
+		},
+		{
+			name: "line separators",
+			data: []byte("{\"key\":\"a\u2028b\u2029c\"}"),
+			want: `{"key":"a\u2028b\u2029c"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeJSONForHTML(tt.data)
+			if got != tt.want {
+				t.Errorf("escapeJSONForHTML() = %v, want %v", got, tt.want)
+			}
+
+			var v, want any
+			if err := json.Unmarshal([]byte(got), &v); err != nil {
+				t.Errorf("escapeJSONForHTML() invalid JSON: %v", err)
+			}
+			if err := json.Unmarshal(tt.data, &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, want) {
+				t.Errorf("escapeJSONForHTML() decoded = %v, want %v", v, want)
+			}
+		})
+	}
+}
+
+func TestEscapeScriptForHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name:   "default",
+			script: `console.log("test");`,
+			want:   `console.log("test");`,
+		},
+		{
+			name:   "script end tag",
+			script: `console.log("</script><script>alert(1)</script>");`,
+			want:   `console.log("<\/script><script>alert(1)<\/script>");`,
+		},
+		{
+			name:   "script end tag case",
+			script: `console.log("</SCRIPT>");`,
+			want:   `console.log("<\/SCRIPT>");`,
+		},
+		{
+			name:   "html comment",
+			script: `console.log("<!--");`,
+			want:   `console.log("<\!--");`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeScriptForHTML(tt.script); got != tt.want {
+				t.Errorf("escapeScriptForHTML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
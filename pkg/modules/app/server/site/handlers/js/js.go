@@ -1,11 +1,15 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"html/template"
 	"io/fs"
 	"log/slog"
 	"net/http"
@@ -14,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bhuisgen/gomonkey"
@@ -21,56 +26,107 @@ import (
 	"golang.org/x/net/html"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/fault"
 	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/match"
+	"github.com/bhuisgen/neon/pkg/metrics"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
 	"github.com/bhuisgen/neon/pkg/render"
+	"github.com/bhuisgen/neon/pkg/timing"
+	"github.com/bhuisgen/neon/pkg/trace"
 )
 
 // jsHandler implements the js handler.
 type jsHandler struct {
-	config      *jsHandlerConfig
-	logger      *slog.Logger
-	regexps     []*regexp.Regexp
-	index       []byte
-	indexInfo   *time.Time
-	muIndex     *sync.RWMutex
-	bundle      []byte
-	bundleInfo  *time.Time
-	muBundle    *sync.RWMutex
-	vms         chan struct{}
-	rwPool      render.RenderWriterPool
-	cache       Cache
-	site        core.ServerSite
-	osOpen      func(name string) (*os.File, error)
-	osOpenFile  func(name string, flag int, perm fs.FileMode) (*os.File, error)
-	osReadFile  func(name string) ([]byte, error)
-	osClose     func(*os.File) error
-	osStat      func(name string) (fs.FileInfo, error)
-	jsonMarshal func(v any) ([]byte, error)
+	config        *jsHandlerConfig
+	logger        *slog.Logger
+	regexps       []*regexp.Regexp
+	ruleSet       *pattern.Set
+	constraints   []*match.Constraint
+	cacheHost     bool
+	cacheRuleSet  *pattern.Set
+	budgetRuleSet *pattern.Set
+	index         *jsShell
+	indexTmpl     *template.Template
+	indexInfo     *time.Time
+	muIndex       *sync.RWMutex
+	bundle        []byte
+	bundleInfo    *time.Time
+	stencil       *vmStencil
+	muBundle      *sync.RWMutex
+	entries       []*jsBundleEntry
+	entryRuleSet  *pattern.Set
+	vms           chan struct{}
+	vmPool        *vmPoolMember
+	vmCrashes     *atomic.Uint64
+	canaryVMs     chan struct{}
+	rwPool        render.RenderWriterPool
+	cache         Cache
+	lastGood      Cache
+	fallbacks     *atomic.Uint64
+	site          core.ServerSite
+	osOpen        func(name string) (*os.File, error)
+	osOpenFile    func(name string, flag int, perm fs.FileMode) (*os.File, error)
+	osReadFile    func(name string) ([]byte, error)
+	osClose       func(*os.File) error
+	osStat        func(name string) (fs.FileInfo, error)
+	jsonMarshal   func(v any) ([]byte, error)
 }
 
 // jsHandlerConfig implements the js handler configuration.
 type jsHandlerConfig struct {
-	Index         string   `mapstructure:"index"`
-	Bundle        string   `mapstructure:"bundle"`
-	Env           *string  `mapstructure:"env"`
-	Container     *string  `mapstructure:"container"`
-	State         *string  `mapstructure:"state"`
-	MaxVMs        *int     `mapstructure:"maxVMs"`
-	VMMaxHeapSize *int     `mapstructure:"vmMaxHeapSize"`
-	VMStackSize   *int     `mapstructure:"vmStackSize"`
-	VMTimeout     *int     `mapstructure:"vmTimeout"`
-	Cache         *bool    `mapstructure:"cache"`
-	CacheTTL      *int     `mapstructure:"cacheTTL"`
-	CacheMaxItems *int     `mapstructure:"cacheMaxItems"`
-	Rules         []JSRule `mapstructure:"rules"`
+	Index            string        `mapstructure:"index"`
+	IndexTemplate    *bool         `mapstructure:"indexTemplate"`
+	Bundle           string        `mapstructure:"bundle"`
+	BundleMap        []JSEntry     `mapstructure:"bundleMap"`
+	Env              *string       `mapstructure:"env"`
+	Container        *string       `mapstructure:"container"`
+	State            *string       `mapstructure:"state"`
+	MaxVMs           *int          `mapstructure:"maxVMs"`
+	VMPool           *string       `mapstructure:"vmPool"`
+	VMPoolSize       *int          `mapstructure:"vmPoolSize"`
+	VMMaxHeapSize    *int          `mapstructure:"vmMaxHeapSize"`
+	VMStackSize      *int          `mapstructure:"vmStackSize"`
+	VMTimeout        *int          `mapstructure:"vmTimeout"`
+	VMGracePeriod    *int          `mapstructure:"vmGracePeriod"`
+	VMCPUBudget      *int          `mapstructure:"vmCPUBudget"`
+	VMStencil        *bool         `mapstructure:"vmStencil"`
+	VMHeaders        []string      `mapstructure:"vmHeaders"`
+	Cache            *bool         `mapstructure:"cache"`
+	CacheTTL         *int          `mapstructure:"cacheTTL"`
+	CacheNotFoundTTL *int          `mapstructure:"cacheNotFoundTTL"`
+	CacheMaxItems    *int          `mapstructure:"cacheMaxItems"`
+	CachePolicy      *string       `mapstructure:"cachePolicy"`
+	CacheVaryDevice  *bool         `mapstructure:"cacheVaryDevice"`
+	CacheQuery       *bool         `mapstructure:"cacheQuery"`
+	CacheCompress    *bool         `mapstructure:"cacheCompress"`
+	CacheHeaders     *bool         `mapstructure:"cacheHeaders"`
+	CachePrivate     *bool         `mapstructure:"cachePrivate"`
+	CacheLastGood    *bool         `mapstructure:"cacheLastGood"`
+	CacheRules       []JSCacheRule `mapstructure:"cacheRules"`
+	Rules            []JSRule      `mapstructure:"rules"`
+	Canary           *JSCanary     `mapstructure:"canary"`
+	Budgets          []JSBudget    `mapstructure:"budgets"`
+	StateJSON        *bool         `mapstructure:"stateJSON"`
+	StateJSONParam   *string       `mapstructure:"stateJSONParam"`
 }
 
 // JSRule implements a rule.
 type JSRule struct {
-	Path  string             `mapstructure:"path"`
-	State []JSRuleStateEntry `mapstructure:"state"`
-	Last  bool               `mapstructure:"last"`
+	Path    string             `mapstructure:"path"`
+	Host    string             `mapstructure:"host"`
+	Methods []string           `mapstructure:"methods"`
+	State   []JSRuleStateEntry `mapstructure:"state"`
+	Last    bool               `mapstructure:"last"`
+}
+
+// JSCacheRule implements a cache rule.
+type JSCacheRule struct {
+	Path string `mapstructure:"path"`
+	TTL  *int   `mapstructure:"ttl"`
 }
 
 // JSRuleStateEntry implements a rule state entry.
@@ -82,8 +138,25 @@ type JSRuleStateEntry struct {
 
 // jsCacheItem implements a cached item.
 type jsCacheItem struct {
-	render render.Render
-	expire time.Time
+	render    render.Render
+	variants  map[string][]byte
+	resources []string
+	stored    time.Time
+	expire    time.Time
+}
+
+// jsState implements the state resolved for a request.
+type jsState struct {
+	server    map[string]jsResource
+	client    map[string]jsResource
+	resources []string
+	valid     bool
+}
+
+// jsIndexTemplateData implements the index template data.
+type jsIndexTemplateData struct {
+	Env     string
+	Request *http.Request
 }
 
 // jsResource implements a resource.
@@ -97,16 +170,37 @@ const (
 
 	jsResourceUnknown string = "unknown resource"
 
-	jsConfigDefaultEnv            string = "production"
-	jsConfigDefaultContainer      string = "root"
-	jsConfigDefaultState          string = "state"
-	jsConfigDefaultMaxVMs         int    = 4
-	jsConfigDefaultVMTimeout      int    = 1000
-	jsConfigDefaultVMHeapMaxBytes int    = 0
-	jsConfigDefaultVMStackSize    int    = 0
-	jsConfigDefaultCache          bool   = false
-	jsConfigDefaultCacheTTL       int    = 60
-	jsConfigDefaultCacheMaxItems  int    = 100
+	jsMetricVMCrashes string = "js.vmCrashes"
+
+	jsTimingVM    string = "vm"
+	jsTimingVMCPU string = "vmCpu"
+
+	jsConfigDefaultIndexTemplate    bool   = false
+	jsConfigDefaultEnv              string = "production"
+	jsConfigDefaultContainer        string = "root"
+	jsConfigDefaultState            string = "state"
+	jsConfigDefaultMaxVMs           int    = 4
+	jsConfigDefaultVMPoolSize       int    = 8
+	jsConfigDefaultVMTimeout        int    = 1000
+	jsConfigDefaultVMGracePeriod    int    = 0
+	jsConfigDefaultVMCPUBudget      int    = 0
+	jsConfigDefaultVMStencil        bool   = false
+	jsConfigDefaultVMHeapMaxBytes   int    = 0
+	jsConfigDefaultVMStackSize      int    = 0
+	jsConfigDefaultCache            bool   = false
+	jsConfigDefaultCacheTTL         int    = 60
+	jsConfigDefaultCacheNotFoundTTL int    = 5
+	jsConfigDefaultCacheMaxItems    int    = 100
+	jsConfigDefaultCachePolicy      string = cachePolicyLRU
+	jsConfigDefaultCacheVaryDevice  bool   = false
+	jsConfigDefaultCacheQuery       bool   = false
+	jsConfigDefaultCacheCompress    bool   = false
+	jsConfigDefaultCacheHeaders     bool   = false
+	jsConfigDefaultCachePrivate     bool   = false
+	jsConfigDefaultCacheLastGood    bool   = false
+	jsConfigDefaultCanaryPath       string = "/__neon/canary"
+	jsConfigDefaultCanaryMaxVMs     int    = 1
+	jsConfigDefaultStateJSON        bool   = false
 )
 
 // jsOsOpen redirects to os.Open.
@@ -159,6 +253,8 @@ func (h jsHandler) ModuleInfo() module.ModuleInfo {
 				logger:      slog.New(log.NewHandler(os.Stderr, string(jsModuleID), nil)),
 				muIndex:     new(sync.RWMutex),
 				muBundle:    new(sync.RWMutex),
+				vmCrashes:   metrics.Counter(jsMetricVMCrashes),
+				fallbacks:   new(atomic.Uint64),
 				osOpen:      jsOsOpen,
 				osOpenFile:  jsOsOpenFile,
 				osReadFile:  jsOsReadFile,
@@ -221,6 +317,38 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 			}
 		}
 	}
+	var entryRegexps []*regexp.Regexp
+	var entries []*jsBundleEntry
+	for index, entry := range h.config.BundleMap {
+		if entry.Path == "" {
+			h.logger.Error("Missing option or value", "bundleMap", index+1, "option", "Path")
+			errConfig = true
+		} else {
+			re, err := pattern.Compile(entry.Path)
+			if err != nil {
+				h.logger.Error("Invalid regular expression", "bundleMap", index+1, "option", "Path", "value", entry.Path,
+					"err", err)
+				errConfig = true
+			} else {
+				entryRegexps = append(entryRegexps, re)
+			}
+		}
+		if entry.Bundle == "" {
+			h.logger.Error("Missing option or value", "bundleMap", index+1, "option", "Bundle")
+			errConfig = true
+		} else if fi, err := h.osStat(entry.Bundle); err != nil || fi.IsDir() {
+			h.logger.Error("Invalid value", "bundleMap", index+1, "option", "Bundle", "value", entry.Bundle)
+			errConfig = true
+		}
+		entries = append(entries, newJSBundleEntry(entry.Bundle))
+	}
+	if h.config.IndexTemplate == nil {
+		defaultValue := jsConfigDefaultIndexTemplate
+		h.config.IndexTemplate = &defaultValue
+	}
+	if *h.config.IndexTemplate {
+		h.cacheHost = true
+	}
 	if h.config.Env == nil {
 		defaultValue := jsConfigDefaultEnv
 		h.config.Env = &defaultValue
@@ -253,6 +381,18 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "MaxVMs", "value", *h.config.MaxVMs)
 		errConfig = true
 	}
+	if h.config.VMPool != nil && *h.config.VMPool == "" {
+		h.logger.Error("Invalid value", "option", "VMPool", "value", *h.config.VMPool)
+		errConfig = true
+	}
+	if h.config.VMPoolSize == nil {
+		defaultValue := jsConfigDefaultVMPoolSize
+		h.config.VMPoolSize = &defaultValue
+	}
+	if *h.config.VMPoolSize <= 0 {
+		h.logger.Error("Invalid value", "option", "VMPoolSize", "value", *h.config.VMPoolSize)
+		errConfig = true
+	}
 	if h.config.VMMaxHeapSize == nil {
 		defaultValue := jsConfigDefaultVMHeapMaxBytes
 		h.config.VMMaxHeapSize = &defaultValue
@@ -277,6 +417,32 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "VMTimeout", "value", *h.config.VMTimeout)
 		errConfig = true
 	}
+	if h.config.VMGracePeriod == nil {
+		defaultValue := jsConfigDefaultVMGracePeriod
+		h.config.VMGracePeriod = &defaultValue
+	}
+	if *h.config.VMGracePeriod < 0 {
+		h.logger.Error("Invalid value", "option", "VMGracePeriod", "value", *h.config.VMGracePeriod)
+		errConfig = true
+	}
+	if h.config.VMCPUBudget == nil {
+		defaultValue := jsConfigDefaultVMCPUBudget
+		h.config.VMCPUBudget = &defaultValue
+	}
+	if *h.config.VMCPUBudget < 0 {
+		h.logger.Error("Invalid value", "option", "VMCPUBudget", "value", *h.config.VMCPUBudget)
+		errConfig = true
+	}
+	if h.config.VMStencil == nil {
+		defaultValue := jsConfigDefaultVMStencil
+		h.config.VMStencil = &defaultValue
+	}
+	for _, header := range h.config.VMHeaders {
+		if strings.TrimSuffix(header, "*") == "" {
+			h.logger.Error("Invalid value", "option", "VMHeaders", "value", header)
+			errConfig = true
+		}
+	}
 	if h.config.Cache == nil {
 		defaultValue := jsConfigDefaultCache
 		h.config.Cache = &defaultValue
@@ -289,6 +455,14 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "CacheTTL", "value", *h.config.CacheTTL)
 		errConfig = true
 	}
+	if h.config.CacheNotFoundTTL == nil {
+		defaultValue := jsConfigDefaultCacheNotFoundTTL
+		h.config.CacheNotFoundTTL = &defaultValue
+	}
+	if *h.config.CacheNotFoundTTL < 0 {
+		h.logger.Error("Invalid value", "option", "CacheNotFoundTTL", "value", *h.config.CacheNotFoundTTL)
+		errConfig = true
+	}
 	if h.config.CacheMaxItems == nil {
 		defaultValue := jsConfigDefaultCacheMaxItems
 		h.config.CacheMaxItems = &defaultValue
@@ -297,14 +471,90 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "CacheMaxCapacity", "value", *h.config.CacheMaxItems)
 		errConfig = true
 	}
+	if h.config.CachePolicy == nil {
+		defaultValue := jsConfigDefaultCachePolicy
+		h.config.CachePolicy = &defaultValue
+	}
+	if *h.config.CachePolicy != cachePolicyLRU && *h.config.CachePolicy != cachePolicyTinyLFU {
+		h.logger.Error("Invalid value", "option", "CachePolicy", "value", *h.config.CachePolicy)
+		errConfig = true
+	}
+	if h.config.CacheVaryDevice == nil {
+		defaultValue := jsConfigDefaultCacheVaryDevice
+		h.config.CacheVaryDevice = &defaultValue
+	}
+	if h.config.CacheQuery == nil {
+		defaultValue := jsConfigDefaultCacheQuery
+		h.config.CacheQuery = &defaultValue
+	}
+	if h.config.CacheCompress == nil {
+		defaultValue := jsConfigDefaultCacheCompress
+		h.config.CacheCompress = &defaultValue
+	}
+	if h.config.CacheHeaders == nil {
+		defaultValue := jsConfigDefaultCacheHeaders
+		h.config.CacheHeaders = &defaultValue
+	}
+	if h.config.CachePrivate == nil {
+		defaultValue := jsConfigDefaultCachePrivate
+		h.config.CachePrivate = &defaultValue
+	}
+	if h.config.CacheLastGood == nil {
+		defaultValue := jsConfigDefaultCacheLastGood
+		h.config.CacheLastGood = &defaultValue
+	}
+	if !*h.config.Cache && *h.config.CacheLastGood {
+		h.logger.Warn("Last known good renders are not retained without the cache", "option", "CacheLastGood")
+	}
+	if *h.config.Cache && !*h.config.CachePrivate {
+		for _, header := range []string{jsHeaderAuthorization, jsHeaderCookie} {
+			if h.vmHeaderAllowed(header) {
+				h.logger.Warn("Per-user header exposed to the VM with the cache enabled, the renders of the requests "+
+					"with this header are never cached without the private cache", "option", "VMHeaders", "value", header)
+			}
+		}
+	}
+	var cacheRegexps []*regexp.Regexp
+	for index, rule := range h.config.CacheRules {
+		if rule.Path == "" {
+			h.logger.Error("Missing option or value", "cacheRule", index+1, "option", "Path")
+			errConfig = true
+		} else {
+			re, err := pattern.Compile(rule.Path)
+			if err != nil {
+				h.logger.Error("Invalid regular expression", "cacheRule", index+1, "option", "Path", "value", rule.Path,
+					"err", err)
+				errConfig = true
+			} else {
+				cacheRegexps = append(cacheRegexps, re)
+			}
+		}
+		if rule.TTL == nil {
+			h.logger.Error("Missing option or value", "cacheRule", index+1, "option", "TTL")
+			errConfig = true
+		} else if *rule.TTL < 0 {
+			h.logger.Error("Invalid value", "cacheRule", index+1, "option", "TTL", "value", *rule.TTL)
+			errConfig = true
+		}
+	}
 	for index, rule := range h.config.Rules {
+		constraint, err := match.NewConstraint(rule.Host, rule.Methods)
+		if err != nil {
+			h.logger.Error("Invalid constraint", "rule", index+1, "err", err)
+			errConfig = true
+		}
+		h.constraints = append(h.constraints, constraint)
+		if constraint.HasHost() {
+			h.cacheHost = true
+		}
 		if rule.Path == "" {
 			h.logger.Error("Missing option or value", "rule", index+1, "option", "Path")
 			errConfig = true
 		} else {
-			re, err := regexp.Compile(rule.Path)
+			re, err := pattern.Compile(rule.Path)
 			if err != nil {
-				h.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path)
+				h.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path,
+					"err", err)
 				errConfig = true
 			} else {
 				h.regexps = append(h.regexps, re)
@@ -321,14 +571,107 @@ func (h *jsHandler) Init(config map[string]interface{}) error {
 			}
 		}
 	}
+	if h.config.StateJSON == nil {
+		defaultValue := jsConfigDefaultStateJSON
+		h.config.StateJSON = &defaultValue
+	}
+	if h.config.StateJSONParam != nil && *h.config.StateJSONParam == "" {
+		h.logger.Error("Invalid value", "option", "StateJSONParam", "value", *h.config.StateJSONParam)
+		errConfig = true
+	}
+	var budgetRegexps []*regexp.Regexp
+	for index, budget := range h.config.Budgets {
+		if budget.Path == "" {
+			h.logger.Error("Missing option or value", "budget", index+1, "option", "Path")
+			errConfig = true
+		} else {
+			re, err := pattern.Compile(budget.Path)
+			if err != nil {
+				h.logger.Error("Invalid regular expression", "budget", index+1, "option", "Path", "value", budget.Path,
+					"err", err)
+				errConfig = true
+			} else {
+				budgetRegexps = append(budgetRegexps, re)
+			}
+		}
+		if budget.HTMLBytes != nil && *budget.HTMLBytes <= 0 {
+			h.logger.Error("Invalid value", "budget", index+1, "option", "HTMLBytes", "value", *budget.HTMLBytes)
+			errConfig = true
+		}
+		if budget.StateBytes != nil && *budget.StateBytes <= 0 {
+			h.logger.Error("Invalid value", "budget", index+1, "option", "StateBytes", "value", *budget.StateBytes)
+			errConfig = true
+		}
+		if budget.RenderTime != nil && *budget.RenderTime <= 0 {
+			h.logger.Error("Invalid value", "budget", index+1, "option", "RenderTime", "value", *budget.RenderTime)
+			errConfig = true
+		}
+		if budget.Mode == nil {
+			defaultValue := jsBudgetModeWarn
+			h.config.Budgets[index].Mode = &defaultValue
+		} else if *budget.Mode != jsBudgetModeWarn && *budget.Mode != jsBudgetModeEnforce {
+			h.logger.Error("Invalid value", "budget", index+1, "option", "Mode", "value", *budget.Mode)
+			errConfig = true
+		}
+	}
+	if h.config.Canary != nil {
+		if h.config.Canary.Bundle == "" {
+			h.logger.Error("Missing option or value", "option", "Canary.Bundle")
+			errConfig = true
+		} else if fi, err := h.osStat(h.config.Canary.Bundle); err != nil || fi.IsDir() {
+			h.logger.Error("Invalid value", "option", "Canary.Bundle", "value", h.config.Canary.Bundle)
+			errConfig = true
+		}
+		if h.config.Canary.Path == nil {
+			defaultValue := jsConfigDefaultCanaryPath
+			h.config.Canary.Path = &defaultValue
+		}
+		if !strings.HasPrefix(*h.config.Canary.Path, "/") {
+			h.logger.Error("Invalid value", "option", "Canary.Path", "value", *h.config.Canary.Path)
+			errConfig = true
+		}
+		if h.config.Canary.Token == "" {
+			h.logger.Error("Missing option or value", "option", "Canary.Token")
+			errConfig = true
+		}
+		if h.config.Canary.MaxVMs == nil {
+			defaultValue := jsConfigDefaultCanaryMaxVMs
+			h.config.Canary.MaxVMs = &defaultValue
+		}
+		if *h.config.Canary.MaxVMs <= 0 {
+			h.logger.Error("Invalid value", "option", "Canary.MaxVMs", "value", *h.config.Canary.MaxVMs)
+			errConfig = true
+		}
+		if len(h.config.Canary.Routes) == 0 {
+			h.logger.Error("Missing option or value", "option", "Canary.Routes")
+			errConfig = true
+		}
+		for index, route := range h.config.Canary.Routes {
+			if !strings.HasPrefix(route, "/") {
+				h.logger.Error("Invalid value", "option", "Canary.Routes", "index", index+1, "value", route)
+				errConfig = true
+			}
+		}
+	}
 
 	if errConfig {
 		return errors.New("config")
 	}
 
+	h.ruleSet = pattern.NewSet(h.regexps)
+	h.cacheRuleSet = pattern.NewSet(cacheRegexps)
+	h.budgetRuleSet = pattern.NewSet(budgetRegexps)
+	h.entries = entries
+	h.entryRuleSet = pattern.NewSet(entryRegexps)
 	h.vms = make(chan struct{}, *h.config.MaxVMs)
+	if h.config.Canary != nil {
+		h.canaryVMs = make(chan struct{}, *h.config.Canary.MaxVMs)
+	}
 	h.rwPool = render.NewRenderWriterPool()
-	h.cache = newCache(*h.config.CacheMaxItems)
+	h.cache = newCache(*h.config.CacheMaxItems, *h.config.CachePolicy)
+	if *h.config.Cache && *h.config.CacheLastGood {
+		h.lastGood = newCache(*h.config.CacheMaxItems, *h.config.CachePolicy)
+	}
 
 	return nil
 }
@@ -341,14 +684,27 @@ func (h *jsHandler) Register(site core.ServerSite) error {
 		return fmt.Errorf("register handler: %v", err)
 	}
 
+	if loader := site.Loader(); loader != nil {
+		loader.Subscribe(h.purge)
+	}
+
 	return nil
 }
 
 // Start starts the handler.
+//
+// With a shared VM pool, the handler joins the pool and its maximum number of VMs becomes its share of the pool.
 func (h *jsHandler) Start() error {
 	if err := h.read(); err != nil {
 		return fmt.Errorf("read: %v", err)
 	}
+	if err := h.readEntries(); err != nil {
+		return fmt.Errorf("read entries: %v", err)
+	}
+
+	if h.config.VMPool != nil && h.vmPool == nil {
+		h.vmPool = vmPoolGet(*h.config.VMPool, *h.config.VMPoolSize).join(*h.config.MaxVMs)
+	}
 
 	return nil
 }
@@ -361,10 +717,53 @@ func (h *jsHandler) Stop() error {
 
 	h.muBundle.Lock()
 	h.bundleInfo = nil
+	h.stencil.release()
+	h.stencil = nil
 	h.muBundle.Unlock()
 
+	h.releaseEntries()
+
 	h.cache.Clear()
 
+	if h.vmPool != nil {
+		h.vmPool.leave()
+		h.vmPool = nil
+	}
+
+	return nil
+}
+
+// Degradable implements priority.Degradable.
+func (h *jsHandler) Degradable() bool {
+	return true
+}
+
+// Preflight reads the index, bundle and bundle entries files and compiles the bundles without executing them.
+func (h *jsHandler) Preflight(ctx context.Context) error {
+	if err := h.read(); err != nil {
+		return fmt.Errorf("read: %v", err)
+	}
+	if err := h.readEntries(); err != nil {
+		return fmt.Errorf("read entries: %v", err)
+	}
+
+	h.muBundle.RLock()
+	bundle := h.bundle
+	h.muBundle.RUnlock()
+
+	if err := vmCompile(h.config.Bundle, bundle); err != nil {
+		return fmt.Errorf("compile bundle %s: %v", h.config.Bundle, err)
+	}
+	for _, e := range h.entries {
+		e.mu.RLock()
+		bundle := e.bundle
+		e.mu.RUnlock()
+
+		if err := vmCompile(e.name, bundle); err != nil {
+			return fmt.Errorf("compile bundle entry %s: %v", e.name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -376,23 +775,53 @@ func (h *jsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key := r.URL.Path
+	if h.config.Canary != nil && r.URL.Path == *h.config.Canary.Path {
+		h.serveCanary(w, r)
+		return
+	}
+
+	if *h.config.StateJSON {
+		w.Header().Add("Vary", "Accept")
+		if h.isStateRequest(r) {
+			h.serveState(w, r)
+			return
+		}
+	}
+
+	key := normalize.CacheKey(r.URL, *h.config.CacheQuery)
+	if h.cacheHost {
+		// the rules or the index template depend on the host so the renders of each host are cached apart
+		key = match.Host(r) + key
+	}
+	if *h.config.Cache && *h.config.CacheVaryDevice {
+		key = deviceClass(r) + ":" + key
+	}
+	tr := trace.FromContext(r.Context())
+	degraded := priority.Degraded(r.Context())
+
+	// the renders of authorized requests depend on the user so they are cached only per user with the private cache
+	cached := *h.config.Cache
+	user, private := h.cacheUser(r)
+	if cached && private {
+		if !*h.config.CachePrivate {
+			cached = false
+			tr.Add(string(jsModuleID), "Cache bypass", "reason", "credentials")
+		} else {
+			key = "user:" + user + ":" + key
+		}
+	}
 
-	if *h.config.Cache {
-		if item, ok := h.cache.Get(key).(*jsCacheItem); ok && item.expire.After(time.Now()) {
+	if cached {
+		if err := fault.Inject(r.Context(), fault.Cache); err != nil {
+			tr.Add(string(jsModuleID), "Cache fault", "key", key, "err", err)
+		} else if item, ok := h.cache.Get(key).(*jsCacheItem); ok && (degraded || item.expire.After(time.Now())) {
 			render := item.render
 
-			for key, values := range render.Header() {
-				for _, value := range values {
-					w.Header().Add(key, value)
-				}
-			}
-			if render.Redirect() {
-				http.Redirect(w, r, render.RedirectURL(), render.StatusCode())
-				return
-			}
-			w.WriteHeader(render.StatusCode())
-			if _, err := w.Write(render.Body()); err != nil {
+			tr.Add(string(jsModuleID), "Cache hit", "key", key, "resources", item.resources)
+
+			h.setCacheHeader(w, item, key)
+
+			if err := h.writeRender(w, r, render, item.variants); err != nil {
 				h.logger.Error("Failed to write render", "err", err)
 				return
 			}
@@ -403,123 +832,443 @@ func (h *jsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if cached {
+		tr.Add(string(jsModuleID), "Cache miss", "key", key)
+	}
+
+	if degraded {
+		priority.Shed(w, r)
+
+		h.logger.Debug("Request shed", "url", r.URL.Path)
+
+		return
+	}
+
 	if err := h.read(); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		if cached && h.serveLastGood(w, r, key) {
+			return
+		}
+
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
 		return
 	}
 
-	render, err := h.render(r)
+	render, resources, err := h.render(r)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		if cached && h.serveLastGood(w, r, key) {
+			return
+		}
+
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
 		return
 	}
+	if cached && render.StatusCode() >= http.StatusInternalServerError && h.serveLastGood(w, r, key) {
+		return
+	}
 
-	if *h.config.Cache {
-		h.cache.Set(key, &jsCacheItem{
-			render: render,
-			expire: time.Now().Add(time.Duration(*h.config.CacheTTL) * time.Second),
-		})
+	var variants map[string][]byte
+	if cached {
+		// a render setting cookies is never shared between users
+		if ttl := h.cacheTTL(r, render); ttl > 0 && (private || !setsCookie(render)) &&
+			fault.Inject(r.Context(), fault.Cache) == nil {
+			size := len(render.Body())
+			if *h.config.CacheCompress && !render.Redirect() {
+				variants, err = jsCompress(render.Body())
+				if err != nil {
+					h.logger.Error("Failed to compress render", "url", r.URL.Path, "err", err)
+				}
+				for _, variant := range variants {
+					size += len(variant)
+				}
+			}
+
+			now := time.Now()
+			item := &jsCacheItem{
+				render:    render,
+				variants:  variants,
+				resources: resources,
+				stored:    now,
+				expire:    now.Add(ttl),
+			}
+			h.cache.Set(key, item, size)
+			h.storeLastGood(key, item, size)
+
+			stats := h.cache.Stats()
+			h.logger.Debug("Render cached", "url", r.URL.Path, "size", size, "entries", stats.Entries,
+				"bytes", stats.Bytes, "evictions", stats.Evictions, "rejections", stats.Rejections,
+				"hitRatio", stats.HitRatio())
+		}
 	}
 
-	for key, values := range render.Header() {
+	if cached {
+		h.setCacheHeader(w, nil, key)
+	}
+
+	if err := h.writeRender(w, r, render, variants); err != nil {
+		h.logger.Error("Failed to write render", "err", err)
+		return
+	}
+
+	h.logger.Debug("Render completed", "url", r.URL.Path, "status", render.StatusCode(), "cache", false)
+}
+
+// setCacheHeader sets the cache debugging headers of the response served from the given cached item or rendered for
+// the request if nil.
+func (h *jsHandler) setCacheHeader(w http.ResponseWriter, item *jsCacheItem, key string) {
+	if !*h.config.CacheHeaders {
+		return
+	}
+
+	if item == nil {
+		render.SetCacheHeader(w, render.CacheMiss, time.Time{}, key)
+		return
+	}
+	status := render.CacheHit
+	if !item.expire.After(time.Now()) {
+		status = render.CacheStale
+	}
+	render.SetCacheHeader(w, status, item.stored, key)
+}
+
+// writeRender writes the given render, using the pre-compressed variant of its body negotiated with the request if
+// any.
+func (h *jsHandler) writeRender(w http.ResponseWriter, r *http.Request, rd render.Render,
+	variants map[string][]byte) error {
+	for key, values := range rd.Header() {
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
-	if render.Redirect() {
-		http.Redirect(w, r, render.RedirectURL(), render.StatusCode())
-		return
+	if rd.Redirect() {
+		http.Redirect(w, r, rd.RedirectURL(), rd.StatusCode())
+		return nil
 	}
-	w.WriteHeader(render.StatusCode())
-	if _, err := w.Write(render.Body()); err != nil {
-		h.logger.Error("Failed to write render", "err", err)
+
+	body := rd.Body()
+	if variants != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding := render.NegotiateEncoding(r, jsEncodings...); encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			body = variants[encoding]
+		}
+	}
+	w.WriteHeader(rd.StatusCode())
+	_, err := w.Write(body)
+
+	return err
+}
+
+// serveError writes an error response without the headers set before the failure.
+func (h *jsHandler) serveError(w http.ResponseWriter, statusCode int) {
+	render.ResetHeader(w)
+	w.WriteHeader(statusCode)
+}
+
+// vmHeaders returns the request headers allowed to be exposed to the VM.
+//
+// A header name ending with '*' allows all the headers with this prefix.
+func (h *jsHandler) vmHeaders(r *http.Request) http.Header {
+	headers := http.Header{}
+	for _, allowed := range h.config.VMHeaders {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			prefix = http.CanonicalHeaderKey(prefix)
+			for key, values := range r.Header {
+				if strings.HasPrefix(key, prefix) {
+					headers[key] = values
+				}
+			}
+			continue
+		}
+		if values := r.Header.Values(allowed); len(values) > 0 {
+			headers[http.CanonicalHeaderKey(allowed)] = values
+		}
+	}
+
+	return headers
+}
+
+// cacheTTL returns the cache duration of the given render.
+//
+// The renders are cached with the default TTL, except the not found renders
+// which are cached with the short not found TTL to avoid storing soft-404
+// pages as valid content.
+//
+// The first cache rule matching the request path overrides the default TTL,
+// and a zero TTL disables the caching of all the renders of this path.
+func (h *jsHandler) cacheTTL(r *http.Request, render render.Render) time.Duration {
+	ttl := *h.config.CacheTTL
+	path := normalize.Path(r.URL.Path)
+	if index := h.cacheRuleSet.Next(path, 0); index >= 0 {
+		ttl = *h.config.CacheRules[index].TTL
+		if ttl == 0 {
+			return 0
+		}
+	}
+
+	if !render.Redirect() && render.StatusCode() == http.StatusNotFound {
+		return time.Duration(*h.config.CacheNotFoundTTL) * time.Second
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// purge removes the cached renders using one of the given resources.
+func (h *jsHandler) purge(names []string) {
+	if !*h.config.Cache {
 		return
 	}
 
-	h.logger.Debug("Render completed", "url", r.URL.Path, "status", render.StatusCode(), "cache", false)
+	changed := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		changed[name] = struct{}{}
+	}
+
+	h.cache.RemoveFunc(func(key string, value any) bool {
+		item, ok := value.(*jsCacheItem)
+		if !ok {
+			return false
+		}
+		for _, resource := range item.resources {
+			if _, ok := changed[resource]; ok {
+				h.logger.Debug("Purging render", "url", key, "resource", resource)
+				return true
+			}
+		}
+		return false
+	})
 }
 
 // read reads the application html and bundle files.
+//
+// A file is read again when its modification time changes, including to an older time as a copy preserving the
+// times would set. While a file is missing or locked during a swap, its previous content is kept if any.
 func (h *jsHandler) read() error {
+	if err := h.readIndex(); err != nil {
+		return err
+	}
+
+	return h.readBundle()
+}
+
+// readIndex reads the application html file.
+func (h *jsHandler) readIndex() error {
+	h.muIndex.RLock()
+	current := h.indexInfo
+	h.muIndex.RUnlock()
+
 	htmlInfo, err := h.osStat(h.config.Index)
 	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to stat index file, keeping previous content", "file", h.config.Index, "err", err)
+			return nil
+		}
 		h.logger.Error("Failed to stat index file", "file", h.config.Index, "err", err)
 		return fmt.Errorf("stat file %s: %v", h.config.Index, err)
 	}
+	if current != nil && htmlInfo.ModTime().Equal(*current) {
+		return nil
+	}
 
-	h.muIndex.RLock()
-	if h.indexInfo == nil || htmlInfo.ModTime().After(*h.indexInfo) {
-		h.muIndex.RUnlock()
+	buf, err := h.osReadFile(h.config.Index)
+	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to read index file, keeping previous content", "file", h.config.Index, "err", err)
+			return nil
+		}
+		h.logger.Error("Failed to read index file", "file", h.config.Index, "err", err)
+		return fmt.Errorf("read file %s: %v", h.config.Index, err)
+	}
 
-		buf, err := h.osReadFile(h.config.Index)
+	var shell *jsShell
+	var tmpl *template.Template
+	if *h.config.IndexTemplate {
+		tmpl, err = template.New("index").Funcs(template.FuncMap{
+			"env": os.Getenv,
+		}).Parse(string(buf))
 		if err != nil {
-			h.logger.Error("Failed to read index file", "file", h.config.Index, "err", err)
-			return fmt.Errorf("read file %s: %v", h.config.Index, err)
+			h.logger.Error("Failed to parse index template", "file", h.config.Index, "err", err)
+			return fmt.Errorf("parse template %s: %v", h.config.Index, err)
 		}
-
-		h.muIndex.Lock()
-		h.index = buf
-		i := htmlInfo.ModTime()
-		h.indexInfo = &i
-		h.muIndex.Unlock()
 	} else {
-		h.muIndex.RUnlock()
+		shell, err = newJSShell(bytes.NewReader(buf), *h.config.Container)
+		if err != nil {
+			h.logger.Error("Failed to parse index file", "file", h.config.Index, "err", err)
+			return fmt.Errorf("parse file %s: %v", h.config.Index, err)
+		}
 	}
 
+	h.muIndex.Lock()
+	h.index = shell
+	h.indexTmpl = tmpl
+	i := htmlInfo.ModTime()
+	h.indexInfo = &i
+	h.muIndex.Unlock()
+
+	return nil
+}
+
+// readBundle reads the application bundle file and checks that it does not use native async functions.
+func (h *jsHandler) readBundle() error {
+	h.muBundle.RLock()
+	current := h.bundleInfo
+	h.muBundle.RUnlock()
+
 	bundleInfo, err := h.osStat(h.config.Bundle)
 	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to stat bundle file, keeping previous content", "file", h.config.Bundle, "err", err)
+			return nil
+		}
 		h.logger.Error("Failed to stat bundle file", "file", h.config.Bundle, "err", err)
 		return fmt.Errorf("stat file %s: %v", h.config.Bundle, err)
 	}
+	if current != nil && bundleInfo.ModTime().Equal(*current) {
+		return nil
+	}
 
-	h.muBundle.RLock()
-	if h.bundleInfo == nil || bundleInfo.ModTime().After(*h.bundleInfo) {
-		h.muBundle.RUnlock()
-
-		buf, err := h.osReadFile(h.config.Bundle)
-		if err != nil {
-			h.logger.Error("Failed to read bundle file", "file", h.config.Bundle, "err", err)
-			return fmt.Errorf("read file %s: %v", h.config.Bundle, err)
+	buf, err := h.osReadFile(h.config.Bundle)
+	if err != nil {
+		if current != nil {
+			h.logger.Warn("Failed to read bundle file, keeping previous content", "file", h.config.Bundle, "err", err)
+			return nil
+		}
+		h.logger.Error("Failed to read bundle file", "file", h.config.Bundle, "err", err)
+		return fmt.Errorf("read file %s: %v", h.config.Bundle, err)
+	}
+	if err := vmCheckAsync(buf); err != nil {
+		if current != nil {
+			h.logger.Warn("Unsupported bundle file, keeping previous content", "file", h.config.Bundle, "err", err)
+			return nil
 		}
+		h.logger.Error("Unsupported bundle file", "file", h.config.Bundle, "err", err)
+		return fmt.Errorf("check file %s: %v", h.config.Bundle, err)
+	}
 
-		h.muBundle.Lock()
-		h.bundle = buf
-		i := bundleInfo.ModTime()
-		h.bundleInfo = &i
-		h.muBundle.Unlock()
-	} else {
-		h.muBundle.RUnlock()
+	h.muBundle.Lock()
+	h.bundle = buf
+	i := bundleInfo.ModTime()
+	h.bundleInfo = &i
+	h.stencil.release()
+	h.stencil = nil
+	h.muBundle.Unlock()
+
+	if *h.config.VMStencil {
+		go h.compileStencil(buf, i)
 	}
 
 	return nil
 }
 
-// render makes a new render.
-func (h *jsHandler) render(r *http.Request) (render.Render, error) {
-	rw := h.rwPool.Get()
-	defer h.rwPool.Put(rw)
+// compileStencil compiles in background the given bundle to a stencil shared by the next VMs.
+//
+// The VMs compile the bundle themselves until the stencil is ready, so that the first requests are not delayed by the
+// compilation. The stencil is dropped if the bundle has been replaced meanwhile.
+func (h *jsHandler) compileStencil(bundle []byte, modTime time.Time) {
+	start := time.Now()
+	stencil, err := vmCompileStencil(h.config.Bundle, bundle)
+	if err != nil {
+		h.logger.Error("Failed to compile bundle stencil", "file", h.config.Bundle, "err", err)
+		return
+	}
 
-	var valid bool = true
-	var mServerState map[string]jsResource
-	var serverState *[]byte
-	var mClientState map[string]jsResource
-	var clientState *[]byte
-	var vmResult *vmResult
+	h.muBundle.Lock()
+	if h.bundleInfo == nil || !h.bundleInfo.Equal(modTime) {
+		h.muBundle.Unlock()
+		stencil.release()
+		return
+	}
+	h.stencil = stencil
+	h.muBundle.Unlock()
 
-	for index, rule := range h.config.Rules {
-		m := h.regexps[index].FindStringSubmatch(r.URL.Path)
-		if m == nil {
+	h.logger.Debug("Bundle stencil compiled", "file", h.config.Bundle, "duration", time.Since(start).Milliseconds())
+}
+
+// healVM records a crash of the engine during an execution from the given stencil if not nil.
+//
+// Only the request in flight fails: the stencil is dropped if it is still the current one, so that the next VMs compile
+// the bundle themselves until a new stencil is compiled in background. The crashes are counted in the metrics exported
+// by the status handler.
+//
+// The engine runs in the process, so that only the panics of the bindings, the failures to create a context and the
+// out of memory errors are recovered here. A fatal signal raised by the engine still terminates the process.
+func (h *jsHandler) healVM(name string, stencil *vmStencil) {
+	crashes := h.vmCrashes.Add(1)
+	h.logger.Warn("VM crashed", "file", name, "crashes", crashes)
+
+	if stencil == nil {
+		return
+	}
+	h.muBundle.Lock()
+	if h.stencil != stencil {
+		h.muBundle.Unlock()
+		h.healEntry(stencil)
+		return
+	}
+	h.stencil.release()
+	h.stencil = nil
+	bundle := h.bundle
+	bundleInfo := h.bundleInfo
+	h.muBundle.Unlock()
+
+	if *h.config.VMStencil && bundleInfo != nil {
+		go h.compileStencil(bundle, *bundleInfo)
+	}
+}
+
+// render makes a new render with the current bundle, or the bundle entry matching the request if any, and returns it
+// with the names of the used resources.
+func (h *jsHandler) render(r *http.Request) (render.Render, []string, error) {
+	if e := h.entry(r); e != nil {
+		if err := h.readEntry(e); err != nil {
+			return nil, nil, fmt.Errorf("read entry: %v", err)
+		}
+
+		return h.renderEntry(r, e)
+	}
+
+	h.muBundle.RLock()
+	bundle := h.bundle
+	stencil := h.stencil.acquire()
+	h.muBundle.RUnlock()
+	defer stencil.release()
+
+	return h.renderBundle(r, h.config.Bundle, bundle, stencil, h.slots())
+}
+
+// slots returns the slots of the VMs of the handler, shared with other handlers if the handler has joined a pool.
+func (h *jsHandler) slots() vmSlots {
+	if h.vmPool != nil {
+		return h.vmPool
+	}
+
+	return vmSemaphore(h.vms)
+}
+
+// resolveState resolves the state entries of the rules matching the request from the store.
+func (h *jsHandler) resolveState(r *http.Request) *jsState {
+	state := &jsState{
+		valid: true,
+	}
+	tr := trace.FromContext(r.Context())
+
+	path := normalize.Path(r.URL.Path)
+	for index := h.ruleSet.Next(path, 0); index >= 0; index = h.ruleSet.Next(path, index+1) {
+		rule := h.config.Rules[index]
+		if !h.constraints[index].Match(r) {
 			continue
 		}
+		m := h.regexps[index].FindStringSubmatch(path)
+
+		tr.Add(string(jsModuleID), "Rule matched", "index", index, "path", rule.Path, "last", rule.Last)
 
 		params := make(map[string]string)
-		params["url"] = r.URL.Path
+		params["url"] = path
 		if len(m) > 1 {
 			for i, value := range m {
 				if i > 0 {
@@ -534,25 +1283,29 @@ func (h *jsHandler) render(r *http.Request) (render.Render, error) {
 		}
 
 		for _, entry := range rule.State {
-			if mServerState == nil {
-				mServerState = make(map[string]jsResource)
+			if state.server == nil {
+				state.server = make(map[string]jsResource)
 			}
-			if mClientState == nil && entry.Export != nil && *entry.Export {
-				mClientState = make(map[string]jsResource)
+			if state.client == nil && entry.Export != nil && *entry.Export {
+				state.client = make(map[string]jsResource)
 			}
 
 			stateKey := h.replaceIndexRouteParameters(entry.Key, params)
 			resourceKey := h.replaceIndexRouteParameters(entry.Resource, params)
 
+			state.resources = append(state.resources, resourceKey)
+
 			var resourceResult jsResource
 			resource, err := h.site.Store().LoadResource(resourceKey)
+			tr.Add(string(jsModuleID), "State entry resolved", "key", stateKey, "resource", resourceKey,
+				"found", err == nil)
 			if err != nil {
 				resourceResult.Error = jsResourceUnknown
-				mServerState[stateKey] = resourceResult
+				state.server[stateKey] = resourceResult
 				if entry.Export != nil && *entry.Export {
-					mClientState[stateKey] = resourceResult
+					state.client[stateKey] = resourceResult
 				}
-				valid = false
+				state.valid = false
 				continue
 			}
 
@@ -561,65 +1314,95 @@ func (h *jsHandler) render(r *http.Request) (render.Render, error) {
 				resourceResult.Data[index] = string(resource.Data[index])
 			}
 
-			mServerState[stateKey] = resourceResult
+			state.server[stateKey] = resourceResult
 			if entry.Export != nil && *entry.Export {
-				mClientState[stateKey] = resourceResult
+				state.client[stateKey] = resourceResult
 			}
 		}
 
-		if mServerState != nil {
-			buf, err := h.jsonMarshal(mServerState)
-			if err != nil {
-				return nil, fmt.Errorf("marshal server state: %v", err)
-			}
-
-			serverState = &buf
+		if h.config.Rules[index].Last {
+			break
 		}
+	}
 
-		if mClientState != nil {
-			buf, err := h.jsonMarshal(mClientState)
-			if err != nil {
-				return nil, fmt.Errorf("marshal client state: %v", err)
-			}
+	return state
+}
 
-			clientState = &buf
-		}
+// renderBundle makes a new render with the given bundle, or its stencil if not nil, executed in a VM of the given slots
+// and returns it with the names of the used resources.
+func (h *jsHandler) renderBundle(r *http.Request, name string, bundle []byte, stencil *vmStencil,
+	vms vmSlots) (render.Render, []string, error) {
+	rw := h.rwPool.Get()
+	defer h.rwPool.Put(rw)
 
-		if h.config.Rules[index].Last {
-			break
+	var clientState *[]byte
+	var vmResult *vmResult
+	tr := trace.FromContext(r.Context())
+
+	state := h.resolveState(r)
+	serverState, resources, valid := state.server, state.resources, state.valid
+	if state.client != nil {
+		buf, err := h.jsonMarshal(state.client)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal client state: %v", err)
 		}
+
+		clientState = &buf
 	}
 
-	h.vms <- struct{}{}
-	defer func() {
-		<-h.vms
-	}()
+	wait := time.Now()
+	if err := vms.acquire(r.Context()); err != nil {
+		return nil, nil, fmt.Errorf("acquire VM: %v", err)
+	}
+	defer vms.release()
+	tr.Add(string(jsModuleID), "VM acquired", "wait", time.Since(wait).Milliseconds())
+	if err := fault.Inject(r.Context(), fault.VM); err != nil {
+		return nil, nil, fmt.Errorf("execute VM: %v", err)
+	}
 	vm, err := newVM(
 		WithHeapMaxBytes(uint(*h.config.VMMaxHeapSize)),
 		WithStackSize(uint(*h.config.VMStackSize)),
+		WithGracePeriod(time.Duration(*h.config.VMGracePeriod)*time.Millisecond),
+		WithCPUBudget(time.Duration(*h.config.VMCPUBudget)*time.Millisecond),
 	)
 	if err != nil {
 		h.logger.Debug("Failed to create VM", "err", err)
-		return nil, fmt.Errorf("create VM: %v", err)
+		return nil, nil, fmt.Errorf("create VM: %v", err)
 	}
 
-	h.muBundle.RLock()
 	vmResult, err = vm.Execute(vmConfig{
 		Env:     *h.config.Env,
 		State:   serverState,
 		Request: r,
+		Headers: h.vmHeaders(r),
+		Device:  deviceClass(r),
 		Site:    h.site,
-	}, h.config.Bundle, h.bundle, time.Duration(*h.config.VMTimeout)*time.Millisecond)
-	h.muBundle.RUnlock()
+		Stencil: stencil,
+	}, name, bundle, time.Duration(*h.config.VMTimeout)*time.Millisecond)
+	stats := vm.Stats()
+	h.logger.Debug("VM execution completed", "url", r.URL.Path,
+		"duration", stats.Duration.Milliseconds(), "cpuTime", stats.CPUTime.Milliseconds())
+	tr.Add(string(jsModuleID), "VM execution completed", "duration", stats.Duration.Milliseconds(),
+		"cpuTime", stats.CPUTime.Milliseconds(), "error", err != nil)
+	timings := timing.FromContext(r.Context())
+	timings.Add(jsTimingVM, stats.Duration)
+	timings.Add(jsTimingVMCPU, stats.CPUTime)
 	if err != nil {
+		if stats.Crashed {
+			h.healVM(name, stencil)
+		}
 		h.logger.Debug("Failed to execute VM", "err", err)
-		return nil, fmt.Errorf("execute VM: %v", err)
+		return nil, nil, fmt.Errorf("execute VM: %v", err)
+	}
+	for _, t := range vmResult.Timings {
+		timings.Add(t.Name, t.Duration)
+		tr.Add(string(jsModuleID), "VM timing", "name", t.Name, "duration", t.Duration.Milliseconds())
 	}
 
 	if vmResult.Redirect != nil && *vmResult.Redirect && vmResult.RedirectURL != nil && vmResult.RedirectStatus != nil {
 		rw.WriteRedirect(*vmResult.RedirectURL, *vmResult.RedirectStatus)
 
-		return rw.Render(), nil
+		return rw.Render(), resources, nil
 	}
 
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -628,65 +1411,90 @@ func (h *jsHandler) render(r *http.Request) (render.Render, error) {
 			rw.Header().Add(key, v)
 		}
 	}
-	if valid {
-		rw.WriteHeader(*vmResult.Status)
-	} else {
+	switch {
+	case !valid:
 		rw.WriteHeader(http.StatusServiceUnavailable)
+	case vmResult.NotFound != nil && *vmResult.NotFound:
+		rw.WriteHeader(http.StatusNotFound)
+	case vmResult.Status != nil:
+		rw.WriteHeader(*vmResult.Status)
+	default:
+		rw.WriteHeader(http.StatusOK)
 	}
 
 	h.muIndex.RLock()
-	if h.index != nil {
-		err = h.doc(rw, r, bytes.NewReader(h.index), clientState, vmResult)
-	} else {
+	switch {
+	case h.indexTmpl != nil:
+		var buf bytes.Buffer
+		err = h.indexTmpl.Execute(&buf, jsIndexTemplateData{
+			Env:     *h.config.Env,
+			Request: r,
+		})
+		var shell *jsShell
+		if err == nil {
+			shell, err = newJSShell(&buf, *h.config.Container)
+		}
+		if err == nil {
+			err = h.doc(rw, r, shell, clientState, vmResult)
+		}
+	case h.index != nil:
+		err = h.doc(rw, r, h.index, clientState, vmResult)
+	default:
 		err = errors.New("index not loaded")
 	}
 	h.muIndex.RUnlock()
 	if err != nil {
 		h.logger.Debug("Failed to process render", "err", err)
-		return nil, fmt.Errorf("process render: %v", err)
+		return nil, nil, fmt.Errorf("process render: %v", err)
 	}
 
-	return rw.Render(), nil
+	result := rw.Render()
+	if h.budgetRuleSet.Len() > 0 {
+		usage := jsBudgetUsage{
+			htmlBytes:  len(result.Body()),
+			renderTime: stats.Duration,
+		}
+		if clientState != nil {
+			usage.stateBytes = len(*clientState)
+		}
+		violations, enforced := h.checkBudgets(r, usage)
+		if enforced {
+			return nil, nil, errors.New("render budget exceeded")
+		}
+		if len(violations) > 0 && *h.config.Env != jsConfigDefaultEnv {
+			result.Header().Set(jsBudgetHeader, strings.Join(violations, ", "))
+		}
+	}
+
+	return result, resources, nil
 }
 
-// doc writes the final index.
-func (h *jsHandler) doc(w render.RenderWriter, _ *http.Request, b io.Reader, state *[]byte, result *vmResult) error {
-	doc, err := html.Parse(b)
-	if err != nil {
-		return fmt.Errorf("parse html: %v", err)
-	}
-
-	if result.Render != nil {
-		var renderContainer func(*html.Node) bool
-		renderContainer = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "div" {
-				for _, d := range n.Attr {
-					if d.Key == "id" && d.Val == *h.config.Container {
-						n.AppendChild(&html.Node{
-							Type: html.RawNode,
-							Data: string(*result.Render),
-						})
-						return true
-					}
-				}
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderContainer(c) {
-					return true
+// doc writes the final index by writing the shell segments and the render elements at the insertion points.
+func (h *jsHandler) doc(w render.RenderWriter, _ *http.Request, shell *jsShell, state *[]byte, result *vmResult) error {
+	if result.Render != nil && !shell.has(jsShellPointContainer) {
+		return errors.New("container not found")
+	}
+	if state != nil && !shell.has(jsShellPointBody) {
+		return errors.New("body not found")
+	}
+	if (result.Title != nil || result.Metas != nil || result.Links != nil || result.Scripts != nil) &&
+		!shell.has(jsShellPointHead) {
+		return errors.New("head not found")
+	}
+
+	bw := bufio.NewWriter(w)
+	err := shell.write(bw, func(point jsShellPoint) error {
+		switch point {
+		case jsShellPointContainer:
+			if result.Render != nil {
+				if _, err := bw.Write(*result.Render); err != nil {
+					return err
 				}
 			}
-			return false
-		}
-		if !renderContainer(doc) {
-			return errors.New("container not found")
-		}
-	}
 
-	if state != nil {
-		var renderState func(*html.Node) bool
-		renderState = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "body" {
-				n.AppendChild(&html.Node{
+		case jsShellPointBody:
+			if state != nil {
+				if err := html.Render(bw, &html.Node{
 					Type: html.ElementNode,
 					Data: "script",
 					Attr: []html.Attribute{
@@ -701,182 +1509,98 @@ func (h *jsHandler) doc(w render.RenderWriter, _ *http.Request, b io.Reader, sta
 					},
 					FirstChild: &html.Node{
 						Type: html.RawNode,
-						Data: string(*state),
+						Data: escapeJSONForHTML(*state),
 					},
-				})
-				return true
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderState(c) {
-					return true
+				}); err != nil {
+					return err
 				}
 			}
-			return false
-		}
-		if !renderState(doc) {
-			return errors.New("body not found")
-		}
-	}
 
-	if result.Title != nil {
-		var renderTitle func(*html.Node) bool
-		renderTitle = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "head" {
-				n.AppendChild(&html.Node{
-					Type: html.ElementNode,
-					Data: "title",
-					FirstChild: &html.Node{
-						Type: html.TextNode,
-						Data: *result.Title,
-					},
-				})
-				return true
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderTitle(c) {
-					return true
+		case jsShellPointHead:
+			for _, n := range h.docHeadNodes(result) {
+				if err := html.Render(bw, n); err != nil {
+					return err
 				}
 			}
-			return false
-		}
-		if !renderTitle(doc) {
-			return errors.New("head not found")
 		}
+		return nil
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("render html: %v", err)
 	}
 
-	if result.Metas != nil {
-		var renderMetas func(*html.Node) bool
-		renderMetas = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "head" {
-				for _, id := range result.Metas.Ids() {
-					e, err := result.Metas.Get(id)
-					if err != nil {
-						continue
-					}
-					var attrs []html.Attribute
-					attrs = append(attrs, html.Attribute{
-						Key: "id",
-						Val: id,
-					})
-					for _, k := range e.Attributes() {
-						attrs = append(attrs, html.Attribute{
-							Key: k,
-							Val: e.GetAttribute(k),
-						})
-					}
-					n.AppendChild(&html.Node{
-						Type: html.ElementNode,
-						Data: "meta",
-						Attr: attrs,
-					})
-				}
-				return true
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderMetas(c) {
-					return true
-				}
-			}
-			return false
-		}
-		if !renderMetas(doc) {
-			return errors.New("head not found")
-		}
+	return nil
+}
+
+// docHeadNodes returns the nodes of the render elements to write at the end of the head element.
+func (h *jsHandler) docHeadNodes(result *vmResult) []*html.Node {
+	var nodes []*html.Node
+
+	if result.Title != nil {
+		nodes = append(nodes, &html.Node{
+			Type: html.ElementNode,
+			Data: "title",
+			FirstChild: &html.Node{
+				Type: html.TextNode,
+				Data: *result.Title,
+			},
+		})
 	}
 
-	if result.Links != nil {
-		var renderLink func(*html.Node) bool
-		renderLink = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "head" {
-				for _, id := range result.Links.Ids() {
-					e, err := result.Links.Get(id)
-					if err != nil {
-						continue
-					}
-					var attrs []html.Attribute
-					attrs = append(attrs, html.Attribute{
-						Key: "id",
-						Val: id,
-					})
-					for _, k := range e.Attributes() {
-						attrs = append(attrs, html.Attribute{
-							Key: k,
-							Val: e.GetAttribute(k),
-						})
-					}
-					n.AppendChild(&html.Node{
-						Type: html.ElementNode,
-						Data: "link",
-						Attr: attrs,
-					})
-				}
-				return true
+	elements := func(list *domElementList, tag string) {
+		for _, id := range list.Ids() {
+			e, err := list.Get(id)
+			if err != nil {
+				continue
 			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderLink(c) {
-					return true
+			var attrs []html.Attribute
+			attrs = append(attrs, html.Attribute{
+				Key: "id",
+				Val: id,
+			})
+			for _, k := range e.Attributes() {
+				if k == "id" || (tag == "script" && k == "children") {
+					continue
 				}
+				attrs = append(attrs, html.Attribute{
+					Key: k,
+					Val: e.GetAttribute(k),
+				})
 			}
-			return false
-		}
-		if !renderLink(doc) {
-			return errors.New("head not found")
-		}
-	}
-
-	if result.Scripts != nil {
-		var renderScript func(*html.Node) bool
-		renderScript = func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "head" {
-				for _, id := range result.Scripts.Ids() {
-					e, err := result.Scripts.Get(id)
-					if err != nil {
-						continue
-					}
-					var attrs []html.Attribute
-					attrs = append(attrs, html.Attribute{
-						Key: "id",
-						Val: id,
-					})
-					for _, k := range e.Attributes() {
-						if k == "children" {
-							continue
-						}
-						attrs = append(attrs, html.Attribute{
-							Key: k,
-							Val: e.GetAttribute(k),
-						})
-					}
-					children := e.GetAttribute("children")
-					n.AppendChild(&html.Node{
-						Type: html.ElementNode,
-						Data: "script",
-						Attr: attrs,
-						FirstChild: &html.Node{
-							Type: html.RawNode,
-							Data: children,
-						},
-					})
-				}
-				return true
+			n := &html.Node{
+				Type: html.ElementNode,
+				Data: tag,
+				Attr: attrs,
 			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if renderScript(c) {
-					return true
+			if tag == "script" {
+				children := e.GetAttribute("children")
+				if strings.Contains(strings.ToLower(e.GetAttribute("type")), "json") {
+					children = escapeJSONForHTML([]byte(children))
+				} else {
+					children = escapeScriptForHTML(children)
+				}
+				n.FirstChild = &html.Node{
+					Type: html.RawNode,
+					Data: children,
 				}
 			}
-			return false
-		}
-		if !renderScript(doc) {
-			return errors.New("head not found")
+			nodes = append(nodes, n)
 		}
 	}
-
-	if err := html.Render(w, doc); err != nil {
-		return fmt.Errorf("render html: %v", err)
+	if result.Metas != nil {
+		elements(result.Metas, "meta")
+	}
+	if result.Links != nil {
+		elements(result.Links, "link")
+	}
+	if result.Scripts != nil {
+		elements(result.Scripts, "script")
 	}
 
-	return nil
+	return nodes
 }
 
 // replaceIndexRouteParameters returns a copy of the string s with all its parameters replaced.
@@ -889,3 +1613,5 @@ func (h *jsHandler) replaceIndexRouteParameters(s string, params map[string]stri
 }
 
 var _ core.ServerSiteMiddlewareModule = (*jsHandler)(nil)
+var _ priority.Degradable = (*jsHandler)(nil)
+var _ core.PreflightModule = (*jsHandler)(nil)
@@ -1,20 +1,29 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
 	"github.com/bhuisgen/neon/pkg/render"
+	"github.com/bhuisgen/neon/pkg/timing"
 )
 
 func boolPtr(b bool) *bool {
@@ -141,7 +150,7 @@ func TestJSHandlerModuleInfo(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -210,7 +219,7 @@ func TestJSHandlerInit(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -273,21 +282,50 @@ func TestJSHandlerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
-					"Index":         "index.html",
-					"Bundle":        "bundle.js",
-					"Env":           "test",
-					"Container":     "root",
-					"State":         "state",
-					"MaxVMs":        4,
-					"VMMaxHeapSize": 32 * 1024 * 1024,
-					"VMStackSize":   512 * 1024,
-					"VMTimeout":     1000,
-					"Cache":         true,
-					"CacheTTL":      60,
-					"CacheMaxItems": 100,
+					"Index":            "index.html",
+					"IndexTemplate":    true,
+					"Bundle":           "bundle.js",
+					"Env":              "test",
+					"Container":        "root",
+					"State":            "state",
+					"MaxVMs":           4,
+					"VMPool":           "shared",
+					"VMPoolSize":       8,
+					"VMMaxHeapSize":    32 * 1024 * 1024,
+					"VMStackSize":      512 * 1024,
+					"VMTimeout":        1000,
+					"VMGracePeriod":    100,
+					"VMCPUBudget":      500,
+					"VMStencil":        true,
+					"VMHeaders":        []string{"X-Country", "X-Geo-*"},
+					"Cache":            true,
+					"CacheTTL":         60,
+					"CacheHeaders":     true,
+					"CachePrivate":     true,
+					"CacheLastGood":    true,
+					"CacheNotFoundTTL": 5,
+					"CacheMaxItems":    100,
+					"CachePolicy":      "tinylfu",
+					"CacheCompress":    true,
+					"CacheVaryDevice":  true,
+					"CacheQuery":       true,
+					"StateJSON":        true,
+					"StateJSONParam":   "__state",
+					"CacheRules": []map[string]interface{}{
+						{
+							"Path": "^/$",
+							"TTL":  10,
+						},
+						{
+							"Path": "^/legal/",
+							"TTL":  0,
+						},
+					},
 					"Rules": []map[string]interface{}{
 						{
-							"Path": "/",
+							"Path":    "/",
+							"Host":    `^example\.com$`,
+							"Methods": []string{"GET"},
 							"State:": []map[string]interface{}{
 								{
 									"Key":      "test",
@@ -297,6 +335,32 @@ func TestJSHandlerInit(t *testing.T) {
 							"Last": true,
 						},
 					},
+					"BundleMap": []map[string]interface{}{
+						{
+							"Path":   "^/$",
+							"Bundle": "entry-home.js",
+						},
+					},
+					"Canary": map[string]interface{}{
+						"Bundle": "candidate.js",
+						"Path":   "/__canary",
+						"Token":  "token",
+						"MaxVMs": 2,
+						"Routes": []string{"/", "/test"},
+					},
+					"Budgets": []map[string]interface{}{
+						{
+							"Path":       "^/",
+							"HTMLBytes":  100000,
+							"StateBytes": 10000,
+							"RenderTime": 100,
+						},
+						{
+							"Path":      "^/$",
+							"HTMLBytes": 50000,
+							"Mode":      "enforce",
+						},
+					},
 				},
 			},
 		},
@@ -335,20 +399,62 @@ func TestJSHandlerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
-					"Index":         "",
-					"Bundle":        "",
-					"Env":           "",
-					"Container":     "",
-					"State":         "",
-					"MaxVMs":        0,
-					"VMMaxHeapSize": -1,
-					"VMStackSize":   -1,
-					"VMTimeout":     0,
-					"CacheTTL":      0,
-					"CacheMaxItems": 0,
-					"Rules": []map[string]interface{}{
+					"Index":            "",
+					"Bundle":           "",
+					"Env":              "",
+					"Container":        "",
+					"State":            "",
+					"MaxVMs":           0,
+					"VMPool":           "",
+					"VMPoolSize":       0,
+					"VMMaxHeapSize":    -1,
+					"VMStackSize":      -1,
+					"VMTimeout":        0,
+					"VMGracePeriod":    -1,
+					"VMCPUBudget":      -1,
+					"VMHeaders":        []string{"", "*"},
+					"CacheTTL":         0,
+					"CacheNotFoundTTL": -1,
+					"CacheMaxItems":    0,
+					"CachePolicy":      "invalid",
+					"StateJSONParam":   "",
+					"CacheRules": []map[string]interface{}{
+						{
+							"Path": "",
+						},
+						{
+							"Path": "(",
+							"TTL":  -1,
+						},
+					},
+					"BundleMap": []map[string]interface{}{
+						{
+							"Path":   "(",
+							"Bundle": "",
+						},
+					},
+					"Canary": map[string]interface{}{
+						"Path":   "canary",
+						"MaxVMs": 0,
+						"Routes": []string{"test"},
+					},
+					"Budgets": []map[string]interface{}{
 						{
 							"Path": "",
+						},
+						{
+							"Path":       "(",
+							"HTMLBytes":  0,
+							"StateBytes": -1,
+							"RenderTime": 0,
+							"Mode":       "invalid",
+						},
+					},
+					"Rules": []map[string]interface{}{
+						{
+							"Path":    "",
+							"Host":    "(",
+							"Methods": []string{""},
 							"State": []map[string]interface{}{
 								{
 									"Key":      "",
@@ -465,7 +571,7 @@ func TestJSHandlerRegister(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -542,7 +648,7 @@ func TestJSHandlerStart(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -569,7 +675,78 @@ func TestJSHandlerStart(t *testing.T) {
 			fields: fields{
 				config: &jsHandlerConfig{
 					Index:         "test/default/index.html",
+					IndexTemplate: boolPtr(false),
+					VMStencil:     boolPtr(false),
+					Bundle:        "test/default/bundle.js",
+					Container:     stringPtr("root"),
+					VMMaxHeapSize: intPtr(0),
+					VMStackSize:   intPtr(0),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			},
+		},
+		{
+			name: "index template",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Index:         "test/template/index.html",
+					IndexTemplate: boolPtr(true),
+					VMStencil:     boolPtr(false),
+					Bundle:        "test/template/bundle.js",
+					VMMaxHeapSize: intPtr(0),
+					VMStackSize:   intPtr(0),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			},
+		},
+		{
+			name: "error index template",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Index:         "test/template/index.html",
+					IndexTemplate: boolPtr(true),
+					VMStencil:     boolPtr(false),
+					Bundle:        "test/template/bundle.js",
+					VMMaxHeapSize: intPtr(0),
+					VMStackSize:   intPtr(0),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				osReadFile: func(name string) ([]byte, error) {
+					return []byte(`{{ .Invalid `), nil
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error bundle native async",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Index:         "test/default/index.html",
+					IndexTemplate: boolPtr(false),
+					VMStencil:     boolPtr(false),
 					Bundle:        "test/default/bundle.js",
+					Container:     stringPtr("root"),
 					VMMaxHeapSize: intPtr(0),
 					VMStackSize:   intPtr(0),
 				},
@@ -577,12 +754,16 @@ func TestJSHandlerStart(t *testing.T) {
 				muIndex:  &sync.RWMutex{},
 				muBundle: &sync.RWMutex{},
 				osReadFile: func(name string) ([]byte, error) {
+					if name == "test/default/bundle.js" {
+						return []byte(`(async () => server.response.render("test"))();`), nil
+					}
 					return os.ReadFile(name)
 				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return os.Stat(name)
 				},
 			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -620,7 +801,7 @@ func TestJSHandlerStop(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -647,7 +828,7 @@ func TestJSHandlerStop(t *testing.T) {
 			fields: fields{
 				muIndex:  &sync.RWMutex{},
 				muBundle: &sync.RWMutex{},
-				cache:    newCache(1),
+				cache:    newCache(1, cachePolicyLRU),
 			},
 		},
 	}
@@ -686,7 +867,7 @@ func TestJSHandlerServeHTTP(t *testing.T) {
 		config      *jsHandlerConfig
 		logger      *slog.Logger
 		regexps     []*regexp.Regexp
-		index       []byte
+		index       *jsShell
 		indexInfo   *time.Time
 		muIndex     *sync.RWMutex
 		bundle      []byte
@@ -708,33 +889,45 @@ func TestJSHandlerServeHTTP(t *testing.T) {
 		r *http.Request
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name       string
+		fields     fields
+		args       args
+		wantStatus int
+		wantTTL    time.Duration
 	}{
 		{
 			name: "default",
 			fields: fields{
 				config: &jsHandlerConfig{
-					Index:         "test/default/index.html",
-					Bundle:        "test/default/bundle.js",
-					Env:           stringPtr("test"),
-					Container:     stringPtr("root"),
-					State:         stringPtr("state"),
-					MaxVMs:        intPtr(0),
-					VMMaxHeapSize: intPtr(0),
-					VMStackSize:   intPtr(0),
-					VMTimeout:     intPtr(1000),
-					Cache:         boolPtr(true),
-					CacheTTL:      intPtr(60),
-					CacheMaxItems: intPtr(100),
+					Index:            "test/default/index.html",
+					IndexTemplate:    boolPtr(false),
+					Bundle:           "test/default/bundle.js",
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(0),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(true),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					StateJSON:        boolPtr(false),
+					CacheCompress:    boolPtr(false),
 				},
 				logger:   slog.Default(),
 				muIndex:  &sync.RWMutex{},
 				muBundle: &sync.RWMutex{},
 				vms:      make(chan struct{}, 1),
 				rwPool:   render.NewRenderWriterPool(),
-				cache:    newCache(1),
+				cache:    newCache(1, cachePolicyLRU),
 				site:     testJSHandlerServerSite{},
 				osReadFile: func(name string) ([]byte, error) {
 					return os.ReadFile(name)
@@ -756,6 +949,114 @@ func TestJSHandlerServeHTTP(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "index template",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Index:            "test/template/index.html",
+					IndexTemplate:    boolPtr(true),
+					Bundle:           "test/template/bundle.js",
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(0),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(true),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					StateJSON:        boolPtr(false),
+					CacheCompress:    boolPtr(false),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				vms:      make(chan struct{}, 1),
+				rwPool:   render.NewRenderWriterPool(),
+				cache:    newCache(1, cachePolicyLRU),
+				site:     testJSHandlerServerSite{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			},
+			args: args{
+				w: testJSHandlerResponseWriter{
+					header: http.Header{},
+				},
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL: &url.URL{
+						Path: "/test",
+					},
+					Header: http.Header{},
+				},
+			},
+		},
+		{
+			name: "not found",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Index:            "test/notfound/index.html",
+					IndexTemplate:    boolPtr(false),
+					Bundle:           "test/notfound/bundle.js",
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(0),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(true),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					StateJSON:        boolPtr(false),
+					CacheCompress:    boolPtr(false),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				vms:      make(chan struct{}, 1),
+				rwPool:   render.NewRenderWriterPool(),
+				cache:    newCache(1, cachePolicyLRU),
+				site:     testJSHandlerServerSite{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: &http.Request{
+					Method: http.MethodGet,
+					URL: &url.URL{
+						Path: "/test",
+					},
+					Header: http.Header{},
+				},
+			},
+			wantStatus: http.StatusNotFound,
+			wantTTL:    5 * time.Second,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -781,6 +1082,601 @@ func TestJSHandlerServeHTTP(t *testing.T) {
 				jsonMarshal: tt.fields.jsonMarshal,
 			}
 			h.ServeHTTP(tt.args.w, tt.args.r)
+			if w, ok := tt.args.w.(*httptest.ResponseRecorder); ok && tt.wantStatus != 0 && w.Code != tt.wantStatus {
+				t.Errorf("jsHandler.ServeHTTP() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantTTL != 0 {
+				item, ok := h.cache.Get(tt.args.r.URL.Path).(*jsCacheItem)
+				if !ok {
+					t.Fatal("jsHandler.ServeHTTP() render not cached")
+				}
+				if ttl := item.expire.Sub(item.stored); ttl != tt.wantTTL {
+					t.Errorf("jsHandler.ServeHTTP() cache TTL = %v, want %v", ttl, tt.wantTTL)
+				}
+			}
 		})
 	}
 }
+
+func TestJSHandlerServeHTTPTimings(t *testing.T) {
+	h := &jsHandler{
+		config: &jsHandlerConfig{
+			Index:            "test/default/index.html",
+			IndexTemplate:    boolPtr(false),
+			Bundle:           "test/default/bundle.js",
+			Env:              stringPtr("test"),
+			Container:        stringPtr("root"),
+			State:            stringPtr("state"),
+			MaxVMs:           intPtr(0),
+			VMMaxHeapSize:    intPtr(0),
+			VMStackSize:      intPtr(0),
+			VMTimeout:        intPtr(1000),
+			VMGracePeriod:    intPtr(0),
+			VMCPUBudget:      intPtr(0),
+			VMStencil:        boolPtr(false),
+			Cache:            boolPtr(false),
+			CacheHeaders:     boolPtr(false),
+			CachePrivate:     boolPtr(false),
+			CacheNotFoundTTL: intPtr(5),
+			CacheVaryDevice:  boolPtr(false),
+			CacheQuery:       boolPtr(false),
+			StateJSON:        boolPtr(false),
+			CacheCompress:    boolPtr(false),
+		},
+		logger:   slog.Default(),
+		muIndex:  &sync.RWMutex{},
+		muBundle: &sync.RWMutex{},
+		vms:      make(chan struct{}, 1),
+		rwPool:   render.NewRenderWriterPool(),
+		site:     testJSHandlerServerSite{},
+		osReadFile: func(name string) ([]byte, error) {
+			return os.ReadFile(name)
+		},
+		osStat: func(name string) (fs.FileInfo, error) {
+			return os.Stat(name)
+		},
+	}
+
+	timings := timing.New()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(
+		timing.NewContext(context.Background(), timings)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("jsHandler.ServeHTTP() status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	// the timings are written as a single field of the access log
+	got := timings.String()
+	if !strings.HasPrefix(got, jsTimingVM+"=") || !strings.Contains(got, ","+jsTimingVMCPU+"=") {
+		t.Errorf("jsHandler.ServeHTTP() timings = %v", got)
+	}
+}
+
+func TestJSHandlerServeHTTPIndexTemplateHost(t *testing.T) {
+	h, ok := jsHandler{}.ModuleInfo().NewInstance().(*jsHandler)
+	if !ok {
+		t.Fatal("jsHandler.NewInstance() invalid instance")
+	}
+	if err := h.Init(map[string]interface{}{
+		"Index":         "test/template/index.html",
+		"IndexTemplate": true,
+		"Bundle":        "test/template/bundle.js",
+		"Env":           "test",
+		"Cache":         true,
+	}); err != nil {
+		t.Fatalf("jsHandler.Init() error = %v", err)
+	}
+	if err := h.Register(testJSHandlerServerSite{}); err != nil {
+		t.Fatalf("jsHandler.Register() error = %v", err)
+	}
+	if err := h.Start(); err != nil {
+		t.Fatalf("jsHandler.Start() error = %v", err)
+	}
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://"+host+"/test", nil))
+		if want := `data-host="` + host + `"`; w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("jsHandler.ServeHTTP() status = %v, body = %v, want %v", w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestJSHandlerDoc(t *testing.T) {
+	scripts := newDOMElementList()
+	script := newDOMElement("script")
+	script.SetAttribute("children", `console.log("</script><script>alert(1)</script>");`)
+	scripts.Set(script)
+	jsonScript := newDOMElement("ld")
+	jsonScript.SetAttribute("type", "application/ld+json")
+	jsonScript.SetAttribute("children", `{"name":"</script><script>alert(2)</script>"}`)
+	scripts.Set(jsonScript)
+	metas := newDOMElementList()
+	meta := newDOMElement("description")
+	meta.SetAttribute("name", "description")
+	meta.SetAttribute("content", `"><script>alert(3)</script>`)
+	metas.Set(meta)
+	links := newDOMElementList()
+	link := newDOMElement("canonical")
+	link.SetAttribute("id", `canonical"><script>alert(4)</script>`)
+	link.SetAttribute("href", `https://test/'><script>alert(5)</script>`)
+	links.Set(link)
+
+	type args struct {
+		index  string
+		state  *[]byte
+		result *vmResult
+	}
+	tests := []struct {
+		name        string
+		args        args
+		want        []string
+		wantScripts int
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			args: args{
+				index:  `<html><head></head><body><div id="root"></div></body></html>`,
+				state:  bytePtr([]byte(`{"key":"value"}`)),
+				result: &vmResult{},
+			},
+			want: []string{
+				`<script id="state" type="application/json">{"key":"value"}</script>`,
+			},
+			wantScripts: 1,
+		},
+		{
+			name: "hostile state",
+			args: args{
+				index:  `<html><head></head><body><div id="root"></div></body></html>`,
+				state:  bytePtr([]byte(`{"key":"</script><script>alert(1)</script><!--"}`)),
+				result: &vmResult{},
+			},
+			want: []string{
+				`<script id="state" type="application/json">` +
+					`{"key":"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e\u003c!--"}</script>`,
+			},
+			wantScripts: 1,
+		},
+		{
+			name: "hostile scripts",
+			args: args{
+				index: `<html><head></head><body><div id="root"></div></body></html>`,
+				result: &vmResult{
+					Scripts: scripts,
+				},
+			},
+			want: []string{
+				`console.log("<\/script><script>alert(1)<\/script>");`,
+				`{"name":"\u003c/script\u003e\u003cscript\u003ealert(2)\u003c/script\u003e"}`,
+			},
+			wantScripts: 2,
+		},
+		{
+			name: "hostile attributes",
+			args: args{
+				index: `<html><head></head><body><div id="root"></div></body></html>`,
+				result: &vmResult{
+					Title: stringPtr(`</title><script>alert(6)</script>`),
+					Metas: metas,
+					Links: links,
+				},
+			},
+			want: []string{
+				`<title>&lt;/title&gt;&lt;script&gt;alert(6)&lt;/script&gt;</title>`,
+				`content="&#34;&gt;&lt;script&gt;alert(3)&lt;/script&gt;"`,
+				`<link id="canonical" href="https://test/&#39;&gt;&lt;script&gt;alert(5)&lt;/script&gt;"/>`,
+			},
+		},
+		{
+			name: "error container not found",
+			args: args{
+				index: `<html><head></head><body></body></html>`,
+				result: &vmResult{
+					Render: bytePtr([]byte(`test`)),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Container: stringPtr("root"),
+					State:     stringPtr("state"),
+				},
+				logger: slog.Default(),
+			}
+			shell, err := newJSShell(strings.NewReader(tt.args.index), *h.config.Container)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := render.NewRenderWriter()
+			err = h.doc(w, nil, shell, tt.args.state, tt.args.result)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("jsHandler.doc() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got := string(w.Render().Body())
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("jsHandler.doc() = %v, want %v", got, want)
+				}
+			}
+			if n := strings.Count(got, "</script>"); n != tt.wantScripts {
+				t.Errorf("jsHandler.doc() = %v, got %d script elements, want %d", got, n, tt.wantScripts)
+			}
+		})
+	}
+}
+
+func TestJSHandlerWriteRender(t *testing.T) {
+	rw := render.NewRenderWriter()
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	if _, err := rw.Write([]byte("identity")); err != nil {
+		t.Fatal(err)
+	}
+	rd := rw.Render()
+	variants := map[string][]byte{
+		jsEncodingBrotli: []byte("br"),
+		jsEncodingGzip:   []byte("gzip"),
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		variants       map[string][]byte
+		want           string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{
+			name:           "no variants",
+			acceptEncoding: "br, gzip",
+			want:           "identity",
+		},
+		{
+			name:     "identity",
+			variants: variants,
+			want:     "identity",
+			wantVary: true,
+		},
+		{
+			name:           "brotli",
+			acceptEncoding: "gzip, deflate, br",
+			variants:       variants,
+			want:           "br",
+			wantEncoding:   jsEncodingBrotli,
+			wantVary:       true,
+		},
+		{
+			name:           "gzip",
+			acceptEncoding: "gzip",
+			variants:       variants,
+			want:           "gzip",
+			wantEncoding:   jsEncodingGzip,
+			wantVary:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				logger: slog.Default(),
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			if err := h.writeRender(w, r, rd, tt.variants); err != nil {
+				t.Fatalf("jsHandler.writeRender() error = %v", err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("jsHandler.writeRender() body = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("jsHandler.writeRender() encoding = %v, want %v", got, tt.wantEncoding)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("jsHandler.writeRender() vary = %v, want %v", got, tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestJSHandlerSetCacheHeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheHeaders bool
+		item         *jsCacheItem
+		want         string
+		wantAge      bool
+	}{
+		{
+			name: "disabled",
+			item: &jsCacheItem{
+				stored: time.Now(),
+				expire: time.Now().Add(time.Minute),
+			},
+		},
+		{
+			name:         "miss",
+			cacheHeaders: true,
+			want:         "MISS",
+		},
+		{
+			name:         "hit",
+			cacheHeaders: true,
+			item: &jsCacheItem{
+				stored: time.Now(),
+				expire: time.Now().Add(time.Minute),
+			},
+			want:    "HIT",
+			wantAge: true,
+		},
+		{
+			name:         "stale",
+			cacheHeaders: true,
+			item: &jsCacheItem{
+				stored: time.Now().Add(-2 * time.Minute),
+				expire: time.Now().Add(-time.Minute),
+			},
+			want:    "STALE",
+			wantAge: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					CacheHeaders: boolPtr(tt.cacheHeaders),
+				},
+			}
+			w := httptest.NewRecorder()
+			h.setCacheHeader(w, tt.item, "/test")
+			if got := w.Header().Get("X-Cache"); got != tt.want {
+				t.Errorf("setCacheHeader() X-Cache = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get("Age") != ""; got != tt.wantAge {
+				t.Errorf("setCacheHeader() Age = %v, want %v", got, tt.wantAge)
+			}
+			if got := w.Header().Get("X-Cache-Key-Hash") != ""; got != tt.cacheHeaders {
+				t.Errorf("setCacheHeader() X-Cache-Key-Hash = %v, want %v", got, tt.cacheHeaders)
+			}
+		})
+	}
+}
+
+func TestJSHandlerCacheTTL(t *testing.T) {
+	rw := render.NewRenderWriter()
+	rw.WriteHeader(http.StatusOK)
+	ok := rw.Render()
+	rw = render.NewRenderWriter()
+	rw.WriteHeader(http.StatusNotFound)
+	notFound := rw.Render()
+	rw = render.NewRenderWriter()
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	unavailable := rw.Render()
+
+	tests := []struct {
+		name   string
+		path   string
+		render render.Render
+		want   time.Duration
+	}{
+		{
+			name:   "default",
+			path:   "/product",
+			render: ok,
+			want:   60 * time.Second,
+		},
+		{
+			name:   "not found",
+			path:   "/product",
+			render: notFound,
+			want:   5 * time.Second,
+		},
+		{
+			name:   "error",
+			path:   "/product",
+			render: unavailable,
+			want:   60 * time.Second,
+		},
+		{
+			name:   "rule",
+			path:   "/",
+			render: ok,
+			want:   3600 * time.Second,
+		},
+		{
+			name:   "rule not found",
+			path:   "/",
+			render: notFound,
+			want:   5 * time.Second,
+		},
+		{
+			name:   "rule no cache",
+			path:   "/legal/terms",
+			render: ok,
+			want:   0,
+		},
+		{
+			name:   "rule no cache not found",
+			path:   "/legal/terms",
+			render: notFound,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					CacheTTL:         intPtr(60),
+					CacheNotFoundTTL: intPtr(5),
+					CacheRules: []JSCacheRule{
+						{
+							Path: "^/$",
+							TTL:  intPtr(3600),
+						},
+						{
+							Path: "^/legal/",
+							TTL:  intPtr(0),
+						},
+					},
+				},
+				cacheRuleSet: pattern.NewSet([]*regexp.Regexp{
+					regexp.MustCompile("^/$"),
+					regexp.MustCompile("^/legal/"),
+				}),
+			}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if got := h.cacheTTL(r, tt.render); got != tt.want {
+				t.Errorf("jsHandler.cacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSHandlerPurge(t *testing.T) {
+	type fields struct {
+		config *jsHandlerConfig
+		logger *slog.Logger
+		cache  Cache
+	}
+	type args struct {
+		names []string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantHit bool
+	}{
+		{
+			name: "resource changed",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Cache: boolPtr(true),
+				},
+				logger: slog.Default(),
+				cache:  newCache(1, cachePolicyLRU),
+			},
+			args: args{
+				names: []string{"test"},
+			},
+		},
+		{
+			name: "other resource changed",
+			fields: fields{
+				config: &jsHandlerConfig{
+					Cache: boolPtr(true),
+				},
+				logger: slog.Default(),
+				cache:  newCache(1, cachePolicyLRU),
+			},
+			args: args{
+				names: []string{"other"},
+			},
+			wantHit: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				cache:  tt.fields.cache,
+			}
+			h.cache.Set("/test", &jsCacheItem{
+				resources: []string{"test"},
+				expire:    time.Now().Add(time.Minute),
+			}, 0)
+			h.purge(tt.args.names)
+			if hit := h.cache.Get("/test") != nil; hit != tt.wantHit {
+				t.Errorf("jsHandler.purge() hit = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestJSHandlerVMHeaders(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Country", "FR")
+	req.Header.Set("X-Geo-City", "Paris")
+	req.Header.Set("X-Geo-Region", "IDF")
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    http.Header
+	}{
+		{
+			name: "default deny",
+			want: http.Header{},
+		},
+		{
+			name:    "allowlist",
+			headers: []string{"x-country", "X-Missing", "X-Geo-*"},
+			want: http.Header{
+				"X-Country":    []string{"FR"},
+				"X-Geo-City":   []string{"Paris"},
+				"X-Geo-Region": []string{"IDF"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					VMHeaders: tt.headers,
+				},
+			}
+			if got := h.vmHeaders(req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jsHandler.vmHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSHandlerHealVM(t *testing.T) {
+	stencil, err := vmCompileStencil("test", []byte(`(() => { server.response.render("test"); })();`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := stencil.acquire()
+	defer other.release()
+
+	h := &jsHandler{
+		config: &jsHandlerConfig{
+			VMStencil: boolPtr(false),
+		},
+		logger:    slog.Default(),
+		stencil:   stencil,
+		muBundle:  new(sync.RWMutex),
+		vmCrashes: new(atomic.Uint64),
+	}
+
+	h.healVM("test", nil)
+	if h.stencil != stencil {
+		t.Errorf("jsHandler.healVM() stencil dropped without stencil")
+	}
+	h.healVM("test", stencil)
+	if h.stencil != nil {
+		t.Errorf("jsHandler.healVM() stencil = %v, want %v", h.stencil, nil)
+	}
+	h.healVM("test", other)
+	if got := h.vmCrashes.Load(); got != 3 {
+		t.Errorf("jsHandler.healVM() crashes = %v, want %v", got, 3)
+	}
+}
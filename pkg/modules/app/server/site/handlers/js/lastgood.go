@@ -0,0 +1,54 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"net/http"
+
+	"github.com/bhuisgen/neon/pkg/trace"
+)
+
+const (
+	jsHeaderWarning string = "Warning"
+
+	jsLastGoodWarning string = `111 - "Revalidation Failed"`
+)
+
+// storeLastGood retains the given cached item as the last known good render of its key if it is successful.
+//
+// The last known good renders are kept apart from the cache so that they outlive the expiration and the purge of the
+// cached renders.
+func (h *jsHandler) storeLastGood(key string, item *jsCacheItem, size int) {
+	if h.lastGood == nil || item.render.StatusCode() != http.StatusOK {
+		return
+	}
+
+	h.lastGood.Set(key, item, size)
+}
+
+// serveLastGood serves the last known good render of the given key with a warning header, and returns false if there
+// is none.
+func (h *jsHandler) serveLastGood(w http.ResponseWriter, r *http.Request, key string) bool {
+	if h.lastGood == nil {
+		return false
+	}
+	item, ok := h.lastGood.Get(key).(*jsCacheItem)
+	if !ok {
+		return false
+	}
+
+	fallbacks := h.fallbacks.Add(1)
+	h.logger.Warn("Render failed, serving last known good render", "url", r.URL.Path, "stored", item.stored,
+		"fallbacks", fallbacks)
+	trace.FromContext(r.Context()).Add(string(jsModuleID), "Last known good render served", "key", key,
+		"stored", item.stored)
+
+	h.setCacheHeader(w, item, key)
+	w.Header().Set(jsHeaderWarning, jsLastGoodWarning)
+
+	if err := h.writeRender(w, r, item.render, item.variants); err != nil {
+		h.logger.Error("Failed to write render", "err", err)
+	}
+
+	return true
+}
@@ -0,0 +1,112 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+func TestJSHandlerServeHTTPLastGood(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastGood    bool
+		wantStatus  int
+		wantWarning bool
+	}{
+		{
+			name:       "default",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:        "last good",
+			lastGood:    true,
+			wantStatus:  http.StatusOK,
+			wantWarning: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Index:            "test/default/index.html",
+					IndexTemplate:    boolPtr(false),
+					Bundle:           "test/default/bundle.js",
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(0),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(true),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CachePrivate:     boolPtr(false),
+					CacheLastGood:    boolPtr(tt.lastGood),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					StateJSON:        boolPtr(false),
+					CacheCompress:    boolPtr(false),
+				},
+				logger:    slog.Default(),
+				muIndex:   &sync.RWMutex{},
+				muBundle:  &sync.RWMutex{},
+				vms:       make(chan struct{}, 1),
+				fallbacks: new(atomic.Uint64),
+				rwPool:    render.NewRenderWriterPool(),
+				cache:     newCache(10, cachePolicyLRU),
+				site:      testJSHandlerServerSite{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			}
+			if tt.lastGood {
+				h.lastGood = newCache(10, cachePolicyLRU)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("jsHandler.ServeHTTP() status = %v, want %v", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+
+			// the cached render expires and the new bundle fails
+			h.cache = newCache(10, cachePolicyLRU)
+			h.config.Bundle = "test/invalid/bundle.js"
+			h.bundleInfo = nil
+
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("jsHandler.ServeHTTP() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get(jsHeaderWarning); (got != "") != tt.wantWarning {
+				t.Errorf("jsHandler.ServeHTTP() warning = %v, want %v", got, tt.wantWarning)
+			}
+			if tt.wantWarning && w.Body.String() != body {
+				t.Errorf("jsHandler.ServeHTTP() body = %v, want %v", w.Body.String(), body)
+			}
+			if got := h.fallbacks.Load(); (got > 0) != tt.wantWarning {
+				t.Errorf("jsHandler.ServeHTTP() fallbacks = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// vmSlots implements the slots limiting the number of concurrent VMs.
+type vmSlots interface {
+	acquire(ctx context.Context) error
+	release()
+}
+
+// vmSemaphore implements the slots of the VMs owned by a single handler.
+type vmSemaphore chan struct{}
+
+// acquire waits for a free slot.
+func (s vmSemaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot.
+func (s vmSemaphore) release() {
+	<-s
+}
+
+var _ vmSlots = (vmSemaphore)(nil)
+
+// vmPool implements a pool of VMs shared by several handlers.
+//
+// The free slots are granted in turn to the handlers waiting for a VM, each handler being limited to its own maximum
+// number of concurrent VMs, so that a busy server cannot starve the others.
+type vmPool struct {
+	size    int
+	running int
+	members []*vmPoolMember
+	next    int
+	mu      *sync.Mutex
+}
+
+// vmPoolMember implements a handler sharing a pool.
+type vmPoolMember struct {
+	pool    *vmPool
+	max     int
+	running int
+	waiters []chan bool
+	left    bool
+}
+
+var (
+	vmPools   = make(map[string]*vmPool)
+	muVMPools sync.Mutex
+
+	errVMPoolLeft = errors.New("pool left")
+)
+
+// vmPoolGet returns the shared pool of the given name, created or grown to the given size.
+//
+// The size of a pool is the largest size requested by its handlers.
+func vmPoolGet(name string, size int) *vmPool {
+	muVMPools.Lock()
+	defer muVMPools.Unlock()
+
+	p, ok := vmPools[name]
+	if !ok {
+		p = newVMPool(size)
+		vmPools[name] = p
+		return p
+	}
+
+	p.mu.Lock()
+	if size > p.size {
+		p.size = size
+		p.dispatch()
+	}
+	p.mu.Unlock()
+
+	return p
+}
+
+// newVMPool creates a new pool.
+func newVMPool(size int) *vmPool {
+	return &vmPool{
+		size: size,
+		mu:   &sync.Mutex{},
+	}
+}
+
+// join adds a new member allowed to run the given maximum number of concurrent VMs.
+func (p *vmPool) join(max int) *vmPoolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := &vmPoolMember{
+		pool: p,
+		max:  max,
+	}
+	p.members = append(p.members, m)
+
+	return m
+}
+
+// dispatch grants the free slots to the waiting members in turn.
+//
+// The pool lock must be held.
+func (p *vmPool) dispatch() {
+	for p.running < p.size && len(p.members) > 0 {
+		granted := false
+		for i := 0; i < len(p.members); i++ {
+			index := (p.next + i) % len(p.members)
+			m := p.members[index]
+			if len(m.waiters) == 0 || m.running >= m.max {
+				continue
+			}
+
+			ch := m.waiters[0]
+			m.waiters = m.waiters[1:]
+			m.running++
+			p.running++
+			p.next = index + 1
+			ch <- true
+
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// leave removes the member from its pool. The pending acquisitions of the member fail.
+func (m *vmPoolMember) leave() {
+	p := m.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for index, member := range p.members {
+		if member == m {
+			p.members = append(p.members[:index], p.members[index+1:]...)
+			break
+		}
+	}
+	if p.next > len(p.members) {
+		p.next = 0
+	}
+	for _, ch := range m.waiters {
+		ch <- false
+	}
+	m.waiters = nil
+	m.left = true
+}
+
+// acquire waits for a slot of the pool.
+func (m *vmPoolMember) acquire(ctx context.Context) error {
+	p := m.pool
+	p.mu.Lock()
+	if m.left {
+		p.mu.Unlock()
+		return errVMPoolLeft
+	}
+	ch := make(chan bool, 1)
+	m.waiters = append(m.waiters, ch)
+	p.dispatch()
+	p.mu.Unlock()
+
+	select {
+	case ok := <-ch:
+		if !ok {
+			return errVMPoolLeft
+		}
+		return nil
+
+	case <-ctx.Done():
+		p.mu.Lock()
+		for index, waiter := range m.waiters {
+			if waiter == ch {
+				m.waiters = append(m.waiters[:index], m.waiters[index+1:]...)
+				p.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		p.mu.Unlock()
+
+		if ok := <-ch; ok {
+			m.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees a slot of the pool.
+func (m *vmPoolMember) release() {
+	p := m.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m.running--
+	p.running--
+	p.dispatch()
+}
+
+var _ vmSlots = (*vmPoolMember)(nil)
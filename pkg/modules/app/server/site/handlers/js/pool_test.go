@@ -0,0 +1,115 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVMSemaphore(t *testing.T) {
+	s := make(vmSemaphore, 1)
+	if err := s.acquire(context.Background()); err != nil {
+		t.Errorf("vmSemaphore.acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.acquire(ctx); err == nil {
+		t.Errorf("vmSemaphore.acquire() error = %v, wantErr %v", err, true)
+	}
+
+	s.release()
+	if err := s.acquire(context.Background()); err != nil {
+		t.Errorf("vmSemaphore.acquire() error = %v", err)
+	}
+}
+
+func TestVMPoolGet(t *testing.T) {
+	p := vmPoolGet("test", 2)
+	if got := vmPoolGet("test", 4); got != p || got.size != 4 {
+		t.Errorf("vmPoolGet() = %p (size %d), want %p (size %d)", got, got.size, p, 4)
+	}
+	if got := vmPoolGet("test", 1); got.size != 4 {
+		t.Errorf("vmPoolGet() size = %v, want %v", got.size, 4)
+	}
+}
+
+func TestVMPoolFairShare(t *testing.T) {
+	p := newVMPool(2)
+	a := p.join(2)
+	b := p.join(2)
+
+	for i := 0; i < 2; i++ {
+		if err := a.acquire(context.Background()); err != nil {
+			t.Fatalf("vmPoolMember.acquire() error = %v", err)
+		}
+	}
+
+	granted := make(chan string, 2)
+	go func() {
+		if err := a.acquire(context.Background()); err == nil {
+			granted <- "a"
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		if err := b.acquire(context.Background()); err == nil {
+			granted <- "b"
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	a.release()
+	if got := <-granted; got != "b" {
+		t.Errorf("vmPool.dispatch() granted = %v, want %v", got, "b")
+	}
+	a.release()
+	if got := <-granted; got != "a" {
+		t.Errorf("vmPool.dispatch() granted = %v, want %v", got, "a")
+	}
+}
+
+func TestVMPoolMemberMax(t *testing.T) {
+	p := newVMPool(2)
+	m := p.join(1)
+	if err := m.acquire(context.Background()); err != nil {
+		t.Fatalf("vmPoolMember.acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.acquire(ctx); err == nil {
+		t.Errorf("vmPoolMember.acquire() error = %v, wantErr %v", err, true)
+	}
+	if len(m.waiters) != 0 || p.running != 1 {
+		t.Errorf("vmPoolMember.acquire() waiters = %v, running = %v, want %v, %v", len(m.waiters), p.running, 0, 1)
+	}
+}
+
+func TestVMPoolMemberLeave(t *testing.T) {
+	p := newVMPool(1)
+	a := p.join(1)
+	b := p.join(1)
+	if err := a.acquire(context.Background()); err != nil {
+		t.Fatalf("vmPoolMember.acquire() error = %v", err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		errs <- b.acquire(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	b.leave()
+	if err := <-errs; err == nil {
+		t.Errorf("vmPoolMember.acquire() error = %v, wantErr %v", err, true)
+	}
+	if err := b.acquire(context.Background()); err == nil {
+		t.Errorf("vmPoolMember.acquire() error = %v, wantErr %v", err, true)
+	}
+	if len(p.members) != 1 {
+		t.Errorf("vmPoolMember.leave() members = %v, want %v", len(p.members), 1)
+	}
+}
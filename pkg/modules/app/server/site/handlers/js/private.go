@@ -0,0 +1,58 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+const (
+	jsHeaderAuthorization string = "Authorization"
+	jsHeaderCookie        string = "Cookie"
+	jsHeaderSetCookie     string = "Set-Cookie"
+)
+
+// cacheUser returns the hash of the credentials of the request, or false if its render does not depend on the user.
+//
+// The Authorization header is always a credential, the cookies only if they are exposed to the VM.
+func (h *jsHandler) cacheUser(r *http.Request) (string, bool) {
+	credentials := r.Header.Values(jsHeaderAuthorization)
+	if h.vmHeaderAllowed(jsHeaderCookie) {
+		credentials = append(credentials, r.Header.Values(jsHeaderCookie)...)
+	}
+	if len(credentials) == 0 {
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(credentials, "\n")))
+
+	return hex.EncodeToString(sum[:]), true
+}
+
+// vmHeaderAllowed returns true if the given request header is exposed to the VM.
+func (h *jsHandler) vmHeaderAllowed(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, allowed := range h.config.VMHeaders {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(name, http.CanonicalHeaderKey(prefix)) {
+				return true
+			}
+			continue
+		}
+		if http.CanonicalHeaderKey(allowed) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setsCookie returns true if the given render sets cookies.
+func setsCookie(render render.Render) bool {
+	return len(render.Header().Values(jsHeaderSetCookie)) > 0
+}
@@ -0,0 +1,170 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+func TestJSHandlerCacheUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		vmHeaders []string
+		header    http.Header
+		want      bool
+	}{
+		{
+			name:   "anonymous",
+			header: http.Header{"Cookie": {"session=test"}},
+		},
+		{
+			name:   "authorization",
+			header: http.Header{"Authorization": {"Bearer test"}},
+			want:   true,
+		},
+		{
+			name:      "cookie exposed",
+			vmHeaders: []string{"cookie"},
+			header:    http.Header{"Cookie": {"session=test"}},
+			want:      true,
+		},
+		{
+			name:      "cookie exposed by prefix",
+			vmHeaders: []string{"Co*"},
+			header:    http.Header{"Cookie": {"session=test"}},
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					VMHeaders: tt.vmHeaders,
+				},
+			}
+			user, got := h.cacheUser(&http.Request{Header: tt.header})
+			if got != tt.want || (user != "") != tt.want {
+				t.Errorf("jsHandler.cacheUser() = %v, %v, want %v", user, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSHandlerServeHTTPPrivate(t *testing.T) {
+	tests := []struct {
+		name          string
+		bundle        string
+		cachePrivate  bool
+		header        http.Header
+		wantEntries   int
+		wantKeyPrefix string
+	}{
+		{
+			name:        "default",
+			bundle:      "test/default/bundle.js",
+			wantEntries: 1,
+		},
+		{
+			name:   "authorization",
+			bundle: "test/default/bundle.js",
+			header: http.Header{"Authorization": {"Bearer test"}},
+		},
+		{
+			name:          "authorization private",
+			bundle:        "test/default/bundle.js",
+			cachePrivate:  true,
+			header:        http.Header{"Authorization": {"Bearer test"}},
+			wantEntries:   1,
+			wantKeyPrefix: "user:",
+		},
+		{
+			name:   "set cookie",
+			bundle: "test/cookie/bundle.js",
+		},
+		{
+			name:         "set cookie private",
+			bundle:       "test/cookie/bundle.js",
+			cachePrivate: true,
+		},
+		{
+			name:          "set cookie authorization private",
+			bundle:        "test/cookie/bundle.js",
+			cachePrivate:  true,
+			header:        http.Header{"Authorization": {"Bearer test"}},
+			wantEntries:   1,
+			wantKeyPrefix: "user:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Index:            "test/default/index.html",
+					IndexTemplate:    boolPtr(false),
+					Bundle:           tt.bundle,
+					Env:              stringPtr("test"),
+					Container:        stringPtr("root"),
+					State:            stringPtr("state"),
+					MaxVMs:           intPtr(0),
+					VMMaxHeapSize:    intPtr(0),
+					VMStackSize:      intPtr(0),
+					VMTimeout:        intPtr(1000),
+					VMGracePeriod:    intPtr(0),
+					VMCPUBudget:      intPtr(0),
+					VMStencil:        boolPtr(false),
+					Cache:            boolPtr(true),
+					CacheTTL:         intPtr(60),
+					CacheHeaders:     boolPtr(false),
+					CachePrivate:     boolPtr(tt.cachePrivate),
+					CacheNotFoundTTL: intPtr(5),
+					CacheMaxItems:    intPtr(100),
+					CacheVaryDevice:  boolPtr(false),
+					CacheQuery:       boolPtr(false),
+					StateJSON:        boolPtr(false),
+					CacheCompress:    boolPtr(false),
+				},
+				logger:   slog.Default(),
+				muIndex:  &sync.RWMutex{},
+				muBundle: &sync.RWMutex{},
+				vms:      make(chan struct{}, 1),
+				rwPool:   render.NewRenderWriterPool(),
+				cache:    newCache(10, cachePolicyLRU),
+				site:     testJSHandlerServerSite{},
+				osReadFile: func(name string) ([]byte, error) {
+					return os.ReadFile(name)
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return os.Stat(name)
+				},
+			}
+			r := httptest.NewRequest(http.MethodGet, "/test", nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got := h.cache.Stats().Entries; got != tt.wantEntries {
+				t.Errorf("jsHandler.ServeHTTP() cache entries = %v, want %v", got, tt.wantEntries)
+			}
+			if tt.wantKeyPrefix != "" {
+				var found bool
+				h.cache.RemoveFunc(func(key string, value any) bool {
+					found = found || strings.HasPrefix(key, tt.wantKeyPrefix)
+					return false
+				})
+				if !found {
+					t.Errorf("jsHandler.ServeHTTP() cache key prefix %v not found", tt.wantKeyPrefix)
+				}
+			}
+		})
+	}
+}
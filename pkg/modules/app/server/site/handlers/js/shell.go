@@ -0,0 +1,130 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// jsShell implements a pre-parsed HTML shell made of the static segments surrounding the insertion points.
+type jsShell struct {
+	segments [][]byte
+	points   []jsShellPoint
+}
+
+// jsShellPoint represents an insertion point of the HTML shell.
+type jsShellPoint int
+
+const (
+	jsShellPointContainer jsShellPoint = iota
+	jsShellPointHead
+	jsShellPointBody
+)
+
+// jsShellMarkers contains the markers of the insertion points. The HTML parser replaces the NUL characters of the
+// input so the markers can't appear in the rendered document.
+var jsShellMarkers = map[jsShellPoint][]byte{
+	jsShellPointContainer: []byte("\x00neon:container\x00"),
+	jsShellPointHead:      []byte("\x00neon:head\x00"),
+	jsShellPointBody:      []byte("\x00neon:body\x00"),
+}
+
+// newJSShell parses the given HTML document and returns its shell with the insertion points at the end of the
+// container element, the head element and the body element.
+func newJSShell(r io.Reader, container string) (*jsShell, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %v", err)
+	}
+
+	mark := func(point jsShellPoint, match func(*html.Node) bool) {
+		var find func(*html.Node) bool
+		find = func(n *html.Node) bool {
+			if n.Type == html.ElementNode && match(n) {
+				n.AppendChild(&html.Node{
+					Type: html.RawNode,
+					Data: string(jsShellMarkers[point]),
+				})
+				return true
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if find(c) {
+					return true
+				}
+			}
+			return false
+		}
+		find(doc)
+	}
+	mark(jsShellPointContainer, func(n *html.Node) bool {
+		if n.Data != "div" {
+			return false
+		}
+		for _, a := range n.Attr {
+			if a.Key == "id" && a.Val == container {
+				return true
+			}
+		}
+		return false
+	})
+	mark(jsShellPointHead, func(n *html.Node) bool {
+		return n.Data == "head"
+	})
+	mark(jsShellPointBody, func(n *html.Node) bool {
+		return n.Data == "body"
+	})
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("render html: %v", err)
+	}
+
+	s := &jsShell{}
+	data := buf.Bytes()
+	for {
+		index, point := -1, jsShellPoint(0)
+		for p, marker := range jsShellMarkers {
+			if i := bytes.Index(data, marker); i >= 0 && (index < 0 || i < index) {
+				index, point = i, p
+			}
+		}
+		if index < 0 {
+			break
+		}
+		s.segments = append(s.segments, data[:index])
+		s.points = append(s.points, point)
+		data = data[index+len(jsShellMarkers[point]):]
+	}
+	s.segments = append(s.segments, data)
+
+	return s, nil
+}
+
+// has returns true if the shell has the given insertion point.
+func (s *jsShell) has(point jsShellPoint) bool {
+	for _, p := range s.points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes the shell segments, calling the given function at each insertion point.
+func (s *jsShell) write(w io.Writer, insert func(point jsShellPoint) error) error {
+	for index, segment := range s.segments {
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+		if index < len(s.points) {
+			if err := insert(s.points[index]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
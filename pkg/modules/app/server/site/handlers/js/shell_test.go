@@ -0,0 +1,105 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewJSShell(t *testing.T) {
+	type args struct {
+		html      string
+		container string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantSegments []string
+		wantPoints   []jsShellPoint
+	}{
+		{
+			name: "default",
+			args: args{
+				html:      `<html><head><title>test</title></head><body><div id="root"></div><p>test</p></body></html>`,
+				container: "root",
+			},
+			wantSegments: []string{
+				`<html><head><title>test</title>`,
+				`</head><body><div id="root">`,
+				`</div><p>test</p>`,
+				`</body></html>`,
+			},
+			wantPoints: []jsShellPoint{jsShellPointHead, jsShellPointContainer, jsShellPointBody},
+		},
+		{
+			name: "missing container",
+			args: args{
+				html:      `<p>test</p>`,
+				container: "root",
+			},
+			wantSegments: []string{
+				`<html><head>`,
+				`</head><body><p>test</p>`,
+				`</body></html>`,
+			},
+			wantPoints: []jsShellPoint{jsShellPointHead, jsShellPointBody},
+		},
+		{
+			name: "nul characters",
+			args: args{
+				html:      "<html><head></head><body><div id=\"root\">\x00neon:body\x00</div></body></html>",
+				container: "root",
+			},
+			wantPoints: []jsShellPoint{jsShellPointHead, jsShellPointContainer, jsShellPointBody},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newJSShell(strings.NewReader(tt.args.html), tt.args.container)
+			if err != nil {
+				t.Fatalf("newJSShell() error = %v", err)
+			}
+			if tt.wantSegments != nil {
+				segments := make([]string, 0, len(got.segments))
+				for _, segment := range got.segments {
+					segments = append(segments, string(segment))
+				}
+				if !reflect.DeepEqual(segments, tt.wantSegments) {
+					t.Errorf("newJSShell() segments = %q, want %q", segments, tt.wantSegments)
+				}
+			}
+			if !reflect.DeepEqual(got.points, tt.wantPoints) {
+				t.Errorf("newJSShell() points = %v, want %v", got.points, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestJSShellWrite(t *testing.T) {
+	s, err := newJSShell(strings.NewReader(`<html><head></head><body><div id="root"></div></body></html>`), "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = s.write(&buf, func(point jsShellPoint) error {
+		switch point {
+		case jsShellPointContainer:
+			buf.WriteString("container")
+		case jsShellPointHead:
+			buf.WriteString("head")
+		case jsShellPointBody:
+			buf.WriteString("body")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("jsShell.write() error = %v", err)
+	}
+	want := `<html><head>head</head><body><div id="root">container</div>body</body></html>`
+	if got := buf.String(); got != want {
+		t.Errorf("jsShell.write() = %v, want %v", got, want)
+	}
+}
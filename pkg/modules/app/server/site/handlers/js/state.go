@@ -0,0 +1,67 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"net/http"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+// jsStateResponse implements the response of a state request.
+type jsStateResponse struct {
+	Status int                   `json:"status"`
+	State  map[string]jsResource `json:"state"`
+}
+
+const (
+	jsStateContentType  string = "application/json"
+	jsRenderContentType string = "text/html"
+)
+
+// isStateRequest returns true if the request asks for the state only instead of the render, either with its accepted
+// media types preferring JSON over HTML or the configured query parameter.
+func (h *jsHandler) isStateRequest(r *http.Request) bool {
+	if !*h.config.StateJSON {
+		return false
+	}
+	if h.config.StateJSONParam != nil && r.URL.Query().Has(*h.config.StateJSONParam) {
+		return true
+	}
+
+	return render.NegotiateMediaType(r, jsRenderContentType, jsStateContentType) == jsStateContentType
+}
+
+// serveState writes the exported state of the rules matching the request and the status of the render, so that the
+// client-side navigations reuse the data layer without executing the bundle.
+func (h *jsHandler) serveState(w http.ResponseWriter, r *http.Request) {
+	state := h.resolveState(r)
+
+	response := jsStateResponse{
+		Status: http.StatusOK,
+		State:  state.client,
+	}
+	if !state.valid {
+		response.Status = http.StatusServiceUnavailable
+	}
+	if response.State == nil {
+		response.State = map[string]jsResource{}
+	}
+	buf, err := h.jsonMarshal(&response)
+	if err != nil {
+		h.serveError(w, http.StatusServiceUnavailable)
+
+		h.logger.Error("Failed to marshal state", "url", r.URL.Path, "err", err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", jsStateContentType)
+	w.WriteHeader(response.Status)
+	if _, err := w.Write(buf); err != nil {
+		h.logger.Error("Failed to write state", "err", err)
+		return
+	}
+
+	h.logger.Debug("State completed", "url", r.URL.Path, "status", response.Status)
+}
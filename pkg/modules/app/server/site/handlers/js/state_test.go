@@ -0,0 +1,194 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/match"
+	"github.com/bhuisgen/neon/pkg/pattern"
+)
+
+type testJSHandlerStateServerSite struct {
+	testJSHandlerServerSite
+	store core.Store
+}
+
+func (s testJSHandlerStateServerSite) Store() core.Store {
+	return s.store
+}
+
+var _ core.ServerSite = (*testJSHandlerStateServerSite)(nil)
+
+type testJSHandlerStore struct {
+	resources map[string]*core.Resource
+}
+
+func (s testJSHandlerStore) LoadResource(name string) (*core.Resource, error) {
+	resource, ok := s.resources[name]
+	if !ok {
+		return nil, errors.New("test error")
+	}
+	return resource, nil
+}
+
+func (s testJSHandlerStore) StoreResource(name string, resource *core.Resource) error {
+	return nil
+}
+
+var _ core.Store = (*testJSHandlerStore)(nil)
+
+func TestJSHandlerIsStateRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *jsHandlerConfig
+		target string
+		accept string
+		want   bool
+	}{
+		{
+			name: "disabled",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(false),
+			},
+			target: "/",
+			accept: "application/json",
+		},
+		{
+			name: "html",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(true),
+			},
+			target: "/",
+			accept: "text/html,application/xhtml+xml,*/*;q=0.8",
+		},
+		{
+			name: "accept",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(true),
+			},
+			target: "/",
+			accept: "text/plain, application/json; charset=utf-8",
+			want:   true,
+		},
+		{
+			name: "accept quality",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(true),
+			},
+			target: "/",
+			accept: "text/html;q=0.5, application/json;q=0.9",
+			want:   true,
+		},
+		{
+			name: "accept html preferred",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(true),
+			},
+			target: "/",
+			accept: "text/html, application/json;q=0.9",
+		},
+		{
+			name: "accept not acceptable",
+			config: &jsHandlerConfig{
+				StateJSON: boolPtr(true),
+			},
+			target: "/",
+			accept: "text/html;q=0, application/json;q=0",
+		},
+		{
+			name: "param",
+			config: &jsHandlerConfig{
+				StateJSON:      boolPtr(true),
+				StateJSONParam: stringPtr("__state"),
+			},
+			target: "/?__state",
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &jsHandler{
+				config: tt.config,
+			}
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := h.isStateRequest(r); got != tt.want {
+				t.Errorf("jsHandler.isStateRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSHandlerServeState(t *testing.T) {
+	tests := []struct {
+		name       string
+		resources  map[string]*core.Resource
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "default",
+			resources: map[string]*core.Resource{
+				"page-test": {Data: [][]byte{[]byte(`{"title":"test"}`)}},
+				"config":    {Data: [][]byte{[]byte(`{}`)}},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":200,"state":{"page":{"data":["{\"title\":\"test\"}"],"error":""}}}`,
+		},
+		{
+			name: "unknown resource",
+			resources: map[string]*core.Resource{
+				"config": {Data: [][]byte{[]byte(`{}`)}},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":503,"state":{"page":{"data":null,"error":"unknown resource"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := []JSRule{
+				{
+					Path: "^/(?P<slug>[^/]+)$",
+					State: []JSRuleStateEntry{
+						{Key: "config", Resource: "config"},
+						{Key: "page", Resource: "page-$slug", Export: boolPtr(true)},
+					},
+				},
+			}
+			regexps := []*regexp.Regexp{regexp.MustCompile(rules[0].Path)}
+			h := &jsHandler{
+				config: &jsHandlerConfig{
+					Rules: rules,
+				},
+				logger:      slog.Default(),
+				regexps:     regexps,
+				ruleSet:     pattern.NewSet(regexps),
+				constraints: []*match.Constraint{nil},
+				site: testJSHandlerStateServerSite{
+					store: testJSHandlerStore{resources: tt.resources},
+				},
+				jsonMarshal: jsJsonMarshal,
+			}
+			w := httptest.NewRecorder()
+			h.serveState(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("jsHandler.serveState() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("jsHandler.serveState() body = %v, want %v", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Type"); got != jsStateContentType {
+				t.Errorf("jsHandler.serveState() content type = %v, want %v", got, jsStateContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+(() => { server.response.setHeader("Set-Cookie", "session=test"); server.response.render("<p>test</p>", 200); })();
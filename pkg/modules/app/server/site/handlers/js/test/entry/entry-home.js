@@ -0,0 +1 @@
+(() => { server.response.render("<p>entry</p>", 200); })();
@@ -0,0 +1 @@
+(() => { server.response.notFound(); server.response.render("<p>not found</p>"); })();
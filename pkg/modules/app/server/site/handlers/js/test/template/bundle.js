@@ -0,0 +1 @@
+(() => { server.response.render("<p>test</p>", 200); })();
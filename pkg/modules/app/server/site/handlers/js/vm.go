@@ -1,3 +1,5 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
@@ -7,17 +9,21 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bhuisgen/gomonkey"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/timing"
 )
 
 // VM
 type VM interface {
 	Execute(config vmConfig, name string, code []byte, timeout time.Duration) (*vmResult, error)
+	Stats() vmStats
 }
 
 // vm implements a VM.
@@ -26,12 +32,15 @@ type vm struct {
 	logger  *slog.Logger
 	config  *vmConfig
 	data    *vmData
+	stats   vmStats
 }
 
 // vmOptions implements the VM options.
 type vmOptions struct {
 	heapMaxBytes uint
 	stackSize    uint
+	gracePeriod  time.Duration
+	cpuBudget    time.Duration
 }
 
 // vmOptionFunc represents a vm option function.
@@ -40,15 +49,20 @@ type vmOptionFunc func(v *vm) error
 // vmConfig implements the VM execution configuration.
 type vmConfig struct {
 	Env     string
-	State   *[]byte
+	State   map[string]jsResource
 	Request *http.Request
+	Headers http.Header
+	Device  string
 	Site    core.ServerSite
+	Stencil *vmStencil
 }
 
 // vmData implements the VM execution data.
 type vmData struct {
 	render         *[]byte
 	status         *int
+	notFound       *bool
+	done           *bool
 	redirect       *bool
 	redirectURL    *string
 	redirectStatus *int
@@ -57,10 +71,17 @@ type vmData struct {
 	metas          *domElementList
 	links          *domElementList
 	scripts        *domElementList
+	start          time.Time
+	marks          map[string]time.Duration
+	timings        []timing.Timing
 }
 
 const (
 	vmLoggerID string = "app.server.site.handler.js.vm"
+
+	vmCPUBudgetCheckInterval time.Duration = 10 * time.Millisecond
+	vmOutOfMemoryMessage     string        = "out of memory"
+	vmTimingsMax             int           = 100
 )
 
 // newVM creates a new VM.
@@ -93,6 +114,23 @@ func WithStackSize(size uint) vmOptionFunc {
 	}
 }
 
+// WithGracePeriod sets the maximum duration to wait for the pending jobs once
+// the response has been rendered.
+func WithGracePeriod(d time.Duration) vmOptionFunc {
+	return func(v *vm) error {
+		v.options.gracePeriod = d
+		return nil
+	}
+}
+
+// WithCPUBudget sets the maximum CPU time of an execution.
+func WithCPUBudget(d time.Duration) vmOptionFunc {
+	return func(v *vm) error {
+		v.options.cpuBudget = d
+		return nil
+	}
+}
+
 // configure configures the VM.
 func (v *vm) configure(context *gomonkey.Context, config *vmConfig) error {
 	global, err := context.Global()
@@ -101,6 +139,10 @@ func (v *vm) configure(context *gomonkey.Context, config *vmConfig) error {
 	}
 	defer global.Release()
 
+	if err := v.apiGlobals(context, global); err != nil {
+		return err
+	}
+
 	server, err := context.DefineObject(global, "server", 0)
 	if err != nil {
 		return err
@@ -119,6 +161,9 @@ func (v *vm) configure(context *gomonkey.Context, config *vmConfig) error {
 	if err := v.apiResponse(context, server); err != nil {
 		return err
 	}
+	if err := v.apiBuildInfo(context, server); err != nil {
+		return err
+	}
 
 	process, err := context.DefineObject(global, "process", 0)
 	if err != nil {
@@ -140,81 +185,321 @@ func (v *vm) configure(context *gomonkey.Context, config *vmConfig) error {
 	}
 
 	v.config = config
-	v.data = &vmData{}
+	v.data = &vmData{
+		start: time.Now(),
+	}
 
 	return nil
 }
 
+// vmCompile compiles the given code in a new context without executing it.
+func vmCompile(name string, code []byte) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		ctx, err := gomonkey.NewContext()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer ctx.Destroy()
+
+		script, err := ctx.CompileScript(name, code)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		script.Release()
+
+		errCh <- nil
+	}()
+
+	return <-errCh
+}
+
+// vmStencil implements a bundle compiled once and shared between the VMs.
+//
+// The stencil is reference counted as it is released by the last VM using it once the bundle has been replaced.
+type vmStencil struct {
+	stencil *gomonkey.Stencil
+	refs    atomic.Int64
+}
+
+// vmCompileStencil compiles the given code to a stencil owned by the caller.
+func vmCompileStencil(name string, code []byte) (*vmStencil, error) {
+	stencilCh := make(chan *gomonkey.Stencil, 1)
+	errCh := make(chan error, 1)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		ctx, err := gomonkey.NewFrontendContext()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer ctx.Destroy()
+
+		stencil, err := ctx.CompileScriptToStencil(name, code)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		stencilCh <- stencil
+	}()
+
+	select {
+	case stencil := <-stencilCh:
+		s := &vmStencil{
+			stencil: stencil,
+		}
+		s.refs.Store(1)
+		return s, nil
+	case err := <-errCh:
+		return nil, err
+	}
+}
+
+// acquire adds a reference to the stencil.
+func (s *vmStencil) acquire() *vmStencil {
+	if s == nil {
+		return nil
+	}
+	s.refs.Add(1)
+
+	return s
+}
+
+// release removes a reference to the stencil and releases it if it was the last one.
+func (s *vmStencil) release() {
+	if s == nil {
+		return
+	}
+	if s.refs.Add(-1) == 0 {
+		s.stencil.Release()
+	}
+}
+
 // Executes executes the VM.
+//
+// The code is executed from the stencil of the configuration if any, without being compiled again.
 func (v *vm) Execute(config vmConfig, name string, code []byte, timeout time.Duration) (*vmResult, error) {
 	defer v.timeTrack("Execute()", time.Now())
 
 	ctxCh := make(chan *gomonkey.Context, 1)
 	doneCh := make(chan struct{}, 1)
 	errCh := make(chan error, 1)
+	stopCh := make(chan struct{})
+
+	var tid int
+	var cpuStart time.Duration
+	var cpuErr error
+
+	start := time.Now()
+	v.stats = vmStats{}
 
 	go func() {
 		runtime.LockOSThread()
 		defer runtime.UnlockOSThread()
 
+		var ctxSent bool
+		tid = vmThreadID()
+		cpuStart, cpuErr = vmThreadCPUTime(tid)
+		finish := func(err error) {
+			if cpuErr == nil {
+				if cpu, err := vmThreadCPUTime(tid); err == nil {
+					v.stats.CPUTime = cpu - cpuStart
+				}
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+			doneCh <- struct{}{}
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				if !ctxSent {
+					ctxCh <- nil
+				}
+				finish(&vmCrashError{err: fmt.Errorf("panic: %v", r)})
+			}
+		}()
+
 		ctx, err := gomonkey.NewContext(
 			gomonkey.WithHeapMaxBytes(v.options.heapMaxBytes),
 			gomonkey.WithNativeStackSize(v.options.stackSize),
 		)
 		if err != nil {
-			errCh <- err
+			ctxSent = true
+			ctxCh <- nil
+			finish(&vmCrashError{err: err})
 			return
 		}
 		defer ctx.Destroy()
+		ctxSent = true
 		ctxCh <- ctx
 
 		if err := v.configure(ctx, &config); err != nil {
-			errCh <- err
+			finish(err)
 			return
 		}
 
-		script, err := ctx.CompileScript(name, code)
-		if err != nil {
-			errCh <- err
-			return
+		var result *gomonkey.Value
+		if config.Stencil != nil {
+			result, err = ctx.ExecuteScriptFromStencil(config.Stencil.stencil)
+		} else {
+			var script *gomonkey.Script
+			script, err = ctx.CompileScript(name, code)
+			if err != nil {
+				finish(err)
+				return
+			}
+			result, err = ctx.ExecuteScript(script)
 		}
-		result, err := ctx.ExecuteScript(script)
 		if err != nil {
-			errCh <- err
+			finish(err)
 			return
 		}
 		result.Release()
-		doneCh <- struct{}{}
+		finish(v.runJobs(ctx, stopCh))
 	}()
 
 	ctx := <-ctxCh
 
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var cpuCh <-chan time.Time
+	if v.options.cpuBudget > 0 && cpuErr == nil {
+		ticker := time.NewTicker(vmCPUBudgetCheckInterval)
+		defer ticker.Stop()
+		cpuCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-doneCh:
+			v.stats.Duration = time.Since(start)
+			return newVMResult(v.data), nil
+
+		case err := <-errCh:
+			v.stats.Duration = time.Since(start)
+			v.logError(err)
+			if vmCrashed(err) {
+				v.stats.Crashed = true
+				return nil, errVMCrash
+			}
+			return nil, errVMExecute
+
+		case <-cpuCh:
+			cpu, err := vmThreadCPUTime(tid)
+			if err != nil || cpu-cpuStart <= v.options.cpuBudget {
+				continue
+			}
+			v.interrupt(ctx, stopCh, doneCh, errCh)
+			v.stats.Duration = time.Since(start)
+			return nil, errVMExecuteCPUBudget
+
+		case <-timer.C:
+			v.interrupt(ctx, stopCh, doneCh, errCh)
+			v.stats.Duration = time.Since(start)
+			return nil, errVMExecuteTimeout
+		}
+	}
+}
+
+// Stats returns the statistics of the last execution.
+func (v *vm) Stats() vmStats {
+	return v.stats
+}
+
+// interrupt interrupts the execution and waits for its termination.
+func (v *vm) interrupt(ctx *gomonkey.Context, stopCh chan struct{}, doneCh <-chan struct{}, errCh <-chan error) {
+	close(stopCh)
+	if ctx != nil {
+		ctx.RequestInterrupt()
+	}
+
 	select {
 	case <-doneCh:
-		return newVMResult(v.data), nil
-
 	case err := <-errCh:
-		var jsError *gomonkey.JSError
-		if errors.As(err, &jsError) {
-			v.logger.Error("Failed to execute VM", "err", "JS error",
-				"message", jsError.Message, "filename", jsError.Filename, "line", jsError.LineNumber)
-		} else {
-			v.logger.Error("Failed to execute VM", "err", err)
+		v.logError(err)
+	}
+}
+
+// vmCrashed returns true if the given execution error leaves the engine unusable, either because the context could
+// not be created, the execution panicked or the engine ran out of memory.
+func vmCrashed(err error) bool {
+	var crashError *vmCrashError
+	if errors.As(err, &crashError) {
+		return true
+	}
+	var jsError *gomonkey.JSError
+	if errors.As(err, &jsError) {
+		return strings.Contains(strings.ToLower(jsError.Message), vmOutOfMemoryMessage)
+	}
+
+	return strings.Contains(strings.ToLower(err.Error()), vmOutOfMemoryMessage)
+}
+
+// logError logs an execution error.
+func (v *vm) logError(err error) {
+	var jsError *gomonkey.JSError
+	if errors.As(err, &jsError) {
+		v.logger.Error("Failed to execute VM", "err", "JS error",
+			"message", jsError.Message, "filename", jsError.Filename, "line", jsError.LineNumber)
+	} else {
+		v.logger.Error("Failed to execute VM", "err", err)
+	}
+}
+
+// runJobs runs the pending jobs of the event loop.
+//
+// The jobs are run until the event loop is empty or the response is marked as
+// done. Once the response is rendered, the pending timers are only waited for
+// the grace period.
+func (v *vm) runJobs(ctx *gomonkey.Context, stopCh <-chan struct{}) error {
+	global, err := ctx.Global()
+	if err != nil {
+		return err
+	}
+	defer global.Release()
+
+	var renderTime time.Time
+	for {
+		result, err := ctx.CallFunctionName("__neonRunJobs", global)
+		if err != nil {
+			return err
 		}
-		return nil, errVMExecute
+		next := time.Duration(result.ToInt32()) * time.Millisecond
+		result.Release()
 
-	case <-time.After(timeout):
-		ctx.RequestInterrupt()
+		if next < 0 || v.data.done != nil && *v.data.done {
+			return nil
+		}
+		if v.data.render != nil || v.data.redirect != nil {
+			if renderTime.IsZero() {
+				renderTime = time.Now()
+			}
+			remaining := v.options.gracePeriod - time.Since(renderTime)
+			if remaining <= 0 {
+				return nil
+			}
+			if next > remaining {
+				next = remaining
+			}
+		}
 
-		err := <-errCh
-		var jsError *gomonkey.JSError
-		if errors.As(err, &jsError) {
-			v.logger.Error("Failed to execute VM", "err", "JS error",
-				"message", jsError.Message, "filename", jsError.Filename, "line", jsError.LineNumber)
-		} else {
-			v.logger.Error("Failed to execute VM", "err", err)
+		select {
+		case <-time.After(next):
+		case <-stopCh:
+			return errors.New("execution stopped")
 		}
-		return nil, errVMExecuteTimeout
 	}
 }
 
@@ -230,6 +515,7 @@ var _ VM = (*vm)(nil)
 type vmResult struct {
 	Render         *[]byte
 	Status         *int
+	NotFound       *bool
 	Redirect       *bool
 	RedirectURL    *string
 	RedirectStatus *int
@@ -238,6 +524,14 @@ type vmResult struct {
 	Metas          *domElementList
 	Links          *domElementList
 	Scripts        *domElementList
+	Timings        []timing.Timing
+}
+
+// vmStats implements the statistics of a VM execution.
+type vmStats struct {
+	Duration time.Duration
+	CPUTime  time.Duration
+	Crashed  bool
 }
 
 // newVMResult creates a new VM result.
@@ -245,6 +539,7 @@ func newVMResult(d *vmData) *vmResult {
 	return &vmResult{
 		Render:         d.render,
 		Status:         d.status,
+		NotFound:       d.notFound,
 		Redirect:       d.redirect,
 		RedirectURL:    d.redirectURL,
 		RedirectStatus: d.redirectStatus,
@@ -253,6 +548,7 @@ func newVMResult(d *vmData) *vmResult {
 		Metas:          d.metas,
 		Links:          d.links,
 		Scripts:        d.scripts,
+		Timings:        d.timings,
 	}
 }
 
@@ -273,10 +569,27 @@ func (e vmError) Error() string {
 	return e.message
 }
 
+// vmCrashError implements the error of an execution which crashed the engine.
+type vmCrashError struct {
+	err error
+}
+
+// Error returns the error message.
+func (e *vmCrashError) Error() string {
+	return fmt.Sprintf("engine crash: %v", e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *vmCrashError) Unwrap() error {
+	return e.err
+}
+
 var (
-	errVMBuild          = newVMError("build error")
-	errVMExecute        = newVMError("execution error")
-	errVMExecuteTimeout = newVMError("execution timeout")
+	errVMBuild            = newVMError("build error")
+	errVMExecute          = newVMError("execution error")
+	errVMExecuteTimeout   = newVMError("execution timeout")
+	errVMExecuteCPUBudget = newVMError("execution CPU budget exceeded")
+	errVMCrash            = newVMError("execution crash")
 )
 
 var _ error = (*vmError)(nil)
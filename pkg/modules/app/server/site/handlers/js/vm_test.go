@@ -1,6 +1,9 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
+	"errors"
 	"log/slog"
 	"os"
 	"testing"
@@ -38,11 +41,98 @@ func TestNewVM(t *testing.T) {
 	}
 }
 
+func TestVMCompile(t *testing.T) {
+	type args struct {
+		name string
+		code []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				name: "test",
+				code: []byte(`(() => { throw new Error("not executed"); })();`),
+			},
+		},
+		{
+			name: "error syntax",
+			args: args{
+				name: "test",
+				code: []byte(`(() => {`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := vmCompile(tt.args.name, tt.args.code); (err != nil) != tt.wantErr {
+				t.Errorf("vmCompile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVMCompileStencil(t *testing.T) {
+	type args struct {
+		name string
+		code []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				name: "test",
+				code: []byte(`(() => { throw new Error("not executed"); })();`),
+			},
+		},
+		{
+			name: "error syntax",
+			args: args{
+				name: "test",
+				code: []byte(`(() => {`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vmCompileStencil(tt.args.name, tt.args.code)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("vmCompileStencil() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if refs := got.refs.Load(); refs != 1 {
+				t.Errorf("vmCompileStencil() refs = %v, want %v", refs, 1)
+			}
+			got.acquire().release()
+			got.release()
+		})
+	}
+}
+
 func TestVMExecute(t *testing.T) {
+	stencil, err := vmCompileStencil("test", []byte(`(() => { server.response.render("test"); })();`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stencil.release()
+
 	type fields struct {
-		config *vmConfig
-		logger *slog.Logger
-		data   *vmData
+		options vmOptions
+		config  *vmConfig
+		logger  *slog.Logger
+		data    *vmData
 	}
 	type args struct {
 		config  vmConfig
@@ -71,6 +161,54 @@ func TestVMExecute(t *testing.T) {
 				timeout: 4 * time.Second,
 			},
 		},
+		{
+			name: "stencil",
+			fields: fields{
+				logger: slog.Default(),
+				data:   &vmData{},
+			},
+			args: args{
+				config: vmConfig{
+					Env:     "test",
+					Stencil: stencil,
+				},
+				name:    "test",
+				timeout: 4 * time.Second,
+			},
+		},
+		{
+			name: "async render",
+			fields: fields{
+				logger: slog.Default(),
+				data:   &vmData{},
+			},
+			args: args{
+				config: vmConfig{
+					Env: "test",
+				},
+				name:    "test",
+				code:    []byte(`(() => { setTimeout(() => server.response.render("test"), 10); })();`),
+				timeout: 4 * time.Second,
+			},
+		},
+		{
+			name: "grace period",
+			fields: fields{
+				options: vmOptions{
+					gracePeriod: 10 * time.Millisecond,
+				},
+				logger: slog.Default(),
+				data:   &vmData{},
+			},
+			args: args{
+				config: vmConfig{
+					Env: "test",
+				},
+				name:    "test",
+				code:    []byte(`(() => { setInterval(() => {}, 1); server.response.render("test"); })();`),
+				timeout: 4 * time.Second,
+			},
+		},
 		{
 			name: "script error",
 			fields: fields{
@@ -103,13 +241,49 @@ func TestVMExecute(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "cpu budget",
+			fields: fields{
+				options: vmOptions{
+					cpuBudget: 10 * time.Millisecond,
+				},
+				logger: slog.Default(),
+				data:   &vmData{},
+			},
+			args: args{
+				config: vmConfig{
+					Env: "test",
+				},
+				name:    "test",
+				code:    []byte(`(() => { for(;;) {} })();`),
+				timeout: 4 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "timeout pending timer",
+			fields: fields{
+				logger: slog.Default(),
+				data:   &vmData{},
+			},
+			args: args{
+				config: vmConfig{
+					Env: "test",
+				},
+				name:    "test",
+				code:    []byte(`(() => { setInterval(() => {}, 1); })();`),
+				timeout: 10 * time.Millisecond,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			v := &vm{
-				config: tt.fields.config,
-				logger: tt.fields.logger,
-				data:   tt.fields.data,
+				options: tt.fields.options,
+				config:  tt.fields.config,
+				logger:  tt.fields.logger,
+				data:    tt.fields.data,
 			}
 			_, err := v.Execute(tt.args.config, tt.args.name, tt.args.code, tt.args.timeout)
 			if (err != nil) != tt.wantErr {
@@ -118,3 +292,37 @@ func TestVMExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestVMCrashed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "crash",
+			err:  &vmCrashError{err: errors.New("new context")},
+			want: true,
+		},
+		{
+			name: "out of memory",
+			err:  &gomonkey.JSError{Message: "out of memory"},
+			want: true,
+		},
+		{
+			name: "JS error",
+			err:  &gomonkey.JSError{Message: "ReferenceError: test is not defined"},
+		},
+		{
+			name: "error",
+			err:  errors.New("test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vmCrashed(tt.err); got != tt.want {
+				t.Errorf("vmCrashed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,245 @@
+//go:build cgo && !nojs
+
 package js
 
 import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/bhuisgen/gomonkey"
+
+	"github.com/bhuisgen/neon/pkg/buildinfo"
+	"github.com/bhuisgen/neon/pkg/include"
+	"github.com/bhuisgen/neon/pkg/timing"
 )
 
+//go:embed vmapi.js
+var vmAPIGlobals []byte
+
+// vmURLDefaultPorts contains the default ports of the special URL schemes.
+var vmURLDefaultPorts = map[string]string{
+	"ftp":   "21",
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// vmURLParts implements the parts of a parsed URL.
+type vmURLParts struct {
+	Protocol string `json:"protocol"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Hostname string `json:"hostname"`
+	Port     string `json:"port"`
+	Pathname string `json:"pathname"`
+	Search   string `json:"search"`
+	Hash     string `json:"hash"`
+	Origin   string `json:"origin"`
+	Opaque   bool   `json:"opaque"`
+}
+
+// apiGlobals adds the Web standard globals (crypto, TextEncoder, TextDecoder, URL and URLSearchParams) and the server
+// state global.
+func (v *vm) apiGlobals(ctx *gomonkey.Context, global *gomonkey.Object) error {
+	natives, err := ctx.DefineObject(global, "__neon", 0)
+	if err != nil {
+		return err
+	}
+	defer natives.Release()
+
+	randomBytes := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsNumber() {
+			return nil, errors.New("invalid arguments")
+		}
+		n := args[0].ToInt32()
+		if n < 0 || n > 65536 {
+			return nil, errors.New("invalid length")
+		}
+		buf := make([]byte, n)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		return gomonkey.NewValueString(ctx, hex.EncodeToString(buf))
+	}
+	if err := ctx.DefineFunction(natives, "randomBytes", randomBytes, 1, 0); err != nil {
+		return err
+	}
+
+	parseURL := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() {
+			return nil, errors.New("invalid arguments")
+		}
+		u, err := url.Parse(args[0].ToString())
+		if err != nil {
+			return nil, err
+		}
+		if len(args) >= 2 && args[1].IsString() {
+			base, err := url.Parse(args[1].ToString())
+			if err != nil {
+				return nil, err
+			}
+			if base.Scheme == "" {
+				return nil, errors.New("invalid base")
+			}
+			u = base.ResolveReference(u)
+		}
+		if u.Scheme == "" {
+			return nil, errors.New("invalid url")
+		}
+		data, err := json.Marshal(newVMURLParts(u))
+		if err != nil {
+			return nil, err
+		}
+		return gomonkey.NewValueString(ctx, string(data))
+	}
+	if err := ctx.DefineFunction(natives, "parseURL", parseURL, 2, 0); err != nil {
+		return err
+	}
+
+	stateKeys := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		keys := make([]string, 0, len(v.config.State))
+		for key := range v.config.State {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]*gomonkey.Value, 0, len(keys))
+		defer func() {
+			for _, item := range items {
+				item.Release()
+			}
+		}()
+		for _, key := range keys {
+			item, err := gomonkey.NewValueString(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		arr, err := gomonkey.NewArrayObject(ctx, items...)
+		if err != nil {
+			return nil, err
+		}
+		return arr.AsValue(), nil
+	}
+	if err := ctx.DefineFunction(natives, "stateKeys", stateKeys, 0, 0); err != nil {
+		return err
+	}
+
+	stateResource := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() {
+			return nil, errors.New("invalid arguments")
+		}
+		resource, ok := v.config.State[args[0].ToString()]
+		if !ok {
+			return gomonkey.NewValueUndefined(ctx)
+		}
+		return newVMResource(ctx, resource)
+	}
+	if err := ctx.DefineFunction(natives, "stateResource", stateResource, 1, 0); err != nil {
+		return err
+	}
+
+	result, err := ctx.Evaluate(vmAPIGlobals)
+	if err != nil {
+		return err
+	}
+	result.Release()
+
+	return nil
+}
+
+// newVMResource returns a new object holding the given resource, its data being passed as strings without any JSON
+// round trip.
+func newVMResource(ctx *gomonkey.Context, resource jsResource) (*gomonkey.Value, error) {
+	items := make([]*gomonkey.Value, 0, len(resource.Data))
+	defer func() {
+		for _, item := range items {
+			item.Release()
+		}
+	}()
+	for _, data := range resource.Data {
+		item, err := gomonkey.NewValueString(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	data, err := gomonkey.NewArrayObject(ctx, items...)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Release()
+	resourceError, err := gomonkey.NewValueString(ctx, resource.Error)
+	if err != nil {
+		return nil, err
+	}
+	defer resourceError.Release()
+
+	o, err := gomonkey.NewObject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Set("data", data.AsValue()); err != nil {
+		o.Release()
+		return nil, err
+	}
+	if err := o.Set("error", resourceError); err != nil {
+		o.Release()
+		return nil, err
+	}
+	return o.AsValue(), nil
+}
+
+// newVMURLParts returns the parts of the given URL.
+func newVMURLParts(u *url.URL) vmURLParts {
+	p := vmURLParts{
+		Protocol: u.Scheme + ":",
+		Hostname: u.Hostname(),
+		Port:     u.Port(),
+		Pathname: u.EscapedPath(),
+		Origin:   "null",
+		Opaque:   u.Opaque != "",
+	}
+	if strings.Contains(p.Hostname, ":") {
+		p.Hostname = "[" + p.Hostname + "]"
+	}
+	if u.User != nil {
+		p.Username = u.User.Username()
+		p.Password, _ = u.User.Password()
+	}
+	if port, ok := vmURLDefaultPorts[u.Scheme]; ok {
+		if p.Port == port {
+			p.Port = ""
+		}
+		if p.Pathname == "" {
+			p.Pathname = "/"
+		}
+		p.Origin = p.Protocol + "//" + p.Hostname
+		if p.Port != "" {
+			p.Origin += ":" + p.Port
+		}
+	}
+	if p.Opaque {
+		p.Pathname = u.Opaque
+	}
+	if u.RawQuery != "" {
+		p.Search = "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		p.Hash = "#" + u.EscapedFragment()
+	}
+	return p
+}
+
 // apiSite adds the site API.
 func (v *vm) apiSite(ctx *gomonkey.Context, server *gomonkey.Object) error {
 	site, err := ctx.DefineObject(server, "site", 0)
@@ -81,6 +313,44 @@ func (v *vm) apiSite(ctx *gomonkey.Context, server *gomonkey.Object) error {
 	return nil
 }
 
+// apiBuildInfo adds the build information API.
+func (v *vm) apiBuildInfo(ctx *gomonkey.Context, server *gomonkey.Object) error {
+	buildInfo := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		info := buildinfo.Get()
+		o, err := gomonkey.NewObject(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, item := range map[string]string{
+			"name":          info.Name,
+			"version":       info.Version,
+			"commit":        info.Commit,
+			"date":          info.Date,
+			"goVersion":     info.GoVersion,
+			"engineVersion": info.EngineVersion,
+			"platform":      info.Platform,
+		} {
+			value, err := gomonkey.NewValueString(ctx, item)
+			if err != nil {
+				o.Release()
+				return nil, err
+			}
+			err = o.Set(key, value)
+			value.Release()
+			if err != nil {
+				o.Release()
+				return nil, err
+			}
+		}
+		return o.AsValue(), nil
+	}
+	if err := ctx.DefineFunction(server, "buildInfo", buildInfo, 0, 0); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // apiHandler add the handler API.
 func (v *vm) apiHandler(ctx *gomonkey.Context, server *gomonkey.Object) error {
 	handler, err := ctx.DefineObject(server, "handler", 0)
@@ -90,10 +360,23 @@ func (v *vm) apiHandler(ctx *gomonkey.Context, server *gomonkey.Object) error {
 	defer handler.Release()
 
 	state := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
-		o, err := gomonkey.JSONParse(ctx, string(*v.config.State))
+		o, err := gomonkey.NewObject(ctx)
 		if err != nil {
 			return nil, err
 		}
+		for key, resource := range v.config.State {
+			value, err := newVMResource(ctx, resource)
+			if err != nil {
+				o.Release()
+				return nil, err
+			}
+			err = o.Set(key, value)
+			value.Release()
+			if err != nil {
+				o.Release()
+				return nil, err
+			}
+		}
 		return o.AsValue(), nil
 	}
 	if err := ctx.DefineFunction(handler, "state", state, 0, 0); err != nil {
@@ -173,7 +456,10 @@ func (v *vm) apiRequest(ctx *gomonkey.Context, server *gomonkey.Object) error {
 	}
 
 	headers := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
-		headers := v.config.Request.Header
+		headers := v.config.Headers
+		if headers == nil {
+			headers = http.Header{}
+		}
 		data, err := json.Marshal(&headers)
 		if err != nil {
 			return nil, err
@@ -184,6 +470,27 @@ func (v *vm) apiRequest(ctx *gomonkey.Context, server *gomonkey.Object) error {
 		return err
 	}
 
+	header := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() {
+			return nil, errors.New("invalid arguments")
+		}
+		values := v.config.Headers.Values(args[0].ToString())
+		if len(values) == 0 {
+			return gomonkey.NewValueNull(ctx)
+		}
+		return gomonkey.NewValueString(ctx, values[0])
+	}
+	if err := ctx.DefineFunction(request, "header", header, 1, 0); err != nil {
+		return err
+	}
+
+	device := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		return gomonkey.NewValueString(ctx, v.config.Device)
+	}
+	if err := ctx.DefineFunction(request, "device", device, 0, 0); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -221,6 +528,28 @@ func (v *vm) apiResponse(ctx *gomonkey.Context, server *gomonkey.Object) error {
 		return err
 	}
 
+	notFound := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		notFound := true
+
+		v.data.notFound = &notFound
+
+		return nil, nil
+	}
+	if err := ctx.DefineFunction(response, "notFound", notFound, 0, 0); err != nil {
+		return err
+	}
+
+	done := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		done := true
+
+		v.data.done = &done
+
+		return nil, nil
+	}
+	if err := ctx.DefineFunction(response, "done", done, 0, 0); err != nil {
+		return err
+	}
+
 	redirect := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
 		if len(args) < 1 {
 			return nil, errors.New("invalid arguments")
@@ -353,7 +682,9 @@ func (v *vm) apiResponse(ctx *gomonkey.Context, server *gomonkey.Object) error {
 				return nil, nil
 			}
 			defer v.Release()
-			e.SetAttribute(k.String(), v.String())
+			if err := e.SetAttribute(k.String(), v.String()); err != nil {
+				return nil, err
+			}
 		}
 		if v.data.metas == nil {
 			v.data.metas = newDOMElementList()
@@ -429,7 +760,9 @@ func (v *vm) apiResponse(ctx *gomonkey.Context, server *gomonkey.Object) error {
 				return nil, nil
 			}
 			defer v.Release()
-			e.SetAttribute(k.String(), v.String())
+			if err := e.SetAttribute(k.String(), v.String()); err != nil {
+				return nil, err
+			}
 		}
 		if v.data.links == nil {
 			v.data.links = newDOMElementList()
@@ -505,7 +838,9 @@ func (v *vm) apiResponse(ctx *gomonkey.Context, server *gomonkey.Object) error {
 				return nil, nil
 			}
 			defer v.Release()
-			e.SetAttribute(k.String(), v.String())
+			if err := e.SetAttribute(k.String(), v.String()); err != nil {
+				return nil, err
+			}
 		}
 		if v.data.scripts == nil {
 			v.data.scripts = newDOMElementList()
@@ -518,5 +853,82 @@ func (v *vm) apiResponse(ctx *gomonkey.Context, server *gomonkey.Object) error {
 		return err
 	}
 
+	mark := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() || args[0].ToString() == "" {
+			return nil, errors.New("invalid arguments")
+		}
+		name := args[0].ToString()
+		elapsed := time.Since(v.data.start)
+
+		if v.data.marks == nil {
+			v.data.marks = make(map[string]time.Duration)
+		}
+		v.data.marks[name] = elapsed
+		v.addTiming(name, elapsed)
+
+		return nil, nil
+	}
+	if err := ctx.DefineFunction(response, "mark", mark, 0, 0); err != nil {
+		return err
+	}
+
+	measure := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() || args[0].ToString() == "" {
+			return nil, errors.New("invalid arguments")
+		}
+		name := args[0].ToString()
+		var start time.Duration
+		end := time.Since(v.data.start)
+		for index, value := range args[1:] {
+			if index > 1 || value.IsNullOrUndefined() {
+				continue
+			}
+			if !value.IsString() {
+				return nil, errors.New("invalid mark")
+			}
+			elapsed, ok := v.data.marks[value.ToString()]
+			if !ok {
+				return nil, errors.New("unknown mark")
+			}
+			if index == 0 {
+				start = elapsed
+			} else {
+				end = elapsed
+			}
+		}
+		v.addTiming(name, end-start)
+
+		return nil, nil
+	}
+	if err := ctx.DefineFunction(response, "measure", measure, 0, 0); err != nil {
+		return err
+	}
+
+	includeFn := func(args []*gomonkey.Value) (*gomonkey.Value, error) {
+		if len(args) < 1 || !args[0].IsString() {
+			return nil, errors.New("invalid arguments")
+		}
+		tag, err := include.Tag(args[0].ToString())
+		if err != nil {
+			return nil, err
+		}
+
+		return gomonkey.NewValueString(ctx, tag)
+	}
+	if err := ctx.DefineFunction(response, "include", includeFn, 0, 0); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// addTiming adds a timing of the execution, ignored once the maximum number of timings is reached.
+func (v *vm) addTiming(name string, d time.Duration) {
+	if len(v.data.timings) >= vmTimingsMax {
+		return
+	}
+	v.data.timings = append(v.data.timings, timing.Timing{
+		Name:     name,
+		Duration: d,
+	})
+}
@@ -0,0 +1,813 @@
+((global) => {
+  const natives = global.__neon;
+  delete global.__neon;
+
+  const randomBytes = natives.randomBytes;
+  const parseURL = natives.parseURL;
+  const stateKeys = natives.stateKeys;
+  const stateResource = natives.stateResource;
+
+  const microtasks = [];
+  let timers = [];
+  let timerID = 0;
+
+  const runMicrotasks = () => {
+    while (microtasks.length > 0) {
+      microtasks.shift()();
+    }
+  };
+
+  const addTimer = (callback, delay, args, repeat) => {
+    if (typeof callback !== "function") {
+      throw new TypeError("The callback must be a function");
+    }
+    delay = Math.max(0, Number(delay) || 0);
+    timerID++;
+    timers.push({ id: timerID, callback, args, due: Date.now() + delay, interval: repeat ? delay : null });
+    return timerID;
+  };
+
+  const clearTimer = (id) => {
+    timers = timers.filter((timer) => timer.id !== id);
+  };
+
+  const define = (name, value) => {
+    Object.defineProperty(global, name, { value, writable: true, configurable: true });
+  };
+
+  let serverState;
+  Object.defineProperty(global, "serverState", {
+    get() {
+      if (serverState === undefined) {
+        serverState = {};
+        for (const key of stateKeys()) {
+          Object.defineProperty(serverState, key, {
+            get() {
+              const value = stateResource(key);
+              Object.defineProperty(serverState, key, { value, writable: true, enumerable: true, configurable: true });
+              return value;
+            },
+            enumerable: true,
+            configurable: true,
+          });
+        }
+      }
+      return serverState;
+    },
+    configurable: true,
+  });
+
+  define("queueMicrotask", (callback) => {
+    if (typeof callback !== "function") {
+      throw new TypeError("The callback must be a function");
+    }
+    microtasks.push(callback);
+  });
+  define("setTimeout", (callback, delay, ...args) => addTimer(callback, delay, args, false));
+  define("setInterval", (callback, delay, ...args) => addTimer(callback, delay, args, true));
+  define("clearTimeout", clearTimer);
+  define("clearInterval", clearTimer);
+
+  // The engine has no job queue, so native promise reactions are replaced by
+  // an implementation running its reactions as microtasks of the event loop.
+  class Promise {
+    #state = "pending";
+    #value;
+    #reactions = [];
+
+    constructor(executor) {
+      if (typeof executor !== "function") {
+        throw new TypeError("Promise resolver is not a function");
+      }
+      const { resolve, reject } = this.#resolvingFunctions();
+      try {
+        executor(resolve, reject);
+      } catch (e) {
+        reject(e);
+      }
+    }
+
+    #resolvingFunctions() {
+      let called = false;
+      return {
+        resolve: (value) => {
+          if (!called) {
+            called = true;
+            this.#resolve(value);
+          }
+        },
+        reject: (reason) => {
+          if (!called) {
+            called = true;
+            this.#settle("rejected", reason);
+          }
+        },
+      };
+    }
+
+    #resolve(value) {
+      if (value === this) {
+        this.#settle("rejected", new TypeError("Chaining cycle detected for promise"));
+        return;
+      }
+      if (value !== null && (typeof value === "object" || typeof value === "function")) {
+        let then;
+        try {
+          then = value.then;
+        } catch (e) {
+          this.#settle("rejected", e);
+          return;
+        }
+        if (typeof then === "function") {
+          const { resolve, reject } = this.#resolvingFunctions();
+          microtasks.push(() => {
+            try {
+              then.call(value, resolve, reject);
+            } catch (e) {
+              reject(e);
+            }
+          });
+          return;
+        }
+      }
+      this.#settle("fulfilled", value);
+    }
+
+    #settle(state, value) {
+      if (this.#state !== "pending") {
+        return;
+      }
+      this.#state = state;
+      this.#value = value;
+      const reactions = this.#reactions;
+      this.#reactions = null;
+      for (const reaction of reactions) {
+        this.#schedule(reaction);
+      }
+    }
+
+    #schedule({ onFulfilled, onRejected, resolve, reject }) {
+      microtasks.push(() => {
+        const fulfilled = this.#state === "fulfilled";
+        const handler = fulfilled ? onFulfilled : onRejected;
+        if (typeof handler !== "function") {
+          (fulfilled ? resolve : reject)(this.#value);
+          return;
+        }
+        let result;
+        try {
+          result = handler(this.#value);
+        } catch (e) {
+          reject(e);
+          return;
+        }
+        resolve(result);
+      });
+    }
+
+    then(onFulfilled, onRejected) {
+      const { promise, resolve, reject } = Promise.withResolvers();
+      const reaction = { onFulfilled, onRejected, resolve, reject };
+      if (this.#state === "pending") {
+        this.#reactions.push(reaction);
+      } else {
+        this.#schedule(reaction);
+      }
+      return promise;
+    }
+
+    catch(onRejected) {
+      return this.then(undefined, onRejected);
+    }
+
+    finally(onFinally) {
+      if (typeof onFinally !== "function") {
+        return this.then(onFinally, onFinally);
+      }
+      return this.then(
+        (value) => Promise.resolve(onFinally()).then(() => value),
+        (reason) => Promise.resolve(onFinally()).then(() => {
+          throw reason;
+        }),
+      );
+    }
+
+    get [Symbol.toStringTag]() {
+      return "Promise";
+    }
+
+    static resolve(value) {
+      if (value instanceof Promise) {
+        return value;
+      }
+      return new Promise((resolve) => resolve(value));
+    }
+
+    static reject(reason) {
+      return new Promise((_, reject) => reject(reason));
+    }
+
+    static withResolvers() {
+      let resolve;
+      let reject;
+      const promise = new Promise((res, rej) => {
+        resolve = res;
+        reject = rej;
+      });
+      return { promise, resolve, reject };
+    }
+
+    static all(iterable) {
+      return new Promise((resolve, reject) => {
+        const items = Array.from(iterable);
+        const values = new Array(items.length);
+        let remaining = items.length;
+        if (remaining === 0) {
+          resolve(values);
+          return;
+        }
+        items.forEach((item, index) => {
+          Promise.resolve(item).then((value) => {
+            values[index] = value;
+            if (--remaining === 0) {
+              resolve(values);
+            }
+          }, reject);
+        });
+      });
+    }
+
+    static allSettled(iterable) {
+      return Promise.all(Array.from(iterable, (item) => Promise.resolve(item).then(
+        (value) => ({ status: "fulfilled", value }),
+        (reason) => ({ status: "rejected", reason }),
+      )));
+    }
+
+    static race(iterable) {
+      return new Promise((resolve, reject) => {
+        for (const item of iterable) {
+          Promise.resolve(item).then(resolve, reject);
+        }
+      });
+    }
+
+    static any(iterable) {
+      return new Promise((resolve, reject) => {
+        const items = Array.from(iterable);
+        const errors = new Array(items.length);
+        let remaining = items.length;
+        if (remaining === 0) {
+          reject(new AggregateError(errors, "All promises were rejected"));
+          return;
+        }
+        items.forEach((item, index) => {
+          Promise.resolve(item).then(resolve, (reason) => {
+            errors[index] = reason;
+            if (--remaining === 0) {
+              reject(new AggregateError(errors, "All promises were rejected"));
+            }
+          });
+        });
+      });
+    }
+  }
+  define("Promise", Promise);
+
+  // Runs the pending microtasks and the expired timers, and returns the delay
+  // in milliseconds before the next timer or -1 if the event loop is empty.
+  Object.defineProperty(global, "__neonRunJobs", {
+    value: () => {
+      runMicrotasks();
+      const now = Date.now();
+      const expired = timers.filter((timer) => timer.due <= now).sort((a, b) => a.due - b.due || a.id - b.id);
+      for (const timer of expired) {
+        if (!timers.includes(timer)) {
+          continue;
+        }
+        if (timer.interval === null) {
+          clearTimer(timer.id);
+        } else {
+          timer.due = now + Math.max(timer.interval, 1);
+        }
+        timer.callback(...timer.args);
+        runMicrotasks();
+      }
+      if (timers.length === 0) {
+        return -1;
+      }
+      return Math.max(0, Math.min(...timers.map((timer) => timer.due)) - Date.now());
+    },
+    configurable: true,
+  });
+
+  const hexToBytes = (hex) => {
+    const bytes = new Uint8Array(hex.length / 2);
+    for (let i = 0; i < bytes.length; i++) {
+      bytes[i] = parseInt(hex.substr(i * 2, 2), 16);
+    }
+    return bytes;
+  };
+
+  const toBytes = (input) => {
+    if (input === undefined) {
+      return new Uint8Array(0);
+    }
+    if (input instanceof ArrayBuffer) {
+      return new Uint8Array(input);
+    }
+    if (ArrayBuffer.isView(input)) {
+      return new Uint8Array(input.buffer, input.byteOffset, input.byteLength);
+    }
+    throw new TypeError("The provided value is not of type '(ArrayBuffer or ArrayBufferView)'");
+  };
+
+  if (typeof global.crypto === "undefined") {
+    const crypto = {
+      getRandomValues(array) {
+        if (!(array instanceof Int8Array || array instanceof Uint8Array || array instanceof Uint8ClampedArray ||
+          array instanceof Int16Array || array instanceof Uint16Array ||
+          array instanceof Int32Array || array instanceof Uint32Array ||
+          array instanceof BigInt64Array || array instanceof BigUint64Array)) {
+          throw new TypeError("The provided value is not an integer-typed array");
+        }
+        if (array.byteLength > 65536) {
+          throw new RangeError("The requested length exceeds 65536 bytes");
+        }
+        toBytes(array).set(hexToBytes(randomBytes(array.byteLength)));
+        return array;
+      },
+      randomUUID() {
+        const bytes = hexToBytes(randomBytes(16));
+        bytes[6] = (bytes[6] & 0x0f) | 0x40;
+        bytes[8] = (bytes[8] & 0x3f) | 0x80;
+        const hex = Array.from(bytes, (b) => b.toString(16).padStart(2, "0")).join("");
+        return `${hex.slice(0, 8)}-${hex.slice(8, 12)}-${hex.slice(12, 16)}-${hex.slice(16, 20)}-${hex.slice(20)}`;
+      },
+    };
+    Object.defineProperty(global, "crypto", { value: crypto, writable: true, configurable: true });
+  }
+
+  if (typeof global.TextEncoder === "undefined") {
+    class TextEncoder {
+      get encoding() {
+        return "utf-8";
+      }
+
+      encode(input = "") {
+        const str = String(input);
+        const bytes = [];
+        for (let i = 0; i < str.length; i++) {
+          let c = str.codePointAt(i);
+          if (c >= 0xd800 && c <= 0xdfff) {
+            c = 0xfffd;
+          }
+          if (c > 0xffff) {
+            i++;
+          }
+          if (c < 0x80) {
+            bytes.push(c);
+          } else if (c < 0x800) {
+            bytes.push(0xc0 | (c >> 6), 0x80 | (c & 0x3f));
+          } else if (c < 0x10000) {
+            bytes.push(0xe0 | (c >> 12), 0x80 | ((c >> 6) & 0x3f), 0x80 | (c & 0x3f));
+          } else {
+            bytes.push(0xf0 | (c >> 18), 0x80 | ((c >> 12) & 0x3f), 0x80 | ((c >> 6) & 0x3f), 0x80 | (c & 0x3f));
+          }
+        }
+        return new Uint8Array(bytes);
+      }
+
+      encodeInto(input, destination) {
+        const str = String(input);
+        let read = 0;
+        let written = 0;
+        for (const ch of str) {
+          const bytes = this.encode(ch);
+          if (written + bytes.length > destination.length) {
+            break;
+          }
+          destination.set(bytes, written);
+          read += ch.length;
+          written += bytes.length;
+        }
+        return { read, written };
+      }
+    }
+    Object.defineProperty(global, "TextEncoder", { value: TextEncoder, writable: true, configurable: true });
+  }
+
+  if (typeof global.TextDecoder === "undefined") {
+    class TextDecoder {
+      #fatal;
+      #ignoreBOM;
+      #pending = [];
+      #bomSeen = false;
+
+      constructor(label = "utf-8", options = {}) {
+        const encoding = String(label).trim().toLowerCase();
+        if (encoding !== "utf-8" && encoding !== "utf8" && encoding !== "unicode-1-1-utf-8") {
+          throw new RangeError(`The encoding label provided ('${label}') is invalid`);
+        }
+        this.#fatal = Boolean(options.fatal);
+        this.#ignoreBOM = Boolean(options.ignoreBOM);
+      }
+
+      get encoding() {
+        return "utf-8";
+      }
+
+      get fatal() {
+        return this.#fatal;
+      }
+
+      get ignoreBOM() {
+        return this.#ignoreBOM;
+      }
+
+      decode(input, options = {}) {
+        const stream = Boolean(options.stream);
+        const bytes = this.#pending.concat(Array.from(toBytes(input)));
+        this.#pending = [];
+
+        let out = "";
+        let i = 0;
+        const invalid = () => {
+          if (this.#fatal) {
+            this.#pending = [];
+            this.#bomSeen = false;
+            throw new TypeError("The encoded data was not valid");
+          }
+          out += "�";
+        };
+        while (i < bytes.length) {
+          const b = bytes[i];
+          let needed = 0;
+          let c = 0;
+          let lower = 0x80;
+          let upper = 0xbf;
+          if (b < 0x80) {
+            out += String.fromCharCode(b);
+            i++;
+            continue;
+          } else if (b >= 0xc2 && b <= 0xdf) {
+            needed = 1;
+            c = b & 0x1f;
+          } else if (b >= 0xe0 && b <= 0xef) {
+            needed = 2;
+            c = b & 0x0f;
+            if (b === 0xe0) {
+              lower = 0xa0;
+            } else if (b === 0xed) {
+              upper = 0x9f;
+            }
+          } else if (b >= 0xf0 && b <= 0xf4) {
+            needed = 3;
+            c = b & 0x07;
+            if (b === 0xf0) {
+              lower = 0x90;
+            } else if (b === 0xf4) {
+              upper = 0x8f;
+            }
+          } else {
+            invalid();
+            i++;
+            continue;
+          }
+          let j = 1;
+          for (; j <= needed && i + j < bytes.length; j++) {
+            const n = bytes[i + j];
+            if (n < lower || n > upper) {
+              break;
+            }
+            lower = 0x80;
+            upper = 0xbf;
+            c = (c << 6) | (n & 0x3f);
+          }
+          if (j <= needed) {
+            if (i + j >= bytes.length && stream) {
+              this.#pending = bytes.slice(i);
+              break;
+            }
+            invalid();
+            i += j;
+            continue;
+          }
+          out += String.fromCodePoint(c);
+          i += needed + 1;
+        }
+
+        if (!this.#ignoreBOM && !this.#bomSeen && out.length > 0) {
+          if (out.charCodeAt(0) === 0xfeff) {
+            out = out.slice(1);
+          }
+          this.#bomSeen = true;
+        }
+        if (!stream) {
+          this.#bomSeen = false;
+        }
+        return out;
+      }
+    }
+    Object.defineProperty(global, "TextDecoder", { value: TextDecoder, writable: true, configurable: true });
+  }
+
+  if (typeof global.URLSearchParams === "undefined") {
+    const encode = (s) => encodeURIComponent(s).replace(/%20/g, "+").replace(/[!'()~]/g,
+      (c) => "%" + c.charCodeAt(0).toString(16).toUpperCase());
+    const decode = (s) => {
+      try {
+        return decodeURIComponent(s.replace(/\+/g, " "));
+      } catch {
+        return s.replace(/\+/g, " ");
+      }
+    };
+
+    class URLSearchParams {
+      #list = [];
+      #url = null;
+
+      constructor(init = "") {
+        if (typeof init === "object" && init !== null) {
+          if (typeof init[Symbol.iterator] === "function") {
+            for (const pair of init) {
+              const entry = Array.from(pair);
+              if (entry.length !== 2) {
+                throw new TypeError("Each query pair must be an iterable [name, value] tuple");
+              }
+              this.#list.push([String(entry[0]), String(entry[1])]);
+            }
+          } else {
+            for (const key of Object.keys(init)) {
+              this.#list.push([key, String(init[key])]);
+            }
+          }
+        } else {
+          this.#parse(String(init));
+        }
+      }
+
+      static _attach(params, url) {
+        params.#url = url;
+      }
+
+      static _reset(params, search) {
+        params.#list = [];
+        params.#parse(search);
+      }
+
+      #parse(str) {
+        if (str.startsWith("?")) {
+          str = str.slice(1);
+        }
+        for (const part of str.split("&")) {
+          if (part === "") {
+            continue;
+          }
+          const index = part.indexOf("=");
+          const name = index >= 0 ? part.slice(0, index) : part;
+          const value = index >= 0 ? part.slice(index + 1) : "";
+          this.#list.push([decode(name), decode(value)]);
+        }
+      }
+
+      #update() {
+        if (this.#url !== null) {
+          URL._updateSearch(this.#url, this.toString());
+        }
+      }
+
+      get size() {
+        return this.#list.length;
+      }
+
+      append(name, value) {
+        this.#list.push([String(name), String(value)]);
+        this.#update();
+      }
+
+      delete(name, value) {
+        name = String(name);
+        this.#list = this.#list.filter(([k, v]) => k !== name || (value !== undefined && v !== String(value)));
+        this.#update();
+      }
+
+      get(name) {
+        name = String(name);
+        const entry = this.#list.find(([k]) => k === name);
+        return entry ? entry[1] : null;
+      }
+
+      getAll(name) {
+        name = String(name);
+        return this.#list.filter(([k]) => k === name).map(([, v]) => v);
+      }
+
+      has(name, value) {
+        name = String(name);
+        return this.#list.some(([k, v]) => k === name && (value === undefined || v === String(value)));
+      }
+
+      set(name, value) {
+        name = String(name);
+        value = String(value);
+        const index = this.#list.findIndex(([k]) => k === name);
+        if (index < 0) {
+          this.#list.push([name, value]);
+        } else {
+          this.#list[index][1] = value;
+          this.#list = this.#list.filter(([k], i) => k !== name || i === index);
+        }
+        this.#update();
+      }
+
+      sort() {
+        this.#list.sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0));
+        this.#update();
+      }
+
+      forEach(callback, thisArg) {
+        for (const [k, v] of this.#list) {
+          callback.call(thisArg, v, k, this);
+        }
+      }
+
+      *keys() {
+        for (const [k] of this.#list) {
+          yield k;
+        }
+      }
+
+      *values() {
+        for (const [, v] of this.#list) {
+          yield v;
+        }
+      }
+
+      *entries() {
+        for (const [k, v] of this.#list) {
+          yield [k, v];
+        }
+      }
+
+      [Symbol.iterator]() {
+        return this.entries();
+      }
+
+      toString() {
+        return this.#list.map(([k, v]) => `${encode(k)}=${encode(v)}`).join("&");
+      }
+    }
+    Object.defineProperty(global, "URLSearchParams", { value: URLSearchParams, writable: true, configurable: true });
+  }
+
+  if (typeof global.URL === "undefined") {
+    const parse = (input, base) => {
+      try {
+        return JSON.parse(base === undefined ? parseURL(String(input)) : parseURL(String(input), String(base)));
+      } catch {
+        throw new TypeError(`Invalid URL: ${input}`);
+      }
+    };
+
+    class URL {
+      #parts;
+      #searchParams;
+
+      constructor(url, base) {
+        this.#parts = parse(url, base);
+        this.#searchParams = new URLSearchParams(this.#parts.search);
+        URLSearchParams._attach(this.#searchParams, this);
+      }
+
+      static canParse(url, base) {
+        try {
+          parse(url, base);
+          return true;
+        } catch {
+          return false;
+        }
+      }
+
+      static _updateSearch(url, search) {
+        url.#parts = parse(url.#build({ search: search === "" ? "" : `?${search}` }));
+      }
+
+      #build(changes) {
+        const p = Object.assign({}, this.#parts, changes);
+        const auth = p.username !== "" ? `${p.username}${p.password !== "" ? `:${p.password}` : ""}@` : "";
+        const host = p.port !== "" ? `${p.hostname}:${p.port}` : p.hostname;
+        const authority = p.opaque ? "" : `//${auth}${host}`;
+        return `${p.protocol}${authority}${p.pathname}${p.search}${p.hash}`;
+      }
+
+      #set(changes) {
+        this.#parts = parse(this.#build(changes));
+        URLSearchParams._reset(this.#searchParams, this.#parts.search);
+      }
+
+      get href() {
+        return this.#build({});
+      }
+
+      set href(value) {
+        this.#parts = parse(value);
+        URLSearchParams._reset(this.#searchParams, this.#parts.search);
+      }
+
+      get origin() {
+        return this.#parts.origin;
+      }
+
+      get protocol() {
+        return this.#parts.protocol;
+      }
+
+      set protocol(value) {
+        value = String(value);
+        this.#set({ protocol: value.endsWith(":") ? value : `${value}:` });
+      }
+
+      get username() {
+        return this.#parts.username;
+      }
+
+      set username(value) {
+        this.#set({ username: encodeURIComponent(String(value)) });
+      }
+
+      get password() {
+        return this.#parts.password;
+      }
+
+      set password(value) {
+        this.#set({ password: encodeURIComponent(String(value)) });
+      }
+
+      get host() {
+        return this.#parts.port !== "" ? `${this.#parts.hostname}:${this.#parts.port}` : this.#parts.hostname;
+      }
+
+      set host(value) {
+        const [hostname, port = ""] = String(value).split(":");
+        this.#set({ hostname, port });
+      }
+
+      get hostname() {
+        return this.#parts.hostname;
+      }
+
+      set hostname(value) {
+        this.#set({ hostname: String(value) });
+      }
+
+      get port() {
+        return this.#parts.port;
+      }
+
+      set port(value) {
+        this.#set({ port: String(value) });
+      }
+
+      get pathname() {
+        return this.#parts.pathname;
+      }
+
+      set pathname(value) {
+        value = String(value);
+        this.#set({ pathname: value.startsWith("/") || this.#parts.opaque ? value : `/${value}` });
+      }
+
+      get search() {
+        return this.#parts.search;
+      }
+
+      set search(value) {
+        value = String(value);
+        this.#set({ search: value === "" || value.startsWith("?") ? value : `?${value}` });
+      }
+
+      get searchParams() {
+        return this.#searchParams;
+      }
+
+      get hash() {
+        return this.#parts.hash;
+      }
+
+      set hash(value) {
+        value = String(value);
+        this.#set({ hash: value === "" || value.startsWith("#") ? value : `#${value}` });
+      }
+
+      toString() {
+        return this.href;
+      }
+
+      toJSON() {
+        return this.href;
+      }
+    }
+    Object.defineProperty(global, "URL", { value: URL, writable: true, configurable: true });
+  }
+})(globalThis);
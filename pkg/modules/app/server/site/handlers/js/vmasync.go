@@ -0,0 +1,262 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"fmt"
+)
+
+// vmRegexpKeywords contains the keywords after which a slash starts a regular expression literal.
+var vmRegexpKeywords = map[string]bool{
+	"await": true, "case": true, "delete": true, "do": true, "else": true, "in": true, "instanceof": true,
+	"new": true, "of": true, "return": true, "throw": true, "typeof": true, "void": true, "yield": true,
+}
+
+// vmCheckAsync checks that the given code does not use native async functions.
+//
+// The VM runs the jobs of the polyfilled promises only, and the engine crashes the process as soon as a native async
+// function awaits a value or its promise is resolved. The bundles must therefore be transpiled to generators or
+// promise chains, which is detected here by looking for the async functions and the await expressions outside of the
+// comments, strings and regular expressions.
+func vmCheckAsync(code []byte) error {
+	s := &vmAsyncScanner{
+		code:    code,
+		line:    1,
+		regexp:  true,
+		literal: true,
+	}
+	return s.scan()
+}
+
+// vmAsyncScanner implements a minimal JavaScript tokenizer looking for native async functions.
+type vmAsyncScanner struct {
+	code      []byte
+	pos       int
+	line      int
+	regexp    bool
+	literal   bool
+	braces    int
+	templates []int
+}
+
+// scan scans the code.
+func (s *vmAsyncScanner) scan() error {
+	for s.pos < len(s.code) {
+		c := s.code[s.pos]
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			s.pos++
+
+		case c == '/' && s.peek(1) == '/':
+			for s.pos < len(s.code) && s.code[s.pos] != '\n' {
+				s.pos++
+			}
+
+		case c == '/' && s.peek(1) == '*':
+			s.pos += 2
+			for s.pos < len(s.code) && !(s.code[s.pos] == '*' && s.peek(1) == '/') {
+				s.next()
+			}
+			s.pos += 2
+
+		case c == '/' && s.regexp:
+			s.skipRegexp()
+			s.value()
+
+		case c == '\'' || c == '"':
+			s.skipString(c)
+			s.value()
+
+		case c == '`':
+			s.pos++
+			s.skipTemplate()
+
+		case c == '}' && len(s.templates) > 0 && s.templates[len(s.templates)-1] == s.braces:
+			s.templates = s.templates[:len(s.templates)-1]
+			s.pos++
+			s.skipTemplate()
+
+		case vmAsyncIdentifierStart(c):
+			line := s.line
+			word := s.identifier()
+			if s.literal && word == "await" && s.at(s.skipSpaces(s.pos)) != ':' {
+				return fmt.Errorf("line %d: await expressions are not supported", line)
+			}
+			if s.literal && word == "async" && s.asyncFunction() {
+				return fmt.Errorf("line %d: async functions are not supported", line)
+			}
+			s.regexp = vmRegexpKeywords[word]
+			s.literal = true
+
+		case c >= '0' && c <= '9':
+			for s.pos < len(s.code) && (vmAsyncIdentifierPart(s.code[s.pos]) || s.code[s.pos] == '.') {
+				s.pos++
+			}
+			s.value()
+
+		default:
+			switch c {
+			case '{':
+				s.braces++
+			case '}':
+				s.braces--
+			}
+			s.pos++
+			s.regexp = c != ')' && c != ']' && c != '}'
+			s.literal = c != '.' || s.peek(-2) == '.'
+		}
+	}
+
+	return nil
+}
+
+// peek returns the byte at the given offset from the current position.
+func (s *vmAsyncScanner) peek(offset int) byte {
+	return s.at(s.pos + offset)
+}
+
+// at returns the byte at the given position, or zero if the position is out of the code.
+func (s *vmAsyncScanner) at(i int) byte {
+	if i < 0 || i >= len(s.code) {
+		return 0
+	}
+	return s.code[i]
+}
+
+// next moves to the next byte and counts the lines.
+func (s *vmAsyncScanner) next() {
+	if s.code[s.pos] == '\n' {
+		s.line++
+	}
+	s.pos++
+}
+
+// value marks the end of a value token.
+func (s *vmAsyncScanner) value() {
+	s.regexp = false
+	s.literal = true
+}
+
+// identifier reads an identifier or a keyword.
+func (s *vmAsyncScanner) identifier() string {
+	start := s.pos
+	for s.pos < len(s.code) && vmAsyncIdentifierPart(s.code[s.pos]) {
+		s.pos++
+	}
+	return string(s.code[start:s.pos])
+}
+
+// asyncFunction returns true if the async keyword just read starts a function, either a function expression, an
+// arrow function or a method.
+func (s *vmAsyncScanner) asyncFunction() bool {
+	i := s.pos
+	for i < len(s.code) && (s.code[i] == ' ' || s.code[i] == '\t') {
+		i++
+	}
+	if i >= len(s.code) {
+		return false
+	}
+	c := s.code[i]
+	switch {
+	case vmAsyncIdentifierStart(c), c == '*', c == '[':
+		return true
+	case c == '(':
+		for depth := 0; i < len(s.code); i++ {
+			if s.code[i] == '(' {
+				depth++
+			} else if s.code[i] == ')' {
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		i = s.skipSpaces(i + 1)
+		return i+1 < len(s.code) && s.code[i] == '=' && s.code[i+1] == '>'
+	}
+	return false
+}
+
+// skipSpaces returns the position of the next character from the given position which is not a white space.
+func (s *vmAsyncScanner) skipSpaces(i int) int {
+	for i < len(s.code) && (s.code[i] == ' ' || s.code[i] == '\t' || s.code[i] == '\n' || s.code[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipString skips a string literal.
+func (s *vmAsyncScanner) skipString(quote byte) {
+	s.pos++
+	for s.pos < len(s.code) && s.code[s.pos] != quote && s.code[s.pos] != '\n' {
+		if s.code[s.pos] == '\\' {
+			s.pos++
+		}
+		if s.pos < len(s.code) {
+			s.next()
+		}
+	}
+	s.pos++
+}
+
+// skipTemplate skips the characters of a template literal up to its end or its next substitution.
+func (s *vmAsyncScanner) skipTemplate() {
+	for s.pos < len(s.code) {
+		switch {
+		case s.code[s.pos] == '\\':
+			s.pos++
+			if s.pos < len(s.code) {
+				s.next()
+			}
+		case s.code[s.pos] == '`':
+			s.pos++
+			s.value()
+			return
+		case s.code[s.pos] == '$' && s.peek(1) == '{':
+			s.pos += 2
+			s.templates = append(s.templates, s.braces)
+			s.regexp = true
+			s.literal = true
+			return
+		default:
+			s.next()
+		}
+	}
+}
+
+// skipRegexp skips a regular expression literal and its flags.
+func (s *vmAsyncScanner) skipRegexp() {
+	s.pos++
+	var class bool
+	for s.pos < len(s.code) && s.code[s.pos] != '\n' {
+		c := s.code[s.pos]
+		s.pos++
+		switch {
+		case c == '\\':
+			s.pos++
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '/' && !class:
+			for s.pos < len(s.code) && vmAsyncIdentifierPart(s.code[s.pos]) {
+				s.pos++
+			}
+			return
+		}
+	}
+}
+
+// vmAsyncIdentifierStart returns true if the given byte starts an identifier.
+func vmAsyncIdentifierStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
+
+// vmAsyncIdentifierPart returns true if the given byte is part of an identifier.
+func vmAsyncIdentifierPart(c byte) bool {
+	return vmAsyncIdentifierStart(c) || c >= '0' && c <= '9'
+}
@@ -0,0 +1,85 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"testing"
+)
+
+func TestVMCheckAsync(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		wantErr bool
+	}{
+		{
+			name: "promise",
+			code: `Promise.resolve(1).then((value) => server.response.render(String(value)));`,
+		},
+		{
+			name: "generator",
+			code: `function* render() { yield 1; } render().next();`,
+		},
+		{
+			name: "property",
+			code: `script.async = true; const options = { async: true, await: false }; options.await;`,
+		},
+		{
+			name: "call",
+			code: `async(tasks, () => {});`,
+		},
+		{
+			name: "method",
+			code: `const lib = { async() { return 1; } };`,
+		},
+		{
+			name: "comments",
+			code: "// await fetch()\n/* async function render() {} */",
+		},
+		{
+			name: "strings",
+			code: `const a = "async function"; const b = 'await value'; const c = ` + "`async () => ${1} await`;",
+		},
+		{
+			name: "regexp",
+			code: `const re = /async function [a-z/]+/g; const ratio = 1 / 2 / 3;`,
+		},
+		{
+			name:    "async function",
+			code:    `async function render() {}`,
+			wantErr: true,
+		},
+		{
+			name:    "async arrow function",
+			code:    `(async () => 1)().then((value) => server.response.render(String(value)));`,
+			wantErr: true,
+		},
+		{
+			name:    "async arrow function parameter",
+			code:    `const render = async value => value;`,
+			wantErr: true,
+		},
+		{
+			name:    "async method",
+			code:    `class App { async render() {} }`,
+			wantErr: true,
+		},
+		{
+			name:    "await",
+			code:    "function* render() {\n  const value = yield 1;\n}\nfor await (const value of values) {}",
+			wantErr: true,
+		},
+		{
+			name:    "template substitution",
+			code:    "const html = `${(async () => 1)()}`;",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := vmCheckAsync([]byte(tt.code)); (err != nil) != tt.wantErr {
+				t.Errorf("vmCheckAsync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
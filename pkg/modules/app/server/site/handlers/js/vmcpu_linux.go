@@ -0,0 +1,34 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// vmThreadID returns the ID of the calling thread.
+func vmThreadID() int {
+	return syscall.Gettid()
+}
+
+// vmThreadCPUTime returns the CPU time consumed by the given thread.
+func vmThreadCPUTime(tid int) (time.Duration, error) {
+	buf, err := os.ReadFile(fmt.Sprintf("/proc/self/task/%d/schedstat", tid))
+	if err != nil {
+		return 0, err
+	}
+	fields := bytes.Fields(buf)
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("invalid schedstat: %s", buf)
+	}
+	ns, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse schedstat: %v", err)
+	}
+	return time.Duration(ns), nil
+}
@@ -0,0 +1,41 @@
+//go:build cgo && !nojs
+
+package js
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestVMThreadCPUTime(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tests := []struct {
+		name    string
+		tid     int
+		wantErr bool
+	}{
+		{
+			name: "default",
+			tid:  vmThreadID(),
+		},
+		{
+			name:    "invalid thread",
+			tid:     -1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vmThreadCPUTime(tt.tid)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("vmThreadCPUTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got < 0 {
+				t.Errorf("vmThreadCPUTime() = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+//go:build cgo && !nojs && !linux
+
+package js
+
+import (
+	"errors"
+	"time"
+)
+
+// vmThreadID returns the ID of the calling thread.
+func vmThreadID() int {
+	return 0
+}
+
+// vmThreadCPUTime returns the CPU time consumed by the given thread.
+func vmThreadCPUTime(tid int) (time.Duration, error) {
+	return 0, errors.New("not supported")
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"text/template"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
 	"github.com/bhuisgen/neon/pkg/render"
 )
 
@@ -31,29 +33,47 @@ type robotsHandler struct {
 
 // robotsHandlerConfig implements the robots handler configuration.
 type robotsHandlerConfig struct {
-	Hosts    []string `mapstructure:"hosts"`
-	Cache    *bool    `mapstructure:"cache"`
-	CacheTTL *int     `mapstructure:"cacheTTL"`
-	Sitemaps []string `mapstructure:"sitemaps"`
+	Hosts        []string          `mapstructure:"hosts"`
+	Cache        *bool             `mapstructure:"cache"`
+	CacheTTL     *int              `mapstructure:"cacheTTL"`
+	CacheHeaders *bool             `mapstructure:"cacheHeaders"`
+	Sitemaps     []string          `mapstructure:"sitemaps"`
+	Host         *string           `mapstructure:"host"`
+	CleanParams  []string          `mapstructure:"cleanParams"`
+	UserAgents   []RobotsUserAgent `mapstructure:"userAgents"`
+}
+
+// RobotsUserAgent implements a user agent section of the robots handler.
+type RobotsUserAgent struct {
+	Names       []string `mapstructure:"names"`
+	Allow       []string `mapstructure:"allow"`
+	Disallow    []string `mapstructure:"disallow"`
+	CrawlDelay  *int     `mapstructure:"crawlDelay"`
+	CleanParams []string `mapstructure:"cleanParams"`
 }
 
 // robotsTemplateData implements the robots template data.
 type robotsTemplateData struct {
-	Check    bool
-	Sitemaps []string
+	Check       bool
+	Sitemaps    []string
+	Host        string
+	CleanParams []string
+	UserAgents  []RobotsUserAgent
 }
 
 // robotsHandlerCache implements the robots handler cache.
 type robotsHandlerCache struct {
 	render render.Render
+	stored time.Time
 	expire time.Time
 }
 
 const (
 	robotsModuleID module.ModuleID = "app.server.site.handler.robots"
 
-	robotsConfigDefaultCache    bool = false
-	robotsConfigDefaultCacheTTL int  = 60
+	robotsConfigDefaultCache        bool = false
+	robotsConfigDefaultCacheTTL     int  = 60
+	robotsConfigDefaultCacheHeaders bool = false
 )
 
 var (
@@ -108,12 +128,61 @@ func (h *robotsHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "CacheTTL", "value", *h.config.CacheTTL)
 		errConfig = true
 	}
+	if h.config.CacheHeaders == nil {
+		defaultValue := robotsConfigDefaultCacheHeaders
+		h.config.CacheHeaders = &defaultValue
+	}
 	for _, item := range h.config.Sitemaps {
 		if item == "" {
 			h.logger.Error("Invalid value", "option", "Sitemaps", "value", item)
 			errConfig = true
 		}
 	}
+	if h.config.Host != nil && !robotsValidValue(*h.config.Host) {
+		h.logger.Error("Invalid value", "option", "Host", "value", *h.config.Host)
+		errConfig = true
+	}
+	for _, item := range h.config.CleanParams {
+		if !robotsValidValue(item) {
+			h.logger.Error("Invalid value", "option", "CleanParams", "value", item)
+			errConfig = true
+		}
+	}
+	for index, userAgent := range h.config.UserAgents {
+		if len(userAgent.Names) == 0 {
+			h.logger.Error("Missing option or value", "userAgent", index+1, "option", "Names")
+			errConfig = true
+		}
+		for _, item := range userAgent.Names {
+			if !robotsValidValue(item) {
+				h.logger.Error("Invalid value", "userAgent", index+1, "option", "Names", "value", item)
+				errConfig = true
+			}
+		}
+		for _, item := range userAgent.Allow {
+			if !robotsValidValue(item) {
+				h.logger.Error("Invalid value", "userAgent", index+1, "option", "Allow", "value", item)
+				errConfig = true
+			}
+		}
+		for _, item := range userAgent.Disallow {
+			if strings.ContainsAny(item, "\r\n") {
+				h.logger.Error("Invalid value", "userAgent", index+1, "option", "Disallow", "value", item)
+				errConfig = true
+			}
+		}
+		if userAgent.CrawlDelay != nil && *userAgent.CrawlDelay <= 0 {
+			h.logger.Error("Invalid value", "userAgent", index+1, "option", "CrawlDelay", "value",
+				*userAgent.CrawlDelay)
+			errConfig = true
+		}
+		for _, item := range userAgent.CleanParams {
+			if !robotsValidValue(item) {
+				h.logger.Error("Invalid value", "userAgent", index+1, "option", "CleanParams", "value", item)
+				errConfig = true
+			}
+		}
+	}
 
 	if errConfig {
 		return errors.New("config")
@@ -153,6 +222,11 @@ func (h *robotsHandler) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (h *robotsHandler) Degradable() bool {
+	return true
+}
+
 // ServeHTTP implements the http handler.
 func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
@@ -161,11 +235,16 @@ func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	degraded := priority.Degraded(r.Context())
+
 	if *h.config.Cache {
 		h.muCache.RLock()
-		if h.cache != nil && h.cache.expire.After(time.Now()) {
-			render := h.cache.render
+		if h.cache != nil && (degraded || h.cache.expire.After(time.Now())) {
+			cache := h.cache
 			h.muCache.RUnlock()
+			render := cache.render
+
+			h.setCacheHeader(w, cache)
 
 			w.WriteHeader(render.StatusCode())
 			if _, err := w.Write(render.Body()); err != nil {
@@ -181,9 +260,17 @@ func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if degraded {
+		priority.Shed(w, r)
+
+		h.logger.Debug("Request shed", "url", r.URL.Path)
+
+		return
+	}
+
 	render, err := h.render(r)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
@@ -192,11 +279,15 @@ func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if *h.config.Cache {
 		h.muCache.Lock()
+		now := time.Now()
 		h.cache = &robotsHandlerCache{
 			render: render,
-			expire: time.Now().Add(time.Duration(*h.config.CacheTTL) * time.Second),
+			stored: now,
+			expire: now.Add(time.Duration(*h.config.CacheTTL) * time.Second),
 		}
 		h.muCache.Unlock()
+
+		h.setCacheHeader(w, nil)
 	}
 
 	w.WriteHeader(render.StatusCode())
@@ -208,6 +299,30 @@ func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Render completed ", "url", r.URL.Path, "status", render.StatusCode(), "cache", false)
 }
 
+// setCacheHeader sets the cache debugging headers of the response served from the given cached render or rendered
+// for the request if nil.
+func (h *robotsHandler) setCacheHeader(w http.ResponseWriter, cache *robotsHandlerCache) {
+	if !*h.config.CacheHeaders {
+		return
+	}
+
+	if cache == nil {
+		render.SetCacheHeader(w, render.CacheMiss, time.Time{}, "")
+		return
+	}
+	status := render.CacheHit
+	if !cache.expire.After(time.Now()) {
+		status = render.CacheStale
+	}
+	render.SetCacheHeader(w, status, cache.stored, "")
+}
+
+// serveError writes an error response without the headers set before the failure.
+func (h *robotsHandler) serveError(w http.ResponseWriter, statusCode int) {
+	render.ResetHeader(w)
+	w.WriteHeader(statusCode)
+}
+
 // render makes a new render.
 func (h *robotsHandler) render(r *http.Request) (render.Render, error) {
 	rw := h.rwPool.Get()
@@ -223,10 +338,16 @@ func (h *robotsHandler) render(r *http.Request) (render.Render, error) {
 		}
 	}
 
-	if err := h.template.Execute(rw, robotsTemplateData{
-		Check:    check,
-		Sitemaps: h.config.Sitemaps,
-	}); err != nil {
+	data := robotsTemplateData{
+		Check:       check,
+		Sitemaps:    h.config.Sitemaps,
+		CleanParams: h.config.CleanParams,
+		UserAgents:  h.config.UserAgents,
+	}
+	if h.config.Host != nil {
+		data.Host = *h.config.Host
+	}
+	if err := h.template.Execute(rw, data); err != nil {
 		h.logger.Error("Failed to execute template", "err", err)
 		return nil, fmt.Errorf("execute template: %v", err)
 	}
@@ -234,4 +355,10 @@ func (h *robotsHandler) render(r *http.Request) (render.Render, error) {
 	return rw.Render(), nil
 }
 
+// robotsValidValue returns true if the given directive value is not empty and holds on a single line.
+func robotsValidValue(value string) bool {
+	return value != "" && !strings.ContainsAny(value, "\r\n")
+}
+
 var _ core.ServerSiteHandlerModule = (*robotsHandler)(nil)
+var _ priority.Degradable = (*robotsHandler)(nil)
@@ -23,6 +23,10 @@ func intPtr(i int) *int {
 	return &i
 }
 
+func stringPtr(s string) *string {
+	return &s
+}
+
 type testRobotsHandlerServerSite struct {
 	err bool
 }
@@ -169,10 +173,22 @@ func TestRobotsHandlerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
-					"Hosts":    []string{"test"},
-					"Cache":    true,
-					"CacheTTL": 60,
-					"Sitemaps": []string{"http://test/sitemap.xml"},
+					"Hosts":        []string{"test"},
+					"Cache":        true,
+					"CacheTTL":     60,
+					"CacheHeaders": true,
+					"Sitemaps":     []string{"http://test/sitemap.xml"},
+					"Host":         "test",
+					"CleanParams":  []string{"ref /"},
+					"UserAgents": []map[string]interface{}{
+						{
+							"Names":       []string{"Yandex"},
+							"Allow":       []string{"/"},
+							"Disallow":    []string{"/private"},
+							"CrawlDelay":  2,
+							"CleanParams": []string{"utm_source&utm_medium /"},
+						},
+					},
 				},
 			},
 		},
@@ -183,9 +199,19 @@ func TestRobotsHandlerInit(t *testing.T) {
 			},
 			args: args{
 				config: map[string]interface{}{
-					"Hosts":    []string{""},
-					"CacheTTL": 0,
-					"Sitemaps": []string{""},
+					"Hosts":       []string{""},
+					"CacheTTL":    0,
+					"Sitemaps":    []string{""},
+					"Host":        "",
+					"CleanParams": []string{""},
+					"UserAgents": []map[string]interface{}{
+						{
+							"Allow":       []string{""},
+							"Disallow":    []string{"/\n"},
+							"CrawlDelay":  0,
+							"CleanParams": []string{""},
+						},
+					},
 				},
 			},
 			wantErr: true,
@@ -359,8 +385,9 @@ func TestRobotsHandlerServeHTTP(t *testing.T) {
 			name: "default",
 			fields: fields{
 				config: &robotsHandlerConfig{
-					Cache:    boolPtr(true),
-					CacheTTL: intPtr(60),
+					Cache:        boolPtr(true),
+					CacheTTL:     intPtr(60),
+					CacheHeaders: boolPtr(false),
 				},
 				logger:   slog.Default(),
 				template: tmpl,
@@ -393,3 +420,80 @@ func TestRobotsHandlerServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestRobotsHandlerRender(t *testing.T) {
+	tmpl, err := template.New("robots").Parse(robotsTemplate)
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		name   string
+		config *robotsHandlerConfig
+		host   string
+		want   string
+	}{
+		{
+			name:   "disallow",
+			config: &robotsHandlerConfig{},
+			host:   "test",
+			want:   "User-agent: *\nDisallow: /\n",
+		},
+		{
+			name: "allow",
+			config: &robotsHandlerConfig{
+				Hosts:    []string{"test"},
+				Sitemaps: []string{"http://test/sitemap.xml"},
+			},
+			host: "test",
+			want: "User-agent: *\nAllow: /\n\nSitemap: http://test/sitemap.xml\n",
+		},
+		{
+			name: "directives",
+			config: &robotsHandlerConfig{
+				Hosts:       []string{"test"},
+				Sitemaps:    []string{"http://test/sitemap.xml"},
+				Host:        stringPtr("test"),
+				CleanParams: []string{"ref /"},
+				UserAgents: []RobotsUserAgent{
+					{
+						Names:       []string{"Yandex", "YandexImages"},
+						Allow:       []string{"/"},
+						Disallow:    []string{"/private"},
+						CrawlDelay:  intPtr(2),
+						CleanParams: []string{"utm_source&utm_medium /"},
+					},
+					{
+						Names:    []string{"Baiduspider"},
+						Disallow: []string{"/"},
+					},
+				},
+			},
+			host: "test",
+			want: "User-agent: *\nAllow: /\nClean-param: ref /\n" +
+				"\nUser-agent: Yandex\nUser-agent: YandexImages\nAllow: /\nDisallow: /private\nCrawl-delay: 2\n" +
+				"Clean-param: utm_source&utm_medium /\n" +
+				"\nUser-agent: Baiduspider\nDisallow: /\n" +
+				"\nHost: test\n" +
+				"\nSitemap: http://test/sitemap.xml\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &robotsHandler{
+				config:   tt.config,
+				logger:   slog.Default(),
+				template: tmpl,
+				rwPool:   render.NewRenderWriterPool(),
+			}
+			got, err := h.render(&http.Request{Host: tt.host})
+			if err != nil {
+				t.Errorf("robotsHandler.render() error = %v", err)
+				return
+			}
+			if string(got.Body()) != tt.want {
+				t.Errorf("robotsHandler.render() = %q, want %q", got.Body(), tt.want)
+			}
+		})
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -21,6 +23,7 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
 	"github.com/bhuisgen/neon/pkg/render"
 )
 
@@ -41,6 +44,7 @@ type sitemapHandlerConfig struct {
 	Root         string              `mapstructure:"root"`
 	Cache        *bool               `mapstructure:"cache"`
 	CacheTTL     *int                `mapstructure:"cacheTTL"`
+	CacheHeaders *bool               `mapstructure:"cacheHeaders"`
 	Kind         string              `mapstructure:"kind"`
 	SitemapIndex []SitemapIndexEntry `mapstructure:"sitemapIndex"`
 	Sitemap      []SitemapEntry      `mapstructure:"sitemap"`
@@ -76,13 +80,14 @@ type SitemapEntryStatic struct {
 
 // SitemapEntryList implements a sitemap entry list.
 type SitemapEntryList struct {
-	Resource    string   `mapstructure:"resource"`
-	Filter      string   `mapstructure:"filter"`
-	ItemLoc     string   `mapstructure:"itemLoc"`
-	ItemLastmod *string  `mapstructure:"itemLastmod"`
-	ItemIgnore  *string  `mapstructure:"itemIgnore"`
-	Changefreq  *string  `mapstructure:"changefreq"`
-	Priority    *float64 `mapstructure:"priority"`
+	Resource        string   `mapstructure:"resource"`
+	Filter          string   `mapstructure:"filter"`
+	ItemLoc         string   `mapstructure:"itemLoc"`
+	ItemLastmod     *string  `mapstructure:"itemLastmod"`
+	ItemIgnore      *string  `mapstructure:"itemIgnore"`
+	Changefreq      *string  `mapstructure:"changefreq"`
+	Priority        *float64 `mapstructure:"priority"`
+	LastmodStrategy *string  `mapstructure:"lastmodStrategy"`
 }
 
 // sitemapTemplateSitemapIndexData implements the sitemap index template data.
@@ -111,6 +116,7 @@ type sitemapTemplateSitemapItem struct {
 // sitemapHandlerCache implements the sitemap handler cache.
 type sitemapHandlerCache struct {
 	render render.Render
+	stored time.Time
 	expire time.Time
 }
 
@@ -129,9 +135,14 @@ const (
 	sitemapChangefreqMonthly           string = "monthly"
 	sitemapChangefreqYearly            string = "yearly"
 	sitemapChangefreqNever             string = "never"
-
-	sitemapConfigDefaultCache    bool = false
-	sitemapConfigDefaultCacheTTL int  = 60
+	sitemapLastmodStrategyField        string = "field"
+	sitemapLastmodStrategyFetchTime    string = "fetchTime"
+	sitemapLastmodStrategyPayloadMax   string = "payloadMax"
+
+	sitemapConfigDefaultCache           bool   = false
+	sitemapConfigDefaultCacheTTL        int    = 60
+	sitemapConfigDefaultCacheHeaders    bool   = false
+	sitemapConfigDefaultLastmodStrategy string = sitemapLastmodStrategyField
 )
 
 var (
@@ -173,6 +184,9 @@ func (h *sitemapHandler) Init(config map[string]interface{}) error {
 	if h.config.Root == "" {
 		h.logger.Error("Missing option or value", "option", "Root")
 		errConfig = true
+	} else if _, err := sitemapURL(h.config.Root, ""); err != nil {
+		h.logger.Error("Invalid value", "option", "Root", "value", h.config.Root, "err", err)
+		errConfig = true
 	}
 	if h.config.Cache == nil {
 		defaultValue := sitemapConfigDefaultCache
@@ -186,6 +200,10 @@ func (h *sitemapHandler) Init(config map[string]interface{}) error {
 		h.logger.Error("Invalid value", "option", "CacheTTL", "value", *h.config.CacheTTL)
 		errConfig = true
 	}
+	if h.config.CacheHeaders == nil {
+		defaultValue := sitemapConfigDefaultCacheHeaders
+		h.config.CacheHeaders = &defaultValue
+	}
 	var sitemapIndex, sitemap bool
 	switch h.config.Kind {
 	case "":
@@ -274,7 +292,8 @@ func (h *sitemapHandler) Init(config map[string]interface{}) error {
 						errConfig = true
 					}
 				}
-				if entry.Static.Priority != nil && (*entry.Static.Priority < 0 || *entry.Static.Priority > 1) {
+				if entry.Static.Priority != nil && (*entry.Static.Priority < 0 || *entry.Static.Priority > 1 ||
+					math.IsNaN(*entry.Static.Priority)) {
 					h.logger.Error("Invalid value", "kind", "sitemap", "entry", index+1, "type", "static", "option", "Priority",
 						"value", *entry.Static.Priority)
 					errConfig = true
@@ -322,11 +341,31 @@ func (h *sitemapHandler) Init(config map[string]interface{}) error {
 						errConfig = true
 					}
 				}
-				if entry.List.Priority != nil && (*entry.List.Priority < 0 || *entry.List.Priority > 1) {
+				if entry.List.Priority != nil && (*entry.List.Priority < 0 || *entry.List.Priority > 1 ||
+					math.IsNaN(*entry.List.Priority)) {
 					h.logger.Error("Invalid value", "kind", "sitemap", "entry", index+1, "type", "list", "option", "Priority",
 						"value", *entry.List.Priority)
 					errConfig = true
 				}
+				if entry.List.LastmodStrategy == nil {
+					defaultValue := sitemapConfigDefaultLastmodStrategy
+					h.config.Sitemap[index].List.LastmodStrategy = &defaultValue
+					entry.List.LastmodStrategy = &defaultValue
+				}
+				switch *entry.List.LastmodStrategy {
+				case sitemapLastmodStrategyField:
+				case sitemapLastmodStrategyFetchTime:
+				case sitemapLastmodStrategyPayloadMax:
+					if entry.List.ItemLastmod == nil {
+						h.logger.Error("Missing option or value", "kind", "sitemap", "entry", index+1, "type", "list",
+							"option", "ItemLastmod")
+						errConfig = true
+					}
+				default:
+					h.logger.Error("Invalid value", "kind", "sitemap", "entry", index+1, "type", "list",
+						"option", "LastmodStrategy", "value", *entry.List.LastmodStrategy)
+					errConfig = true
+				}
 			}
 		}
 	}
@@ -359,6 +398,10 @@ func (h *sitemapHandler) Register(site core.ServerSite) error {
 		return fmt.Errorf("register handler: %v", err)
 	}
 
+	if loader := site.Loader(); loader != nil {
+		loader.Subscribe(h.purge)
+	}
+
 	return nil
 }
 
@@ -376,6 +419,11 @@ func (h *sitemapHandler) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (h *sitemapHandler) Degradable() bool {
+	return true
+}
+
 // ServeHTTP implements the http handler.
 func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
@@ -384,11 +432,16 @@ func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	degraded := priority.Degraded(r.Context())
+
 	if *h.config.Cache {
 		h.muCache.RLock()
-		if h.cache != nil && h.cache.expire.After(time.Now()) {
-			render := h.cache.render
+		if h.cache != nil && (degraded || h.cache.expire.After(time.Now())) {
+			cache := h.cache
 			h.muCache.RUnlock()
+			render := cache.render
+
+			h.setCacheHeader(w, cache)
 
 			w.WriteHeader(render.StatusCode())
 			if _, err := w.Write(render.Body()); err != nil {
@@ -404,9 +457,17 @@ func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if degraded {
+		priority.Shed(w, r)
+
+		h.logger.Debug("Request shed", "url", r.URL.Path)
+
+		return
+	}
+
 	render, err := h.render(r)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		h.serveError(w, http.StatusServiceUnavailable)
 
 		h.logger.Error("Render error", "url", r.URL.Path, "status", http.StatusServiceUnavailable)
 
@@ -415,11 +476,15 @@ func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if *h.config.Cache {
 		h.muCache.Lock()
+		now := time.Now()
 		h.cache = &sitemapHandlerCache{
 			render: render,
-			expire: time.Now().Add(time.Duration(*h.config.CacheTTL) * time.Second),
+			stored: now,
+			expire: now.Add(time.Duration(*h.config.CacheTTL) * time.Second),
 		}
 		h.muCache.Unlock()
+
+		h.setCacheHeader(w, nil)
 	}
 
 	w.WriteHeader(render.StatusCode())
@@ -431,6 +496,47 @@ func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Render completed", "url", r.URL.Path, "status", render.StatusCode(), "cache", false)
 }
 
+// setCacheHeader sets the cache debugging headers of the response served from the given cached render or rendered
+// for the request if nil.
+func (h *sitemapHandler) setCacheHeader(w http.ResponseWriter, cache *sitemapHandlerCache) {
+	if !*h.config.CacheHeaders {
+		return
+	}
+
+	if cache == nil {
+		render.SetCacheHeader(w, render.CacheMiss, time.Time{}, "")
+		return
+	}
+	status := render.CacheHit
+	if !cache.expire.After(time.Now()) {
+		status = render.CacheStale
+	}
+	render.SetCacheHeader(w, status, cache.stored, "")
+}
+
+// serveError writes an error response without the headers set before the failure.
+func (h *sitemapHandler) serveError(w http.ResponseWriter, statusCode int) {
+	render.ResetHeader(w)
+	w.WriteHeader(statusCode)
+}
+
+// purge removes the cached render if one of the given resources is used.
+func (h *sitemapHandler) purge(names []string) {
+	for _, name := range names {
+		for _, entry := range h.config.Sitemap {
+			if entry.Type == sitemapEntrySitemapTypeList && entry.List.Resource == name {
+				h.logger.Debug("Purging render", "resource", name)
+
+				h.muCache.Lock()
+				h.cache = nil
+				h.muCache.Unlock()
+
+				return
+			}
+		}
+	}
+}
+
 // render makes a new render.
 func (h *sitemapHandler) render(r *http.Request) (render.Render, error) {
 	rw := h.rwPool.Get()
@@ -458,8 +564,14 @@ func (h *sitemapHandler) render(r *http.Request) (render.Render, error) {
 func (h *sitemapHandler) sitemapIndex(s []SitemapIndexEntry, w io.Writer, _ *http.Request) error {
 	items := make([]sitemapTemplateSitemapIndexItem, 0, len(s))
 	for _, sitemapEntry := range s {
+		loc, err := sitemapURL(sitemapEntry.Static.Loc, h.config.Root)
+		if err != nil {
+			h.logger.Warn("Skipping invalid sitemap item", "entry", sitemapEntry.Name, "loc", sitemapEntry.Static.Loc,
+				"err", err)
+			continue
+		}
 		items = append(items, sitemapTemplateSitemapIndexItem{
-			Loc: h.absURL(sitemapEntry.Static.Loc, h.config.Root),
+			Loc: loc,
 		})
 	}
 
@@ -480,7 +592,9 @@ func (h *sitemapHandler) sitemap(s []SitemapEntry, w io.Writer, _ *http.Request)
 		case sitemapEntrySitemapTypeStatic:
 			staticItem, err := h.sitemapTemplateStaticItem(sitemapEntry)
 			if err != nil {
-				return fmt.Errorf("write static entry: %v", err)
+				h.logger.Warn("Skipping invalid sitemap item", "entry", sitemapEntry.Name, "loc", sitemapEntry.Static.Loc,
+					"err", err)
+				continue
 			}
 			items = append(items, staticItem)
 
@@ -504,8 +618,13 @@ func (h *sitemapHandler) sitemap(s []SitemapEntry, w io.Writer, _ *http.Request)
 
 // sitemapTemplateStaticItem returns a sitemap template static item
 func (h *sitemapHandler) sitemapTemplateStaticItem(entry SitemapEntry) (sitemapTemplateSitemapItem, error) {
+	loc, err := sitemapURL(entry.Static.Loc, h.config.Root)
+	if err != nil {
+		return sitemapTemplateSitemapItem{}, fmt.Errorf("loc: %v", err)
+	}
+
 	item := sitemapTemplateSitemapItem{
-		Loc: h.absURL(entry.Static.Loc, h.config.Root),
+		Loc: loc,
 	}
 	if entry.Static.Lastmod != nil {
 		item.Lastmod = *entry.Static.Lastmod
@@ -514,13 +633,17 @@ func (h *sitemapHandler) sitemapTemplateStaticItem(entry SitemapEntry) (sitemapT
 		item.Changefreq = *entry.Static.Changefreq
 	}
 	if entry.Static.Priority != nil {
-		item.Priority = fmt.Sprintf("%v", *entry.Static.Priority)
+		item.Priority = sitemapPriority(*entry.Static.Priority)
 	}
 
 	return item, nil
 }
 
 // sitemapTemplateListItems returns the sitemap template list items
+//
+// With the field strategy, the lastmod of an item is extracted from the item itself and an item without lastmod is
+// ignored. With the fetchTime and payloadMax strategies, an item without lastmod is kept and gets the fetch time of
+// the resource or the most recent lastmod of all the items.
 func (h *sitemapHandler) sitemapTemplateListItems(entry SitemapEntry) ([]sitemapTemplateSitemapItem, error) {
 	var items []sitemapTemplateSitemapItem
 
@@ -554,11 +677,18 @@ func (h *sitemapHandler) sitemapTemplateListItems(entry SitemapEntry) ([]sitemap
 				continue
 			}
 			if v, ok := itemLoc.(string); ok {
-				loc = h.absURL(v, h.config.Root)
+				loc, err = sitemapURL(v, h.config.Root)
+				if err != nil {
+					h.logger.Warn("Skipping invalid sitemap item", "resource", entry.List.Resource, "loc", v, "err", err)
+					continue
+				}
+			} else {
+				h.logger.Warn("Skipping invalid sitemap item", "resource", entry.List.Resource, "loc", itemLoc)
+				continue
 			}
 			if entry.List.ItemLastmod != nil {
 				itemLastmod, err := jsonpath.Get(*entry.List.ItemLastmod, element)
-				if err != nil {
+				if err != nil && *entry.List.LastmodStrategy == sitemapLastmodStrategyField {
 					h.logger.Debug("Failed to extract lastmod from resource item", "resource", entry.List.Resource)
 					continue
 				}
@@ -593,21 +723,139 @@ func (h *sitemapHandler) sitemapTemplateListItems(entry SitemapEntry) ([]sitemap
 				item.Changefreq = *entry.List.Changefreq
 			}
 			if entry.List.Priority != nil {
-				item.Priority = fmt.Sprintf("%v", *entry.List.Priority)
+				item.Priority = sitemapPriority(*entry.List.Priority)
 			}
 			items = append(items, item)
 		}
 	}
 
+	var lastmod string
+	switch *entry.List.LastmodStrategy {
+	case sitemapLastmodStrategyFetchTime:
+		if !resource.Time.IsZero() {
+			lastmod = resource.Time.UTC().Format(time.RFC3339)
+		}
+	case sitemapLastmodStrategyPayloadMax:
+		lastmod = sitemapMaxLastmod(items)
+	}
+	if lastmod != "" {
+		for index := range items {
+			if items[index].Lastmod == "" {
+				items[index].Lastmod = lastmod
+			}
+		}
+	}
+
 	return items, nil
 }
 
-// absURL returns the absolute form of the given URL.
-func (h *sitemapHandler) absURL(url string, root string) string {
-	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-		return url
+// sitemapMaxLastmod returns the most recent lastmod of the given items or an empty string if none is valid.
+func sitemapMaxLastmod(items []sitemapTemplateSitemapItem) string {
+	var latest time.Time
+	var lastmod string
+	for _, item := range items {
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			t, err := time.Parse(layout, item.Lastmod)
+			if err != nil {
+				continue
+			}
+			if t.After(latest) {
+				latest = t
+				lastmod = item.Lastmod
+			}
+			break
+		}
 	}
-	return root + url
+
+	return lastmod
+}
+
+// sitemapURL returns the canonical form of the given URL, resolved against the given root URL if relative.
+//
+// The invalid characters are percent-encoded and the fragment is removed. The scheme is upgraded to https if the URL
+// shares the host of an https root. An URL without host or with a scheme other than http or https is invalid.
+func sitemapURL(loc string, root string) (string, error) {
+	if loc == "" {
+		return "", errors.New("empty url")
+	}
+
+	u, err := url.Parse(sitemapEscape(loc))
+	if err != nil {
+		return "", fmt.Errorf("parse url: %v", err)
+	}
+	var r *url.URL
+	if root != "" {
+		r, err = url.Parse(root)
+		if err != nil {
+			return "", fmt.Errorf("parse root: %v", err)
+		}
+		if !u.IsAbs() {
+			if u.Host == "" {
+				u.Path = strings.TrimSuffix(r.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+				if u.RawPath != "" {
+					u.RawPath = strings.TrimSuffix(r.EscapedPath(), "/") + "/" + strings.TrimPrefix(u.RawPath, "/")
+				}
+				u.Host = r.Host
+			}
+			u.Scheme = r.Scheme
+		}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("missing host")
+	}
+	u.Host = strings.ToLower(u.Host)
+	if r != nil && r.Scheme == "https" && u.Scheme == "http" && u.Hostname() == strings.ToLower(r.Hostname()) {
+		u.Scheme = "https"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+// sitemapEscape percent-encodes the characters of the given URL which are not allowed by RFC 3986, keeping the
+// existing percent-encoded octets.
+func sitemapEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' && i+2 < len(s) && sitemapIsHex(s[i+1]) && sitemapIsHex(s[i+2]) {
+			b.WriteByte(c)
+			continue
+		}
+		if c != '%' && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexByte("-._~:/?#[]@!$&'()*+,;=", c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// sitemapIsHex returns true if the given character is an hexadecimal digit.
+func sitemapIsHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// sitemapPriority returns the given priority clamped between 0.0 and 1.0 with at least one decimal, or an empty
+// string if it is not a number.
+func sitemapPriority(priority float64) string {
+	if math.IsNaN(priority) {
+		return ""
+	}
+	value := strconv.FormatFloat(math.Max(0, math.Min(1, priority)), 'f', -1, 64)
+	if !strings.Contains(value, ".") {
+		value += ".0"
+	}
+
+	return value
 }
 
 var _ core.ServerSiteHandlerModule = (*sitemapHandler)(nil)
+var _ priority.Degradable = (*sitemapHandler)(nil)
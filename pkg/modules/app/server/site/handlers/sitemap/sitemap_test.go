@@ -4,17 +4,24 @@ import (
 	_ "embed"
 	"errors"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
+	"reflect"
 	"sync"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
 	"github.com/bhuisgen/neon/pkg/render"
 )
 
+func stringPtr(s string) *string {
+	return &s
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
@@ -23,8 +30,13 @@ func intPtr(i int) *int {
 	return &i
 }
 
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
 type testSitemapHandlerServerSite struct {
-	err bool
+	err   bool
+	store core.Store
 }
 
 func (s testSitemapHandlerServerSite) Name() string {
@@ -44,7 +56,7 @@ func (s testSitemapHandlerServerSite) IsDefault() bool {
 }
 
 func (s testSitemapHandlerServerSite) Store() core.Store {
-	return nil
+	return s.store
 }
 
 func (s testSitemapHandlerServerSite) Fetcher() core.Fetcher {
@@ -75,6 +87,23 @@ func (s testSitemapHandlerServerSite) RegisterHandler(handler http.Handler) erro
 
 var _ core.ServerSite = (*testSitemapHandlerServerSite)(nil)
 
+type testSitemapHandlerStore struct {
+	resource *core.Resource
+}
+
+func (s testSitemapHandlerStore) LoadResource(name string) (*core.Resource, error) {
+	if s.resource == nil {
+		return nil, errors.New("test error")
+	}
+	return s.resource, nil
+}
+
+func (s testSitemapHandlerStore) StoreResource(name string, resource *core.Resource) error {
+	return nil
+}
+
+var _ core.Store = (*testSitemapHandlerStore)(nil)
+
 type testSitemapHandlerResponseWriter struct {
 	header http.Header
 }
@@ -247,13 +276,14 @@ func TestSitemapHandlerInit(t *testing.T) {
 							"Name": "posts",
 							"Type": "list",
 							"List": map[string]interface{}{
-								"Resource":    "resource",
-								"Filter":      "$.results",
-								"ItemLoc":     "$.loc",
-								"ItemLastmod": "$.lastmod",
-								"ItemIgnore":  "$.ignore",
-								"Changefreq":  "always",
-								"Priority":    0.5,
+								"Resource":        "resource",
+								"Filter":          "$.results",
+								"ItemLoc":         "$.loc",
+								"ItemLastmod":     "$.lastmod",
+								"ItemIgnore":      "$.ignore",
+								"Changefreq":      "always",
+								"Priority":        0.5,
+								"LastmodStrategy": "payloadMax",
 							},
 						},
 					},
@@ -274,6 +304,28 @@ func TestSitemapHandlerInit(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid root",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"Root": "localhost",
+					"Kind": "sitemapIndex",
+					"SitemapIndex": []map[string]interface{}{
+						{
+							"Name": "test",
+							"Type": "static",
+							"Static": map[string]interface{}{
+								"Loc": "/sitemap_test.xml",
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing sitemap index entry",
 			fields: fields{
@@ -365,13 +417,14 @@ func TestSitemapHandlerInit(t *testing.T) {
 							"Name": "",
 							"Type": "list",
 							"List": map[string]interface{}{
-								"Resource":    "",
-								"Filter":      "",
-								"ItemLoc":     "",
-								"ItemLastmod": "",
-								"ItemIgnore":  "",
-								"Changefreq":  "",
-								"Priority":    -1,
+								"Resource":        "",
+								"Filter":          "",
+								"ItemLoc":         "",
+								"ItemLastmod":     "",
+								"ItemIgnore":      "",
+								"Changefreq":      "",
+								"Priority":        -1,
+								"LastmodStrategy": "invalid",
 							},
 						},
 					},
@@ -569,8 +622,9 @@ func TestSitemapHandlerServeHTTP(t *testing.T) {
 			name: "default",
 			fields: fields{
 				config: &sitemapHandlerConfig{
-					Cache:    boolPtr(true),
-					CacheTTL: intPtr(60),
+					Cache:        boolPtr(true),
+					CacheTTL:     intPtr(60),
+					CacheHeaders: boolPtr(false),
 				},
 				logger:               slog.Default(),
 				templateSitemapIndex: tmplSitemapIndex,
@@ -606,3 +660,344 @@ func TestSitemapHandlerServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestSitemapHandlerPurge(t *testing.T) {
+	type fields struct {
+		config  *sitemapHandlerConfig
+		logger  *slog.Logger
+		muCache *sync.RWMutex
+	}
+	type args struct {
+		names []string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantHit bool
+	}{
+		{
+			name: "resource changed",
+			fields: fields{
+				config: &sitemapHandlerConfig{
+					Sitemap: []SitemapEntry{
+						{
+							Name: "test",
+							Type: sitemapEntrySitemapTypeList,
+							List: SitemapEntryList{
+								Resource: "test",
+							},
+						},
+					},
+				},
+				logger:  slog.Default(),
+				muCache: &sync.RWMutex{},
+			},
+			args: args{
+				names: []string{"test"},
+			},
+		},
+		{
+			name: "other resource changed",
+			fields: fields{
+				config: &sitemapHandlerConfig{
+					Sitemap: []SitemapEntry{
+						{
+							Name: "test",
+							Type: sitemapEntrySitemapTypeList,
+							List: SitemapEntryList{
+								Resource: "test",
+							},
+						},
+					},
+				},
+				logger:  slog.Default(),
+				muCache: &sync.RWMutex{},
+			},
+			args: args{
+				names: []string{"other"},
+			},
+			wantHit: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &sitemapHandler{
+				config:  tt.fields.config,
+				logger:  tt.fields.logger,
+				muCache: tt.fields.muCache,
+				cache: &sitemapHandlerCache{
+					expire: time.Now().Add(time.Minute),
+				},
+			}
+			h.purge(tt.args.names)
+			if hit := h.cache != nil; hit != tt.wantHit {
+				t.Errorf("sitemapHandler.purge() hit = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestSitemapHandlerSitemapTemplateListItems(t *testing.T) {
+	resource := &core.Resource{
+		Data: [][]byte{[]byte(`{"results":[{"loc":"/a","lastmod":"2024-01-01"},` +
+			`{"loc":"/b","lastmod":"2024-03-01T10:00:00Z"},{"loc":"/c"}]}`)},
+		Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		resource *core.Resource
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "field",
+			strategy: sitemapLastmodStrategyField,
+			resource: resource,
+			want:     []string{"2024-01-01", "2024-03-01T10:00:00Z"},
+		},
+		{
+			name:     "fetch time",
+			strategy: sitemapLastmodStrategyFetchTime,
+			resource: resource,
+			want:     []string{"2024-01-01", "2024-03-01T10:00:00Z", "2024-06-01T12:00:00Z"},
+		},
+		{
+			name:     "payload max",
+			strategy: sitemapLastmodStrategyPayloadMax,
+			resource: resource,
+			want:     []string{"2024-01-01", "2024-03-01T10:00:00Z", "2024-03-01T10:00:00Z"},
+		},
+		{
+			name:     "error load resource",
+			strategy: sitemapLastmodStrategyField,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &sitemapHandler{
+				config: &sitemapHandlerConfig{
+					Root: "http://localhost",
+				},
+				logger: slog.Default(),
+				site: testSitemapHandlerServerSite{
+					store: testSitemapHandlerStore{
+						resource: tt.resource,
+					},
+				},
+			}
+			got, err := h.sitemapTemplateListItems(SitemapEntry{
+				Type: sitemapEntrySitemapTypeList,
+				List: SitemapEntryList{
+					Resource:        "resource",
+					Filter:          "$.results",
+					ItemLoc:         "$.loc",
+					ItemLastmod:     stringPtr("$.lastmod"),
+					LastmodStrategy: stringPtr(tt.strategy),
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sitemapHandler.sitemapTemplateListItems() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var lastmods []string
+			for _, item := range got {
+				lastmods = append(lastmods, item.Lastmod)
+			}
+			if !reflect.DeepEqual(lastmods, tt.want) {
+				t.Errorf("sitemapHandler.sitemapTemplateListItems() lastmods = %v, want %v", lastmods, tt.want)
+			}
+		})
+	}
+}
+
+func TestSitemapHandlerSitemapTemplateListItemsInvalidLoc(t *testing.T) {
+	h := &sitemapHandler{
+		config: &sitemapHandlerConfig{
+			Root: "https://localhost",
+		},
+		logger: slog.Default(),
+		site: testSitemapHandlerServerSite{
+			store: testSitemapHandlerStore{
+				resource: &core.Resource{
+					Data: [][]byte{[]byte(`{"results":[{"loc":"/a b#top"},{"loc":"mailto:test@localhost"},{"loc":1},` +
+						`{"loc":"http://localhost/c"}]}`)},
+				},
+			},
+		},
+	}
+	got, err := h.sitemapTemplateListItems(SitemapEntry{
+		Type: sitemapEntrySitemapTypeList,
+		List: SitemapEntryList{
+			Resource:        "resource",
+			Filter:          "$.results",
+			ItemLoc:         "$.loc",
+			Priority:        floatPtr(1),
+			LastmodStrategy: stringPtr(sitemapLastmodStrategyFetchTime),
+		},
+	})
+	if err != nil {
+		t.Errorf("sitemapHandler.sitemapTemplateListItems() error = %v", err)
+		return
+	}
+	want := []sitemapTemplateSitemapItem{
+		{Loc: "https://localhost/a%20b", Priority: "1.0"},
+		{Loc: "https://localhost/c", Priority: "1.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sitemapHandler.sitemapTemplateListItems() = %v, want %v", got, want)
+	}
+}
+
+func TestSitemapURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		loc     string
+		root    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "absolute",
+			loc:  "http://example.com/test",
+			root: "http://localhost",
+			want: "http://example.com/test",
+		},
+		{
+			name: "relative",
+			loc:  "/test",
+			root: "http://localhost",
+			want: "http://localhost/test",
+		},
+		{
+			name: "relative without slash",
+			loc:  "test",
+			root: "http://localhost/",
+			want: "http://localhost/test",
+		},
+		{
+			name: "relative to root path",
+			loc:  "/test",
+			root: "http://localhost/blog",
+			want: "http://localhost/blog/test",
+		},
+		{
+			name: "protocol relative",
+			loc:  "//example.com/test",
+			root: "https://localhost",
+			want: "https://example.com/test",
+		},
+		{
+			name: "invalid characters",
+			loc:  "/a b/\u00e9t\u00e9?q=\"x\"&p=<1>",
+			root: "http://localhost",
+			want: "http://localhost/a%20b/%C3%A9t%C3%A9?q=%22x%22&p=%3C1%3E",
+		},
+		{
+			name: "encoded characters",
+			loc:  "/a%20b%zz",
+			root: "http://localhost",
+			want: "http://localhost/a%20b%25zz",
+		},
+		{
+			name: "fragment",
+			loc:  "/test#section",
+			root: "http://localhost",
+			want: "http://localhost/test",
+		},
+		{
+			name: "https root",
+			loc:  "http://LOCALHOST/test",
+			root: "https://localhost",
+			want: "https://localhost/test",
+		},
+		{
+			name: "https root other host",
+			loc:  "http://example.com/test",
+			root: "https://localhost",
+			want: "http://example.com/test",
+		},
+		{
+			name:    "error empty",
+			loc:     "",
+			root:    "http://localhost",
+			wantErr: true,
+		},
+		{
+			name:    "error scheme",
+			loc:     "ftp://localhost/test",
+			root:    "http://localhost",
+			wantErr: true,
+		},
+		{
+			name:    "error missing host",
+			loc:     "/test",
+			root:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sitemapURL(tt.loc, tt.root)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sitemapURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("sitemapURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSitemapPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority float64
+		want     string
+	}{
+		{
+			name:     "default",
+			priority: 0.5,
+			want:     "0.5",
+		},
+		{
+			name:     "precision",
+			priority: 0.25,
+			want:     "0.25",
+		},
+		{
+			name:     "integer",
+			priority: 1,
+			want:     "1.0",
+		},
+		{
+			name:     "small",
+			priority: 0.00001,
+			want:     "0.00001",
+		},
+		{
+			name:     "lower bound",
+			priority: -0.5,
+			want:     "0.0",
+		},
+		{
+			name:     "upper bound",
+			priority: 2,
+			want:     "1.0",
+		},
+		{
+			name:     "not a number",
+			priority: math.NaN(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sitemapPriority(tt.priority); got != tt.want {
+				t.Errorf("sitemapPriority() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
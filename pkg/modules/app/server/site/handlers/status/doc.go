@@ -0,0 +1,2 @@
+// Package status implements the status handler.
+package status
@@ -0,0 +1,270 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/bhuisgen/neon/pkg/access"
+	"github.com/bhuisgen/neon/pkg/buildinfo"
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/metrics"
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+// statusHandler implements the status handler.
+type statusHandler struct {
+	config *statusHandlerConfig
+	logger *slog.Logger
+	start  time.Time
+	allow  *access.List
+}
+
+// statusHandlerConfig implements the status handler configuration.
+type statusHandlerConfig struct {
+	Enable     *bool    `mapstructure:"enable"`
+	Build      *bool    `mapstructure:"build"`
+	AllowedIPs []string `mapstructure:"allowedIPs"`
+	Token      *string  `mapstructure:"token"`
+	Public     *bool    `mapstructure:"public"`
+	LogLevel   *bool    `mapstructure:"logLevel"`
+}
+
+// statusResponse implements the status response.
+type statusResponse struct {
+	Status  string            `json:"status"`
+	Uptime  *int64            `json:"uptime,omitempty"`
+	Build   *buildinfo.Info   `json:"build,omitempty"`
+	Log     *statusLog        `json:"log,omitempty"`
+	Metrics map[string]uint64 `json:"metrics,omitempty"`
+}
+
+// statusLog implements the log status.
+type statusLog struct {
+	Level string     `json:"level"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+const (
+	statusModuleID module.ModuleID = "app.server.site.handler.status"
+
+	statusConfigDefaultEnable   bool = true
+	statusConfigDefaultBuild    bool = true
+	statusConfigDefaultPublic   bool = false
+	statusConfigDefaultLogLevel bool = false
+
+	statusLogLevelParam      string = "level"
+	statusLogLevelTTLParam   string = "ttl"
+	statusLogLevelDefaultTTL int    = 600
+
+	statusOK string = "ok"
+)
+
+// init initializes the package.
+func init() {
+	module.Register(statusHandler{})
+}
+
+// ModuleInfo returns the module information.
+func (h statusHandler) ModuleInfo() module.ModuleInfo {
+	return module.ModuleInfo{
+		ID:           statusModuleID,
+		LoadModule:   func() {},
+		UnloadModule: func() {},
+		NewInstance: func() module.Module {
+			return &statusHandler{
+				logger: slog.New(log.NewHandler(os.Stderr, string(statusModuleID), nil)),
+			}
+		},
+	}
+}
+
+// Init initializes the handler.
+func (h *statusHandler) Init(config map[string]interface{}) error {
+	if err := mapstructure.Decode(config, &h.config); err != nil {
+		h.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	var errConfig bool
+
+	if h.config.Enable == nil {
+		defaultValue := statusConfigDefaultEnable
+		h.config.Enable = &defaultValue
+	}
+	if h.config.Build == nil {
+		defaultValue := statusConfigDefaultBuild
+		h.config.Build = &defaultValue
+	}
+	if len(h.config.AllowedIPs) > 0 {
+		allow, err := access.ParseList(h.config.AllowedIPs)
+		if err != nil {
+			h.logger.Error("Invalid value", "option", "AllowedIPs", "value", h.config.AllowedIPs, "err", err)
+			errConfig = true
+		}
+		h.allow = allow
+	}
+	if h.config.Token != nil && *h.config.Token == "" {
+		h.logger.Error("Invalid value", "option", "Token", "value", *h.config.Token)
+		errConfig = true
+	}
+	if h.config.Public == nil {
+		defaultValue := statusConfigDefaultPublic
+		h.config.Public = &defaultValue
+	}
+	if h.config.LogLevel == nil {
+		defaultValue := statusConfigDefaultLogLevel
+		h.config.LogLevel = &defaultValue
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	return nil
+}
+
+// Register registers the handler.
+func (h *statusHandler) Register(site core.ServerSite) error {
+	if err := site.RegisterHandler(h); err != nil {
+		return fmt.Errorf("register handler: %v", err)
+	}
+
+	return nil
+}
+
+// Start starts the handler.
+func (h *statusHandler) Start() error {
+	h.start = time.Now()
+
+	return nil
+}
+
+// Stop stops the handler.
+func (h *statusHandler) Stop() error {
+	return nil
+}
+
+// ServeHTTP implements the http handler.
+//
+// If enabled, a PUT request from an allowed client changes the log level temporarily.
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logLevel := h.config.LogLevel != nil && *h.config.LogLevel
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && (r.Method != http.MethodPut || !logLevel) {
+		if logLevel {
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+		} else {
+			w.Header().Set("Allow", "GET, HEAD")
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !*h.config.Enable {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	allowed := h.allowed(r)
+	if !allowed && (!*h.config.Public || r.Method == http.MethodPut) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusForbidden)
+
+		h.logger.Debug("Status denied", "url", r.URL.Path, "remoteAddr", r.RemoteAddr)
+
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if err := h.setLogLevel(r); err != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusBadRequest)
+
+			h.logger.Debug("Invalid log level request", "url", r.URL.Path, "err", err)
+
+			return
+		}
+	}
+
+	response := statusResponse{
+		Status: statusOK,
+	}
+	if allowed && logLevel {
+		level, until := log.Level()
+		response.Log = &statusLog{
+			Level: level.String(),
+		}
+		if !until.IsZero() {
+			response.Log.Until = &until
+		}
+	}
+	if allowed {
+		uptime := int64(time.Since(h.start).Seconds())
+		response.Uptime = &uptime
+		response.Metrics = metrics.Counters()
+	}
+	if allowed && *h.config.Build {
+		info := buildinfo.Get()
+		response.Build = &info
+	}
+	buf, err := json.Marshal(&response)
+	if err != nil {
+		h.logger.Error("Failed to marshal status", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf); err != nil {
+		h.logger.Error("Failed to write status", "err", err)
+		return
+	}
+
+	h.logger.Debug("Status completed", "url", r.URL.Path)
+}
+
+// setLogLevel changes the log level given by the request for the given or the default duration.
+func (h *statusHandler) setLogLevel(r *http.Request) error {
+	query := r.URL.Query()
+	level, err := log.ParseLevel(query.Get(statusLogLevelParam))
+	if err != nil {
+		return err
+	}
+	ttl := statusLogLevelDefaultTTL
+	if v := query.Get(statusLogLevelTTLParam); v != "" {
+		ttl, err = strconv.Atoi(v)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %s", v)
+		}
+	}
+
+	log.SetLevel(level, time.Duration(ttl)*time.Second)
+
+	h.logger.Warn("Log level changed", "level", level, "ttl", ttl, "remoteAddr", r.RemoteAddr)
+
+	return nil
+}
+
+// allowed returns true if the request is allowed by the configured client addresses and token.
+func (h *statusHandler) allowed(r *http.Request) bool {
+	if h.allow != nil && !h.allow.Allowed(r) {
+		return false
+	}
+	if h.config.Token != nil && !access.Token(r, *h.config.Token) {
+		return false
+	}
+
+	return true
+}
+
+var _ core.ServerSiteHandlerModule = (*statusHandler)(nil)
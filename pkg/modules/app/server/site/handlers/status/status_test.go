@@ -0,0 +1,442 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/access"
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/metrics"
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+type testStatusHandlerServerSite struct {
+	err bool
+}
+
+func (s testStatusHandlerServerSite) Name() string {
+	return "test"
+}
+
+func (s testStatusHandlerServerSite) Listeners() []string {
+	return nil
+}
+
+func (s testStatusHandlerServerSite) Hosts() []string {
+	return nil
+}
+
+func (s testStatusHandlerServerSite) IsDefault() bool {
+	return false
+}
+
+func (s testStatusHandlerServerSite) Store() core.Store {
+	return nil
+}
+
+func (s testStatusHandlerServerSite) Loader() core.Loader {
+	return nil
+}
+
+func (s testStatusHandlerServerSite) Server() core.Server {
+	return nil
+}
+
+func (s testStatusHandlerServerSite) RegisterMiddleware(middleware func(next http.Handler) http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+func (s testStatusHandlerServerSite) RegisterHandler(handler http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+var _ core.ServerSite = (*testStatusHandlerServerSite)(nil)
+
+func TestStatusHandlerModuleInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		want module.ModuleInfo
+	}{
+		{
+			name: "default",
+			want: module.ModuleInfo{
+				ID:          statusModuleID,
+				NewInstance: func() module.Module { return &statusHandler{} },
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := statusHandler{}
+			got := h.ModuleInfo()
+			if got.ID != tt.want.ID {
+				t.Errorf("statusHandler.ModuleInfo() = %v, want %v", got.ID, tt.want.ID)
+			}
+			if instance := got.NewInstance(); instance == nil {
+				t.Errorf("statusHandler.NewInstance() = %v, want %v", instance, "not nil")
+			}
+		})
+	}
+}
+
+func TestStatusHandlerInit(t *testing.T) {
+	type args struct {
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "minimal",
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+		{
+			name: "full",
+			args: args{
+				config: map[string]interface{}{
+					"Enable":     true,
+					"Build":      false,
+					"AllowedIPs": []string{"127.0.0.1", "10.0.0.0/8"},
+					"Token":      "secret",
+					"Public":     true,
+					"LogLevel":   true,
+				},
+			},
+		},
+		{
+			name: "invalid values",
+			args: args{
+				config: map[string]interface{}{
+					"AllowedIPs": []string{"localhost"},
+					"Token":      "",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error parse",
+			args: args{
+				config: map[string]interface{}{
+					"Build": "invalid",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &statusHandler{
+				logger: slog.Default(),
+			}
+			if err := h.Init(tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("statusHandler.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatusHandlerRegister(t *testing.T) {
+	type args struct {
+		site core.ServerSite
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				site: testStatusHandlerServerSite{},
+			},
+		},
+		{
+			name: "error register",
+			args: args{
+				site: testStatusHandlerServerSite{
+					err: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &statusHandler{}
+			if err := h.Register(tt.args.site); (err != nil) != tt.wantErr {
+				t.Errorf("statusHandler.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatusHandlerStartStop(t *testing.T) {
+	h := &statusHandler{}
+	if err := h.Start(); err != nil {
+		t.Errorf("statusHandler.Start() error = %v", err)
+	}
+	if h.start.IsZero() {
+		t.Errorf("statusHandler.Start() start = %v, want %v", h.start, "not zero")
+	}
+	if err := h.Stop(); err != nil {
+		t.Errorf("statusHandler.Stop() error = %v", err)
+	}
+}
+
+func TestStatusHandlerServeHTTP(t *testing.T) {
+	allow, err := access.ParseList([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.Counter("test.counter").Store(1)
+
+	tests := []struct {
+		name          string
+		config        *statusHandlerConfig
+		allow         *access.List
+		method        string
+		authorization string
+		wantStatus    int
+		wantBuild     bool
+		wantUptime    bool
+	}{
+		{
+			name: "default",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Public: boolPtr(false),
+			},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBuild:  true,
+			wantUptime: true,
+		},
+		{
+			name: "without build",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(false),
+				Public: boolPtr(false),
+			},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantUptime: true,
+		},
+		{
+			name: "method not allowed",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Public: boolPtr(false),
+			},
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name: "disabled",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(false),
+				Build:  boolPtr(true),
+				Public: boolPtr(false),
+			},
+			method:     http.MethodGet,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "address denied",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Public: boolPtr(false),
+			},
+			allow:      allow,
+			method:     http.MethodGet,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "token allowed",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Token:  stringPtr("secret"),
+				Public: boolPtr(false),
+			},
+			method:        http.MethodGet,
+			authorization: "Bearer secret",
+			wantStatus:    http.StatusOK,
+			wantBuild:     true,
+			wantUptime:    true,
+		},
+		{
+			name: "token denied",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Token:  stringPtr("secret"),
+				Public: boolPtr(false),
+			},
+			method:        http.MethodGet,
+			authorization: "Bearer invalid",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name: "public",
+			config: &statusHandlerConfig{
+				Enable: boolPtr(true),
+				Build:  boolPtr(true),
+				Token:  stringPtr("secret"),
+				Public: boolPtr(true),
+			},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &statusHandler{
+				config: tt.config,
+				logger: slog.Default(),
+				start:  time.Now(),
+				allow:  tt.allow,
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/status", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("statusHandler.ServeHTTP() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got statusResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("statusHandler.ServeHTTP() body = %v", w.Body.String())
+			}
+			if got.Status != statusOK || (got.Build != nil) != tt.wantBuild || (got.Uptime != nil) != tt.wantUptime {
+				t.Errorf("statusHandler.ServeHTTP() response = %+v", got)
+			}
+			if tt.wantBuild && got.Build.GoVersion == "" {
+				t.Errorf("statusHandler.ServeHTTP() build = %+v", got.Build)
+			}
+			if tt.wantUptime != (got.Metrics["test.counter"] == 1) {
+				t.Errorf("statusHandler.ServeHTTP() metrics = %+v", got.Metrics)
+			}
+		})
+	}
+}
+
+func TestStatusHandlerServeHTTPLogLevel(t *testing.T) {
+	allow, err := access.ParseList([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.SetDefaultLevel(slog.LevelInfo)
+
+	tests := []struct {
+		name       string
+		logLevel   bool
+		allow      *access.List
+		target     string
+		wantStatus int
+		wantLevel  slog.Level
+	}{
+		{
+			name:       "default",
+			logLevel:   true,
+			target:     "/status?level=debug&ttl=60",
+			wantStatus: http.StatusOK,
+			wantLevel:  slog.LevelDebug,
+		},
+		{
+			name:       "disabled",
+			target:     "/status?level=debug",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "invalid level",
+			logLevel:   true,
+			target:     "/status?level=verbose",
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "invalid ttl",
+			logLevel:   true,
+			target:     "/status?level=debug&ttl=0",
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "address denied",
+			logLevel:   true,
+			allow:      allow,
+			target:     "/status?level=debug",
+			wantStatus: http.StatusForbidden,
+			wantLevel:  slog.LevelInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log.SetDefaultLevel(slog.LevelInfo)
+
+			h := &statusHandler{
+				config: &statusHandlerConfig{
+					Enable:   boolPtr(true),
+					Build:    boolPtr(false),
+					Public:   boolPtr(true),
+					LogLevel: boolPtr(tt.logLevel),
+				},
+				logger: slog.Default(),
+				start:  time.Now(),
+				allow:  tt.allow,
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("statusHandler.ServeHTTP() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if level, _ := log.Level(); level != tt.wantLevel {
+				t.Errorf("statusHandler.ServeHTTP() level = %v, want %v", level, tt.wantLevel)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got statusResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("statusHandler.ServeHTTP() body = %v", w.Body.String())
+			}
+			if got.Log == nil || got.Log.Level != tt.wantLevel.String() || got.Log.Until == nil {
+				t.Errorf("statusHandler.ServeHTTP() log = %+v", got.Log)
+			}
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/priority"
+	"github.com/bhuisgen/neon/pkg/redact"
+)
+
+// alertMiddleware implements the alert middleware.
+type alertMiddleware struct {
+	config  *alertMiddlewareConfig
+	logger  *slog.Logger
+	site    string
+	client  *http.Client
+	routes  map[string]*alertRoute
+	alerted map[string]time.Time
+	mu      *sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// alertMiddlewareConfig implements the alert middleware configuration.
+type alertMiddlewareConfig struct {
+	Window         *int     `mapstructure:"window"`
+	Threshold      *int     `mapstructure:"threshold"`
+	Cooldown       *int     `mapstructure:"cooldown"`
+	MaxRoutes      *int     `mapstructure:"maxRoutes"`
+	MaxExamples    *int     `mapstructure:"maxExamples"`
+	Webhook        *string  `mapstructure:"webhook"`
+	WebhookTimeout *int     `mapstructure:"webhookTimeout"`
+	Headers        []string `mapstructure:"headers"`
+}
+
+// alertRoute implements the errors of a route aggregated over the current window.
+type alertRoute struct {
+	count      int
+	statuses   map[int]int
+	requestIds []string
+	examples   []alertPayloadExample
+}
+
+// alertPayload implements the payload of an alert.
+type alertPayload struct {
+	Site   string              `json:"site"`
+	Start  time.Time           `json:"start"`
+	Window int                 `json:"window"`
+	Routes []alertPayloadRoute `json:"routes"`
+}
+
+// alertPayloadRoute implements the errors of a route in the payload of an alert.
+type alertPayloadRoute struct {
+	Path       string                `json:"path"`
+	Count      int                   `json:"count"`
+	Statuses   map[string]int        `json:"statuses"`
+	RequestIds []string              `json:"requestIds"`
+	Examples   []alertPayloadExample `json:"examples,omitempty"`
+}
+
+// alertPayloadExample implements the redacted request headers of an error example in the payload of an alert.
+type alertPayloadExample struct {
+	RequestId string            `json:"requestId,omitempty"`
+	Headers   map[string]string `json:"headers"`
+}
+
+const (
+	alertModuleID module.ModuleID = "app.server.site.middleware.alert"
+
+	alertConfigDefaultWindow         int = 60
+	alertConfigDefaultThreshold      int = 1
+	alertConfigDefaultCooldown       int = 900
+	alertConfigDefaultMaxRoutes      int = 100
+	alertConfigDefaultMaxExamples    int = 5
+	alertConfigDefaultWebhookTimeout int = 5
+
+	alertHeaderRequestId string = "X-Request-ID"
+	alertRouteOther      string = "other"
+)
+
+// init initializes the package.
+func init() {
+	module.Register(alertMiddleware{})
+}
+
+// ModuleInfo returns the module information.
+func (m alertMiddleware) ModuleInfo() module.ModuleInfo {
+	return module.ModuleInfo{
+		ID:           alertModuleID,
+		LoadModule:   func() {},
+		UnloadModule: func() {},
+		NewInstance: func() module.Module {
+			return &alertMiddleware{
+				logger:  slog.New(log.NewHandler(os.Stderr, string(alertModuleID), nil)),
+				routes:  make(map[string]*alertRoute),
+				alerted: make(map[string]time.Time),
+				mu:      &sync.Mutex{},
+			}
+		},
+	}
+}
+
+// Init initializes the middleware.
+func (m *alertMiddleware) Init(config map[string]interface{}) error {
+	if err := mapstructure.Decode(config, &m.config); err != nil {
+		m.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	var errConfig bool
+
+	if m.config.Window == nil {
+		defaultValue := alertConfigDefaultWindow
+		m.config.Window = &defaultValue
+	}
+	if *m.config.Window <= 0 {
+		m.logger.Error("Invalid value", "option", "Window", "value", *m.config.Window)
+		errConfig = true
+	}
+	if m.config.Threshold == nil {
+		defaultValue := alertConfigDefaultThreshold
+		m.config.Threshold = &defaultValue
+	}
+	if *m.config.Threshold <= 0 {
+		m.logger.Error("Invalid value", "option", "Threshold", "value", *m.config.Threshold)
+		errConfig = true
+	}
+	if m.config.Cooldown == nil {
+		defaultValue := alertConfigDefaultCooldown
+		m.config.Cooldown = &defaultValue
+	}
+	if *m.config.Cooldown < 0 {
+		m.logger.Error("Invalid value", "option", "Cooldown", "value", *m.config.Cooldown)
+		errConfig = true
+	}
+	if m.config.MaxRoutes == nil {
+		defaultValue := alertConfigDefaultMaxRoutes
+		m.config.MaxRoutes = &defaultValue
+	}
+	if *m.config.MaxRoutes <= 0 {
+		m.logger.Error("Invalid value", "option", "MaxRoutes", "value", *m.config.MaxRoutes)
+		errConfig = true
+	}
+	if m.config.MaxExamples == nil {
+		defaultValue := alertConfigDefaultMaxExamples
+		m.config.MaxExamples = &defaultValue
+	}
+	if *m.config.MaxExamples < 0 {
+		m.logger.Error("Invalid value", "option", "MaxExamples", "value", *m.config.MaxExamples)
+		errConfig = true
+	}
+	for _, name := range m.config.Headers {
+		if name == "" {
+			m.logger.Error("Invalid value", "option", "Headers", "value", name)
+			errConfig = true
+		}
+	}
+	if m.config.Webhook != nil {
+		if u, err := url.Parse(*m.config.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+			m.logger.Error("Invalid value", "option", "Webhook", "value", *m.config.Webhook)
+			errConfig = true
+		}
+	}
+	if m.config.WebhookTimeout == nil {
+		defaultValue := alertConfigDefaultWebhookTimeout
+		m.config.WebhookTimeout = &defaultValue
+	}
+	if *m.config.WebhookTimeout <= 0 {
+		m.logger.Error("Invalid value", "option", "WebhookTimeout", "value", *m.config.WebhookTimeout)
+		errConfig = true
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	m.client = &http.Client{
+		Timeout: time.Duration(*m.config.WebhookTimeout) * time.Second,
+	}
+
+	return nil
+}
+
+// Register registers the middleware.
+func (m *alertMiddleware) Register(site core.ServerSite) error {
+	m.site = site.Name()
+
+	if err := site.RegisterMiddleware(m.Handler); err != nil {
+		return fmt.Errorf("register middleware: %v", err)
+	}
+
+	return nil
+}
+
+// Start starts the middleware.
+func (m *alertMiddleware) Start() error {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(time.Duration(*m.config.Window) * time.Second)
+		defer ticker.Stop()
+
+		start := time.Now()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case now := <-ticker.C:
+				m.flush(start)
+				start = now
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the middleware.
+func (m *alertMiddleware) Stop() error {
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+		m.stop = nil
+	}
+
+	return nil
+}
+
+// Degradable implements priority.Degradable.
+func (m *alertMiddleware) Degradable() bool {
+	return true
+}
+
+// Handler implements the middleware handler.
+func (m *alertMiddleware) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		wrapped := alertResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(&wrapped, r)
+
+		if wrapped.status >= 500 {
+			m.record(normalize.Path(r.URL.Path), wrapped.status, w.Header().Get(alertHeaderRequestId), m.headers(r))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// headers returns the redacted values of the configured headers of the request, or nil if no header is configured.
+func (m *alertMiddleware) headers(r *http.Request) map[string]string {
+	if len(m.config.Headers) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string, len(m.config.Headers))
+	for _, name := range m.config.Headers {
+		if values := r.Header.Values(name); len(values) > 0 {
+			headers[name] = redact.Value(name, strings.Join(values, ", "))
+		}
+	}
+
+	return headers
+}
+
+// record aggregates an error response of the given route with the headers of its request kept as example.
+//
+// Once the maximum number of routes is reached in the current window, the errors of the new routes are aggregated
+// together to keep the memory bounded.
+func (m *alertMiddleware) record(path string, status int, requestId string, headers map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.routes[path]
+	if !ok {
+		if len(m.routes) >= *m.config.MaxRoutes {
+			path = alertRouteOther
+			route, ok = m.routes[path]
+		}
+		if !ok {
+			route = &alertRoute{
+				statuses: make(map[int]int),
+			}
+			m.routes[path] = route
+		}
+	}
+	route.count++
+	route.statuses[status]++
+	if requestId != "" && len(route.requestIds) < *m.config.MaxExamples {
+		route.requestIds = append(route.requestIds, requestId)
+	}
+	if headers != nil && len(route.examples) < *m.config.MaxExamples {
+		route.examples = append(route.examples, alertPayloadExample{
+			RequestId: requestId,
+			Headers:   headers,
+		})
+	}
+}
+
+// flush sends a single alert with the routes of the ended window exceeding the threshold.
+//
+// A route already alerted is not alerted again before the end of the cooldown period.
+func (m *alertMiddleware) flush(start time.Time) {
+	now := time.Now()
+
+	m.mu.Lock()
+	routes := m.routes
+	m.routes = make(map[string]*alertRoute)
+	alert := alertPayload{
+		Site:   m.site,
+		Start:  start,
+		Window: *m.config.Window,
+	}
+	for path, route := range routes {
+		if route.count < *m.config.Threshold {
+			continue
+		}
+		if last, ok := m.alerted[path]; ok && now.Sub(last) < time.Duration(*m.config.Cooldown)*time.Second {
+			continue
+		}
+		m.alerted[path] = now
+
+		statuses := make(map[string]int, len(route.statuses))
+		for status, count := range route.statuses {
+			statuses[strconv.Itoa(status)] = count
+		}
+		alert.Routes = append(alert.Routes, alertPayloadRoute{
+			Path:       path,
+			Count:      route.count,
+			Statuses:   statuses,
+			RequestIds: route.requestIds,
+			Examples:   route.examples,
+		})
+	}
+	for path, last := range m.alerted {
+		if now.Sub(last) >= time.Duration(*m.config.Cooldown)*time.Second {
+			delete(m.alerted, path)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(alert.Routes) == 0 {
+		return
+	}
+	sort.Slice(alert.Routes, func(i, j int) bool {
+		return alert.Routes[i].Count > alert.Routes[j].Count
+	})
+
+	for _, route := range alert.Routes {
+		m.logger.Warn("Server errors detected", "site", alert.Site, "path", route.Path, "count", route.Count,
+			"statuses", route.Statuses, "requestIds", route.RequestIds, "window", alert.Window)
+	}
+
+	if m.config.Webhook != nil {
+		if err := m.send(&alert); err != nil {
+			m.logger.Error("Failed to send alert", "webhook", *m.config.Webhook, "err", err)
+		}
+	}
+}
+
+// send posts the alert to the webhook.
+func (m *alertMiddleware) send(alert *alertPayload) error {
+	buf, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %v", err)
+	}
+	response, err := m.client.Post(*m.config.Webhook, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("post alert: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook error %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// alertResponseWriter implements the alert response writer.
+type alertResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+}
+
+// WriteHeader sends an HTTP response header with the provided status code.
+func (w *alertResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response data.
+func (w *alertResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered data.
+func (w *alertResponseWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original response writer.
+func (w *alertResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var _ core.ServerSiteMiddlewareModule = (*alertMiddleware)(nil)
+var _ priority.Degradable = (*alertMiddleware)(nil)
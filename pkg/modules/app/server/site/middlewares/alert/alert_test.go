@@ -0,0 +1,418 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/redact"
+)
+
+type testAlertMiddlewareServerSite struct {
+	err bool
+}
+
+func (s testAlertMiddlewareServerSite) Name() string {
+	return "test"
+}
+
+func (s testAlertMiddlewareServerSite) Listeners() []string {
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) Hosts() []string {
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) IsDefault() bool {
+	return false
+}
+
+func (s testAlertMiddlewareServerSite) Store() core.Store {
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) Loader() core.Loader {
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) Server() core.Server {
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) RegisterMiddleware(middleware func(next http.Handler) http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+func (s testAlertMiddlewareServerSite) RegisterHandler(handler http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+var _ core.ServerSite = (*testAlertMiddlewareServerSite)(nil)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestAlertMiddlewareModuleInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		want module.ModuleInfo
+	}{
+		{
+			name: "default",
+			want: module.ModuleInfo{
+				ID:          alertModuleID,
+				NewInstance: func() module.Module { return &alertMiddleware{} },
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := alertMiddleware{}
+			got := m.ModuleInfo()
+			if got.ID != tt.want.ID {
+				t.Errorf("alertMiddleware.ModuleInfo() = %v, want %v", got.ID, tt.want.ID)
+			}
+			if instance := got.NewInstance(); instance == nil {
+				t.Errorf("alertMiddleware.NewInstance() = %v, want %v", instance, "not nil")
+			}
+		})
+	}
+}
+
+func TestAlertMiddlewareInit(t *testing.T) {
+	type args struct {
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "minimal",
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+		{
+			name: "full",
+			args: args{
+				config: map[string]interface{}{
+					"Window":         30,
+					"Threshold":      10,
+					"Cooldown":       0,
+					"MaxRoutes":      10,
+					"MaxExamples":    3,
+					"Webhook":        "https://localhost/alert",
+					"WebhookTimeout": 1,
+					"Headers":        []string{"Referer"},
+				},
+			},
+		},
+		{
+			name: "invalid values",
+			args: args{
+				config: map[string]interface{}{
+					"Window":         0,
+					"Threshold":      0,
+					"Cooldown":       -1,
+					"MaxRoutes":      0,
+					"MaxExamples":    -1,
+					"Webhook":        "localhost",
+					"WebhookTimeout": 0,
+					"Headers":        []string{""},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error parse",
+			args: args{
+				config: map[string]interface{}{
+					"Window": "invalid",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &alertMiddleware{
+				logger: slog.Default(),
+			}
+			if err := m.Init(tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("alertMiddleware.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlertMiddlewareRegister(t *testing.T) {
+	type args struct {
+		site core.ServerSite
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				site: testAlertMiddlewareServerSite{},
+			},
+		},
+		{
+			name: "error register",
+			args: args{
+				site: testAlertMiddlewareServerSite{
+					err: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &alertMiddleware{}
+			if err := m.Register(tt.args.site); (err != nil) != tt.wantErr {
+				t.Errorf("alertMiddleware.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlertMiddlewareStartStop(t *testing.T) {
+	m := &alertMiddleware{
+		config: &alertMiddlewareConfig{
+			Window: intPtr(60),
+		},
+	}
+	if err := m.Start(); err != nil {
+		t.Errorf("alertMiddleware.Start() error = %v", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("alertMiddleware.Stop() error = %v", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("alertMiddleware.Stop() error = %v", err)
+	}
+}
+
+func TestAlertMiddlewareHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCount int
+	}{
+		{
+			name:   "default",
+			status: http.StatusOK,
+		},
+		{
+			name:   "client error",
+			status: http.StatusNotFound,
+		},
+		{
+			name:      "server error",
+			status:    http.StatusServiceUnavailable,
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &alertMiddleware{
+				config: &alertMiddlewareConfig{
+					MaxRoutes:   intPtr(10),
+					MaxExamples: intPtr(5),
+				},
+				routes: make(map[string]*alertRoute),
+				mu:     &sync.Mutex{},
+			}
+			h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-ID", "id")
+				w.WriteHeader(tt.status)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+			var count int
+			if route, ok := m.routes["/test"]; ok {
+				count = route.count
+				if len(route.requestIds) != 1 || route.requestIds[0] != "id" {
+					t.Errorf("alertMiddleware.Handler() requestIds = %v, want %v", route.requestIds, []string{"id"})
+				}
+			}
+			if count != tt.wantCount {
+				t.Errorf("alertMiddleware.Handler() count = %v, want %v", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAlertMiddlewareHandlerHeaders(t *testing.T) {
+	m := &alertMiddleware{
+		config: &alertMiddlewareConfig{
+			MaxRoutes:   intPtr(10),
+			MaxExamples: intPtr(5),
+			Headers:     []string{"Referer", "Authorization", "X-Missing"},
+		},
+		routes: make(map[string]*alertRoute),
+		mu:     &sync.Mutex{},
+	}
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "id")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Referer", "http://localhost/")
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := []alertPayloadExample{
+		{
+			RequestId: "id",
+			Headers: map[string]string{
+				"Referer":       "http://localhost/",
+				"Authorization": redact.Redacted,
+			},
+		},
+	}
+	if got := m.routes["/test"].examples; !reflect.DeepEqual(got, want) {
+		t.Errorf("alertMiddleware.Handler() examples = %v, want %v", got, want)
+	}
+}
+
+func TestAlertMiddlewareRecord(t *testing.T) {
+	m := &alertMiddleware{
+		config: &alertMiddlewareConfig{
+			MaxRoutes:   intPtr(1),
+			MaxExamples: intPtr(1),
+		},
+		routes: make(map[string]*alertRoute),
+		mu:     &sync.Mutex{},
+	}
+	m.record("/a", http.StatusInternalServerError, "1", nil)
+	m.record("/a", http.StatusBadGateway, "2", nil)
+	m.record("/b", http.StatusInternalServerError, "3", nil)
+	m.record("/c", http.StatusInternalServerError, "4", nil)
+
+	if got := len(m.routes); got != 2 {
+		t.Errorf("alertMiddleware.record() routes = %v, want %v", got, 2)
+	}
+	if got := m.routes["/a"]; got.count != 2 || len(got.statuses) != 2 || len(got.requestIds) != 1 {
+		t.Errorf("alertMiddleware.record() route = %+v", got)
+	}
+	if got := m.routes[alertRouteOther]; got == nil || got.count != 2 {
+		t.Errorf("alertMiddleware.record() other route = %+v", got)
+	}
+}
+
+func TestAlertMiddlewareFlush(t *testing.T) {
+	var alerts []alertPayload
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		var alert alertPayload
+		if err := json.Unmarshal(buf, &alert); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		alerts = append(alerts, alert)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	m := &alertMiddleware{
+		config: &alertMiddlewareConfig{
+			Window:      intPtr(60),
+			Threshold:   intPtr(2),
+			Cooldown:    intPtr(900),
+			MaxRoutes:   intPtr(10),
+			MaxExamples: intPtr(5),
+			Webhook:     stringPtr(server.URL),
+		},
+		logger:  slog.Default(),
+		site:    "main",
+		client:  server.Client(),
+		routes:  make(map[string]*alertRoute),
+		alerted: make(map[string]time.Time),
+		mu:      &sync.Mutex{},
+	}
+
+	m.record("/a", http.StatusInternalServerError, "1", nil)
+	m.record("/a", http.StatusInternalServerError, "2", nil)
+	m.record("/b", http.StatusInternalServerError, "3", nil)
+	m.flush(time.Now())
+
+	m.record("/a", http.StatusInternalServerError, "4", nil)
+	m.record("/a", http.StatusInternalServerError, "5", nil)
+	m.flush(time.Now())
+
+	if len(alerts) != 1 {
+		t.Fatalf("alertMiddleware.flush() alerts = %v, want %v", len(alerts), 1)
+	}
+	if got := alerts[0]; got.Site != "main" || len(got.Routes) != 1 || got.Routes[0].Path != "/a" ||
+		got.Routes[0].Count != 2 || got.Routes[0].Statuses["500"] != 2 || len(got.Routes[0].RequestIds) != 2 {
+		t.Errorf("alertMiddleware.flush() alert = %+v", got)
+	}
+	if len(m.routes) != 0 {
+		t.Errorf("alertMiddleware.flush() routes = %v, want %v", len(m.routes), 0)
+	}
+}
+
+func TestAlertMiddlewareSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			status: http.StatusOK,
+		},
+		{
+			name:    "error status",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			m := &alertMiddleware{
+				config: &alertMiddlewareConfig{
+					Webhook: stringPtr(server.URL),
+				},
+				client: server.Client(),
+			}
+			if err := m.send(&alertPayload{}); (err != nil) != tt.wantErr {
+				t.Errorf("alertMiddleware.send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package alert implements the alert middleware.
+package alert
@@ -17,6 +17,8 @@ import (
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
+	"github.com/bhuisgen/neon/pkg/render"
 )
 
 // compressMiddleware implements the compress middleware.
@@ -110,26 +112,29 @@ func (m *compressMiddleware) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (m *compressMiddleware) Degradable() bool {
+	return true
+}
+
 // Handler implements the middleware handler.
 func (m *compressMiddleware) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get(compressHeaderAcceptEncoding), compressGzipScheme) {
+		acceptEncoding := r.Header.Get(compressHeaderAcceptEncoding)
+		if !strings.Contains(acceptEncoding, compressGzipScheme) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		w.Header().Set(compressHeaderContentEncoding, compressGzipScheme)
 		r.Header.Del(compressHeaderAcceptEncoding)
+		r = r.WithContext(render.NewAcceptEncodingContext(r.Context(), acceptEncoding))
 
 		writer := m.pool.Get()
 		writer.Reset(w)
 
 		rw := compressResponseWriter{Writer: writer, ResponseWriter: w}
 		next.ServeHTTP(&rw, r)
-		if !rw.wroteBody {
-			if w.Header().Get(compressHeaderContentEncoding) == compressGzipScheme {
-				r.Header.Del(compressHeaderAcceptEncoding)
-			}
+		if !rw.compressed || !rw.wroteBody {
 			writer.Reset(io.Discard)
 		}
 
@@ -141,15 +146,26 @@ func (m *compressMiddleware) Handler(next http.Handler) http.Handler {
 }
 
 // compressResponseWriter implements the compress response writer.
+//
+// A response already encoded by the next handler is written as is.
 type compressResponseWriter struct {
 	io.Writer
 	http.ResponseWriter
-	wroteBody bool
+	wroteHeader bool
+	compressed  bool
+	wroteBody   bool
 }
 
 // WriteHeader sends an HTTP response header with the provided status code.
 func (w *compressResponseWriter) WriteHeader(code int) {
-	w.Header().Del(compressHeaderContentLength)
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.Header().Get(compressHeaderContentEncoding) == "" {
+			w.Header().Set(compressHeaderContentEncoding, compressGzipScheme)
+			w.Header().Del(compressHeaderContentLength)
+			w.compressed = true
+		}
+	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
@@ -158,6 +174,12 @@ func (w *compressResponseWriter) Write(b []byte) (int, error) {
 	if w.Header().Get(compressHeaderContentType) == "" {
 		w.Header().Set(compressHeaderContentType, http.DetectContentType(b))
 	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.compressed {
+		return w.ResponseWriter.Write(b)
+	}
 	w.wroteBody = true
 	n, err := w.Writer.Write(b)
 	if err != nil {
@@ -168,7 +190,9 @@ func (w *compressResponseWriter) Write(b []byte) (int, error) {
 
 // Flush sends the buffered data.
 func (w *compressResponseWriter) Flush() {
-	w.Writer.(*gzip.Writer).Flush()
+	if w.compressed {
+		w.Writer.(*gzip.Writer).Flush()
+	}
 	if f, ok := w.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
 	}
@@ -193,4 +217,10 @@ func (w *compressResponseWriter) Push(target string, opts *http.PushOptions) err
 	return http.ErrNotSupported
 }
 
+// Unwrap returns the original response writer.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 var _ core.ServerSiteMiddlewareModule = (*compressMiddleware)(nil)
+var _ priority.Degradable = (*compressMiddleware)(nil)
@@ -1,13 +1,17 @@
 package compress
 
 import (
+	"compress/gzip"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/render"
 )
 
 type testCompressMiddlewareServerSite struct {
@@ -298,3 +302,63 @@ func TestCompressMiddlewareHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCompressMiddlewareHandlerEncoding(t *testing.T) {
+	tests := []struct {
+		name         string
+		encoding     string
+		wantEncoding string
+		wantBody     string
+	}{
+		{
+			name:         "compressed",
+			wantEncoding: compressGzipScheme,
+			wantBody:     "test",
+		},
+		{
+			name:         "pre-encoded",
+			encoding:     "br",
+			wantEncoding: "br",
+			wantBody:     "test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &compressMiddleware{
+				pool: newGzipPool(&GzipPoolConfig{}),
+			}
+			var acceptEncoding string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = render.AcceptEncoding(r)
+				if tt.encoding != "" {
+					w.Header().Set(compressHeaderContentEncoding, tt.encoding)
+				}
+				_, _ = w.Write([]byte("test"))
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(compressHeaderAcceptEncoding, "gzip, br")
+			w := httptest.NewRecorder()
+			m.Handler(next).ServeHTTP(w, r)
+
+			if acceptEncoding != "gzip, br" {
+				t.Errorf("render.AcceptEncoding() = %v, want %v", acceptEncoding, "gzip, br")
+			}
+			if got := w.Header().Get(compressHeaderContentEncoding); got != tt.wantEncoding {
+				t.Errorf("compressMiddleware.Handler() encoding = %v, want %v", got, tt.wantEncoding)
+			}
+			body := w.Body.Bytes()
+			if tt.wantEncoding == compressGzipScheme {
+				gr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(gr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("compressMiddleware.Handler() body = %v, want %v", string(body), tt.wantBody)
+			}
+		})
+	}
+}
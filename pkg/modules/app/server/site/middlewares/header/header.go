@@ -12,14 +12,19 @@ import (
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/match"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
 )
 
 // headerMiddleware implements the header middleware.
 type headerMiddleware struct {
-	config  *headerMiddlewareConfig
-	logger  *slog.Logger
-	regexps []*regexp.Regexp
+	config      *headerMiddlewareConfig
+	logger      *slog.Logger
+	regexps     []*regexp.Regexp
+	ruleSet     *pattern.Set
+	constraints []*match.Constraint
 }
 
 // headerMiddlewareConfig implements the header middleware configuration.
@@ -29,9 +34,11 @@ type headerMiddlewareConfig struct {
 
 // HeaderRule implements a header rule.
 type HeaderRule struct {
-	Path string            `mapstructure:"path"`
-	Set  map[string]string `mapstructure:"set"`
-	Last bool              `mapstructure:"last"`
+	Path    string            `mapstructure:"path"`
+	Host    string            `mapstructure:"host"`
+	Methods []string          `mapstructure:"methods"`
+	Set     map[string]string `mapstructure:"set"`
+	Last    bool              `mapstructure:"last"`
 }
 
 const (
@@ -67,14 +74,21 @@ func (m *headerMiddleware) Init(config map[string]interface{}) error {
 	var errConfig bool
 
 	for index, rule := range m.config.Rules {
+		constraint, err := match.NewConstraint(rule.Host, rule.Methods)
+		if err != nil {
+			m.logger.Error("Invalid constraint", "rule", index+1, "err", err)
+			errConfig = true
+		}
+		m.constraints = append(m.constraints, constraint)
 		if rule.Path == "" {
 			m.logger.Error("Missing option or value", "rule", index+1, "option", "Path")
 			errConfig = true
 			continue
 		}
-		re, err := regexp.Compile(rule.Path)
+		re, err := pattern.Compile(rule.Path)
 		if err != nil {
-			m.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path)
+			m.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path,
+				"err", err)
 			errConfig = true
 			continue
 		} else {
@@ -93,6 +107,8 @@ func (m *headerMiddleware) Init(config map[string]interface{}) error {
 		return errors.New("config")
 	}
 
+	m.ruleSet = pattern.NewSet(m.regexps)
+
 	return nil
 }
 
@@ -115,17 +131,23 @@ func (m *headerMiddleware) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (m *headerMiddleware) Degradable() bool {
+	return true
+}
+
 // Handler implements the middleware handler.
 func (m *headerMiddleware) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		for index, regexp := range m.regexps {
-			if regexp.MatchString(r.URL.Path) {
-				for k, v := range m.config.Rules[index].Set {
-					w.Header().Set(k, v)
-				}
-				if m.config.Rules[index].Last {
-					break
-				}
+		for index := m.ruleSet.Next(r.URL.Path, 0); index >= 0; index = m.ruleSet.Next(r.URL.Path, index+1) {
+			if !m.constraints[index].Match(r) {
+				continue
+			}
+			for k, v := range m.config.Rules[index].Set {
+				w.Header().Set(k, v)
+			}
+			if m.config.Rules[index].Last {
+				break
 			}
 		}
 
@@ -136,3 +158,4 @@ func (m *headerMiddleware) Handler(next http.Handler) http.Handler {
 }
 
 var _ core.ServerSiteMiddlewareModule = (*headerMiddleware)(nil)
+var _ priority.Degradable = (*headerMiddleware)(nil)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"testing"
 
@@ -133,7 +134,9 @@ func TestHeaderMiddlewareInit(t *testing.T) {
 				config: map[string]interface{}{
 					"Rules": []map[string]interface{}{
 						{
-							"Path": "/.*",
+							"Path":    "/.*",
+							"Host":    `^example\.com$`,
+							"Methods": []string{"GET"},
 							"Set": map[string]string{
 								"test1": "value1",
 							},
@@ -151,7 +154,9 @@ func TestHeaderMiddlewareInit(t *testing.T) {
 				config: map[string]interface{}{
 					"Rules": []map[string]interface{}{
 						{
-							"Path": "",
+							"Path":    "",
+							"Host":    "(",
+							"Methods": []string{""},
 							"Set": map[string]string{
 								"": "",
 							},
@@ -331,3 +336,57 @@ func TestHeaderMiddlewareHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaderMiddlewareHandlerConstraint(t *testing.T) {
+	m := &headerMiddleware{
+		logger: slog.Default(),
+	}
+	if err := m.Init(map[string]interface{}{
+		"Rules": []map[string]interface{}{
+			{
+				"Path":    "^/",
+				"Host":    `^example\.com$`,
+				"Methods": []string{"GET"},
+				"Set": map[string]string{
+					"X-Test": "value",
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("headerMiddleware.Init() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   string
+	}{
+		{
+			name:   "match",
+			method: http.MethodGet,
+			target: "http://example.com:8080/test",
+			want:   "value",
+		},
+		{
+			name:   "host mismatch",
+			method: http.MethodGet,
+			target: "http://example.org/test",
+		},
+		{
+			name:   "method mismatch",
+			method: http.MethodPost,
+			target: "http://example.com/test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w,
+				httptest.NewRequest(tt.method, tt.target, nil))
+			if got := w.Header().Get("X-Test"); got != tt.want {
+				t.Errorf("headerMiddleware.Handler() header = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package include implements the include middleware.
+package include
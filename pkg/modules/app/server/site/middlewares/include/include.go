@@ -0,0 +1,321 @@
+package include
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/include"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+// includeMiddleware implements the include middleware.
+type includeMiddleware struct {
+	config *includeMiddlewareConfig
+	logger *slog.Logger
+	rwPool render.RenderWriterPool
+	cache  map[string]*includeFragment
+	mu     *sync.RWMutex
+}
+
+// includeMiddlewareConfig implements the include middleware configuration.
+type includeMiddlewareConfig struct {
+	TTL          *int `mapstructure:"ttl"`
+	MaxIncludes  *int `mapstructure:"maxIncludes"`
+	MaxFragments *int `mapstructure:"maxFragments"`
+}
+
+// includeFragment implements a cached fragment.
+type includeFragment struct {
+	body   []byte
+	expire time.Time
+}
+
+const (
+	includeModuleID module.ModuleID = "app.server.site.middleware.include"
+
+	includeConfigDefaultTTL          int = 60
+	includeConfigDefaultMaxIncludes  int = 10
+	includeConfigDefaultMaxFragments int = 100
+
+	includeHeaderAcceptEncoding  = "Accept-Encoding"
+	includeHeaderCacheControl    = "Cache-Control"
+	includeHeaderContentEncoding = "Content-Encoding"
+	includeHeaderContentLength   = "Content-Length"
+	includeHeaderContentType     = "Content-Type"
+)
+
+// init initializes the package.
+func init() {
+	module.Register(includeMiddleware{})
+}
+
+// ModuleInfo returns the module information.
+func (m includeMiddleware) ModuleInfo() module.ModuleInfo {
+	return module.ModuleInfo{
+		ID:           includeModuleID,
+		LoadModule:   func() {},
+		UnloadModule: func() {},
+		NewInstance: func() module.Module {
+			return &includeMiddleware{
+				logger: slog.New(log.NewHandler(os.Stderr, string(includeModuleID), nil)),
+				cache:  make(map[string]*includeFragment),
+				mu:     &sync.RWMutex{},
+			}
+		},
+	}
+}
+
+// Init initializes the middleware.
+func (m *includeMiddleware) Init(config map[string]interface{}) error {
+	if err := mapstructure.Decode(config, &m.config); err != nil {
+		m.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	var errConfig bool
+
+	if m.config.TTL == nil {
+		defaultValue := includeConfigDefaultTTL
+		m.config.TTL = &defaultValue
+	}
+	if *m.config.TTL < 0 {
+		m.logger.Error("Invalid value", "option", "TTL", "value", *m.config.TTL)
+		errConfig = true
+	}
+	if m.config.MaxIncludes == nil {
+		defaultValue := includeConfigDefaultMaxIncludes
+		m.config.MaxIncludes = &defaultValue
+	}
+	if *m.config.MaxIncludes <= 0 {
+		m.logger.Error("Invalid value", "option", "MaxIncludes", "value", *m.config.MaxIncludes)
+		errConfig = true
+	}
+	if m.config.MaxFragments == nil {
+		defaultValue := includeConfigDefaultMaxFragments
+		m.config.MaxFragments = &defaultValue
+	}
+	if *m.config.MaxFragments <= 0 {
+		m.logger.Error("Invalid value", "option", "MaxFragments", "value", *m.config.MaxFragments)
+		errConfig = true
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	m.rwPool = render.NewRenderWriterPool()
+
+	return nil
+}
+
+// Register registers the middleware.
+func (m *includeMiddleware) Register(site core.ServerSite) error {
+	if err := site.RegisterMiddleware(m.Handler); err != nil {
+		return fmt.Errorf("register middleware: %v", err)
+	}
+
+	return nil
+}
+
+// Start starts the middleware.
+func (m *includeMiddleware) Start() error {
+	return nil
+}
+
+// Stop stops the middleware.
+func (m *includeMiddleware) Stop() error {
+	m.mu.Lock()
+	m.cache = make(map[string]*includeFragment)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Handler implements the middleware handler.
+//
+// The HTML responses are buffered to replace their include tags by the responses of the included routes, each one
+// served by the next handlers in a sub-request and cached with its own TTL. The next handlers are asked for an
+// unencoded response so that the tags can be found.
+func (m *includeMiddleware) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || include.FromContext(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Header.Del(includeHeaderAcceptEncoding)
+		r = r.WithContext(render.NewAcceptEncodingContext(r.Context(), ""))
+
+		rw := includeResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(&rw, r)
+		if !rw.buffered {
+			return
+		}
+
+		body := rw.buf.Bytes()
+		if include.Contains(body) {
+			body = include.Replace(body, *m.config.MaxIncludes, func(path string) []byte {
+				return m.fragment(next, r, path)
+			})
+		}
+
+		w.Header().Del(includeHeaderContentLength)
+		w.WriteHeader(rw.status)
+		if _, err := w.Write(body); err != nil {
+			m.logger.Error("Failed to write response", "err", err)
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// fragment returns the response of the included route, served from the cache if not expired.
+//
+// The fragment is empty if the route does not respond with a success status.
+func (m *includeMiddleware) fragment(next http.Handler, r *http.Request, path string) []byte {
+	key := r.Host + path
+
+	m.mu.RLock()
+	cached, ok := m.cache[key]
+	m.mu.RUnlock()
+	if ok && cached.expire.After(time.Now()) {
+		return cached.body
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		m.logger.Warn("Invalid include", "url", r.URL.Path, "path", path)
+		return nil
+	}
+	sub := r.Clone(include.NewContext(r.Context()))
+	sub.URL.Path = u.Path
+	sub.URL.RawPath = u.RawPath
+	sub.URL.RawQuery = u.RawQuery
+	sub.RequestURI = u.RequestURI()
+	sub.Body = http.NoBody
+	sub.ContentLength = 0
+	sub.Header.Del("If-None-Match")
+	sub.Header.Del("If-Modified-Since")
+
+	rw := m.rwPool.Get()
+	defer m.rwPool.Put(rw)
+
+	next.ServeHTTP(rw, sub)
+	if rw.StatusCode() != http.StatusOK || rw.Header().Get(includeHeaderContentEncoding) != "" {
+		m.logger.Warn("Include error", "url", r.URL.Path, "path", path, "status", rw.StatusCode())
+		return nil
+	}
+	body := bytes.Clone(rw.Render().Body())
+
+	ttl := includeFragmentTTL(rw.Header(), *m.config.TTL)
+	if ttl > 0 {
+		now := time.Now()
+		m.mu.Lock()
+		if len(m.cache) >= *m.config.MaxFragments {
+			for k, f := range m.cache {
+				if !f.expire.After(now) {
+					delete(m.cache, k)
+				}
+			}
+		}
+		if len(m.cache) < *m.config.MaxFragments || ok {
+			m.cache[key] = &includeFragment{
+				body:   body,
+				expire: now.Add(time.Duration(ttl) * time.Second),
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	return body
+}
+
+// includeFragmentTTL returns the TTL of a fragment from the max-age directive of its response if any, or the given
+// default TTL. A fragment marked with the no-store or private directive is not cached.
+func includeFragmentTTL(header http.Header, defaultTTL int) int {
+	for _, directive := range strings.Split(header.Get(includeHeaderCacheControl), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0
+		case "max-age":
+			if ttl, err := strconv.Atoi(value); err == nil && ttl >= 0 {
+				return ttl
+			}
+		}
+	}
+
+	return defaultTTL
+}
+
+// includeResponseWriter implements the include response writer.
+//
+// A HTML response not encoded by the next handler is buffered and the others are written as is.
+type includeResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	buffered    bool
+	status      int
+	buf         bytes.Buffer
+}
+
+// WriteHeader sends an HTTP response header with the provided status code.
+func (w *includeResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get(includeHeaderContentType))
+	if mediaType == "text/html" && w.Header().Get(includeHeaderContentEncoding) == "" {
+		w.buffered = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response data.
+func (w *includeResponseWriter) Write(b []byte) (int, error) {
+	if w.Header().Get(includeHeaderContentType) == "" {
+		w.Header().Set(includeHeaderContentType, http.DetectContentType(b))
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffered {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered data.
+func (w *includeResponseWriter) Flush() {
+	if w.buffered {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original response writer.
+func (w *includeResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var _ core.ServerSiteMiddlewareModule = (*includeMiddleware)(nil)
@@ -0,0 +1,321 @@
+package include
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+type testIncludeMiddlewareServerSite struct {
+	err bool
+}
+
+func (s testIncludeMiddlewareServerSite) Name() string {
+	return "test"
+}
+
+func (s testIncludeMiddlewareServerSite) Listeners() []string {
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) Hosts() []string {
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) IsDefault() bool {
+	return false
+}
+
+func (s testIncludeMiddlewareServerSite) Store() core.Store {
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) Loader() core.Loader {
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) Server() core.Server {
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) RegisterMiddleware(middleware func(next http.Handler) http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+func (s testIncludeMiddlewareServerSite) RegisterHandler(handler http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+var _ core.ServerSite = (*testIncludeMiddlewareServerSite)(nil)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestIncludeMiddlewareModuleInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		want module.ModuleInfo
+	}{
+		{
+			name: "default",
+			want: module.ModuleInfo{
+				ID:          includeModuleID,
+				NewInstance: func() module.Module { return &includeMiddleware{} },
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := includeMiddleware{}
+			got := m.ModuleInfo()
+			if got.ID != tt.want.ID {
+				t.Errorf("includeMiddleware.ModuleInfo() = %v, want %v", got.ID, tt.want.ID)
+			}
+			if instance := got.NewInstance(); instance == nil {
+				t.Errorf("includeMiddleware.NewInstance() = %v, want %v", instance, "not nil")
+			}
+		})
+	}
+}
+
+func TestIncludeMiddlewareInit(t *testing.T) {
+	type args struct {
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "minimal",
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+		{
+			name: "full",
+			args: args{
+				config: map[string]interface{}{
+					"TTL":          0,
+					"MaxIncludes":  5,
+					"MaxFragments": 10,
+				},
+			},
+		},
+		{
+			name: "invalid values",
+			args: args{
+				config: map[string]interface{}{
+					"TTL":          -1,
+					"MaxIncludes":  0,
+					"MaxFragments": 0,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error parse",
+			args: args{
+				config: map[string]interface{}{
+					"TTL": "invalid",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &includeMiddleware{
+				logger: slog.Default(),
+			}
+			if err := m.Init(tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("includeMiddleware.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIncludeMiddlewareRegister(t *testing.T) {
+	type args struct {
+		site core.ServerSite
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				site: testIncludeMiddlewareServerSite{},
+			},
+		},
+		{
+			name: "error register",
+			args: args{
+				site: testIncludeMiddlewareServerSite{
+					err: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &includeMiddleware{}
+			if err := m.Register(tt.args.site); (err != nil) != tt.wantErr {
+				t.Errorf("includeMiddleware.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIncludeMiddlewareStartStop(t *testing.T) {
+	m := &includeMiddleware{
+		mu: &sync.RWMutex{},
+	}
+	if err := m.Start(); err != nil {
+		t.Errorf("includeMiddleware.Start() error = %v", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("includeMiddleware.Stop() error = %v", err)
+	}
+}
+
+func TestIncludeMiddlewareHandler(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			calls++
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("<header>" + r.URL.Query().Get("title") + "</header>"))
+		case "/footer":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte("<footer></footer>"))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"tag":"<neon-include src=\"/header\"></neon-include>"}`))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`<body><neon-include src="/header?title=a"></neon-include>` +
+				`<neon-include src="/missing"></neon-include><neon-include src="/footer"></neon-include></body>`))
+		}
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "default",
+			path:       "/",
+			wantStatus: http.StatusCreated,
+			wantBody:   "<body><header>a</header><footer></footer></body>",
+		},
+		{
+			name:       "cached fragment",
+			path:       "/",
+			wantStatus: http.StatusCreated,
+			wantBody:   "<body><header>a</header><footer></footer></body>",
+		},
+		{
+			name:       "not html",
+			path:       "/data",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"tag":"<neon-include src=\"/header\"></neon-include>"}`,
+		},
+	}
+
+	m := &includeMiddleware{
+		config: &includeMiddlewareConfig{
+			TTL:          intPtr(60),
+			MaxIncludes:  intPtr(10),
+			MaxFragments: intPtr(10),
+		},
+		logger: slog.Default(),
+		rwPool: render.NewRenderWriterPool(),
+		cache:  make(map[string]*includeFragment),
+		mu:     &sync.RWMutex{},
+	}
+	h := m.Handler(next)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("includeMiddleware.Handler() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("includeMiddleware.Handler() body = %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+	if calls != 1 {
+		t.Errorf("includeMiddleware.Handler() fragment calls = %v, want %v", calls, 1)
+	}
+	if len(m.cache) != 1 {
+		t.Errorf("includeMiddleware.Handler() cached fragments = %v, want %v", len(m.cache), 1)
+	}
+}
+
+func TestIncludeFragmentTTL(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		want         int
+	}{
+		{
+			name: "default",
+			want: 60,
+		},
+		{
+			name:         "max age",
+			cacheControl: "public, max-age=300",
+			want:         300,
+		},
+		{
+			name:         "no store",
+			cacheControl: "no-store",
+			want:         0,
+		},
+		{
+			name:         "private",
+			cacheControl: "private, max-age=300",
+			want:         0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.cacheControl != "" {
+				header.Set("Cache-Control", tt.cacheControl)
+			}
+			if got := includeFragmentTTL(header, 60); got != tt.want {
+				t.Errorf("includeFragmentTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,45 +9,39 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"syscall"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/priority"
+	"github.com/bhuisgen/neon/pkg/redact"
+	"github.com/bhuisgen/neon/pkg/statedir"
+	"github.com/bhuisgen/neon/pkg/timing"
 )
 
 // loggerMiddleware implements the logger middleware.
 type loggerMiddleware struct {
-	config     *loggerMiddlewareConfig
-	logger     *slog.Logger
-	log        *log.Logger
-	reopen     chan os.Signal
-	osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-	osClose    func(*os.File) error
-	osStat     func(name string) (fs.FileInfo, error)
+	config *loggerMiddlewareConfig
+	logger *slog.Logger
+	log    *log.Logger
+	reopen chan os.Signal
+	osStat func(name string) (fs.FileInfo, error)
 }
 
 // loggerMiddlewareConfig implements the logger middleware configuration.
 type loggerMiddlewareConfig struct {
-	File *string `mapstructure:"file"`
+	File    *string  `mapstructure:"file"`
+	Headers []string `mapstructure:"headers"`
 }
 
 const (
 	loggerModuleID module.ModuleID = "app.server.site.middleware.logger"
 )
 
-// loggerOsOpenFile redirects to os.OpenFile.
-func loggerOsOpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
-	return os.OpenFile(name, flag, perm)
-}
-
-// loggerOsClose redirects to os.Close.
-func loggerOsClose(f *os.File) error {
-	return f.Close()
-}
-
 // loggerOsStat redirects to os.Stat.
 func loggerOsStat(name string) (fs.FileInfo, error) {
 	return os.Stat(name)
@@ -66,9 +60,7 @@ func (m loggerMiddleware) ModuleInfo() module.ModuleInfo {
 		UnloadModule: func() {},
 		NewInstance: func() module.Module {
 			return &loggerMiddleware{
-				osOpenFile: loggerOsOpenFile,
-				osClose:    loggerOsClose,
-				osStat:     loggerOsStat,
+				osStat: loggerOsStat,
 			}
 		},
 	}
@@ -88,25 +80,25 @@ func (m *loggerMiddleware) Init(config map[string]interface{}) error {
 			m.logger.Error("Invalid value", "option", "File", "value", *m.config.File)
 			errConfig = true
 		} else {
-			f, err := m.osOpenFile(*m.config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
-			if err != nil {
-				m.logger.Error("Failed to open file", "option", "File", "value", *m.config.File)
+			fi, err := m.osStat(statedir.Path(*m.config.File))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				m.logger.Error("Failed to stat file", "option", "File", "value", *m.config.File)
+				errConfig = true
+			}
+			if err == nil && fi.IsDir() {
+				m.logger.Error("File is a directory", "option", "File", "value", *m.config.File)
 				errConfig = true
-			} else {
-				_ = m.osClose(f)
-				fi, err := m.osStat(*m.config.File)
-				if err != nil {
-					m.logger.Error("Failed to stat file", "option", "File", "value", *m.config.File)
-					errConfig = true
-				}
-				if err == nil && fi.IsDir() {
-					m.logger.Error("File is a directory", "option", "File", "value", *m.config.File)
-					errConfig = true
-				}
 			}
 		}
 	}
 
+	for _, name := range m.config.Headers {
+		if name == "" {
+			m.logger.Error("Invalid value", "option", "Headers", "value", name)
+			errConfig = true
+		}
+	}
+
 	if errConfig {
 		return errors.New("config")
 	}
@@ -127,7 +119,11 @@ func (m *loggerMiddleware) Register(site core.ServerSite) error {
 func (m *loggerMiddleware) Start() error {
 	var logFileWriter LogFileWriter
 	if m.config.File != nil {
-		w, err := CreateLogFileWriter(*m.config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		name := statedir.Path(*m.config.File)
+		if err := statedir.MkdirParent(name); err != nil {
+			return fmt.Errorf("create logfile directory: %v", err)
+		}
+		w, err := CreateLogFileWriter(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 		if err != nil {
 			return fmt.Errorf("create logfile writer: %v", err)
 		}
@@ -135,7 +131,7 @@ func (m *loggerMiddleware) Start() error {
 		logFileWriter = w
 
 		m.reopen = make(chan os.Signal, 1)
-		signal.Notify(m.reopen, syscall.SIGUSR1)
+		loggerNotifyReopen(m.reopen)
 		go func() {
 			for {
 				<-m.reopen
@@ -166,16 +162,34 @@ func (m *loggerMiddleware) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (m *loggerMiddleware) Degradable() bool {
+	return true
+}
+
 // Handler implements the middleware handler.
 func (m *loggerMiddleware) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		wrapped := loggerResponseWriter{ResponseWriter: w, status: http.StatusOK}
 
+		timings := timing.New()
+
 		start := time.Now()
-		next.ServeHTTP(&wrapped, r)
+		next.ServeHTTP(&wrapped, r.WithContext(timing.NewContext(r.Context(), timings)))
 		duration := time.Since(start)
 
-		m.log.Println(r.Method, r.URL.EscapedPath(), wrapped.status, duration)
+		v := []any{r.Method, r.URL.EscapedPath(), wrapped.status, duration}
+		if t := timings.String(); t != "" {
+			v = append(v, t)
+		}
+		if m.config != nil {
+			for _, name := range m.config.Headers {
+				if values := r.Header.Values(name); len(values) > 0 {
+					v = append(v, name+"="+strconv.Quote(redact.Value(name, strings.Join(values, ", "))))
+				}
+			}
+		}
+		m.log.Println(v...)
 	}
 
 	return http.HandlerFunc(fn)
@@ -210,3 +224,4 @@ func (w *loggerResponseWriter) Write(b []byte) (int, error) {
 }
 
 var _ core.ServerSiteMiddlewareModule = (*loggerMiddleware)(nil)
+var _ priority.Degradable = (*loggerMiddleware)(nil)
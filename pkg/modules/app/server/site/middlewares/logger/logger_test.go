@@ -1,16 +1,21 @@
 package logger
 
 import (
+	"bytes"
 	"errors"
 	"io/fs"
+	"log"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/timing"
 )
 
 type testLoggerMiddlewareServerSite struct {
@@ -101,12 +106,10 @@ var _ os.FileInfo = (*testLoggerMiddlewareFileInfo)(nil)
 
 func TestLoggerMiddlewareModuleInfo(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	tests := []struct {
 		name   string
@@ -124,12 +127,10 @@ func TestLoggerMiddlewareModuleInfo(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			got := m.ModuleInfo()
 			if got.ID != tt.want.ID {
@@ -144,12 +145,10 @@ func TestLoggerMiddlewareModuleInfo(t *testing.T) {
 
 func TestLoggerMiddlewareInit(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	type args struct {
 		config map[string]interface{}
@@ -164,12 +163,6 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 			name: "minimal",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, nil
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testLoggerMiddlewareFileInfo{}, nil
 				},
@@ -182,19 +175,14 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 			name: "full",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, nil
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testLoggerMiddlewareFileInfo{}, nil
 				},
 			},
 			args: args{
 				config: map[string]interface{}{
-					"File": "access.log",
+					"File":    "access.log",
+					"Headers": []string{"Referer", "Cookie"},
 				},
 			},
 		},
@@ -202,35 +190,24 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 			name: "invalid values",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, nil
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testLoggerMiddlewareFileInfo{}, nil
 				},
 			},
 			args: args{
 				config: map[string]interface{}{
-					"File": "",
+					"File":    "",
+					"Headers": []string{""},
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "error open file",
+			name: "file not created",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, errors.New("test error")
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
-					return testLoggerMiddlewareFileInfo{}, nil
+					return nil, fs.ErrNotExist
 				},
 			},
 			args: args{
@@ -238,18 +215,11 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 					"File": "access.log",
 				},
 			},
-			wantErr: true,
 		},
 		{
 			name: "error stat file",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, nil
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return nil, errors.New("test error")
 				},
@@ -265,12 +235,6 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 			name: "error file is directory",
 			fields: fields{
 				logger: slog.Default(),
-				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
-					return nil, nil
-				},
-				osClose: func(f *os.File) error {
-					return nil
-				},
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testLoggerMiddlewareFileInfo{
 						isDir: true,
@@ -288,12 +252,10 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			if err := m.Init(tt.args.config); (err != nil) != tt.wantErr {
 				t.Errorf("loggerMiddleware.Init() error = %v, wantErr %v", err, tt.wantErr)
@@ -304,12 +266,10 @@ func TestLoggerMiddlewareInit(t *testing.T) {
 
 func TestLoggerMiddlewareRegister(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	type args struct {
 		site core.ServerSite
@@ -339,12 +299,10 @@ func TestLoggerMiddlewareRegister(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			if err := m.Register(tt.args.site); (err != nil) != tt.wantErr {
 				t.Errorf("loggerMiddleware.Register() error = %v, wantErr %v", err, tt.wantErr)
@@ -355,12 +313,10 @@ func TestLoggerMiddlewareRegister(t *testing.T) {
 
 func TestLoggerMiddlewareStart(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	tests := []struct {
 		name    string
@@ -377,12 +333,10 @@ func TestLoggerMiddlewareStart(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			if err := m.Start(); (err != nil) != tt.wantErr {
 				t.Errorf("loggerMiddleware.Start() error = %v, wantErr %v", err, tt.wantErr)
@@ -393,12 +347,10 @@ func TestLoggerMiddlewareStart(t *testing.T) {
 
 func TestLoggerMiddlewareStop(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	tests := []struct {
 		name    string
@@ -415,12 +367,10 @@ func TestLoggerMiddlewareStop(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			if err := m.Stop(); (err != nil) != tt.wantErr {
 				t.Errorf("loggerMiddleware.Stop() error = %v, wantErr %v", err, tt.wantErr)
@@ -431,12 +381,10 @@ func TestLoggerMiddlewareStop(t *testing.T) {
 
 func TestLoggerMiddlewareHandler(t *testing.T) {
 	type fields struct {
-		config     *loggerMiddlewareConfig
-		logger     *slog.Logger
-		reopen     chan os.Signal
-		osOpenFile func(name string, flag int, perm fs.FileMode) (*os.File, error)
-		osClose    func(f *os.File) error
-		osStat     func(name string) (fs.FileInfo, error)
+		config *loggerMiddlewareConfig
+		logger *slog.Logger
+		reopen chan os.Signal
+		osStat func(name string) (fs.FileInfo, error)
 	}
 	type args struct {
 		next http.Handler
@@ -454,12 +402,10 @@ func TestLoggerMiddlewareHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &loggerMiddleware{
-				config:     tt.fields.config,
-				logger:     tt.fields.logger,
-				reopen:     tt.fields.reopen,
-				osOpenFile: tt.fields.osOpenFile,
-				osClose:    tt.fields.osClose,
-				osStat:     tt.fields.osStat,
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				reopen: tt.fields.reopen,
+				osStat: tt.fields.osStat,
 			}
 			got := m.Handler(tt.args.next)
 			if tt.wantNil && got != nil {
@@ -468,3 +414,39 @@ func TestLoggerMiddlewareHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestLoggerMiddlewareHandlerTimings(t *testing.T) {
+	var buf bytes.Buffer
+	m := &loggerMiddleware{
+		log: log.New(&buf, "", 0),
+	}
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timing.FromContext(r.Context()).Add("render", 2*time.Millisecond)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if got := buf.String(); !strings.HasPrefix(got, "GET /test 200 ") || !strings.HasSuffix(got, " render=2.000\n") {
+		t.Errorf("loggerMiddleware.Handler() log = %v", got)
+	}
+}
+
+func TestLoggerMiddlewareHandlerHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	m := &loggerMiddleware{
+		config: &loggerMiddlewareConfig{
+			Headers: []string{"Referer", "Cookie", "Authorization", "X-Missing"},
+		},
+		log: log.New(&buf, "", 0),
+	}
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Referer", "http://localhost/")
+	r.Header.Set("Cookie", "theme=dark; session=secret")
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := ` Referer="http://localhost/" Cookie="theme=dark; session=[REDACTED]" Authorization="[REDACTED]"` + "\n"
+	if got := buf.String(); !strings.HasPrefix(got, "GET /test 200 ") || !strings.HasSuffix(got, want) {
+		t.Errorf("loggerMiddleware.Handler() log = %v", got)
+	}
+}
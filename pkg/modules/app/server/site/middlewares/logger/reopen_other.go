@@ -0,0 +1,14 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// loggerNotifyReopen relays the reopen signal SIGUSR1 to the given channel.
+func loggerNotifyReopen(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package logger
+
+import (
+	"os"
+)
+
+// loggerNotifyReopen does nothing as there is no reopen signal on Windows, where the log file is rotated on restart.
+func loggerNotifyReopen(c chan<- os.Signal) {
+}
@@ -13,14 +13,20 @@ import (
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/match"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/normalize"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
 )
 
 // rewriteMiddleware implements the rewrite middleware.
 type rewriteMiddleware struct {
-	config  *rewriteMiddlewareConfig
-	logger  *slog.Logger
-	regexps []*regexp.Regexp
+	config      *rewriteMiddlewareConfig
+	logger      *slog.Logger
+	regexps     []*regexp.Regexp
+	ruleSet     *pattern.Set
+	constraints []*match.Constraint
 }
 
 // rewriteMiddlewareConfig implements the rewrite middleware configuration.
@@ -30,10 +36,12 @@ type rewriteMiddlewareConfig struct {
 
 // RewriteRule implements a rewrite rule.
 type RewriteRule struct {
-	Path        string  `mapstructure:"path"`
-	Replacement string  `mapstructure:"replacement"`
-	Flag        *string `mapstructure:"flag"`
-	Last        bool    `mapstructure:"last"`
+	Path        string   `mapstructure:"path"`
+	Host        string   `mapstructure:"host"`
+	Methods     []string `mapstructure:"methods"`
+	Replacement string   `mapstructure:"replacement"`
+	Flag        *string  `mapstructure:"flag"`
+	Last        bool     `mapstructure:"last"`
 }
 
 const (
@@ -72,13 +80,20 @@ func (m *rewriteMiddleware) Init(config map[string]interface{}) error {
 	var errConfig bool
 
 	for index, rule := range m.config.Rules {
+		constraint, err := match.NewConstraint(rule.Host, rule.Methods)
+		if err != nil {
+			m.logger.Error("Invalid constraint", "rule", index+1, "err", err)
+			errConfig = true
+		}
+		m.constraints = append(m.constraints, constraint)
 		if rule.Path == "" {
 			m.logger.Error("Missing option or value", "rule", index+1, "option", "Path")
 			errConfig = true
 		} else {
-			re, err := regexp.Compile(rule.Path)
+			re, err := pattern.Compile(rule.Path)
 			if err != nil {
-				m.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path)
+				m.logger.Error("Invalid regular expression", "rule", index+1, "option", "Path", "value", rule.Path,
+					"err", err)
 				errConfig = true
 			} else {
 				m.regexps = append(m.regexps, re)
@@ -103,6 +118,8 @@ func (m *rewriteMiddleware) Init(config map[string]interface{}) error {
 		return errors.New("config")
 	}
 
+	m.ruleSet = pattern.NewSet(m.regexps)
+
 	return nil
 }
 
@@ -125,15 +142,20 @@ func (m *rewriteMiddleware) Stop() error {
 	return nil
 }
 
+// Degradable implements priority.Degradable.
+func (m *rewriteMiddleware) Degradable() bool {
+	return true
+}
+
 // Handler implements the middleware handler.
 func (m *rewriteMiddleware) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		var rewrite bool
-		var path string = r.URL.Path
+		var path string = normalize.Path(r.URL.Path)
 		var status int = http.StatusFound
 		var redirect bool
-		for index, regexp := range m.regexps {
-			if regexp.MatchString(path) {
+		for index := m.ruleSet.Next(path, 0); index >= 0; index = m.ruleSet.Next(path, index+1) {
+			if m.constraints[index].Match(r) {
 				rewrite = true
 				path = m.config.Rules[index].Replacement
 
@@ -173,3 +195,4 @@ func (m *rewriteMiddleware) Handler(next http.Handler) http.Handler {
 }
 
 var _ core.ServerSiteMiddlewareModule = (*rewriteMiddleware)(nil)
+var _ priority.Degradable = (*rewriteMiddleware)(nil)
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"testing"
 
 	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/match"
 	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
 )
 
 type testRewriteMiddlewareServerSite struct {
@@ -134,6 +137,8 @@ func TestRewriteMiddlewareInit(t *testing.T) {
 					"Rules": []map[string]interface{}{
 						{
 							"Path":        "/.*",
+							"Host":        `^example\.com$`,
+							"Methods":     []string{"GET"},
 							"Replacement": "/test",
 							"Flag":        "redirect",
 						},
@@ -151,6 +156,8 @@ func TestRewriteMiddlewareInit(t *testing.T) {
 					"Rules": []map[string]interface{}{
 						{
 							"Path":        "",
+							"Host":        "(",
+							"Methods":     []string{""},
 							"Replacement": "",
 							"Flag":        "",
 						},
@@ -327,3 +334,115 @@ func TestRewriteMiddlewareHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRewriteMiddlewareHandlerNormalizedPath(t *testing.T) {
+	m := &rewriteMiddleware{
+		config: &rewriteMiddlewareConfig{
+			Rules: []RewriteRule{
+				{
+					Path:        "^/old/page$",
+					Replacement: "/new/page",
+				},
+			},
+		},
+		logger:      slog.Default(),
+		regexps:     []*regexp.Regexp{regexp.MustCompile("^/old/page$")},
+		constraints: []*match.Constraint{nil},
+	}
+	m.ruleSet = pattern.NewSet(m.regexps)
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "canonical",
+			target: "/old/page",
+			want:   "/new/page",
+		},
+		{
+			name:   "duplicate slashes",
+			target: "//old///page",
+			want:   "/new/page",
+		},
+		{
+			name:   "dot segments",
+			target: "/old/test/../page",
+			want:   "/new/page",
+		},
+		{
+			name:   "no match",
+			target: "/old/page/",
+			want:   "/old/page/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Path
+			})
+			m.Handler(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if got != tt.want {
+				t.Errorf("rewriteMiddleware.Handler() path = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteMiddlewareHandlerConstraint(t *testing.T) {
+	m := &rewriteMiddleware{
+		logger: slog.Default(),
+	}
+	if err := m.Init(map[string]interface{}{
+		"Rules": []map[string]interface{}{
+			{
+				"Path":        "^/old$",
+				"Host":        `^example\.com$`,
+				"Methods":     []string{"GET"},
+				"Replacement": "/new",
+			},
+		},
+	}); err != nil {
+		t.Fatalf("rewriteMiddleware.Init() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   string
+	}{
+		{
+			name:   "match",
+			method: http.MethodGet,
+			target: "http://example.com/old",
+			want:   "/new",
+		},
+		{
+			name:   "host mismatch",
+			method: http.MethodGet,
+			target: "http://example.org/old",
+			want:   "/old",
+		},
+		{
+			name:   "method mismatch",
+			method: http.MethodPost,
+			target: "http://example.com/old",
+			want:   "/old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Path
+			})
+			m.Handler(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.target, nil))
+			if got != tt.want {
+				t.Errorf("rewriteMiddleware.Handler() path = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
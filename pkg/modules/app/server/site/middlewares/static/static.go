@@ -1,14 +1,17 @@
 package static
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/mitchellh/mapstructure"
@@ -31,14 +34,20 @@ type staticMiddleware struct {
 
 // staticMiddlewareConfig implements the static middleware configuration.
 type staticMiddlewareConfig struct {
-	Path  string `mapstructure:"path"`
-	Index *bool  `mapstructure:"index"`
+	Path           string `mapstructure:"path"`
+	Index          *bool  `mapstructure:"index"`
+	FollowSymlinks *bool  `mapstructure:"followSymlinks"`
+	AllowHidden    *bool  `mapstructure:"allowHidden"`
 }
 
 const (
 	staticModuleID module.ModuleID = "app.server.site.middleware.static"
 
-	staticConfigDefaultIndex bool = false
+	staticConfigDefaultIndex          bool = false
+	staticConfigDefaultFollowSymlinks bool = true
+	staticConfigDefaultAllowHidden    bool = false
+
+	staticWellKnown string = ".well-known"
 )
 
 // staticOsOpenFile redirects to os.OpenFile.
@@ -112,6 +121,14 @@ func (m *staticMiddleware) Init(config map[string]interface{}) error {
 		defaultValue := staticConfigDefaultIndex
 		m.config.Index = &defaultValue
 	}
+	if m.config.FollowSymlinks == nil {
+		defaultValue := staticConfigDefaultFollowSymlinks
+		m.config.FollowSymlinks = &defaultValue
+	}
+	if m.config.AllowHidden == nil {
+		defaultValue := staticConfigDefaultAllowHidden
+		m.config.AllowHidden = &defaultValue
+	}
 
 	if errConfig {
 		return errors.New("config")
@@ -135,12 +152,22 @@ func (m *staticMiddleware) Start() error {
 	if err != nil {
 		return fmt.Errorf("resolve absolute path: %v", err)
 	}
+	if !*m.config.FollowSymlinks {
+		path, err = staticFileSystemFilepathEvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("resolve symbolic links: %v", err)
+		}
+	}
 
 	m.staticFS = &staticFileSystem{
-		prefix: path,
-		index:  *m.config.Index,
-		osStat: staticFileSystemOsStat,
-		osOpen: staticFilesystemOsOpen,
+		prefix:               path,
+		index:                *m.config.Index,
+		followSymlinks:       *m.config.FollowSymlinks,
+		allowHidden:          *m.config.AllowHidden,
+		windowsNames:         runtime.GOOS == "windows",
+		osStat:               staticFileSystemOsStat,
+		osOpen:               staticFilesystemOsOpen,
+		filepathEvalSymlinks: staticFileSystemFilepathEvalSymlinks,
 	}
 	m.staticHandler = http.FileServer(m.staticFS)
 
@@ -152,6 +179,23 @@ func (m *staticMiddleware) Stop() error {
 	return nil
 }
 
+// Preflight checks that the static directory is readable.
+func (m *staticMiddleware) Preflight(ctx context.Context) error {
+	f, err := m.osOpenFile(m.config.Path, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open directory %s: %v", m.config.Path, err)
+	}
+	defer func() {
+		_ = m.osClose(f)
+	}()
+
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read directory %s: %v", m.config.Path, err)
+	}
+
+	return nil
+}
+
 // Handler implements the middleware handler.
 func (m *staticMiddleware) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -175,10 +219,14 @@ type StaticFileSystem interface {
 
 // staticFileSystem implements the default static filesystem.
 type staticFileSystem struct {
-	prefix string
-	index  bool
-	osStat func(name string) (fs.FileInfo, error)
-	osOpen func(name string) (*os.File, error)
+	prefix               string
+	index                bool
+	followSymlinks       bool
+	allowHidden          bool
+	windowsNames         bool
+	osStat               func(name string) (fs.FileInfo, error)
+	osOpen               func(name string) (*os.File, error)
+	filepathEvalSymlinks func(path string) (string, error)
 }
 
 // staticFileSystemOsStat redirects to os.Stat.
@@ -191,13 +239,18 @@ func staticFilesystemOsOpen(name string) (*os.File, error) {
 	return os.Open(name)
 }
 
+// staticFileSystemFilepathEvalSymlinks redirects to filepath.EvalSymlinks.
+func staticFileSystemFilepathEvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
 // Exists checks if a file or an index exists.
 func (fs *staticFileSystem) Exists(name string) bool {
-	if !strings.HasPrefix(name, "/") {
-		name = "/" + name
+	name, err := fs.resolve(name)
+	if err != nil {
+		return false
 	}
 
-	name = filepath.Join(fs.prefix, name)
 	s, err := fs.osStat(name)
 	if err != nil {
 		return false
@@ -208,6 +261,9 @@ func (fs *staticFileSystem) Exists(name string) bool {
 			return false
 		}
 		name = filepath.Join(name, "index.html")
+		if err := fs.checkSymlinks(name); err != nil {
+			return false
+		}
 		_, err = fs.osStat(name)
 	}
 
@@ -217,19 +273,108 @@ func (fs *staticFileSystem) Exists(name string) bool {
 // Open implements FileSystem using os.Open, opening files for reading rooted.
 // and relative to the directory d.
 func (fs *staticFileSystem) Open(name string) (http.File, error) {
+	fullName, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.osOpen(fullName)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// resolve returns the path of the named file in the filesystem after enforcing the access policy.
+//
+// The name must be already decoded. Any parent directory segment is rejected instead of being cleaned to keep
+// traversal attempts visible, and hidden files are reported as not existing unless allowed. The top level well-known
+// directory (RFC 8615) used by the ACME challenges and security.txt is always served, but not its hidden files.
+func (fs *staticFileSystem) resolve(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", errors.New("invalid character in file path")
+	}
 	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
-		return nil, errors.New("invalid character in file path")
+		return "", errors.New("invalid character in file path")
+	}
+	for _, segment := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return "", os.ErrPermission
+		}
+	}
+
+	name = path.Clean("/" + name)
+	for i, segment := range strings.Split(name, "/") {
+		if !fs.allowHidden && strings.HasPrefix(segment, ".") && !(i == 1 && segment == staticWellKnown) {
+			return "", os.ErrNotExist
+		}
+		if fs.windowsNames && !staticWindowsName(segment) {
+			return "", os.ErrNotExist
+		}
 	}
+
 	dir := fs.prefix
 	if dir == "" {
 		dir = "."
 	}
-	fullName := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+name)))
-	f, err := fs.osOpen(fullName)
+	fullName := filepath.Join(dir, filepath.FromSlash(name))
+	if err := fs.checkSymlinks(fullName); err != nil {
+		return "", err
+	}
+
+	return fullName, nil
+}
+
+// staticWindowsName reports whether the given path segment designates the same file on Windows as on other systems.
+//
+// Windows ignores the trailing dots and spaces, opens the alternate data streams after a colon, maps the reserved
+// device names in any directory and resolves the 8.3 short names, which would bypass the hidden files policy.
+func staticWindowsName(segment string) bool {
+	if segment == "" {
+		return true
+	}
+	if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") || strings.ContainsRune(segment, ':') {
+		return false
+	}
+	for i := 0; i+1 < len(segment); i++ {
+		if segment[i] == '~' && segment[i+1] >= '0' && segment[i+1] <= '9' {
+			return false
+		}
+	}
+	base, _, _ := strings.Cut(segment, ".")
+	base = strings.ToUpper(strings.TrimRight(base, " "))
+	switch base {
+	case "CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$":
+		return false
+	}
+	if len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) &&
+		base[3] >= '0' && base[3] <= '9' {
+		return false
+	}
+
+	return true
+}
+
+// checkSymlinks checks that the given path does not resolve outside the filesystem root if symbolic links must not
+// be followed.
+func (fs *staticFileSystem) checkSymlinks(fullName string) error {
+	if fs.followSymlinks {
+		return nil
+	}
+
+	realName, err := fs.filepathEvalSymlinks(fullName)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
 	}
-	return f, nil
+	rel, err := filepath.Rel(fs.prefix, realName)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return os.ErrPermission
+	}
+
+	return nil
 }
 
 var _ core.ServerSiteMiddlewareModule = (*staticMiddleware)(nil)
+var _ core.PreflightModule = (*staticMiddleware)(nil)
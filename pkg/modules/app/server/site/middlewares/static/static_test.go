@@ -1,11 +1,14 @@
 package static
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -189,6 +192,31 @@ func TestStaticMiddlewareInit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "full",
+			fields: fields{
+				logger: slog.Default(),
+				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
+					return nil, nil
+				},
+				osClose: func(f *os.File) error {
+					return nil
+				},
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticMiddlewareFileInfo{
+						isDir: true,
+					}, nil
+				},
+			},
+			args: args{
+				config: map[string]interface{}{
+					"Path":           "/static",
+					"Index":          true,
+					"FollowSymlinks": false,
+					"AllowHidden":    true,
+				},
+			},
+		},
 		{
 			name: "invalid values",
 			fields: fields{
@@ -351,11 +379,25 @@ func TestStaticMiddlewareStart(t *testing.T) {
 			name: "default",
 			fields: fields{
 				config: &staticMiddlewareConfig{
-					Path:  "/test",
-					Index: boolPtr(false),
+					Path:           "/test",
+					Index:          boolPtr(false),
+					FollowSymlinks: boolPtr(true),
+					AllowHidden:    boolPtr(false),
 				},
 			},
 		},
+		{
+			name: "error resolve symbolic links",
+			fields: fields{
+				config: &staticMiddlewareConfig{
+					Path:           "/test/invalid",
+					Index:          boolPtr(false),
+					FollowSymlinks: boolPtr(false),
+					AllowHidden:    boolPtr(false),
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -375,6 +417,63 @@ func TestStaticMiddlewareStart(t *testing.T) {
 	}
 }
 
+func TestStaticMiddlewarePreflight(t *testing.T) {
+	type fields struct {
+		config        *staticMiddlewareConfig
+		logger        *slog.Logger
+		staticFS      StaticFileSystem
+		staticHandler http.Handler
+		osOpenFile    func(name string, flag int, perm fs.FileMode) (*os.File, error)
+		osClose       func(*os.File) error
+		osStat        func(name string) (fs.FileInfo, error)
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "default",
+			fields: fields{
+				config: &staticMiddlewareConfig{
+					Path: t.TempDir(),
+				},
+				osOpenFile: staticOsOpenFile,
+				osClose:    staticOsClose,
+			},
+		},
+		{
+			name: "error open",
+			fields: fields{
+				config: &staticMiddlewareConfig{
+					Path: "/test",
+				},
+				osOpenFile: func(name string, flag int, perm fs.FileMode) (*os.File, error) {
+					return nil, errors.New("test error")
+				},
+				osClose: staticOsClose,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &staticMiddleware{
+				config:        tt.fields.config,
+				logger:        tt.fields.logger,
+				staticFS:      tt.fields.staticFS,
+				staticHandler: tt.fields.staticHandler,
+				osOpenFile:    tt.fields.osOpenFile,
+				osClose:       tt.fields.osClose,
+				osStat:        tt.fields.osStat,
+			}
+			if err := m.Preflight(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("staticMiddleware.Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestStaticMiddlewareStop(t *testing.T) {
 	type fields struct {
 		config        *staticMiddlewareConfig
@@ -454,6 +553,113 @@ func TestStaticMiddlewareHandler(t *testing.T) {
 	}
 }
 
+func TestStaticMiddlewareHandlerPathPolicy(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "static")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		filepath.Join(base, "secret"):            "secret",
+		filepath.Join(root, "index.html"):        "index",
+		filepath.Join(root, ".env"):              "secret",
+		filepath.Join(root, "public", "test.js"): "public",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "secret"), filepath.Join(root, "outside")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "public"), filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		followSymlinks bool
+		target         string
+		want           string
+	}{
+		{
+			name:   "file",
+			target: "/public/test.js",
+			want:   "public",
+		},
+		{
+			name:   "encoded parent directory",
+			target: "/%2e%2e/secret",
+			want:   "next",
+		},
+		{
+			name:   "encoded slash parent directory",
+			target: "/public/..%2f..%2fsecret",
+			want:   "next",
+		},
+		{
+			name:   "encoded backslash parent directory",
+			target: "/public/..%5c..%5csecret",
+			want:   "next",
+		},
+		{
+			name:   "hidden file",
+			target: "/.env",
+			want:   "next",
+		},
+		{
+			name:   "encoded hidden file",
+			target: "/%2eenv",
+			want:   "next",
+		},
+		{
+			name:           "symlink inside root",
+			followSymlinks: false,
+			target:         "/inside/test.js",
+			want:           "public",
+		},
+		{
+			name:           "symlink outside root",
+			followSymlinks: false,
+			target:         "/outside",
+			want:           "next",
+		},
+		{
+			name:           "symlink outside root followed",
+			followSymlinks: true,
+			target:         "/outside",
+			want:           "secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &staticMiddleware{
+				config: &staticMiddlewareConfig{
+					Path:           root,
+					Index:          boolPtr(true),
+					FollowSymlinks: boolPtr(tt.followSymlinks),
+					AllowHidden:    boolPtr(false),
+				},
+				logger: slog.Default(),
+			}
+			if err := m.Start(); err != nil {
+				t.Fatal(err)
+			}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("next"))
+			})
+			w := httptest.NewRecorder()
+			m.Handler(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("staticMiddleware.Handler() body = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 type testStaticFilesystemFileInfo struct {
 	name     string
 	size     int64
@@ -490,10 +696,14 @@ var _ os.FileInfo = (*testStaticFilesystemFileInfo)(nil)
 
 func TestStaticFileSystemExists(t *testing.T) {
 	type fields struct {
-		prefix string
-		index  bool
-		osStat func(name string) (fs.FileInfo, error)
-		osOpen func(name string) (*os.File, error)
+		prefix               string
+		index                bool
+		followSymlinks       bool
+		allowHidden          bool
+		windowsNames         bool
+		osStat               func(name string) (fs.FileInfo, error)
+		osOpen               func(name string) (*os.File, error)
+		filepathEvalSymlinks func(path string) (string, error)
 	}
 	type args struct {
 		name string
@@ -507,6 +717,7 @@ func TestStaticFileSystemExists(t *testing.T) {
 		{
 			name: "file",
 			fields: fields{
+				followSymlinks: true,
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testStaticFilesystemFileInfo{}, nil
 				},
@@ -519,7 +730,8 @@ func TestStaticFileSystemExists(t *testing.T) {
 		{
 			name: "index file",
 			fields: fields{
-				index: true,
+				followSymlinks: true,
+				index:          true,
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testStaticFilesystemFileInfo{
 						isDir: true,
@@ -534,6 +746,7 @@ func TestStaticFileSystemExists(t *testing.T) {
 		{
 			name: "error stat file",
 			fields: fields{
+				followSymlinks: true,
 				osStat: func(name string) (fs.FileInfo, error) {
 					return nil, errors.New("test error")
 				},
@@ -546,6 +759,7 @@ func TestStaticFileSystemExists(t *testing.T) {
 		{
 			name: "error file is directory",
 			fields: fields{
+				followSymlinks: true,
 				osStat: func(name string) (fs.FileInfo, error) {
 					return testStaticFilesystemFileInfo{
 						isDir: true,
@@ -557,14 +771,161 @@ func TestStaticFileSystemExists(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "hidden file allowed",
+			fields: fields{
+				followSymlinks: true,
+				allowHidden:    true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/.well-known/test",
+			},
+			want: true,
+		},
+		{
+			name: "well-known file",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/.well-known/acme-challenge/test",
+			},
+			want: true,
+		},
+		{
+			name: "windows file",
+			fields: fields{
+				followSymlinks: true,
+				windowsNames:   true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/assets/app~v2.js",
+			},
+			want: true,
+		},
+		{
+			name: "error windows short name",
+			fields: fields{
+				followSymlinks: true,
+				windowsNames:   true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/ENV~1",
+			},
+			want: false,
+		},
+		{
+			name: "error hidden file",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/.env",
+			},
+			want: false,
+		},
+		{
+			name: "error well-known hidden file",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/.well-known/.env",
+			},
+			want: false,
+		},
+		{
+			name: "error nested well-known file",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/assets/.well-known/test",
+			},
+			want: false,
+		},
+		{
+			name: "error hidden directory",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/.git/config",
+			},
+			want: false,
+		},
+		{
+			name: "error parent directory",
+			fields: fields{
+				followSymlinks: true,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{}, nil
+				},
+			},
+			args: args{
+				name: "/test/../../etc/passwd",
+			},
+			want: false,
+		},
+		{
+			name: "error index outside root",
+			fields: fields{
+				prefix:         "/static",
+				index:          true,
+				followSymlinks: false,
+				osStat: func(name string) (fs.FileInfo, error) {
+					return testStaticFilesystemFileInfo{
+						isDir: true,
+					}, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					if path == "/static/test/index.html" {
+						return "/etc/passwd", nil
+					}
+					return path, nil
+				},
+			},
+			args: args{
+				name: "/test",
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			fs := &staticFileSystem{
-				prefix: tt.fields.prefix,
-				index:  tt.fields.index,
-				osStat: tt.fields.osStat,
-				osOpen: tt.fields.osOpen,
+				prefix:               tt.fields.prefix,
+				index:                tt.fields.index,
+				followSymlinks:       tt.fields.followSymlinks,
+				allowHidden:          tt.fields.allowHidden,
+				windowsNames:         tt.fields.windowsNames,
+				osStat:               tt.fields.osStat,
+				osOpen:               tt.fields.osOpen,
+				filepathEvalSymlinks: tt.fields.filepathEvalSymlinks,
 			}
 			if got := fs.Exists(tt.args.name); got != tt.want {
 				t.Errorf("staticFileSystem.Exists() = %v, want %v", got, tt.want)
@@ -575,10 +936,13 @@ func TestStaticFileSystemExists(t *testing.T) {
 
 func TestStaticFileSystemOpen(t *testing.T) {
 	type fields struct {
-		prefix string
-		index  bool
-		osStat func(name string) (fs.FileInfo, error)
-		osOpen func(name string) (*os.File, error)
+		prefix               string
+		index                bool
+		followSymlinks       bool
+		allowHidden          bool
+		osStat               func(name string) (fs.FileInfo, error)
+		osOpen               func(name string) (*os.File, error)
+		filepathEvalSymlinks func(path string) (string, error)
 	}
 	type args struct {
 		name string
@@ -592,6 +956,7 @@ func TestStaticFileSystemOpen(t *testing.T) {
 		{
 			name: "default",
 			fields: fields{
+				followSymlinks: true,
 				osOpen: func(name string) (*os.File, error) {
 					return nil, nil
 				},
@@ -600,20 +965,159 @@ func TestStaticFileSystemOpen(t *testing.T) {
 		{
 			name: "error open file",
 			fields: fields{
+				followSymlinks: true,
 				osOpen: func(name string) (*os.File, error) {
 					return nil, errors.New("test error")
 				},
 			},
 			wantErr: true,
 		},
+		{
+			name: "symlink inside root",
+			fields: fields{
+				prefix:         "/static",
+				followSymlinks: false,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					return "/static/target", nil
+				},
+			},
+			args: args{
+				name: "/link",
+			},
+		},
+		{
+			name: "symlink outside root followed",
+			fields: fields{
+				prefix:         "/static",
+				followSymlinks: true,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					return "/etc/passwd", nil
+				},
+			},
+			args: args{
+				name: "/link",
+			},
+		},
+		{
+			name: "error symlink outside root",
+			fields: fields{
+				prefix:         "/static",
+				followSymlinks: false,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					return "/etc/passwd", nil
+				},
+			},
+			args: args{
+				name: "/link",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error symlink to sibling directory",
+			fields: fields{
+				prefix:         "/static",
+				followSymlinks: false,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					return "/static-private/file", nil
+				},
+			},
+			args: args{
+				name: "/link",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error eval symlinks",
+			fields: fields{
+				prefix:         "/static",
+				followSymlinks: false,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+				filepathEvalSymlinks: func(path string) (string, error) {
+					return "", errors.New("test error")
+				},
+			},
+			args: args{
+				name: "/link",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error hidden file",
+			fields: fields{
+				followSymlinks: true,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				name: "/.htpasswd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error parent directory",
+			fields: fields{
+				followSymlinks: true,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				name: "/../etc/passwd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error parent directory with backslash",
+			fields: fields{
+				followSymlinks: true,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				name: "/test\\..\\..\\etc/passwd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error null byte",
+			fields: fields{
+				followSymlinks: true,
+				osOpen: func(name string) (*os.File, error) {
+					return nil, nil
+				},
+			},
+			args: args{
+				name: "/test\x00.html",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			fs := &staticFileSystem{
-				prefix: tt.fields.prefix,
-				index:  tt.fields.index,
-				osStat: tt.fields.osStat,
-				osOpen: tt.fields.osOpen,
+				prefix:               tt.fields.prefix,
+				index:                tt.fields.index,
+				followSymlinks:       tt.fields.followSymlinks,
+				allowHidden:          tt.fields.allowHidden,
+				osStat:               tt.fields.osStat,
+				osOpen:               tt.fields.osOpen,
+				filepathEvalSymlinks: tt.fields.filepathEvalSymlinks,
 			}
 			_, err := fs.Open(tt.args.name)
 			if (err != nil) != tt.wantErr {
@@ -622,3 +1126,69 @@ func TestStaticFileSystemOpen(t *testing.T) {
 		})
 	}
 }
+
+func TestStaticWindowsName(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment string
+		want    bool
+	}{
+		{
+			name:    "file",
+			segment: "index.html",
+			want:    true,
+		},
+		{
+			name:    "empty",
+			segment: "",
+			want:    true,
+		},
+		{
+			name:    "tilde",
+			segment: "app~v2.js",
+			want:    true,
+		},
+		{
+			name:    "device prefix",
+			segment: "console.js",
+			want:    true,
+		},
+		{
+			name:    "trailing dot",
+			segment: "secret.",
+			want:    false,
+		},
+		{
+			name:    "trailing space",
+			segment: "secret ",
+			want:    false,
+		},
+		{
+			name:    "alternate data stream",
+			segment: "index.html::$DATA",
+			want:    false,
+		},
+		{
+			name:    "short name",
+			segment: "GITIGN~1",
+			want:    false,
+		},
+		{
+			name:    "device name",
+			segment: "nul.txt",
+			want:    false,
+		},
+		{
+			name:    "device name with number",
+			segment: "COM1",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staticWindowsName(tt.segment); got != tt.want {
+				t.Errorf("staticWindowsName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
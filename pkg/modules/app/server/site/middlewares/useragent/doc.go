@@ -0,0 +1,2 @@
+// Package useragent implements the user agent middleware.
+package useragent
@@ -0,0 +1,192 @@
+package useragent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/log"
+	"github.com/bhuisgen/neon/pkg/module"
+	"github.com/bhuisgen/neon/pkg/pattern"
+	"github.com/bhuisgen/neon/pkg/priority"
+	"github.com/bhuisgen/neon/pkg/trace"
+)
+
+// useragentMiddleware implements the user agent middleware.
+type useragentMiddleware struct {
+	config *useragentMiddlewareConfig
+	logger *slog.Logger
+	allow  []*useragentPattern
+	deny   []*useragentPattern
+}
+
+// useragentMiddlewareConfig implements the user agent middleware configuration.
+type useragentMiddlewareConfig struct {
+	Allow  []string `mapstructure:"allow"`
+	Deny   []string `mapstructure:"deny"`
+	Status *int     `mapstructure:"status"`
+}
+
+// useragentPattern implements a compiled pattern with its matches counter.
+type useragentPattern struct {
+	value   string
+	regexp  *regexp.Regexp
+	matches atomic.Uint64
+}
+
+const (
+	useragentModuleID module.ModuleID = "app.server.site.middleware.useragent"
+
+	useragentConfigDefaultStatus int = http.StatusForbidden
+)
+
+// init initializes the package.
+func init() {
+	module.Register(useragentMiddleware{})
+}
+
+// ModuleInfo returns the module information.
+func (m useragentMiddleware) ModuleInfo() module.ModuleInfo {
+	return module.ModuleInfo{
+		ID:           useragentModuleID,
+		LoadModule:   func() {},
+		UnloadModule: func() {},
+		NewInstance: func() module.Module {
+			return &useragentMiddleware{
+				logger: slog.New(log.NewHandler(os.Stderr, string(useragentModuleID), nil)),
+			}
+		},
+	}
+}
+
+// Init initializes the middleware.
+func (m *useragentMiddleware) Init(config map[string]interface{}) error {
+	if err := mapstructure.Decode(config, &m.config); err != nil {
+		m.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	if m.config == nil {
+		m.config = &useragentMiddlewareConfig{}
+	}
+
+	var errConfig bool
+
+	m.allow, m.deny = nil, nil
+	if err := pattern.Validate(m.config.Allow...); err != nil {
+		m.logger.Error("Invalid regular expression", "option", "Allow", "err", err)
+		errConfig = true
+	} else {
+		for _, value := range m.config.Allow {
+			re, _ := pattern.Compile(value)
+			m.allow = append(m.allow, &useragentPattern{value: value, regexp: re})
+		}
+	}
+	if err := pattern.Validate(m.config.Deny...); err != nil {
+		m.logger.Error("Invalid regular expression", "option", "Deny", "err", err)
+		errConfig = true
+	} else {
+		for _, value := range m.config.Deny {
+			re, _ := pattern.Compile(value)
+			m.deny = append(m.deny, &useragentPattern{value: value, regexp: re})
+		}
+	}
+	if m.config.Status == nil {
+		defaultValue := useragentConfigDefaultStatus
+		m.config.Status = &defaultValue
+	}
+	if *m.config.Status != http.StatusForbidden && *m.config.Status != http.StatusTooManyRequests {
+		m.logger.Error("Invalid value", "option", "Status", "value", *m.config.Status)
+		errConfig = true
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	return nil
+}
+
+// Register registers the middleware.
+func (m *useragentMiddleware) Register(site core.ServerSite) error {
+	if err := site.RegisterMiddleware(m.Handler); err != nil {
+		return fmt.Errorf("register middleware: %v", err)
+	}
+
+	return nil
+}
+
+// Start starts the middleware.
+func (m *useragentMiddleware) Start() error {
+	return nil
+}
+
+// Stop stops the middleware.
+func (m *useragentMiddleware) Stop() error {
+	for _, p := range m.deny {
+		if n := p.matches.Load(); n > 0 {
+			m.logger.Info("User agent pattern statistics", "pattern", p.value, "rejected", n)
+		}
+	}
+
+	return nil
+}
+
+// Degradable implements priority.Degradable.
+func (m *useragentMiddleware) Degradable() bool {
+	return true
+}
+
+// Handler implements the middleware handler.
+func (m *useragentMiddleware) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ua := r.UserAgent()
+
+		for _, p := range m.allow {
+			if p.regexp.MatchString(ua) {
+				p.matches.Add(1)
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		for _, p := range m.deny {
+			if p.regexp.MatchString(ua) {
+				n := p.matches.Add(1)
+				m.logger.Debug("Request rejected", "pattern", p.value, "rejected", n, "userAgent", ua)
+				trace.FromContext(r.Context()).Add(string(useragentModuleID), "Request rejected", "pattern",
+					p.value)
+
+				w.WriteHeader(*m.config.Status)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Stats returns the number of matches of each pattern.
+func (m *useragentMiddleware) Stats() map[string]uint64 {
+	stats := make(map[string]uint64, len(m.allow)+len(m.deny))
+	for _, p := range m.allow {
+		stats[p.value] += p.matches.Load()
+	}
+	for _, p := range m.deny {
+		stats[p.value] += p.matches.Load()
+	}
+
+	return stats
+}
+
+var _ core.ServerSiteMiddlewareModule = (*useragentMiddleware)(nil)
+var _ priority.Degradable = (*useragentMiddleware)(nil)
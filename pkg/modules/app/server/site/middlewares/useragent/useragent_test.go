@@ -0,0 +1,397 @@
+package useragent
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/bhuisgen/neon/pkg/core"
+	"github.com/bhuisgen/neon/pkg/module"
+)
+
+type testUseragentMiddlewareServerSite struct {
+	err bool
+}
+
+func (s testUseragentMiddlewareServerSite) Name() string {
+	return "test"
+}
+
+func (s testUseragentMiddlewareServerSite) Listeners() []string {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) Hosts() []string {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) IsDefault() bool {
+	return false
+}
+
+func (s testUseragentMiddlewareServerSite) Store() core.Store {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) Fetcher() core.Fetcher {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) Loader() core.Loader {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) Server() core.Server {
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) RegisterMiddleware(middleware func(next http.Handler) http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+func (s testUseragentMiddlewareServerSite) RegisterHandler(handler http.Handler) error {
+	if s.err {
+		return errors.New("test error")
+	}
+	return nil
+}
+
+var _ core.ServerSite = (*testUseragentMiddlewareServerSite)(nil)
+
+func TestUseragentMiddlewareModuleInfo(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   module.ModuleInfo
+	}{
+		{
+			name: "default",
+			want: module.ModuleInfo{
+				ID:          useragentModuleID,
+				NewInstance: func() module.Module { return &useragentMiddleware{} },
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			got := m.ModuleInfo()
+			if got.ID != tt.want.ID {
+				t.Errorf("useragentMiddleware.ModuleInfo() = %v, want %v", got.ID, tt.want.ID)
+			}
+			if instance := got.NewInstance(); instance == nil {
+				t.Errorf("useragentMiddleware.NewInstance() = %v, want %v", instance, "not nil")
+			}
+		})
+	}
+}
+
+func TestUseragentMiddlewareInit(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	type args struct {
+		config map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "minimal",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{},
+			},
+		},
+		{
+			name: "full",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"Allow":  []string{"(?i)googlebot"},
+					"Deny":   []string{"(?i)bot", "(?i)curl"},
+					"Status": 429,
+				},
+			},
+		},
+		{
+			name: "invalid values",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"Allow":  []string{""},
+					"Deny":   []string{""},
+					"Status": 500,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid regular expression",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"Deny": []string{"("},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			if err := m.Init(tt.args.config); (err != nil) != tt.wantErr {
+				t.Errorf("useragentMiddleware.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseragentMiddlewareRegister(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	type args struct {
+		site core.ServerSite
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "default",
+			args: args{
+				site: testUseragentMiddlewareServerSite{},
+			},
+		},
+		{
+			name: "error register",
+			args: args{
+				site: testUseragentMiddlewareServerSite{
+					err: true,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			if err := m.Register(tt.args.site); (err != nil) != tt.wantErr {
+				t.Errorf("useragentMiddleware.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseragentMiddlewareStart(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			if err := m.Start(); (err != nil) != tt.wantErr {
+				t.Errorf("useragentMiddleware.Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseragentMiddlewareStop(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			if err := m.Stop(); (err != nil) != tt.wantErr {
+				t.Errorf("useragentMiddleware.Stop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseragentMiddlewareHandler(t *testing.T) {
+	type fields struct {
+		config *useragentMiddlewareConfig
+		logger *slog.Logger
+		allow  []*useragentPattern
+		deny   []*useragentPattern
+	}
+	type args struct {
+		userAgent string
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		wantStatus int
+		wantStats  map[string]uint64
+	}{
+		{
+			name: "default",
+			fields: fields{
+				config: &useragentMiddlewareConfig{
+					Status: intPtr(http.StatusForbidden),
+				},
+				logger: slog.Default(),
+			},
+			args: args{
+				userAgent: "test",
+			},
+			wantStatus: http.StatusOK,
+			wantStats:  map[string]uint64{},
+		},
+		{
+			name: "deny",
+			fields: fields{
+				config: &useragentMiddlewareConfig{
+					Status: intPtr(http.StatusTooManyRequests),
+				},
+				logger: slog.Default(),
+				deny: []*useragentPattern{
+					{value: "(?i)bot", regexp: regexp.MustCompile("(?i)bot")},
+				},
+			},
+			args: args{
+				userAgent: "ScraperBot/1.0",
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantStats: map[string]uint64{
+				"(?i)bot": 1,
+			},
+		},
+		{
+			name: "allow",
+			fields: fields{
+				config: &useragentMiddlewareConfig{
+					Status: intPtr(http.StatusForbidden),
+				},
+				logger: slog.Default(),
+				allow: []*useragentPattern{
+					{value: "Googlebot", regexp: regexp.MustCompile("Googlebot")},
+				},
+				deny: []*useragentPattern{
+					{value: "(?i)bot", regexp: regexp.MustCompile("(?i)bot")},
+				},
+			},
+			args: args{
+				userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)",
+			},
+			wantStatus: http.StatusOK,
+			wantStats: map[string]uint64{
+				"Googlebot": 1,
+				"(?i)bot":   0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &useragentMiddleware{
+				config: tt.fields.config,
+				logger: tt.fields.logger,
+				allow:  tt.fields.allow,
+				deny:   tt.fields.deny,
+			}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("User-Agent", tt.args.userAgent)
+			m.Handler(next).ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("useragentMiddleware.Handler() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := m.Stats(); !reflect.DeepEqual(got, tt.wantStats) {
+				t.Errorf("useragentMiddleware.Stats() = %v, want %v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
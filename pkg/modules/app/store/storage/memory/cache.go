@@ -1,62 +1,271 @@
 package memory
 
 import (
+	"container/list"
 	"sync"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/tinylfu"
 )
 
 // Cache
 type Cache interface {
 	Get(key string) any
-	Set(key string, value any)
+	Set(key string, value any, size int, ttl time.Duration)
 	Remove(key string)
 	Clear()
+	Sweep() int
+	Stats() CacheStats
+	Range(fn func(key string, value any, size int, expires time.Time) bool)
+}
+
+// CacheStats implements the cache occupancy and efficiency statistics.
+type CacheStats struct {
+	Entries    int
+	Bytes      int
+	Evictions  uint64
+	Hits       uint64
+	Misses     uint64
+	Rejections uint64
 }
 
-// cache implements a memory cache.
+// HitRatio returns the ratio of the lookups returning an object.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cache implements a LRU memory cache bounded by entries and bytes, with an optional TinyLFU admission policy.
 type cache struct {
-	m  map[string]any
-	mu sync.RWMutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	evictions  uint64
+	hits       uint64
+	misses     uint64
+	rejections uint64
+	m          map[string]*cacheItem
+	l          *list.List
+	filter     *tinylfu.Filter
+	mu         sync.Mutex
+	now        func() time.Time
+}
+
+// cacheItem implements the value in the map.
+type cacheItem struct {
+	key     string
+	v       any
+	size    int
+	expires time.Time
+	e       *list.Element
 }
 
+const (
+	cachePolicyLRU     string = "lru"
+	cachePolicyTinyLFU string = "tinylfu"
+)
+
 // newCache creates a new cache instance.
-func newCache() *cache {
-	return &cache{
-		m: make(map[string]any),
+//
+// A zero maxEntries or maxBytes disables the corresponding limit. With the TinyLFU policy, a new object evicting
+// another one is only stored if it is accessed more frequently than the evicted object.
+func newCache(maxEntries int, maxBytes int, policy string) *cache {
+	c := &cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		m:          make(map[string]*cacheItem),
+		l:          list.New(),
+		now:        time.Now,
+	}
+	if policy == cachePolicyTinyLFU {
+		c.filter = tinylfu.New(maxEntries)
 	}
+	return c
 }
 
 // Get returns the object with the given key.
 func (c *cache) Get(key string) any {
-	c.mu.RLock()
-	if v, ok := c.m[key]; ok {
-		c.mu.RUnlock()
-		return v
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.filter != nil {
+		c.filter.Record(key)
+	}
+
+	i, ok := c.m[key]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	if !i.expires.IsZero() && !c.now().Before(i.expires) {
+		c.remove(i)
+		c.misses++
+		return nil
 	}
-	c.mu.RUnlock()
-	return nil
+	c.l.MoveToFront(i.e)
+	c.hits++
+
+	return i.v
 }
 
-// Set stores a object with the given key.
-func (c *cache) Set(key string, value any) {
+// Set stores a object with the given key, size and time-to-live.
+//
+// A zero ttl stores the object without expiration.
+func (c *cache) Set(key string, value any, size int, ttl time.Duration) {
 	c.mu.Lock()
-	c.m[key] = value
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	if i, ok := c.m[key]; ok {
+		c.bytes += size - i.size
+		i.v = value
+		i.size = size
+		i.expires = expires
+		c.l.MoveToFront(i.e)
+	} else {
+		if c.filter != nil && c.l.Len() > 0 && c.overflow(size) {
+			now := c.now()
+			for c.l.Len() > 0 && c.overflow(size) && c.expireBack(now) {
+			}
+			if c.l.Len() > 0 && c.overflow(size) && !c.filter.Admit(key, c.l.Back().Value.(*cacheItem).key) {
+				c.rejections++
+				return
+			}
+		}
+
+		i := &cacheItem{
+			key:     key,
+			v:       value,
+			size:    size,
+			expires: expires,
+		}
+		i.e = c.l.PushFront(i)
+		c.m[key] = i
+		c.bytes += size
+	}
+
+	// the expired objects are evicted first from the least recently used end, the remaining ones are removed by the
+	// periodic sweeps, and the most recent object is always kept even if it exceeds the bytes limit
+	now := c.now()
+	for c.l.Len() > 1 && c.full() {
+		if !c.expireBack(now) {
+			c.remove(c.l.Back().Value.(*cacheItem))
+			c.evictions++
+		}
+	}
 }
 
 // Remove removes the object with the given key.
 func (c *cache) Remove(key string) {
 	c.mu.Lock()
-	delete(c.m, key)
+	if i, ok := c.m[key]; ok {
+		c.remove(i)
+	}
 	c.mu.Unlock()
 }
 
 // Clear clears all objects.
 func (c *cache) Clear() {
 	c.mu.Lock()
-	for key := range c.m {
-		delete(c.m, key)
-	}
+	c.m = make(map[string]*cacheItem)
+	c.l.Init()
+	c.bytes = 0
 	c.mu.Unlock()
 }
 
+// Sweep removes all expired objects and returns the number of removed objects.
+func (c *cache) Sweep() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.sweep()
+}
+
+// Stats returns the cache statistics.
+func (c *cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Entries:    c.l.Len(),
+		Bytes:      c.bytes,
+		Evictions:  c.evictions,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Rejections: c.rejections,
+	}
+}
+
+// Range calls the given function for each unexpired object from the most to the least recently used, until the
+// function returns false. The function must not access the cache.
+func (c *cache) Range(fn func(key string, value any, size int, expires time.Time) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for e := c.l.Front(); e != nil; e = e.Next() {
+		i := e.Value.(*cacheItem)
+		if !i.expires.IsZero() && !now.Before(i.expires) {
+			continue
+		}
+		if !fn(i.key, i.v, i.size, i.expires) {
+			return
+		}
+	}
+}
+
+// full returns true if the cache exceeds its limits. The caller must hold the lock.
+func (c *cache) full() bool {
+	return c.maxEntries > 0 && c.l.Len() > c.maxEntries || c.maxBytes > 0 && c.bytes > c.maxBytes
+}
+
+// overflow returns true if storing a new object of the given size would exceed the limits. The caller must hold the
+// lock.
+func (c *cache) overflow(size int) bool {
+	return c.maxEntries > 0 && c.l.Len()+1 > c.maxEntries || c.maxBytes > 0 && c.bytes+size > c.maxBytes
+}
+
+// sweep removes all expired items. The caller must hold the lock.
+func (c *cache) sweep() int {
+	now := c.now()
+
+	var n int
+	for _, i := range c.m {
+		if !i.expires.IsZero() && !now.Before(i.expires) {
+			c.remove(i)
+			n++
+		}
+	}
+
+	return n
+}
+
+// expireBack removes the least recently used item if it is expired and returns true if removed. The caller must hold
+// the lock.
+func (c *cache) expireBack(now time.Time) bool {
+	e := c.l.Back()
+	if e == nil {
+		return false
+	}
+	i := e.Value.(*cacheItem)
+	if i.expires.IsZero() || now.Before(i.expires) {
+		return false
+	}
+	c.remove(i)
+	return true
+}
+
+// remove removes an item from the cache. The caller must hold the lock.
+func (c *cache) remove(i *cacheItem) {
+	c.l.Remove(i.e)
+	delete(c.m, i.key)
+	c.bytes -= i.size
+}
+
 var _ Cache = (*cache)(nil)
@@ -2,7 +2,9 @@ package memory
 
 import (
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCacheNew(t *testing.T) {
@@ -16,7 +18,7 @@ func TestCacheNew(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := newCache(); got == nil {
+			if got := newCache(0, 0, cachePolicyLRU); got == nil {
 				t.Errorf("New() got %v, wantNil %v", got, tt.wantNil)
 			}
 		})
@@ -27,8 +29,8 @@ func TestCacheGet(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache()
-	cache.Set(key, value)
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set(key, value, 0, 0)
 
 	if v := cache.Get(key); v != value {
 		t.Errorf("c.Get() got %v, want %v", v, value)
@@ -39,8 +41,8 @@ func TestCacheSet(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache()
-	cache.Set(key, value)
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set(key, value, 0, 0)
 
 	if v := cache.Get(key); v != value {
 		t.Errorf("c.Get() got %v, want %v", v, value)
@@ -52,9 +54,9 @@ func TestCacheSet_UpdateValue(t *testing.T) {
 	value1 := "value1"
 	value2 := "value2"
 
-	cache := newCache()
-	cache.Set(key, value1)
-	cache.Set(key, value2)
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set(key, value1, 0, 0)
+	cache.Set(key, value2, 0, 0)
 
 	if v := cache.Get(key); v != value2 {
 		t.Errorf("c.Get() got %v, want %v", v, value2)
@@ -65,8 +67,8 @@ func TestCacheRemove(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache()
-	cache.Set(key, value)
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set(key, value, 0, 0)
 	cache.Remove(key)
 
 	if v := cache.Get(key); v != nil {
@@ -78,8 +80,8 @@ func TestCacheClear(t *testing.T) {
 	key := "test"
 	value := "value"
 
-	cache := newCache()
-	cache.Set(key, value)
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set(key, value, 0, 0)
 	cache.Clear()
 
 	if v := cache.Get(key); v != nil {
@@ -88,20 +90,20 @@ func TestCacheClear(t *testing.T) {
 }
 
 func BenchmarkCacheSet(b *testing.B) {
-	cache2 := newCache()
+	cache2 := newCache(0, 0, cachePolicyLRU)
 	key := "test"
 	value := "value"
 
 	for n := 0; n < b.N; n++ {
-		cache2.Set(key, value)
+		cache2.Set(key, value, 0, 0)
 	}
 }
 
 func BenchmarkCacheGet(b *testing.B) {
-	cache := newCache()
+	cache := newCache(0, 0, cachePolicyLRU)
 	key := "test"
 	value := "value"
-	cache.Set(key, value)
+	cache.Set(key, value, 0, 0)
 
 	for n := 0; n < b.N; n++ {
 		cache.Get(key)
@@ -109,13 +111,13 @@ func BenchmarkCacheGet(b *testing.B) {
 }
 
 func BenchmarkCacheSetFull(b *testing.B) {
-	cache := newCache()
+	cache := newCache(0, 0, cachePolicyLRU)
 	key := "key"
 	value := "value"
 
 	for n := 0; n < b.N; n++ {
 		for i := 1; i <= 1000; i++ {
-			cache.Set(fmt.Sprint(key, i), value)
+			cache.Set(fmt.Sprint(key, i), value, 0, 0)
 		}
 	}
 }
@@ -123,11 +125,11 @@ func BenchmarkCacheSetFull(b *testing.B) {
 var result any
 
 func BenchmarkCacheGetFull(b *testing.B) {
-	cache := newCache()
+	cache := newCache(0, 0, cachePolicyLRU)
 	key := "key"
 	value := "value"
 	for i := 1; i <= 1000; i++ {
-		cache.Set(fmt.Sprint(key, i), value)
+		cache.Set(fmt.Sprint(key, i), value, 0, 0)
 	}
 
 	var r any
@@ -138,3 +140,217 @@ func BenchmarkCacheGetFull(b *testing.B) {
 	}
 	result = r
 }
+
+func TestCacheSet_MaxEntries(t *testing.T) {
+	cache := newCache(1, 0, cachePolicyLRU)
+	cache.Set("test1", "value1", 1, 0)
+	cache.Set("test2", "value2", 1, 0)
+
+	if v := cache.Get("test1"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if v := cache.Get("test2"); v != "value2" {
+		t.Errorf("c.Get() got %v, want %v", v, "value2")
+	}
+}
+
+func TestCacheSet_MaxBytes(t *testing.T) {
+	cache := newCache(0, 10, cachePolicyLRU)
+	cache.Set("test1", "value1", 6, 0)
+	cache.Set("test2", "value2", 6, 0)
+
+	if v := cache.Get("test1"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if v := cache.Get("test2"); v != "value2" {
+		t.Errorf("c.Get() got %v, want %v", v, "value2")
+	}
+	if s := cache.Stats(); s.Bytes != 6 || s.Entries != 1 || s.Evictions != 1 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
+
+func TestCacheSet_LRU(t *testing.T) {
+	cache := newCache(2, 0, cachePolicyLRU)
+	cache.Set("test1", "value1", 1, 0)
+	cache.Set("test2", "value2", 1, 0)
+	cache.Get("test1")
+	cache.Set("test3", "value3", 1, 0)
+
+	if v := cache.Get("test1"); v != "value1" {
+		t.Errorf("c.Get() got %v, want %v", v, "value1")
+	}
+	if v := cache.Get("test2"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+}
+
+func TestCacheSet_TinyLFU(t *testing.T) {
+	cache := newCache(2, 0, cachePolicyTinyLFU)
+	cache.Set("test1", "value1", 1, 0)
+	cache.Set("test2", "value2", 1, 0)
+	for i := 0; i < 3; i++ {
+		cache.Get("test1")
+		cache.Get("test2")
+	}
+	cache.Set("test3", "value3", 1, 0)
+
+	if v := cache.Get("test3"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if v := cache.Get("test1"); v != "value1" {
+		t.Errorf("c.Get() got %v, want %v", v, "value1")
+	}
+	if v := cache.Get("test2"); v != "value2" {
+		t.Errorf("c.Get() got %v, want %v", v, "value2")
+	}
+	if s := cache.Stats(); s.Entries != 2 || s.Rejections != 1 || s.Evictions != 0 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+
+	for i := 0; i < 8; i++ {
+		cache.Get("test4")
+	}
+	cache.Set("test4", "value4", 1, 0)
+
+	if v := cache.Get("test4"); v != "value4" {
+		t.Errorf("c.Get() got %v, want %v", v, "value4")
+	}
+	if s := cache.Stats(); s.Entries != 2 || s.Rejections != 1 || s.Evictions != 1 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
+
+func TestCacheStats_HitRatio(t *testing.T) {
+	cache := newCache(0, 0, cachePolicyLRU)
+	if r := cache.Stats().HitRatio(); r != 0 {
+		t.Errorf("c.Stats().HitRatio() got %v, want %v", r, 0)
+	}
+
+	cache.Set("test", "value", 5, 0)
+	cache.Get("test")
+	cache.Get("test")
+	cache.Get("test")
+	cache.Get("unknown")
+
+	s := cache.Stats()
+	if s.Hits != 3 || s.Misses != 1 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+	if r := s.HitRatio(); r != 0.75 {
+		t.Errorf("c.Stats().HitRatio() got %v, want %v", r, 0.75)
+	}
+}
+
+func TestCacheGet_Expired(t *testing.T) {
+	now := time.Now()
+
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.now = func() time.Time {
+		return now
+	}
+	cache.Set("test", "value", 5, time.Second)
+
+	if v := cache.Get("test"); v != "value" {
+		t.Errorf("c.Get() got %v, want %v", v, "value")
+	}
+
+	now = now.Add(time.Second)
+	if v := cache.Get("test"); v != nil {
+		t.Errorf("c.Get() got %v, want %v", v, nil)
+	}
+	if s := cache.Stats(); s.Bytes != 0 || s.Entries != 0 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.Set("test1", "value1", 6, 0)
+	cache.Set("test2", "value2", 6, 0)
+	cache.Set("test2", "value", 5, 0)
+	cache.Remove("test1")
+
+	want := CacheStats{
+		Entries: 1,
+		Bytes:   5,
+	}
+	if s := cache.Stats(); s != want {
+		t.Errorf("c.Stats() got %v, want %v", s, want)
+	}
+}
+
+func TestCacheSweep(t *testing.T) {
+	now := time.Now()
+
+	cache := newCache(0, 0, cachePolicyLRU)
+	cache.now = func() time.Time {
+		return now
+	}
+	cache.Set("test1", "value1", 6, time.Second)
+	cache.Set("test2", "value2", 6, time.Minute)
+	cache.Set("test3", "value3", 6, 0)
+
+	now = now.Add(time.Second)
+	if n := cache.Sweep(); n != 1 {
+		t.Errorf("c.Sweep() got %v, want %v", n, 1)
+	}
+	want := CacheStats{
+		Entries: 2,
+		Bytes:   12,
+	}
+	if s := cache.Stats(); s != want {
+		t.Errorf("c.Stats() got %v, want %v", s, want)
+	}
+}
+
+func TestCacheSet_EvictExpiredFirst(t *testing.T) {
+	now := time.Now()
+
+	cache := newCache(2, 0, cachePolicyLRU)
+	cache.now = func() time.Time {
+		return now
+	}
+	cache.Set("test1", "value1", 1, time.Second)
+	cache.Set("test2", "value2", 1, 0)
+	now = now.Add(time.Second)
+	cache.Set("test3", "value3", 1, 0)
+
+	if v := cache.Get("test2"); v != "value2" {
+		t.Errorf("c.Get() got %v, want %v", v, "value2")
+	}
+	if s := cache.Stats(); s.Entries != 2 || s.Evictions != 0 {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	cache := newCache(100, 0, cachePolicyLRU)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint("key", (w*1000+i)%150)
+				switch i % 4 {
+				case 0:
+					cache.Set(key, i, 1, time.Millisecond)
+				case 1:
+					cache.Get(key)
+				case 2:
+					cache.Remove(key)
+				case 3:
+					cache.Sweep()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	s := cache.Stats()
+	if s.Entries > 100 || s.Bytes != s.Entries {
+		t.Errorf("c.Stats() got %v", s)
+	}
+}
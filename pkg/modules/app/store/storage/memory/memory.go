@@ -2,8 +2,13 @@ package memory
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand"
 	"os"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/log"
@@ -12,12 +17,29 @@ import (
 
 // memoryStorage implements the memory storage.
 type memoryStorage struct {
+	config  *memoryStorageConfig
 	logger  *slog.Logger
 	storage Cache
+	stop    chan struct{}
+}
+
+// memoryStorageConfig implements the memory storage configuration.
+type memoryStorageConfig struct {
+	MaxEntries    *int    `mapstructure:"maxEntries"`
+	MaxBytes      *int    `mapstructure:"maxBytes"`
+	Policy        *string `mapstructure:"policy"`
+	TTLJitter     *int    `mapstructure:"ttlJitter"`
+	SweepInterval *int    `mapstructure:"sweepInterval"`
 }
 
 const (
 	memoryModuleID module.ModuleID = "app.store.storage.memory"
+
+	memoryConfigDefaultMaxEntries    int    = 0
+	memoryConfigDefaultMaxBytes      int    = 0
+	memoryConfigDefaultPolicy        string = cachePolicyLRU
+	memoryConfigDefaultTTLJitter     int    = 10
+	memoryConfigDefaultSweepInterval int    = 60
 )
 
 // init initializes the package.
@@ -41,7 +63,80 @@ func (s memoryStorage) ModuleInfo() module.ModuleInfo {
 
 // Init initialize the storage.
 func (s *memoryStorage) Init(config map[string]interface{}) error {
-	s.storage = newCache()
+	if err := mapstructure.Decode(config, &s.config); err != nil {
+		s.logger.Error("Failed to parse configuration", "err", err)
+		return fmt.Errorf("parse config: %v", err)
+	}
+
+	if s.config == nil {
+		s.config = &memoryStorageConfig{}
+	}
+
+	var errConfig bool
+
+	if s.config.MaxEntries == nil {
+		defaultValue := memoryConfigDefaultMaxEntries
+		s.config.MaxEntries = &defaultValue
+	}
+	if *s.config.MaxEntries < 0 {
+		s.logger.Error("Invalid value", "option", "MaxEntries", "value", *s.config.MaxEntries)
+		errConfig = true
+	}
+	if s.config.MaxBytes == nil {
+		defaultValue := memoryConfigDefaultMaxBytes
+		s.config.MaxBytes = &defaultValue
+	}
+	if *s.config.MaxBytes < 0 {
+		s.logger.Error("Invalid value", "option", "MaxBytes", "value", *s.config.MaxBytes)
+		errConfig = true
+	}
+	if s.config.Policy == nil {
+		defaultValue := memoryConfigDefaultPolicy
+		s.config.Policy = &defaultValue
+	}
+	if *s.config.Policy != cachePolicyLRU && *s.config.Policy != cachePolicyTinyLFU {
+		s.logger.Error("Invalid value", "option", "Policy", "value", *s.config.Policy)
+		errConfig = true
+	}
+	if s.config.TTLJitter == nil {
+		defaultValue := memoryConfigDefaultTTLJitter
+		s.config.TTLJitter = &defaultValue
+	}
+	if *s.config.TTLJitter < 0 || *s.config.TTLJitter > 100 {
+		s.logger.Error("Invalid value", "option", "TTLJitter", "value", *s.config.TTLJitter)
+		errConfig = true
+	}
+	if s.config.SweepInterval == nil {
+		defaultValue := memoryConfigDefaultSweepInterval
+		s.config.SweepInterval = &defaultValue
+	}
+	if *s.config.SweepInterval < 0 {
+		s.logger.Error("Invalid value", "option", "SweepInterval", "value", *s.config.SweepInterval)
+		errConfig = true
+	}
+
+	if errConfig {
+		return errors.New("config")
+	}
+
+	_ = s.Stop()
+
+	s.storage = newCache(*s.config.MaxEntries, *s.config.MaxBytes, *s.config.Policy)
+
+	if *s.config.SweepInterval > 0 {
+		s.stop = make(chan struct{})
+		go s.janitor(s.storage, time.Duration(*s.config.SweepInterval)*time.Second, s.stop)
+	}
+
+	return nil
+}
+
+// Stop stops the janitor of the storage.
+func (s *memoryStorage) Stop() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
 
 	return nil
 }
@@ -58,8 +153,52 @@ func (s *memoryStorage) LoadResource(name string) (*core.Resource, error) {
 
 // StoreResource stores a resource into the storage.
 func (s *memoryStorage) StoreResource(name string, resource *core.Resource) error {
-	s.storage.Set(name, resource)
+	size := len(name)
+	for _, data := range resource.Data {
+		size += len(data)
+	}
+
+	s.storage.Set(name, resource, size, s.jitter(resource.TTL))
+
+	stats := s.storage.Stats()
+	s.logger.Debug("Resource stored", "name", name, "size", size, "entries", stats.Entries, "bytes", stats.Bytes,
+		"evictions", stats.Evictions, "rejections", stats.Rejections, "hitRatio", stats.HitRatio())
+
 	return nil
 }
 
+// janitor evicts periodically the expired resources from the storage.
+func (s *memoryStorage) janitor(storage Cache, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if n := storage.Sweep(); n > 0 {
+				stats := storage.Stats()
+				s.logger.Debug("Expired resources evicted", "count", n, "entries", stats.Entries, "bytes", stats.Bytes,
+					"hitRatio", stats.HitRatio())
+			}
+		}
+	}
+}
+
+// jitter spreads the given TTL randomly by the configured percentage to avoid synchronized expirations.
+func (s *memoryStorage) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || s.config == nil || *s.config.TTLJitter == 0 {
+		return ttl
+	}
+
+	delta := int64(ttl) * int64(*s.config.TTLJitter) / 100
+	if delta <= 0 {
+		return ttl
+	}
+
+	return ttl - time.Duration(delta) + time.Duration(rand.Int63n(2*delta+1))
+}
+
 var _ core.StoreStorageModule = (*memoryStorage)(nil)
+var _ core.StoreStorageStopModule = (*memoryStorage)(nil)
@@ -4,11 +4,16 @@ import (
 	"errors"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/bhuisgen/neon/pkg/core"
 	"github.com/bhuisgen/neon/pkg/module"
 )
 
+func intPtr(i int) *int {
+	return &i
+}
+
 type testMemoryStorageStore struct {
 	errLoadResource  bool
 	errStoreResource bool
@@ -48,7 +53,7 @@ func (c testMemoryStorageCache) Get(key string) any {
 	}
 }
 
-func (c testMemoryStorageCache) Set(key string, value any) {
+func (c testMemoryStorageCache) Set(key string, value any, size int, ttl time.Duration) {
 }
 
 func (c testMemoryStorageCache) Remove(key string) {
@@ -57,10 +62,22 @@ func (c testMemoryStorageCache) Remove(key string) {
 func (c testMemoryStorageCache) Clear() {
 }
 
+func (c testMemoryStorageCache) Sweep() int {
+	return 0
+}
+
+func (c testMemoryStorageCache) Stats() CacheStats {
+	return CacheStats{}
+}
+
+func (c testMemoryStorageCache) Range(fn func(key string, value any, size int, expires time.Time) bool) {
+}
+
 var _ Cache = (*testMemoryStorageCache)(nil)
 
 func TestMemoryStorageModuleInfo(t *testing.T) {
 	type fields struct {
+		config  *memoryStorageConfig
 		logger  *slog.Logger
 		storage Cache
 	}
@@ -96,6 +113,7 @@ func TestMemoryStorageModuleInfo(t *testing.T) {
 
 func TestMemoryStorageInit(t *testing.T) {
 	type fields struct {
+		config  *memoryStorageConfig
 		logger  *slog.Logger
 		storage Cache
 	}
@@ -112,10 +130,42 @@ func TestMemoryStorageInit(t *testing.T) {
 			name: "default",
 			args: args{},
 		},
+		{
+			name: "full",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"MaxEntries":    1000,
+					"MaxBytes":      1048576,
+					"Policy":        "tinylfu",
+					"TTLJitter":     20,
+					"SweepInterval": 30,
+				},
+			},
+		},
+		{
+			name: "invalid values",
+			fields: fields{
+				logger: slog.Default(),
+			},
+			args: args{
+				config: map[string]interface{}{
+					"MaxEntries":    -1,
+					"MaxBytes":      -1,
+					"Policy":        "invalid",
+					"TTLJitter":     101,
+					"SweepInterval": -1,
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &memoryStorage{
+				config:  tt.fields.config,
 				logger:  tt.fields.logger,
 				storage: tt.fields.storage,
 			}
@@ -128,6 +178,7 @@ func TestMemoryStorageInit(t *testing.T) {
 
 func TestMemoryStorageLoadResource(t *testing.T) {
 	type fields struct {
+		config  *memoryStorageConfig
 		logger  *slog.Logger
 		storage Cache
 	}
@@ -166,6 +217,7 @@ func TestMemoryStorageLoadResource(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &memoryStorage{
+				config:  tt.fields.config,
 				logger:  tt.fields.logger,
 				storage: tt.fields.storage,
 			}
@@ -179,6 +231,7 @@ func TestMemoryStorageLoadResource(t *testing.T) {
 
 func TestMemoryStorageStoreResource(t *testing.T) {
 	type fields struct {
+		config  *memoryStorageConfig
 		logger  *slog.Logger
 		storage Cache
 	}
@@ -195,6 +248,7 @@ func TestMemoryStorageStoreResource(t *testing.T) {
 		{
 			name: "default",
 			fields: fields{
+				logger:  slog.Default(),
 				storage: testMemoryStorageCache{},
 			},
 			args: args{
@@ -205,10 +259,28 @@ func TestMemoryStorageStoreResource(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ttl jitter",
+			fields: fields{
+				config: &memoryStorageConfig{
+					TTLJitter: intPtr(10),
+				},
+				logger:  slog.Default(),
+				storage: testMemoryStorageCache{},
+			},
+			args: args{
+				name: "test",
+				resource: &core.Resource{
+					Data: [][]byte{[]byte("test")},
+					TTL:  time.Minute,
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &memoryStorage{
+				config:  tt.fields.config,
 				logger:  tt.fields.logger,
 				storage: tt.fields.storage,
 			}
@@ -218,3 +290,79 @@ func TestMemoryStorageStoreResource(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryStorageJitter(t *testing.T) {
+	s := &memoryStorage{
+		config: &memoryStorageConfig{
+			TTLJitter: intPtr(10),
+		},
+	}
+
+	ttl := 100 * time.Second
+	for i := 0; i < 100; i++ {
+		if got := s.jitter(ttl); got < 90*time.Second || got > 110*time.Second {
+			t.Errorf("memoryStorage.jitter() got %v, want between %v and %v", got, 90*time.Second, 110*time.Second)
+		}
+	}
+	if got := s.jitter(0); got != 0 {
+		t.Errorf("memoryStorage.jitter() got %v, want %v", got, 0)
+	}
+}
+
+func TestMemoryStorageStop(t *testing.T) {
+	s := &memoryStorage{
+		logger: slog.Default(),
+	}
+	if err := s.Init(map[string]interface{}{
+		"sweepInterval": 1,
+	}); err != nil {
+		t.Fatalf("memoryStorage.Init() error = %v", err)
+	}
+	stop := s.stop
+
+	if err := s.Stop(); err != nil {
+		t.Errorf("memoryStorage.Stop() error = %v", err)
+	}
+	select {
+	case <-stop:
+	default:
+		t.Error("memoryStorage.Stop() janitor not stopped")
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("memoryStorage.Stop() error = %v", err)
+	}
+}
+
+func TestMemoryStorageJanitor(t *testing.T) {
+	now := time.Now()
+
+	c := newCache(0, 0, cachePolicyLRU)
+	c.now = func() time.Time {
+		return now
+	}
+	c.Set("test", "value", 5, time.Second)
+	now = now.Add(time.Second)
+
+	s := &memoryStorage{
+		logger:  slog.Default(),
+		storage: c,
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.janitor(c, time.Millisecond, stop)
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for c.Stats().Entries != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("memoryStorage.janitor() expired resource not evicted")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	close(stop)
+	<-done
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+// memorySnapshot implements the export of the memory storage.
+type memorySnapshot struct {
+	Version   int
+	Time      time.Time
+	Resources []memorySnapshotResource
+}
+
+// memorySnapshotResource implements an exported resource.
+type memorySnapshotResource struct {
+	Name string
+	Data [][]byte
+	TTL  time.Duration
+	Time time.Time
+}
+
+const (
+	memorySnapshotVersion int = 1
+)
+
+// Export writes the unexpired resources from the most to the least recently used, with their remaining TTL.
+func (s *memoryStorage) Export(w io.Writer) error {
+	now := time.Now()
+	snapshot := memorySnapshot{
+		Version: memorySnapshotVersion,
+		Time:    now,
+	}
+	s.storage.Range(func(key string, value any, size int, expires time.Time) bool {
+		resource, ok := value.(*core.Resource)
+		if !ok {
+			return true
+		}
+		var ttl time.Duration
+		if !expires.IsZero() {
+			ttl = expires.Sub(now)
+		}
+		snapshot.Resources = append(snapshot.Resources, memorySnapshotResource{
+			Name: key,
+			Data: resource.Data,
+			TTL:  ttl,
+			Time: resource.Time,
+		})
+		return true
+	})
+
+	if err := gob.NewEncoder(w).Encode(&snapshot); err != nil {
+		return fmt.Errorf("encode snapshot: %v", err)
+	}
+
+	s.logger.Debug("Resources exported", "count", len(snapshot.Resources))
+
+	return nil
+}
+
+// Import stores the exported resources still valid, with their TTL reduced by the time elapsed since the export.
+//
+// The resources are stored from the least to the most recently used to restore their order in the storage.
+func (s *memoryStorage) Import(r io.Reader) error {
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("decode snapshot: %v", err)
+	}
+	if snapshot.Version != memorySnapshotVersion {
+		return errors.New("unsupported snapshot version")
+	}
+
+	elapsed := time.Since(snapshot.Time)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	var count int
+	for index := len(snapshot.Resources) - 1; index >= 0; index-- {
+		item := snapshot.Resources[index]
+		ttl := item.TTL
+		if ttl > 0 {
+			ttl -= elapsed
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		size := len(item.Name)
+		for _, data := range item.Data {
+			size += len(data)
+		}
+		s.storage.Set(item.Name, &core.Resource{
+			Data: item.Data,
+			TTL:  ttl,
+			Time: item.Time,
+		}, size, ttl)
+		count++
+	}
+
+	s.logger.Debug("Resources imported", "count", count, "expired", len(snapshot.Resources)-count)
+
+	return nil
+}
+
+var _ core.StoreStorageSnapshotModule = (*memoryStorage)(nil)
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bhuisgen/neon/pkg/core"
+)
+
+func TestMemoryStorageExportImport(t *testing.T) {
+	s := &memoryStorage{
+		logger:  slog.Default(),
+		storage: newCache(0, 0, cachePolicyLRU),
+	}
+	s.storage.Set("cold", &core.Resource{Data: [][]byte{[]byte("cold")}, TTL: time.Hour}, 4, time.Hour)
+	s.storage.Set("expired", &core.Resource{Data: [][]byte{[]byte("expired")}}, 7, time.Nanosecond)
+	s.storage.Set("hot", &core.Resource{Data: [][]byte{[]byte("hot")}}, 3, 0)
+	time.Sleep(time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := s.Export(&buf); err != nil {
+		t.Fatalf("memoryStorage.Export() error = %v", err)
+	}
+
+	i := &memoryStorage{
+		logger:  slog.Default(),
+		storage: newCache(0, 0, cachePolicyLRU),
+	}
+	if err := i.Import(&buf); err != nil {
+		t.Fatalf("memoryStorage.Import() error = %v", err)
+	}
+
+	var keys []string
+	i.storage.Range(func(key string, value any, size int, expires time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if want := []string{"hot", "cold"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("memoryStorage.Import() keys = %v, want %v", keys, want)
+	}
+	got, err := i.LoadResource("cold")
+	if err != nil {
+		t.Fatalf("memoryStorage.LoadResource() error = %v", err)
+	}
+	if got.TTL <= 0 || got.TTL > time.Hour || string(got.Data[0]) != "cold" {
+		t.Errorf("memoryStorage.Import() resource = %+v", got)
+	}
+}
+
+func TestMemoryStorageImportExpired(t *testing.T) {
+	var buf bytes.Buffer
+	s := &memoryStorage{
+		logger:  slog.Default(),
+		storage: newCache(0, 0, cachePolicyLRU),
+	}
+	s.storage.Set("test", &core.Resource{Data: [][]byte{[]byte("test")}}, 4, time.Hour)
+	if err := s.Export(&buf); err != nil {
+		t.Fatalf("memoryStorage.Export() error = %v", err)
+	}
+
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(&buf).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Time = snapshot.Time.Add(-2 * time.Hour)
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	i := &memoryStorage{
+		logger:  slog.Default(),
+		storage: newCache(0, 0, cachePolicyLRU),
+	}
+	if err := i.Import(&buf); err != nil {
+		t.Fatalf("memoryStorage.Import() error = %v", err)
+	}
+	if got := i.storage.Stats().Entries; got != 0 {
+		t.Errorf("memoryStorage.Import() entries = %v, want %v", got, 0)
+	}
+}
+
+func TestMemoryStorageImportInvalid(t *testing.T) {
+	i := &memoryStorage{
+		logger:  slog.Default(),
+		storage: newCache(0, 0, cachePolicyLRU),
+	}
+	if err := i.Import(bytes.NewBufferString("invalid")); err == nil {
+		t.Errorf("memoryStorage.Import() error = %v, wantErr %v", err, true)
+	}
+}
@@ -0,0 +1,2 @@
+// Package normalize provides the request normalization shared by the server modules.
+package normalize
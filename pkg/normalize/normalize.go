@@ -0,0 +1,134 @@
+package normalize
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Path returns the canonical form of a decoded URL path.
+//
+// The duplicate slashes are collapsed and the dot segments are removed. The trailing slash is preserved.
+func Path(p string) string {
+	return join(segments(p), strings.HasSuffix(p, "/"))
+}
+
+// EscapedPath returns the canonical form of an escaped URL path.
+//
+// The percent-encoded unreserved characters are decoded and the remaining escapes are uppercased, then the path is
+// normalized like with Path. An encoded slash is kept encoded and never treated as a separator.
+func EscapedPath(p string) string {
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) || !isHex(p[i+1]) || !isHex(p[i+2]) {
+			b.WriteByte(p[i])
+			continue
+		}
+		c := unhex(p[i+1])<<4 | unhex(p[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(p[i+1 : i+3]))
+		}
+		i += 2
+	}
+	p = b.String()
+
+	return join(segments(p), strings.HasSuffix(p, "/"))
+}
+
+// Query returns the canonical form of a raw query for cache keys.
+//
+// The parameters are sorted by key and the values of a same key keep their original order.
+func Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil && len(values) == 0 {
+		return rawQuery
+	}
+
+	return values.Encode()
+}
+
+// URL normalizes in place the path of the given URL.
+func URL(u *url.URL) {
+	escaped := EscapedPath(u.EscapedPath())
+	p, err := url.PathUnescape(escaped)
+	if err != nil {
+		return
+	}
+	u.Path = p
+	u.RawPath = ""
+	if u.EscapedPath() != escaped {
+		u.RawPath = escaped
+	}
+}
+
+// CacheKey returns the cache key of the given URL, including the canonical query if required.
+func CacheKey(u *url.URL, query bool) string {
+	key := Path(u.Path)
+	if query {
+		if q := Query(u.RawQuery); q != "" {
+			key += "?" + q
+		}
+	}
+
+	return key
+}
+
+// segments returns the path segments without the empty and dot segments.
+func segments(p string) []string {
+	var result []string
+	for _, segment := range strings.Split(p, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(result) > 0 {
+				result = result[:len(result)-1]
+			}
+		default:
+			result = append(result, segment)
+		}
+	}
+
+	return result
+}
+
+// join returns the absolute path of the given segments.
+func join(segments []string, trailingSlash bool) string {
+	if len(segments) == 0 {
+		return "/"
+	}
+	p := "/" + strings.Join(segments, "/")
+	if trailingSlash {
+		p += "/"
+	}
+
+	return p
+}
+
+// isHex returns true if the byte is an hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of an hexadecimal digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isUnreserved returns true if the byte is an unreserved character as defined in RFC 3986.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
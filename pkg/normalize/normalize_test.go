@@ -0,0 +1,224 @@
+package normalize
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name string
+		p    string
+		want string
+	}{
+		{
+			name: "empty",
+			p:    "",
+			want: "/",
+		},
+		{
+			name: "root",
+			p:    "/",
+			want: "/",
+		},
+		{
+			name: "canonical",
+			p:    "/test/page",
+			want: "/test/page",
+		},
+		{
+			name: "duplicate slashes",
+			p:    "//test///page",
+			want: "/test/page",
+		},
+		{
+			name: "dot segments",
+			p:    "/test/./a/../page",
+			want: "/test/page",
+		},
+		{
+			name: "parent directory above root",
+			p:    "/../../test",
+			want: "/test",
+		},
+		{
+			name: "trailing slash",
+			p:    "/test//",
+			want: "/test/",
+		},
+		{
+			name: "relative",
+			p:    "test/page",
+			want: "/test/page",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Path(tt.p); got != tt.want {
+				t.Errorf("Path() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapedPath(t *testing.T) {
+	tests := []struct {
+		name string
+		p    string
+		want string
+	}{
+		{
+			name: "canonical",
+			p:    "/test/page",
+			want: "/test/page",
+		},
+		{
+			name: "unreserved characters",
+			p:    "/%7Etest/%41%62c%2D%5f",
+			want: "/~test/Abc-_",
+		},
+		{
+			name: "reserved characters",
+			p:    "/test%2fpage%3a%20",
+			want: "/test%2Fpage%3A%20",
+		},
+		{
+			name: "encoded dot segments",
+			p:    "/test/%2e%2E/%2e/page",
+			want: "/page",
+		},
+		{
+			name: "invalid escape",
+			p:    "/test%zz/%4",
+			want: "/test%zz/%4",
+		},
+		{
+			name: "duplicate slashes",
+			p:    "/test//page/",
+			want: "/test/page/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapedPath(tt.p); got != tt.want {
+				t.Errorf("EscapedPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		want     string
+	}{
+		{
+			name:     "empty",
+			rawQuery: "",
+			want:     "",
+		},
+		{
+			name:     "sorted keys",
+			rawQuery: "b=2&a=1&c=3",
+			want:     "a=1&b=2&c=3",
+		},
+		{
+			name:     "repeated keys",
+			rawQuery: "b=2&a=z&a=y",
+			want:     "a=z&a=y&b=2",
+		},
+		{
+			name:     "encoding",
+			rawQuery: "q=a+b&p=%7e",
+			want:     "p=~&q=a+b",
+		},
+		{
+			name:     "invalid",
+			rawQuery: "%zz",
+			want:     "%zz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Query(tt.rawQuery); got != tt.want {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		rawURL      string
+		wantPath    string
+		wantEscaped string
+	}{
+		{
+			name:        "default",
+			rawURL:      "http://localhost//test/./page?b=2&a=1",
+			wantPath:    "/test/page",
+			wantEscaped: "/test/page",
+		},
+		{
+			name:        "encoded slash",
+			rawURL:      "http://localhost/a%2fb/%7Ec",
+			wantPath:    "/a/b/~c",
+			wantEscaped: "/a%2Fb/~c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.rawURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			URL(u)
+			if u.Path != tt.wantPath {
+				t.Errorf("URL() path = %v, want %v", u.Path, tt.wantPath)
+			}
+			if got := u.EscapedPath(); got != tt.wantEscaped {
+				t.Errorf("URL() escaped path = %v, want %v", got, tt.wantEscaped)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		query  bool
+		want   string
+	}{
+		{
+			name:   "path",
+			rawURL: "http://localhost//test/../page?b=2&a=1",
+			want:   "/page",
+		},
+		{
+			name:   "query",
+			rawURL: "http://localhost//test/../page?b=2&a=1",
+			query:  true,
+			want:   "/page?a=1&b=2",
+		},
+		{
+			name:   "empty query",
+			rawURL: "http://localhost/page",
+			query:  true,
+			want:   "/page",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.rawURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := CacheKey(u, tt.query); got != tt.want {
+				t.Errorf("CacheKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
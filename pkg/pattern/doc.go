@@ -0,0 +1,2 @@
+// Package pattern provides the regular expressions compilation shared by the server modules.
+package pattern
@@ -0,0 +1,81 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ErrEmpty is the error returned when compiling an empty pattern.
+var ErrEmpty = errors.New("empty pattern")
+
+// PatternStats implements the statistics of the patterns cache.
+type PatternStats struct {
+	Patterns int
+	Compiles uint64
+	Hits     uint64
+	Errors   uint64
+}
+
+var (
+	mu       sync.Mutex
+	regexps  = make(map[string]*regexp.Regexp)
+	compiles uint64
+	hits     uint64
+	failures uint64
+)
+
+// Compile returns the regular expression of the given RE2 pattern, compiled once and shared by all its users.
+//
+// The returned regular expression is safe for concurrent use and must not be modified.
+func Compile(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		mu.Lock()
+		failures++
+		mu.Unlock()
+		return nil, ErrEmpty
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if re, ok := regexps[expr]; ok {
+		hits++
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		failures++
+		return nil, err
+	}
+	compiles++
+	regexps[expr] = re
+
+	return re, nil
+}
+
+// Validate compiles all the given patterns and returns the errors of the invalid ones joined together.
+func Validate(exprs ...string) error {
+	var errs []error
+	for index, expr := range exprs {
+		if _, err := Compile(expr); err != nil {
+			errs = append(errs, fmt.Errorf("pattern %d %q: %w", index+1, expr, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stats returns the statistics of the patterns cache.
+func Stats() PatternStats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return PatternStats{
+		Patterns: len(regexps),
+		Compiles: compiles,
+		Hits:     hits,
+		Errors:   failures,
+	}
+}
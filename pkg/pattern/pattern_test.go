@@ -0,0 +1,82 @@
+package pattern
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			expr: "^/test/(?P<id>[0-9]+)$",
+		},
+		{
+			name:    "empty",
+			expr:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			expr:    "(",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported syntax",
+			expr:    "^/(?!admin)",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compile(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.String() != tt.expr {
+				t.Errorf("Compile() = %v, want %v", got, tt.expr)
+			}
+		})
+	}
+}
+
+func TestCompileShared(t *testing.T) {
+	before := Stats()
+
+	re1, err := Compile("^/shared$")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	re2, err := Compile("^/shared$")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if re1 != re2 {
+		t.Errorf("Compile() = %p, want %p", re2, re1)
+	}
+
+	after := Stats()
+	if after.Patterns != before.Patterns+1 || after.Compiles != before.Compiles+1 || after.Hits != before.Hits+1 {
+		t.Errorf("Stats() = %+v, before %+v", after, before)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("^/a$", "^/b$"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	err := Validate("^/a$", "(", "")
+	if err == nil {
+		t.Fatalf("Validate() error = %v, wantErr %v", err, true)
+	}
+	if !errors.Is(err, ErrEmpty) || !strings.Contains(err.Error(), "pattern 2") {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
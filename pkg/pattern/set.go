@@ -0,0 +1,194 @@
+package pattern
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// Set implements an ordered set of patterns.
+//
+// The anchored literal patterns like ^/path or ^/path$ are matched with a radix trie without evaluating their regular
+// expressions, and the other patterns are evaluated in order only when needed.
+type Set struct {
+	regexps []*regexp.Regexp
+	root    *setNode
+	others  []int
+}
+
+// setNode implements a node of the radix trie of a set.
+type setNode struct {
+	label    string
+	children []*setNode
+	prefixes []int
+	exacts   []int
+}
+
+// NewSet returns a new set of the given regular expressions.
+func NewSet(regexps []*regexp.Regexp) *Set {
+	s := &Set{
+		regexps: regexps,
+		root:    &setNode{},
+	}
+	for index, re := range regexps {
+		literal, exact, ok := anchoredLiteral(re)
+		if !ok {
+			s.others = append(s.others, index)
+			continue
+		}
+		s.root.insert(literal, index, exact)
+	}
+
+	return s
+}
+
+// Len returns the number of patterns of the set.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	return len(s.regexps)
+}
+
+// Next returns the index of the first pattern from the given index matching the string, or -1 if there is none. A nil
+// set matches no string.
+func (s *Set) Next(str string, from int) int {
+	if s == nil {
+		return -1
+	}
+
+	next := s.root.lookup(str, from)
+	for _, index := range s.others[sort.SearchInts(s.others, from):] {
+		if next >= 0 && index > next {
+			break
+		}
+		if s.regexps[index].MatchString(str) {
+			return index
+		}
+	}
+
+	return next
+}
+
+// insert adds the pattern of the given index matching the key or all the strings starting with the key.
+func (n *setNode) insert(key string, index int, exact bool) {
+	for {
+		if key == "" {
+			if exact {
+				n.exacts = append(n.exacts, index)
+			} else {
+				n.prefixes = append(n.prefixes, index)
+			}
+			return
+		}
+
+		var child *setNode
+		for _, c := range n.children {
+			if c.label[0] == key[0] {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			child = &setNode{label: key}
+			n.children = append(n.children, child)
+			n = child
+			key = ""
+			continue
+		}
+
+		common := commonPrefix(key, child.label)
+		if common < len(child.label) {
+			split := &setNode{
+				label:    child.label[common:],
+				children: child.children,
+				prefixes: child.prefixes,
+				exacts:   child.exacts,
+			}
+			child.label = child.label[:common]
+			child.children = []*setNode{split}
+			child.prefixes = nil
+			child.exacts = nil
+		}
+		n = child
+		key = key[common:]
+	}
+}
+
+// lookup returns the lowest pattern index from the given index matching the string, or -1 if there is none.
+func (n *setNode) lookup(str string, from int) int {
+	next := -1
+	for {
+		next = lowest(next, n.prefixes, from)
+		if str == "" {
+			return lowest(next, n.exacts, from)
+		}
+
+		var child *setNode
+		for _, c := range n.children {
+			if c.label[0] == str[0] {
+				child = c
+				break
+			}
+		}
+		if child == nil || !strings.HasPrefix(str, child.label) {
+			return next
+		}
+		n = child
+		str = str[len(child.label):]
+	}
+}
+
+// lowest returns the lowest index between the current one and the sorted indexes from the given index.
+func lowest(current int, indexes []int, from int) int {
+	i := sort.SearchInts(indexes, from)
+	if i == len(indexes) {
+		return current
+	}
+	if current < 0 || indexes[i] < current {
+		return indexes[i]
+	}
+
+	return current
+}
+
+// commonPrefix returns the length of the common prefix of the given strings.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
+
+// anchoredLiteral returns the literal of the regular expression if it matches only this literal or all the strings
+// starting with it.
+func anchoredLiteral(re *regexp.Regexp) (literal string, exact bool, ok bool) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", false, false
+	}
+	subs := []*syntax.Regexp{parsed}
+	if parsed.Op == syntax.OpConcat {
+		subs = parsed.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return "", false, false
+	}
+	subs = subs[1:]
+	if len(subs) > 0 && subs[0].Op == syntax.OpLiteral && subs[0].Flags&syntax.FoldCase == 0 {
+		literal = string(subs[0].Rune)
+		subs = subs[1:]
+	}
+	switch {
+	case len(subs) == 0:
+		return literal, false, true
+	case len(subs) == 1 && subs[0].Op == syntax.OpEndText:
+		return literal, true, true
+	}
+
+	return "", false, false
+}
@@ -0,0 +1,107 @@
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestAnchoredLiteral(t *testing.T) {
+	tests := []struct {
+		expr        string
+		wantLiteral string
+		wantExact   bool
+		wantOk      bool
+	}{
+		{expr: "^/", wantLiteral: "/", wantOk: true},
+		{expr: "^/api/", wantLiteral: "/api/", wantOk: true},
+		{expr: "^/api$", wantLiteral: "/api", wantExact: true, wantOk: true},
+		{expr: "^", wantOk: true},
+		{expr: "^$", wantExact: true, wantOk: true},
+		{expr: "/api"},
+		{expr: "^/api/.*"},
+		{expr: "^/(?P<slug>[^/]+)$"},
+		{expr: "(?i)^/api"},
+		{expr: "^/a|^/b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			literal, exact, ok := anchoredLiteral(regexp.MustCompile(tt.expr))
+			if literal != tt.wantLiteral || exact != tt.wantExact || ok != tt.wantOk {
+				t.Errorf("anchoredLiteral() = %q, %v, %v, want %q, %v, %v", literal, exact, ok, tt.wantLiteral,
+					tt.wantExact, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSetNext(t *testing.T) {
+	exprs := []string{
+		"^/api/",
+		"^/(?P<slug>[^/]+)$",
+		"^/api/users$",
+		"^/api",
+		"^/$",
+		"(?i)^/API/",
+		"^/app",
+		"^/apple$",
+		"^/",
+		"^/a|^/b",
+	}
+	var regexps []*regexp.Regexp
+	for _, expr := range exprs {
+		regexps = append(regexps, regexp.MustCompile(expr))
+	}
+	s := NewSet(regexps)
+	if s.Len() != len(exprs) {
+		t.Errorf("Set.Len() = %v, want %v", s.Len(), len(exprs))
+	}
+
+	for _, str := range []string{"", "/", "/a", "/ap", "/api", "/api/", "/api/users", "/api/users/1", "/API/x", "/app",
+		"/apple", "/apples", "/b", "test"} {
+		for from := 0; from <= len(exprs); from++ {
+			want := -1
+			for index := from; index < len(regexps); index++ {
+				if regexps[index].MatchString(str) {
+					want = index
+					break
+				}
+			}
+			if got := s.Next(str, from); got != want {
+				t.Errorf("Set.Next(%q, %d) = %v, want %v", str, from, got, want)
+			}
+		}
+	}
+}
+
+func benchmarkRegexps(n int) []*regexp.Regexp {
+	var regexps []*regexp.Regexp
+	for i := 0; i < n; i++ {
+		regexps = append(regexps, regexp.MustCompile(fmt.Sprintf("^/section-%d/", i)))
+	}
+	regexps = append(regexps, regexp.MustCompile("^/(?P<slug>[^/]+)$"))
+
+	return regexps
+}
+
+func BenchmarkSetNext(b *testing.B) {
+	s := NewSet(benchmarkRegexps(500))
+	path := "/section-499/page"
+
+	for n := 0; n < b.N; n++ {
+		s.Next(path, 0)
+	}
+}
+
+func BenchmarkRegexpsMatch(b *testing.B) {
+	regexps := benchmarkRegexps(500)
+	path := "/section-499/page"
+
+	for n := 0; n < b.N; n++ {
+		for _, re := range regexps {
+			if re.MatchString(path) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package priority provides the request priority classes used to degrade the service under load.
+package priority
@@ -0,0 +1,71 @@
+package priority
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/bhuisgen/neon/pkg/render"
+)
+
+// Class is a request priority class.
+type Class string
+
+const (
+	// High is the class of the requests served first, which is the default class.
+	High Class = "high"
+	// Low is the class of the requests shed or served from a stale cache first under load.
+	Low Class = "low"
+)
+
+// Degradable is the interface of the site modules able to handle a degraded request without doing any work.
+type Degradable interface {
+	// Degradable returns true if the degraded requests are served from a stale cache or shed.
+	Degradable() bool
+}
+
+// state implements the priority state of a request.
+type state struct {
+	class      Class
+	degraded   bool
+	retryAfter int
+}
+
+// stateContextKey is the context key of the priority state.
+type stateContextKey struct{}
+
+// NewContext returns a new context carrying the priority class of the request.
+//
+// A degraded request must be served from a stale cache or shed with the given retry delay in seconds.
+func NewContext(ctx context.Context, class Class, degraded bool, retryAfter int) context.Context {
+	return context.WithValue(ctx, stateContextKey{}, &state{
+		class:      class,
+		degraded:   degraded,
+		retryAfter: retryAfter,
+	})
+}
+
+// FromContext returns the priority class of the request.
+func FromContext(ctx context.Context) Class {
+	if s, ok := ctx.Value(stateContextKey{}).(*state); ok {
+		return s.class
+	}
+	return High
+}
+
+// Degraded returns true if the request must be served from a stale cache or shed.
+func Degraded(ctx context.Context) bool {
+	if s, ok := ctx.Value(stateContextKey{}).(*state); ok {
+		return s.degraded
+	}
+	return false
+}
+
+// Shed writes the response of a shed request.
+func Shed(w http.ResponseWriter, r *http.Request) {
+	render.ResetHeader(w)
+	if s, ok := r.Context().Value(stateContextKey{}).(*state); ok && s.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(s.retryAfter))
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
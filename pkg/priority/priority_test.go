@@ -0,0 +1,90 @@
+package priority
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want Class
+	}{
+		{
+			name: "default",
+			ctx:  context.Background(),
+			want: High,
+		},
+		{
+			name: "low",
+			ctx:  NewContext(context.Background(), Low, false, 0),
+			want: Low,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromContext(tt.ctx); got != tt.want {
+				t.Errorf("FromContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegraded(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{
+			name: "default",
+			ctx:  context.Background(),
+			want: false,
+		},
+		{
+			name: "degraded",
+			ctx:  NewContext(context.Background(), Low, true, 0),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Degraded(tt.ctx); got != tt.want {
+				t.Errorf("Degraded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShed(t *testing.T) {
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		wantRetryAfter string
+	}{
+		{
+			name: "default",
+			ctx:  context.Background(),
+		},
+		{
+			name:           "retry after",
+			ctx:            NewContext(context.Background(), Low, true, 5),
+			wantRetryAfter: "5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Shed(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tt.ctx))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Shed() status = %v, want %v", w.Code, http.StatusServiceUnavailable)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Shed() Retry-After = %v, want %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
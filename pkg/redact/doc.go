@@ -0,0 +1,6 @@
+// Package redact provides the scrubbing of the sensitive request headers and cookies written to the diagnostics.
+//
+// The rules are shared by the access logs, the debug traces and the alert payloads, so that a header or a cookie
+// redacted in one of them is redacted in all of them. The default rules redact the credentials headers and the usual
+// session cookies, and the configured rules are added to them.
+package redact
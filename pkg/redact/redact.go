@@ -0,0 +1,140 @@
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bhuisgen/neon/pkg/pattern"
+)
+
+// Redacted is the value replacing a redacted header or cookie value.
+const Redacted string = "[REDACTED]"
+
+var (
+	// DefaultHeaders are the patterns of the header names always redacted.
+	DefaultHeaders = []string{`^(proxy-)?authorization$`, `token|secret|password|api-?key|signature`}
+	// DefaultCookies are the patterns of the cookie names always redacted.
+	DefaultCookies = []string{`sess|sid|token|auth|jwt|csrf|xsrf|remember`}
+
+	rules atomic.Pointer[redactRules]
+)
+
+// redactRules implements the compiled redaction rules.
+type redactRules struct {
+	headers []*regexp.Regexp
+	cookies []*regexp.Regexp
+}
+
+// init initializes the package.
+func init() {
+	r, err := compile(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	rules.Store(r)
+}
+
+// Configure replaces the rules with the default ones extended by the given RE2 patterns of header and cookie names.
+//
+// The patterns are matched against the lower case names. The current rules are kept if a pattern is invalid.
+func Configure(headers []string, cookies []string) error {
+	r, err := compile(headers, cookies)
+	if err != nil {
+		return err
+	}
+	rules.Store(r)
+
+	return nil
+}
+
+// Header returns a copy of the given headers with the values of the sensitive headers and cookies redacted.
+func Header(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	r := rules.Load()
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		v := make([]string, len(values))
+		for index, value := range values {
+			v[index] = r.value(name, value)
+		}
+		redacted[name] = v
+	}
+
+	return redacted
+}
+
+// Value returns the given value of a header with the sensitive header or cookies redacted.
+func Value(name string, value string) string {
+	return rules.Load().value(name, value)
+}
+
+// compile returns the rules of the default patterns extended by the given ones.
+func compile(headers []string, cookies []string) (*redactRules, error) {
+	r := &redactRules{}
+	for _, expr := range append(append([]string{}, DefaultHeaders...), headers...) {
+		re, err := pattern.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		r.headers = append(r.headers, re)
+	}
+	for _, expr := range append(append([]string{}, DefaultCookies...), cookies...) {
+		re, err := pattern.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		r.cookies = append(r.cookies, re)
+	}
+
+	return r, nil
+}
+
+// value returns the given header value redacted.
+func (r *redactRules) value(name string, value string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Cookie":
+		return r.cookie(value)
+	case "Set-Cookie":
+		pair, attributes, _ := strings.Cut(value, ";")
+		if attributes == "" {
+			return r.cookie(pair)
+		}
+		return r.cookie(pair) + ";" + attributes
+	}
+	if match(r.headers, name) {
+		return Redacted
+	}
+
+	return value
+}
+
+// cookie returns the given cookie pairs with the values of the sensitive cookies redacted.
+func (r *redactRules) cookie(value string) string {
+	pairs := strings.Split(value, ";")
+	for index, pair := range pairs {
+		name, _, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !match(r.cookies, name) {
+			continue
+		}
+		pairs[index] = strings.Replace(pair, strings.TrimSpace(pair), name+"="+Redacted, 1)
+	}
+
+	return strings.Join(pairs, ";")
+}
+
+// match returns true if the lower case name matches one of the given regular expressions.
+func match(res []*regexp.Regexp, name string) bool {
+	name = strings.ToLower(name)
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
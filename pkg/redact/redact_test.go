@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		cookies []string
+		header  http.Header
+		want    http.Header
+	}{
+		{
+			name: "default",
+			header: http.Header{
+				"Accept":              {"text/html"},
+				"Authorization":       {"Bearer test"},
+				"Proxy-Authorization": {"Basic test"},
+				"X-Neon-Debug-Token":  {"test"},
+			},
+			want: http.Header{
+				"Accept":              {"text/html"},
+				"Authorization":       {Redacted},
+				"Proxy-Authorization": {Redacted},
+				"X-Neon-Debug-Token":  {Redacted},
+			},
+		},
+		{
+			name: "cookies",
+			header: http.Header{
+				"Cookie":     {"theme=dark; SESSIONID=test; csrf_token=test"},
+				"Set-Cookie": {"sid=test; Path=/; HttpOnly", "lang=en"},
+			},
+			want: http.Header{
+				"Cookie":     {"theme=dark; SESSIONID=" + Redacted + "; csrf_token=" + Redacted},
+				"Set-Cookie": {"sid=" + Redacted + "; Path=/; HttpOnly", "lang=en"},
+			},
+		},
+		{
+			name:    "custom",
+			headers: []string{`^x-client-ip$`},
+			cookies: []string{`^theme$`},
+			header: http.Header{
+				"X-Client-Ip": {"127.0.0.1"},
+				"Cookie":      {"theme=dark"},
+			},
+			want: http.Header{
+				"X-Client-Ip": {Redacted},
+				"Cookie":      {"theme=" + Redacted},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Configure(tt.headers, tt.cookies); err != nil {
+				t.Fatalf("Configure() error = %v", err)
+			}
+			defer func() {
+				_ = Configure(nil, nil)
+			}()
+
+			if got := Header(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Header() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	if err := Configure([]string{"("}, nil); err == nil {
+		t.Errorf("Configure() error = %v, wantErr %v", err, true)
+	}
+	if got := Value("Authorization", "test"); got != Redacted {
+		t.Errorf("Value() = %v, want %v", got, Redacted)
+	}
+}
@@ -0,0 +1,51 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CacheStatus is the status of a response served by a caching renderer.
+type CacheStatus string
+
+const (
+	// CacheHit is the status of a response served from a fresh cached render.
+	CacheHit CacheStatus = "HIT"
+	// CacheMiss is the status of a response rendered for the request.
+	CacheMiss CacheStatus = "MISS"
+	// CacheStale is the status of a response served from an expired cached render.
+	CacheStale CacheStatus = "STALE"
+)
+
+const (
+	headerCache        string = "X-Cache"
+	headerCacheKeyHash string = "X-Cache-Key-Hash"
+	headerAge          string = "Age"
+)
+
+// SetCacheHeader sets the cache debugging headers of a response.
+//
+// The Age header is set only for a cached render with the time it was stored, and the X-Cache-Key-Hash header only
+// when a cache key is given so that the key itself is never exposed.
+func SetCacheHeader(w http.ResponseWriter, status CacheStatus, stored time.Time, key string) {
+	w.Header().Set(headerCache, string(status))
+	if status != CacheMiss && !stored.IsZero() {
+		age := time.Since(stored)
+		if age < 0 {
+			age = 0
+		}
+		w.Header().Set(headerAge, strconv.FormatInt(int64(age/time.Second), 10))
+	}
+	if key != "" {
+		w.Header().Set(headerCacheKeyHash, CacheKeyHash(key))
+	}
+}
+
+// CacheKeyHash returns the short hash of a cache key.
+func CacheKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetCacheHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      CacheStatus
+		stored      time.Time
+		key         string
+		wantCache   string
+		wantAge     string
+		wantKeyHash string
+	}{
+		{
+			name:      "miss",
+			status:    CacheMiss,
+			wantCache: "MISS",
+		},
+		{
+			name:      "hit",
+			status:    CacheHit,
+			stored:    time.Now().Add(-10 * time.Second),
+			wantCache: "HIT",
+			wantAge:   "10",
+		},
+		{
+			name:      "stale",
+			status:    CacheStale,
+			stored:    time.Now().Add(-2 * time.Minute),
+			wantCache: "STALE",
+			wantAge:   "120",
+		},
+		{
+			name:      "future",
+			status:    CacheHit,
+			stored:    time.Now().Add(time.Minute),
+			wantCache: "HIT",
+			wantAge:   "0",
+		},
+		{
+			name:        "key",
+			status:      CacheMiss,
+			key:         "/test",
+			wantCache:   "MISS",
+			wantKeyHash: CacheKeyHash("/test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			SetCacheHeader(w, tt.status, tt.stored, tt.key)
+			if got := w.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("SetCacheHeader() X-Cache = %v, want %v", got, tt.wantCache)
+			}
+			if got := w.Header().Get("Age"); got != tt.wantAge {
+				t.Errorf("SetCacheHeader() Age = %v, want %v", got, tt.wantAge)
+			}
+			if got := w.Header().Get("X-Cache-Key-Hash"); got != tt.wantKeyHash {
+				t.Errorf("SetCacheHeader() X-Cache-Key-Hash = %v, want %v", got, tt.wantKeyHash)
+			}
+		})
+	}
+}
+
+func TestCacheKeyHash(t *testing.T) {
+	got := CacheKeyHash("/test")
+	if len(got) != 16 {
+		t.Errorf("CacheKeyHash() = %v, want 16 hex characters", got)
+	}
+	if got == CacheKeyHash("/other") {
+		t.Errorf("CacheKeyHash() = %v, want distinct hashes", got)
+	}
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptEncodingContextKey is the context key of the accepted content encodings.
+type acceptEncodingContextKey struct{}
+
+// NewAcceptEncodingContext returns a new context carrying the accepted content encodings of the request.
+//
+// A middleware removing the Accept-Encoding header to encode the response itself keeps the header value in the context
+// so that a handler can still write a pre-encoded response.
+func NewAcceptEncodingContext(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, acceptEncodingContextKey{}, value)
+}
+
+// AcceptEncoding returns the accepted content encodings of the request.
+func AcceptEncoding(r *http.Request) string {
+	if value, ok := r.Context().Value(acceptEncodingContextKey{}).(string); ok {
+		return value
+	}
+	return r.Header.Get("Accept-Encoding")
+}
+
+// NegotiateEncoding returns the first of the available content encodings accepted by the request or an empty string
+// if none is accepted.
+func NegotiateEncoding(r *http.Request, available ...string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(AcceptEncoding(r), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		allowed := true
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil || q <= 0 {
+				allowed = false
+			}
+		}
+		accepted[name] = allowed
+	}
+
+	for _, encoding := range available {
+		if allowed, ok := accepted[encoding]; ok {
+			if allowed {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+
+	return ""
+}
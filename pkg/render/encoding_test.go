@@ -0,0 +1,109 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ctx    string
+		want   string
+	}{
+		{
+			name:   "header",
+			header: "gzip",
+			want:   "gzip",
+		},
+		{
+			name:   "context",
+			header: "",
+			ctx:    "br, gzip",
+			want:   "br, gzip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				r.Header.Set("Accept-Encoding", tt.header)
+			}
+			if tt.ctx != "" {
+				r = r.WithContext(NewAcceptEncodingContext(context.Background(), tt.ctx))
+			}
+			if got := AcceptEncoding(r); got != tt.want {
+				t.Errorf("AcceptEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		available []string
+		want      string
+	}{
+		{
+			name:      "none",
+			header:    "",
+			available: []string{"br", "gzip"},
+			want:      "",
+		},
+		{
+			name:      "preferred",
+			header:    "gzip, deflate, br",
+			available: []string{"br", "gzip"},
+			want:      "br",
+		},
+		{
+			name:      "fallback",
+			header:    "gzip;q=0.8, deflate",
+			available: []string{"br", "gzip"},
+			want:      "gzip",
+		},
+		{
+			name:      "refused",
+			header:    "br;q=0, gzip",
+			available: []string{"br", "gzip"},
+			want:      "gzip",
+		},
+		{
+			name:      "wildcard",
+			header:    "*",
+			available: []string{"br", "gzip"},
+			want:      "br",
+		},
+		{
+			name:      "wildcard refused",
+			header:    "br;q=0, *",
+			available: []string{"br", "gzip"},
+			want:      "gzip",
+		},
+		{
+			name:      "case insensitive",
+			header:    "GZIP",
+			available: []string{"br", "gzip"},
+			want:      "gzip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Accept-Encoding", tt.header)
+			if got := NegotiateEncoding(r, tt.available...); got != tt.want {
+				t.Errorf("NegotiateEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderResetter is the interface of a response writer buffering the headers until the response is written.
+type HeaderResetter interface {
+	// ResetHeader removes the buffered headers not allowed in an error or default response.
+	ResetHeader()
+}
+
+// ResetHeader resets the buffered headers of the response writer before writing an error or default response.
+//
+// The response writer is unwrapped until a writer implementing HeaderResetter is found.
+func ResetHeader(w http.ResponseWriter) {
+	for w != nil {
+		if r, ok := w.(HeaderResetter); ok {
+			r.ResetHeader()
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// FilterHeader removes from the header all the keys not allowed.
+//
+// A name ending with '*' allows all the keys with this prefix.
+func FilterHeader(header http.Header, allowed []string) {
+	for key := range header {
+		if !headerAllowed(key, allowed) {
+			delete(header, key)
+		}
+	}
+}
+
+// headerAllowed returns true if the header key is allowed.
+func headerAllowed(key string, allowed []string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, name := range allowed {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if strings.HasPrefix(key, http.CanonicalHeaderKey(prefix)) {
+				return true
+			}
+			continue
+		}
+		if key == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
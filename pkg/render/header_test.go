@@ -0,0 +1,112 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testHeaderResetter struct {
+	http.ResponseWriter
+	reset bool
+}
+
+func (w *testHeaderResetter) ResetHeader() {
+	w.reset = true
+}
+
+type testHeaderWrapper struct {
+	http.ResponseWriter
+}
+
+func (w testHeaderWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestResetHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		resetter  *testHeaderResetter
+		w         func(r *testHeaderResetter) http.ResponseWriter
+		wantReset bool
+	}{
+		{
+			name:     "resetter",
+			resetter: &testHeaderResetter{},
+			w: func(r *testHeaderResetter) http.ResponseWriter {
+				return r
+			},
+			wantReset: true,
+		},
+		{
+			name:     "wrapped resetter",
+			resetter: &testHeaderResetter{},
+			w: func(r *testHeaderResetter) http.ResponseWriter {
+				return testHeaderWrapper{ResponseWriter: testHeaderWrapper{ResponseWriter: r}}
+			},
+			wantReset: true,
+		},
+		{
+			name:     "no resetter",
+			resetter: &testHeaderResetter{},
+			w: func(r *testHeaderResetter) http.ResponseWriter {
+				return testHeaderWrapper{ResponseWriter: httptest.NewRecorder()}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetHeader(tt.w(tt.resetter))
+			if tt.resetter.reset != tt.wantReset {
+				t.Errorf("ResetHeader() reset = %v, want %v", tt.resetter.reset, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestFilterHeader(t *testing.T) {
+	type args struct {
+		header  http.Header
+		allowed []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want http.Header
+	}{
+		{
+			name: "default",
+			args: args{
+				header: http.Header{
+					"Server":        []string{"neon"},
+					"Set-Cookie":    []string{"session=test"},
+					"X-Request-Id":  []string{"test"},
+					"X-Custom-Test": []string{"test"},
+				},
+				allowed: []string{"server", "X-Custom-*"},
+			},
+			want: http.Header{
+				"Server":        []string{"neon"},
+				"X-Custom-Test": []string{"test"},
+			},
+		},
+		{
+			name: "no allowed headers",
+			args: args{
+				header: http.Header{
+					"Set-Cookie": []string{"session=test"},
+				},
+			},
+			want: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FilterHeader(tt.args.header, tt.args.allowed)
+			if !reflect.DeepEqual(tt.args.header, tt.want) {
+				t.Errorf("FilterHeader() header = %v, want %v", tt.args.header, tt.want)
+			}
+		})
+	}
+}
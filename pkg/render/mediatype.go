@@ -0,0 +1,70 @@
+package render
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NegotiateMediaType returns the available media type with the highest quality accepted by the request, the first one
+// winning on a tie, or an empty string if none is accepted.
+//
+// The quality of a media type is given by the most specific media range matching it, and a request without any
+// Accept header accepts all the media types.
+func NegotiateMediaType(r *http.Request, available ...string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		if len(available) == 0 {
+			return ""
+		}
+		return available[0]
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				q = 0
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	var best string
+	var bestQ float64
+	for _, mediaType := range available {
+		mainType, _, _ := strings.Cut(mediaType, "/")
+		specificity, q := -1, 0.0
+		for _, item := range ranges {
+			var s int
+			switch {
+			case item.mediaType == mediaType:
+				s = 2
+			case item.mediaType == mainType+"/*":
+				s = 1
+			case item.mediaType == "*/*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				specificity, q = s, item.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		available []string
+		want      string
+	}{
+		{
+			name:      "none",
+			header:    "",
+			available: []string{"text/html", "application/json"},
+			want:      "text/html",
+		},
+		{
+			name:      "exact",
+			header:    "application/json",
+			available: []string{"text/html", "application/json"},
+			want:      "application/json",
+		},
+		{
+			name:      "params",
+			header:    "text/plain, application/json; charset=utf-8",
+			available: []string{"text/html", "application/json"},
+			want:      "application/json",
+		},
+		{
+			name:      "quality",
+			header:    "text/html;q=0.5, application/json;q=0.9",
+			available: []string{"text/html", "application/json"},
+			want:      "application/json",
+		},
+		{
+			name:      "quality tie",
+			header:    "text/html, application/json",
+			available: []string{"text/html", "application/json"},
+			want:      "text/html",
+		},
+		{
+			name:      "wildcard",
+			header:    "text/html,application/xhtml+xml,*/*;q=0.8",
+			available: []string{"text/html", "application/json"},
+			want:      "text/html",
+		},
+		{
+			name:      "wildcard subtype",
+			header:    "application/*, text/html;q=0.1",
+			available: []string{"text/html", "application/json"},
+			want:      "application/json",
+		},
+		{
+			name:      "specific range",
+			header:    "*/*, application/json;q=0",
+			available: []string{"application/json"},
+			want:      "",
+		},
+		{
+			name:      "not acceptable",
+			header:    "text/html;q=0",
+			available: []string{"text/html"},
+			want:      "",
+		},
+		{
+			name:      "invalid quality",
+			header:    "application/json;q=invalid",
+			available: []string{"application/json"},
+			want:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept", tt.header)
+			}
+			if got := NegotiateMediaType(r, tt.available...); got != tt.want {
+				t.Errorf("NegotiateMediaType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
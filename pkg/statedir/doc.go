@@ -0,0 +1,6 @@
+// Package statedir provides the resolution of the runtime writable paths.
+//
+// The server does not write outside of its configured files, so it can run with a read-only root filesystem. The
+// relative paths of these files are resolved against the state directory given by the STATE_DIR environment variable,
+// or the current directory if it is not set, and their parent directories are created on the first write.
+package statedir
@@ -0,0 +1,37 @@
+package statedir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// EnvKey is the environment variable of the state directory.
+	EnvKey string = "STATE_DIR"
+)
+
+// Dir returns the state directory or an empty string if it is not set.
+func Dir() string {
+	return os.Getenv(EnvKey)
+}
+
+// Path returns the given file name resolved against the state directory if it is relative.
+func Path(name string) string {
+	dir := Dir()
+	if name == "" || dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// MkdirParent creates the parent directories of the given file name if they do not exist.
+func MkdirParent(name string) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("create directory %s: %v", dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package statedir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	type args struct {
+		dir  string
+		name string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "default",
+			args: args{
+				dir:  "/var/lib/neon",
+				name: "neon.pid",
+			},
+			want: "/var/lib/neon/neon.pid",
+		},
+		{
+			name: "subdirectory",
+			args: args{
+				dir:  "/var/lib/neon",
+				name: "logs/access.log",
+			},
+			want: "/var/lib/neon/logs/access.log",
+		},
+		{
+			name: "absolute name",
+			args: args{
+				dir:  "/var/lib/neon",
+				name: "/run/neon.pid",
+			},
+			want: "/run/neon.pid",
+		},
+		{
+			name: "no state directory",
+			args: args{
+				name: "neon.pid",
+			},
+			want: "neon.pid",
+		},
+		{
+			name: "empty name",
+			args: args{
+				dir: "/var/lib/neon",
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvKey, tt.args.dir)
+			if got := Path(tt.args.name); got != tt.want {
+				t.Errorf("Path() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMkdirParent(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state", "logs", "access.log")
+
+	if err := MkdirParent(file); err != nil {
+		t.Fatalf("MkdirParent() error = %v", err)
+	}
+	fi, err := os.Stat(filepath.Dir(file))
+	if err != nil || !fi.IsDir() {
+		t.Errorf("MkdirParent() directory not created, err = %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("MkdirParent() file created, err = %v", err)
+	}
+	if err := MkdirParent(file); err != nil {
+		t.Errorf("MkdirParent() second call error = %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package throttle provides the primitives to honor the throttling signaled by the upstreams.
+package throttle
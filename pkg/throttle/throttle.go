@@ -0,0 +1,86 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is the error returned when a fetch is not attempted or retried because the upstream is throttling the
+// requests.
+var ErrThrottled = errors.New("upstream throttled")
+
+// report implements the throttling reported during a loader execution.
+type report struct {
+	until time.Time
+	hosts map[string]struct{}
+	mu    sync.Mutex
+}
+
+// throttleContextKey is the context key of the throttling report.
+type throttleContextKey struct{}
+
+// ParseRetryAfter parses the value of a Retry-After header, given in seconds or as a HTTP date, and returns the delay
+// to wait from the given time.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+// NewContext returns a new context collecting the throttling reported by the fetches.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, throttleContextKey{}, &report{
+		hosts: make(map[string]struct{}),
+	})
+}
+
+// Report reports that the given host is throttling the requests until the given time.
+func Report(ctx context.Context, host string, until time.Time) {
+	r, ok := ctx.Value(throttleContextKey{}).(*report)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until.After(r.until) {
+		r.until = until
+	}
+	r.hosts[host] = struct{}{}
+}
+
+// Until returns the time until which the reported hosts are throttling the requests, or false if no throttling has
+// been reported.
+func Until(ctx context.Context) (time.Time, bool) {
+	r, ok := ctx.Value(throttleContextKey{}).(*report)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.until, len(r.hosts) > 0
+}
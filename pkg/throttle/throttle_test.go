@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name:   "seconds",
+			value:  "120",
+			want:   2 * time.Minute,
+			wantOk: true,
+		},
+		{
+			name:   "date",
+			value:  "Mon, 01 Jan 2024 00:00:30 GMT",
+			want:   30 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "past date",
+			value:  "Sun, 31 Dec 2023 23:00:00 GMT",
+			wantOk: true,
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:  "negative",
+			value: "-1",
+		},
+		{
+			name:  "invalid",
+			value: "invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseRetryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	if _, ok := Until(context.Background()); ok {
+		t.Errorf("Until() ok = %v, want %v", ok, false)
+	}
+	Report(context.Background(), "localhost", time.Now())
+
+	ctx := NewContext(context.Background())
+	if _, ok := Until(ctx); ok {
+		t.Errorf("Until() ok = %v, want %v", ok, false)
+	}
+
+	now := time.Now()
+	Report(ctx, "a", now.Add(time.Minute))
+	Report(ctx, "b", now.Add(time.Second))
+	if got, ok := Until(ctx); !ok || !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("Until() = %v, %v, want %v, %v", got, ok, now.Add(time.Minute), true)
+	}
+}
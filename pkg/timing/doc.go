@@ -0,0 +1,2 @@
+// Package timing provides the request-scoped timings reported by the handlers and written to the access log.
+package timing
@@ -0,0 +1,94 @@
+package timing
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timings implements the request-scoped timings.
+type Timings struct {
+	entries []Timing
+	mu      sync.Mutex
+}
+
+// Timing implements a named timing.
+type Timing struct {
+	// Name is the timing name.
+	Name string `json:"name"`
+	// Duration is the timing duration.
+	Duration time.Duration `json:"duration"`
+}
+
+// timingsContextKey is the context key of the timings.
+type timingsContextKey struct{}
+
+// New creates new timings.
+func New() *Timings {
+	return &Timings{}
+}
+
+// NewContext returns a new context carrying the given timings.
+func NewContext(ctx context.Context, t *Timings) context.Context {
+	return context.WithValue(ctx, timingsContextKey{}, t)
+}
+
+// FromContext returns the timings of the context or nil if the request timings are not collected.
+func FromContext(ctx context.Context) *Timings {
+	t, _ := ctx.Value(timingsContextKey{}).(*Timings)
+	return t
+}
+
+// Add adds a timing. It is a no-op on nil timings.
+func (t *Timings) Add(name string, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.entries = append(t.entries, Timing{
+		Name:     name,
+		Duration: d,
+	})
+	t.mu.Unlock()
+}
+
+// Entries returns a copy of the timings.
+func (t *Timings) Entries() []Timing {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]Timing, len(t.entries))
+	copy(entries, t.entries)
+
+	return entries
+}
+
+// String returns the timings as a single field of comma-separated name=milliseconds pairs.
+//
+// The separators and spaces of the names are replaced so that the field can be parsed back from a log line.
+func (t *Timings) String() string {
+	var b strings.Builder
+	for index, entry := range t.Entries() {
+		if index > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strings.Map(func(r rune) rune {
+			switch r {
+			case ',', '=', ' ', '\t', '\r', '\n':
+				return '_'
+			}
+			return r
+		}, entry.Name))
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatFloat(float64(entry.Duration.Microseconds())/1000, 'f', 3, 64))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package timing
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFromContext(t *testing.T) {
+	timings := New()
+
+	if got := FromContext(context.Background()); got != nil {
+		t.Errorf("FromContext() got %v, want %v", got, nil)
+	}
+	if got := FromContext(NewContext(context.Background(), timings)); got != timings {
+		t.Errorf("FromContext() got %v, want %v", got, timings)
+	}
+}
+
+func TestTimingsString(t *testing.T) {
+	var timings *Timings
+	timings.Add("nil", time.Second)
+	if got := timings.String(); got != "" {
+		t.Errorf("Timings.String() got %v, want %v", got, "")
+	}
+
+	timings = New()
+	timings.Add("fetch", 1500*time.Microsecond)
+	timings.Add("render app, 1=2", 12*time.Millisecond)
+	if got, want := timings.String(), "fetch=1.500,render_app__1_2=12.000"; got != want {
+		t.Errorf("Timings.String() got %v, want %v", got, want)
+	}
+	if got := len(timings.Entries()); got != 2 {
+		t.Errorf("Timings.Entries() got %v, want %v", got, 2)
+	}
+}
@@ -0,0 +1,2 @@
+// Package tinylfu provides the TinyLFU admission filter used by the caches to keep the frequently accessed entries.
+package tinylfu
@@ -0,0 +1,108 @@
+package tinylfu
+
+import (
+	"hash/maphash"
+)
+
+// Filter implements a TinyLFU admission filter backed by a count-min sketch of the recent key accesses.
+//
+// The counters are halved after a sample of accesses ten times larger than the capacity so that the old popularity
+// fades. A filter is not safe for concurrent use.
+type Filter struct {
+	rows       [filterDepth][]uint8
+	mask       uint64
+	seed       maphash.Seed
+	additions  int
+	sampleSize int
+}
+
+const (
+	filterDepth           int   = 4
+	filterMaxCounter      uint8 = 15
+	filterMinCapacity     int   = 64
+	filterDefaultCapacity int   = 4096
+	filterSampleFactor    int   = 10
+)
+
+// New returns a new filter sized for a cache of the given capacity.
+//
+// A zero or negative capacity sizes the filter for an unbounded cache.
+func New(capacity int) *Filter {
+	if capacity <= 0 {
+		capacity = filterDefaultCapacity
+	}
+	if capacity < filterMinCapacity {
+		capacity = filterMinCapacity
+	}
+	width := 1
+	for width < capacity {
+		width <<= 1
+	}
+
+	f := &Filter{
+		mask:       uint64(width - 1),
+		seed:       maphash.MakeSeed(),
+		sampleSize: filterSampleFactor * capacity,
+	}
+	for i := range f.rows {
+		f.rows[i] = make([]uint8, width)
+	}
+
+	return f
+}
+
+// Record records an access to the given key.
+func (f *Filter) Record(key string) {
+	h := maphash.String(f.seed, key)
+	for i := range f.rows {
+		index := f.index(h, i)
+		if f.rows[i][index] < filterMaxCounter {
+			f.rows[i][index]++
+		}
+	}
+
+	f.additions++
+	if f.additions >= f.sampleSize {
+		f.age()
+	}
+}
+
+// Estimate returns the estimated access frequency of the given key.
+func (f *Filter) Estimate(key string) int {
+	h := maphash.String(f.seed, key)
+	n := filterMaxCounter
+	for i := range f.rows {
+		if c := f.rows[i][f.index(h, i)]; c < n {
+			n = c
+		}
+	}
+	return int(n)
+}
+
+// Admit returns true if the candidate key is accessed more frequently than the victim key it would evict.
+func (f *Filter) Admit(candidate string, victim string) bool {
+	return f.Estimate(candidate) > f.Estimate(victim)
+}
+
+// Reset clears all the recorded accesses.
+func (f *Filter) Reset() {
+	for i := range f.rows {
+		clear(f.rows[i])
+	}
+	f.additions = 0
+}
+
+// index returns the counter index of the given hash in the given row.
+func (f *Filter) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|1)) & f.mask
+}
+
+// age halves all the counters.
+func (f *Filter) age() {
+	for i := range f.rows {
+		for j := range f.rows[i] {
+			f.rows[i][j] >>= 1
+		}
+	}
+	f.additions /= 2
+}
@@ -0,0 +1,80 @@
+package tinylfu
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilterEstimate(t *testing.T) {
+	f := New(100)
+	for i := 0; i < 5; i++ {
+		f.Record("hot")
+	}
+	f.Record("cold")
+
+	if got := f.Estimate("hot"); got != 5 {
+		t.Errorf("Filter.Estimate() = %v, want %v", got, 5)
+	}
+	if got := f.Estimate("cold"); got != 1 {
+		t.Errorf("Filter.Estimate() = %v, want %v", got, 1)
+	}
+	if got := f.Estimate("unknown"); got != 0 {
+		t.Errorf("Filter.Estimate() = %v, want %v", got, 0)
+	}
+}
+
+func TestFilterAdmit(t *testing.T) {
+	f := New(100)
+	for i := 0; i < 3; i++ {
+		f.Record("hot")
+	}
+	f.Record("cold")
+
+	if f.Admit("cold", "hot") {
+		t.Errorf("Filter.Admit() = true, want false")
+	}
+	if !f.Admit("hot", "cold") {
+		t.Errorf("Filter.Admit() = false, want true")
+	}
+	if f.Admit("cold", "cold") {
+		t.Errorf("Filter.Admit() = true, want false")
+	}
+}
+
+func TestFilterMaxCounter(t *testing.T) {
+	f := New(100)
+	for i := 0; i < 20; i++ {
+		f.Record("key")
+	}
+
+	if got := f.Estimate("key"); got != int(filterMaxCounter) {
+		t.Errorf("Filter.Estimate() = %v, want %v", got, filterMaxCounter)
+	}
+}
+
+func TestFilterAge(t *testing.T) {
+	f := New(filterMinCapacity)
+	for i := 0; i < 8; i++ {
+		f.Record("hot")
+	}
+	for i := f.additions; i < f.sampleSize; i++ {
+		f.Record("key" + strconv.Itoa(i))
+	}
+
+	if got := f.Estimate("hot"); got >= 8 {
+		t.Errorf("Filter.Estimate() = %v, want less than %v", got, 8)
+	}
+	if f.additions >= f.sampleSize {
+		t.Errorf("Filter.additions = %v, want less than %v", f.additions, f.sampleSize)
+	}
+}
+
+func TestFilterReset(t *testing.T) {
+	f := New(0)
+	f.Record("key")
+	f.Reset()
+
+	if got := f.Estimate("key"); got != 0 {
+		t.Errorf("Filter.Estimate() = %v, want %v", got, 0)
+	}
+}
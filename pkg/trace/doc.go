@@ -0,0 +1,2 @@
+// Package trace provides request-scoped debug traces.
+package trace
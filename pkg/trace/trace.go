@@ -0,0 +1,100 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Trace implements a request-scoped debug trace.
+type Trace struct {
+	start  time.Time
+	events []Event
+	mu     sync.Mutex
+}
+
+// Event implements a trace event.
+type Event struct {
+	// Elapsed is the elapsed time since the trace start.
+	Elapsed time.Duration `json:"elapsed"`
+	// Source is the component emitting the event.
+	Source string `json:"source"`
+	// Message is the event message.
+	Message string `json:"message"`
+	// Attrs are the event attributes.
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// traceContextKey is the context key of the trace.
+type traceContextKey struct{}
+
+// New creates a new trace.
+func New() *Trace {
+	return &Trace{
+		start: time.Now(),
+	}
+}
+
+// NewContext returns a new context carrying the given trace.
+func NewContext(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, t)
+}
+
+// FromContext returns the trace of the context or nil if the request is not traced.
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceContextKey{}).(*Trace)
+	return t
+}
+
+// Add adds an event with the given key/value attributes pairs. It is a no-op on a nil trace.
+func (t *Trace) Add(source string, message string, args ...any) {
+	if t == nil {
+		return
+	}
+
+	var attrs map[string]any
+	if len(args) > 0 {
+		attrs = make(map[string]any, len(args)/2)
+		for i := 0; i+1 < len(args); i += 2 {
+			key, ok := args[i].(string)
+			if !ok {
+				continue
+			}
+			attrs[key] = args[i+1]
+		}
+	}
+
+	t.mu.Lock()
+	t.events = append(t.events, Event{
+		Elapsed: time.Since(t.start),
+		Source:  source,
+		Message: message,
+		Attrs:   attrs,
+	})
+	t.mu.Unlock()
+}
+
+// Events returns a copy of the trace events.
+func (t *Trace) Events() []Event {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]Event, len(t.events))
+	copy(events, t.events)
+
+	return events
+}
+
+// MarshalJSON returns the JSON encoding of the trace.
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Events []Event `json:"events"`
+	}{
+		Events: t.Events(),
+	})
+}
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	tr := New()
+
+	if got := FromContext(context.Background()); got != nil {
+		t.Errorf("FromContext() got %v, want %v", got, nil)
+	}
+	if got := FromContext(NewContext(context.Background(), tr)); got != tr {
+		t.Errorf("FromContext() got %v, want %v", got, tr)
+	}
+}
+
+func TestTraceAdd(t *testing.T) {
+	tr := New()
+	tr.Add("test", "message", "key", "value", "invalid")
+
+	events := tr.Events()
+	if len(events) != 1 {
+		t.Fatalf("Trace.Events() got %d events, want %d", len(events), 1)
+	}
+	if events[0].Source != "test" || events[0].Message != "message" {
+		t.Errorf("Trace.Events() got %v", events[0])
+	}
+	if v := events[0].Attrs["key"]; v != "value" {
+		t.Errorf("Trace.Events() got attribute %v, want %v", v, "value")
+	}
+}
+
+func TestTraceAdd_Nil(t *testing.T) {
+	var tr *Trace
+	tr.Add("test", "message")
+
+	if events := tr.Events(); events != nil {
+		t.Errorf("Trace.Events() got %v, want %v", events, nil)
+	}
+}
+
+func TestTraceMarshalJSON(t *testing.T) {
+	tr := New()
+	tr.Add("test", "message")
+
+	buf, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Events) != 1 || v.Events[0].Message != "message" {
+		t.Errorf("Trace.MarshalJSON() got %s", buf)
+	}
+}